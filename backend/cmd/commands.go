@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"token-points-system/internal/archive"
+	"token-points-system/internal/config"
+	"token-points-system/internal/models"
+	"token-points-system/internal/repository"
+	"token-points-system/internal/service"
+
+	"gorm.io/gorm"
+)
+
+// runCommand 执行命令行子命令
+// 用法: main <command> [flags]
+func runCommand(ctx context.Context, cfg *config.Config, db *gorm.DB, args []string) error {
+	switch args[0] {
+	case "rebuild-points":
+		return runRebuildPoints(ctx, cfg, db, args[1:])
+	case "simulate-rules":
+		return runSimulateRules(ctx, cfg, db, args[1:])
+	case "export":
+		return runExport(ctx, cfg, db, args[1:])
+	case "import":
+		return runImport(ctx, db, args[1:])
+	case "create-api-key":
+		return runCreateAPIKey(ctx, cfg, db, args[1:])
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
+
+// runRebuildPoints 从积分流水重建user_points并输出校验报告
+func runRebuildPoints(ctx context.Context, cfg *config.Config, db *gorm.DB, args []string) error {
+	fs := flag.NewFlagSet("rebuild-points", flag.ExitOnError)
+	chainID := fs.String("chain", "", "chain id to rebuild, empty for all enabled chains")
+	apply := fs.Bool("apply", false, "backfill missing ledger entries and overwrite user_points")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	pointsSvc, err := newCommandPointsService(cfg, db)
+	if err != nil {
+		return err
+	}
+
+	chains, err := commandChains(cfg, *chainID)
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for _, chain := range chains {
+		report, err := pointsSvc.RebuildTotals(ctx, chain.ID, *apply)
+		if err != nil {
+			return err
+		}
+		if err := printJSON(report); err != nil {
+			return err
+		}
+		if !report.Verified {
+			failed = true
+		}
+	}
+
+	if failed {
+		return fmt.Errorf("points projection does not match ledger")
+	}
+	return nil
+}
+
+// runSimulateRules 用候选规则在历史窗口上模拟积分并输出与当前规则的对比报告，不写入任何数据
+func runSimulateRules(ctx context.Context, cfg *config.Config, db *gorm.DB, args []string) error {
+	fs := flag.NewFlagSet("simulate-rules", flag.ExitOnError)
+	chainID := fs.String("chain", "", "chain id to simulate (required)")
+	start := fs.String("start", "", "window start, RFC3339 (required)")
+	end := fs.String("end", "", "window end, RFC3339 (required)")
+	rate := fs.String("rate", "", "candidate points per token-hour (required)")
+	minBalance := fs.String("min-balance", "", "candidate minimum balance to accrue points")
+	balanceCap := fs.String("balance-cap", "", "candidate balance cap, 0 for none")
+	ruleVersion := fs.String("rule-version", "", "candidate rule version (v1 or v2), empty for the configured one")
+	top := fs.Int("top", 10, "number of top gainers and losers")
+	csvPath := fs.String("csv", "", "write per-user diffs to this CSV file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := cfg.GetChainConfig(*chainID); err != nil {
+		return err
+	}
+	startAt, err := time.Parse(time.RFC3339, *start)
+	if err != nil {
+		return fmt.Errorf("invalid -start: %w", err)
+	}
+	endAt, err := time.Parse(time.RFC3339, *end)
+	if err != nil {
+		return fmt.Errorf("invalid -end: %w", err)
+	}
+
+	var each func(d *service.UserDiff) error
+	if *csvPath != "" {
+		file, err := os.Create(*csvPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		writer := csv.NewWriter(file)
+		defer writer.Flush()
+		if err := writer.Write(service.SimulationCSVHeader); err != nil {
+			return err
+		}
+		each = func(d *service.UserDiff) error {
+			return writer.Write(d.CSVRecord())
+		}
+	}
+
+	pointsSvc, err := newCommandPointsService(cfg, db)
+	if err != nil {
+		return err
+	}
+	report, err := pointsSvc.SimulateRules(ctx, service.SimulationRequest{
+		ChainID: *chainID,
+		Start:   startAt,
+		End:     endAt,
+		Candidate: service.RuleSet{
+			Rate:        *rate,
+			MinBalance:  *minBalance,
+			BalanceCap:  *balanceCap,
+			RuleVersion: *ruleVersion,
+		},
+		Top: *top,
+	}, each)
+	if err != nil {
+		return err
+	}
+	return printJSON(report)
+}
+
+// runExport 把全部链或指定链的数据导出为压缩NDJSON归档
+func runExport(ctx context.Context, cfg *config.Config, db *gorm.DB, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dir := fs.String("dir", "", "output directory, must not exist or be empty (required)")
+	chainID := fs.String("chain", "", "export only this chain, empty for everything")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dir == "" {
+		return fmt.Errorf("-dir is required")
+	}
+	if *chainID != "" {
+		if _, err := cfg.GetChainConfig(*chainID); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := archive.Export(ctx, db, *dir, *chainID)
+	if err != nil {
+		return err
+	}
+	return printJSON(manifest)
+}
+
+// runImport 校验归档并导入到空数据库，-verify只校验归档不写入
+func runImport(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dir := fs.String("dir", "", "archive directory (required)")
+	verifyOnly := fs.Bool("verify", false, "only verify the manifest and checksums")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dir == "" {
+		return fmt.Errorf("-dir is required")
+	}
+
+	var manifest *archive.Manifest
+	var err error
+	if *verifyOnly {
+		manifest, err = archive.Verify(*dir)
+	} else {
+		manifest, err = archive.Import(ctx, db, *dir)
+	}
+	if err != nil {
+		return err
+	}
+	return printJSON(manifest)
+}
+
+// runCreateAPIKey 创建API Key并输出完整的Key，用于创建第一个管理Key（不经过HTTP认证）
+func runCreateAPIKey(ctx context.Context, cfg *config.Config, db *gorm.DB, args []string) error {
+	fs := flag.NewFlagSet("create-api-key", flag.ExitOnError)
+	name := fs.String("name", "", "key name, recorded as the actor of admin operations (required)")
+	scopes := fs.String("scopes", strings.Join(models.APIKeyScopes, ","), "comma separated scopes")
+	expires := fs.String("expires", "", "expiry time, RFC3339, empty for no expiry")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	req := service.APIKeyRequest{
+		Name:   *name,
+		Scopes: strings.Split(*scopes, ","),
+		Actor:  "cli",
+	}
+	if *expires != "" {
+		expiresAt, err := time.Parse(time.RFC3339, *expires)
+		if err != nil {
+			return fmt.Errorf("invalid -expires: %w", err)
+		}
+		req.ExpiresAt = &expiresAt
+	}
+
+	keySvc := service.NewAPIKeyService(repository.NewAPIKeyRepository(db), &cfg.Auth)
+	issued, err := keySvc.Create(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Store the key now, it cannot be retrieved again.")
+	return printJSON(struct {
+		ID        uint64     `json:"id"`
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		Key       string     `json:"key"`
+	}{
+		ID:        issued.APIKey.ID,
+		Name:      issued.APIKey.Name,
+		Scopes:    issued.APIKey.ScopeList(),
+		ExpiresAt: issued.APIKey.ExpiresAt,
+		Key:       issued.Key,
+	})
+}
+
+func newCommandPointsService(cfg *config.Config, db *gorm.DB) (*service.PointsService, error) {
+	return service.NewPointsService(
+		repository.NewPointsRepository(db),
+		repository.NewHistoryRepository(db),
+		repository.NewCalculationRepository(db),
+		repository.NewLedgerRepository(db),
+		repository.NewRedemptionRepository(db),
+		repository.NewAdjustmentRepository(db),
+		&cfg.Points,
+	)
+}
+
+// commandChains 解析命令行指定的链，未指定时返回全部启用的链
+func commandChains(cfg *config.Config, chainID string) ([]config.ChainConfig, error) {
+	if chainID == "" {
+		return cfg.GetEnabledChains(), nil
+	}
+	chain, err := cfg.GetChainConfig(chainID)
+	if err != nil {
+		return nil, err
+	}
+	return []config.ChainConfig{*chain}, nil
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -6,12 +6,17 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"token-points-system/internal/api"
 	"token-points-system/internal/blockchain"
 	"token-points-system/internal/config"
+	"token-points-system/internal/election"
 	"token-points-system/internal/handler"
+	"token-points-system/internal/jobs"
+	"token-points-system/internal/models"
 	"token-points-system/internal/repository"
 	"token-points-system/internal/scheduler"
 	"token-points-system/internal/service"
@@ -44,29 +49,84 @@ func main() {
 	}
 	defer closeDatabase(db)
 
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), cfg, db, os.Args[1:]); err != nil {
+			logger.Fatal("Command failed:", err)
+		}
+		return
+	}
+
 	balanceRepo := repository.NewBalanceRepository(db)
 	pointsRepo := repository.NewPointsRepository(db)
 	historyRepo := repository.NewHistoryRepository(db)
 	blockRepo := repository.NewBlockRepository(db)
 	calcRepo := repository.NewCalculationRepository(db)
+	ledgerRepo := repository.NewLedgerRepository(db)
+	redemptionRepo := repository.NewRedemptionRepository(db)
+	adjustmentRepo := repository.NewAdjustmentRepository(db)
+	cursorRepo := repository.NewCursorRepository(db)
+	accountRepo := repository.NewAccountRepository(db)
 
 	balanceSvc := service.NewBalanceService(balanceRepo, historyRepo, blockRepo)
-	pointsSvc := service.NewPointsService(pointsRepo, historyRepo, calcRepo, &cfg.Points)
+	pointsSvc, err := service.NewPointsService(pointsRepo, historyRepo, calcRepo, ledgerRepo, redemptionRepo, adjustmentRepo, &cfg.Points)
+	if err != nil {
+		logger.Fatal("Invalid points rule config:", err)
+	}
+	redemptionSvc := service.NewRedemptionService(redemptionRepo, &cfg.Points)
+	adjustmentSvc := service.NewAdjustmentService(adjustmentRepo, calcRepo, &cfg.Points)
+	expirySvc := service.NewExpiryService(ledgerRepo, cursorRepo, &cfg.Points)
+	aggregateSvc, err := service.NewAggregateService(pointsRepo, balanceRepo, accountRepo, cfg.Chains)
+	if err != nil {
+		logger.Fatal("Invalid points weight config:", err)
+	}
+	accountSvc := service.NewAccountService(accountRepo, aggregateSvc, &cfg.Accounts)
+	leaderboardRepo := repository.NewLeaderboardRepository(db)
+	seasonRepo := repository.NewSeasonRepository(db)
+	leaderboardSvc, err := service.NewLeaderboardService(leaderboardRepo, accountRepo, aggregateSvc.ChainWeights(), &cfg.Leaderboard)
+	if err != nil {
+		logger.Fatal("Invalid leaderboard config:", err)
+	}
+	seasonSvc, err := service.NewSeasonService(seasonRepo, cursorRepo, cfg.Chains, &cfg.Points)
+	if err != nil {
+		logger.Fatal("Invalid points period config:", err)
+	}
+	distributionSvc := service.NewDistributionService(repository.NewDistributionRepository(db), seasonRepo, leaderboardRepo, cfg)
+	attestationSvc, err := service.NewAttestationService(repository.NewAttestationRepository(db), pointsRepo, seasonSvc, cfg.Chains, &cfg.Attestation)
+	if err != nil {
+		logger.Fatal("Invalid attestation config:", err)
+	}
+	apiKeySvc := service.NewAPIKeyService(repository.NewAPIKeyRepository(db), &cfg.Auth)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	for _, chainCfg := range cfg.GetEnabledChains() {
-		go startChainListener(ctx, chainCfg, balanceSvc, blockRepo)
-	}
-
-	pointsScheduler := scheduler.NewPointsScheduler(pointsSvc, balanceRepo, cfg.Chains, cfg.Points.CalculationCron)
-	if err := pointsScheduler.Start(); err != nil {
-		logger.Fatal("Failed to start scheduler:", err)
+	pointsScheduler, err := scheduler.NewPointsScheduler(pointsSvc, cursorRepo, blockRepo, cfg.Chains, &cfg.Points)
+	if err != nil {
+		logger.Fatal("Invalid points period config:", err)
 	}
-	defer pointsScheduler.Stop()
+	elector := election.NewElector(repository.NewLeaseRepository(db), &cfg.Leader)
+
+	recoverySvc := service.NewRecoveryService(balanceRepo, repository.NewBackupRepository(db), blockRepo, balanceSvc, &cfg.Backup)
+	jobManager := jobs.NewManager(repository.NewJobRepository(db), elector.NodeID(), &cfg.Jobs)
+	jobManager.Register(models.JobTypeRecalculate, pointsScheduler.RecalculateJob)
+	jobManager.Register(models.JobTypeBackup, recoverySvc.BackupJob)
+	jobManager.Register(models.JobTypeRestore, recoverySvc.RestoreJob)
+	rebuildSvc := service.NewStateRebuildService(repository.NewRebuildRepository(db), balanceSvc)
+	jobManager.Register(models.JobTypeRebuild, rebuildSvc.RebuildJob)
+	reindexSvc := service.NewReindexService(cfg, repository.NewReindexRepository(db), blockRepo, balanceSvc, recoverySvc)
+	jobManager.Register(models.JobTypeReindex, reindexSvc.SyncJob)
+	jobManager.Register(models.JobTypeCutover, reindexSvc.CutoverJob)
+
+	// 链监听、积分调度等单例任务只在主节点运行，HTTP服务在所有节点运行
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		elector.Run(ctx, func(leaderCtx context.Context) {
+			runLeaderTasks(leaderCtx, cfg, balanceSvc, recoverySvc, expirySvc, leaderboardSvc, seasonSvc, blockRepo, pointsScheduler, jobManager)
+		})
+	}()
 
-	router := setupHTTPRouter(balanceSvc, pointsSvc, pointsScheduler, cfg, historyRepo, balanceRepo, pointsRepo, blockRepo, calcRepo)
+	router := setupHTTPRouter(balanceSvc, recoverySvc, reindexSvc, distributionSvc, attestationSvc, apiKeySvc, pointsSvc, redemptionSvc, adjustmentSvc, expirySvc, aggregateSvc, accountSvc, leaderboardSvc, seasonSvc, pointsScheduler, elector, jobManager, cfg, historyRepo, balanceRepo, pointsRepo, blockRepo, calcRepo)
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
@@ -95,9 +155,70 @@ func main() {
 		logger.Error("Server shutdown error:", err)
 	}
 
+	cancel()
+	<-leaderDone
+
 	logger.Info("Server stopped")
 }
 
+// runLeaderTasks 运行仅主节点执行的任务，直到ctx结束（失去主节点身份或服务停止）
+func runLeaderTasks(ctx context.Context, cfg *config.Config, balanceSvc *service.BalanceService, recoverySvc *service.RecoveryService, expirySvc *service.ExpiryService, leaderboardSvc *service.LeaderboardService, seasonSvc *service.SeasonService, blockRepo *repository.BlockRepository, pointsScheduler *scheduler.PointsScheduler, jobManager *jobs.Manager) {
+	var wg sync.WaitGroup
+	for _, chainCfg := range cfg.GetEnabledChains() {
+		wg.Add(1)
+		go func(chainCfg config.ChainConfig) {
+			defer wg.Done()
+			startChainListener(ctx, chainCfg, balanceSvc, recoverySvc, blockRepo)
+		}(chainCfg)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		jobManager.Run(ctx)
+	}()
+	defer wg.Wait()
+
+	if err := pointsScheduler.Start(); err != nil {
+		logger.Error("Failed to start scheduler:", err)
+		return
+	}
+	defer pointsScheduler.Stop()
+
+	if cfg.Points.Expiry.Enabled {
+		expiryScheduler := scheduler.NewExpiryScheduler(expirySvc, cfg.Chains, cfg.Points.Expiry.Cron)
+		if err := expiryScheduler.Start(); err != nil {
+			logger.Error("Failed to start expiry scheduler:", err)
+			return
+		}
+		defer expiryScheduler.Stop()
+	}
+
+	leaderboardScheduler := scheduler.NewLeaderboardScheduler(leaderboardSvc, cfg.Leaderboard.SnapshotCron)
+	if err := leaderboardScheduler.Start(); err != nil {
+		logger.Error("Failed to start leaderboard scheduler:", err)
+		return
+	}
+	defer leaderboardScheduler.Stop()
+
+	seasonScheduler := scheduler.NewSeasonScheduler(seasonSvc)
+	if err := seasonScheduler.Start(); err != nil {
+		logger.Error("Failed to start season scheduler:", err)
+		return
+	}
+	defer seasonScheduler.Stop()
+
+	if cfg.Backup.Enabled {
+		backupScheduler := scheduler.NewBackupScheduler(recoverySvc, cfg.Chains, cfg.Backup.Interval)
+		if err := backupScheduler.Start(); err != nil {
+			logger.Error("Failed to start backup scheduler:", err)
+			return
+		}
+		defer backupScheduler.Stop()
+	}
+
+	<-ctx.Done()
+}
+
 func initDatabase(cfg config.DatabaseConfig) (*gorm.DB, error) {
 	db, err := gorm.Open(mysql.Open(cfg.DSN()), &gorm.Config{})
 	if err != nil {
@@ -125,7 +246,7 @@ func closeDatabase(db *gorm.DB) {
 	sqlDB.Close()
 }
 
-func startChainListener(ctx context.Context, chainCfg config.ChainConfig, balanceSvc *service.BalanceService, blockRepo *repository.BlockRepository) {
+func startChainListener(ctx context.Context, chainCfg config.ChainConfig, balanceSvc *service.BalanceService, recoverySvc *service.RecoveryService, blockRepo *repository.BlockRepository) {
 	client, err := blockchain.NewClient(&chainCfg)
 	if err != nil {
 		logger.Error("Failed to create blockchain client:", err)
@@ -155,6 +276,7 @@ func startChainListener(ctx context.Context, chainCfg config.ChainConfig, balanc
 
 	listener := blockchain.NewEventListener(&chainCfg, client, blockRepo)
 	defer listener.Stop()
+	defer recoverySvc.AttachListener(chainCfg.ID, listener)()
 	go listener.Start(ctx, startBlock)
 
 	for {
@@ -175,33 +297,62 @@ func startChainListener(ctx context.Context, chainCfg config.ChainConfig, balanc
 	}
 }
 
-func setupHTTPRouter(balanceSvc *service.BalanceService, pointsSvc *service.PointsService, scheduler *scheduler.PointsScheduler, cfg *config.Config, historyRepo *repository.HistoryRepository, balanceRepo *repository.BalanceRepository, pointsRepo *repository.PointsRepository, blockRepo *repository.BlockRepository, calcRepo *repository.CalculationRepository) http.Handler {
-	router := http.NewServeMux()
+func setupHTTPRouter(balanceSvc *service.BalanceService, recoverySvc *service.RecoveryService, reindexSvc *service.ReindexService, distributionSvc *service.DistributionService, attestationSvc *service.AttestationService, apiKeySvc *service.APIKeyService, pointsSvc *service.PointsService, redemptionSvc *service.RedemptionService, adjustmentSvc *service.AdjustmentService, expirySvc *service.ExpiryService, aggregateSvc *service.AggregateService, accountSvc *service.AccountService, leaderboardSvc *service.LeaderboardService, seasonSvc *service.SeasonService, scheduler *scheduler.PointsScheduler, elector *election.Elector, jobManager *jobs.Manager, cfg *config.Config, historyRepo *repository.HistoryRepository, balanceRepo *repository.BalanceRepository, pointsRepo *repository.PointsRepository, blockRepo *repository.BlockRepository, calcRepo *repository.CalculationRepository) http.Handler {
+	rt := api.NewRouter(api.Info{
+		Title:   "Token Points System API",
+		Version: "v1",
+	})
 
 	balanceHandler := handler.NewBalanceHandler(balanceSvc)
 	pointsHandler := handler.NewPointsHandler(pointsSvc, pointsRepo, calcRepo)
 	historyHandler := handler.NewHistoryHandler(historyRepo)
 	statsHandler := handler.NewStatsHandler(balanceRepo, pointsRepo, historyRepo, blockRepo, cfg.Chains)
-	recalcHandler := handler.NewRecalculateHandler(scheduler, balanceRepo, cfg)
+	recalcHandler := handler.NewRecalculateHandler(scheduler, jobManager, cfg)
 	txHandler := handler.NewTransactionHandler(historyRepo)
-	backupHandler := handler.NewBackupHandler(calcRepo, cfg)
-
-	router.HandleFunc("/api/balance/", balanceHandler.GetBalance)
-	router.HandleFunc("/api/balance/list", balanceHandler.ListBalances)
-	router.HandleFunc("/api/points/", pointsHandler.GetPoints)
-	router.HandleFunc("/api/points/list", pointsHandler.ListPoints)
-	router.HandleFunc("/api/points/history", pointsHandler.GetPointsHistory)
-	router.HandleFunc("/api/history/", historyHandler.GetHistory)
-	router.HandleFunc("/api/stats", statsHandler.GetStats)
-	router.HandleFunc("/api/recalculate", recalcHandler.TriggerRecalculate)
-	router.HandleFunc("/api/transactions/recent", txHandler.GetRecentTransactions)
-	router.HandleFunc("/api/backup", backupHandler.CreateBackup)
-	router.HandleFunc("/api/backups", backupHandler.ListBackups)
-	router.HandleFunc("/api/backup/restore", backupHandler.RestoreBackup)
-	router.HandleFunc("/health", handler.HandleHealth)
-
-	fs := http.FileServer(http.Dir("./web"))
-	router.Handle("/", fs)
-
-	return router
+	backupHandler := handler.NewBackupHandler(recoverySvc, jobManager, cfg)
+	redemptionHandler := handler.NewRedemptionHandler(redemptionSvc)
+	leaderHandler := handler.NewLeaderHandler(elector)
+	jobHandler := handler.NewJobHandler(jobManager)
+	adjustmentHandler := handler.NewAdjustmentHandler(adjustmentSvc)
+	expiryHandler := handler.NewExpiryHandler(expirySvc)
+	aggregateHandler := handler.NewAggregateHandler(aggregateSvc)
+	accountHandler := handler.NewAccountHandler(accountSvc, aggregateSvc)
+	leaderboardHandler := handler.NewLeaderboardHandler(leaderboardSvc)
+	seasonHandler := handler.NewSeasonHandler(seasonSvc)
+	reindexHandler := handler.NewReindexHandler(reindexSvc, jobManager)
+	distributionHandler := handler.NewDistributionHandler(distributionSvc)
+	attestationHandler := handler.NewAttestationHandler(attestationSvc)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeySvc)
+
+	balanceHandler.Register(rt)
+	pointsHandler.Register(rt)
+	historyHandler.Register(rt)
+	statsHandler.Register(rt)
+	recalcHandler.Register(rt)
+	txHandler.Register(rt)
+	backupHandler.Register(rt)
+	redemptionHandler.Register(rt)
+	leaderHandler.Register(rt)
+	jobHandler.Register(rt)
+	adjustmentHandler.Register(rt)
+	expiryHandler.Register(rt)
+	aggregateHandler.Register(rt)
+	accountHandler.Register(rt)
+	leaderboardHandler.Register(rt)
+	seasonHandler.Register(rt)
+	reindexHandler.Register(rt)
+	distributionHandler.Register(rt)
+	attestationHandler.Register(rt)
+	apiKeyHandler.Register(rt)
+	handler.RegisterHealth(rt)
+
+	if cfg.Auth.Disabled {
+		logger.Warn("API key authentication is disabled, admin endpoints are open to anyone")
+	} else {
+		rt.SetAuthenticator(apiKeyHandler)
+	}
+
+	rt.Mount("/", http.FileServer(http.Dir("./web")))
+
+	return rt
 }
@@ -1,6 +1,6 @@
 module token-points-system
 
-go 1.21
+go 1.22
 
 require (
 	github.com/ethereum/go-ethereum v1.13.5
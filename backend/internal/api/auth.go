@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiKeyHeader 除Authorization: Bearer外也可以通过该头传递API Key
+const apiKeyHeader = "X-API-Key"
+
+// Principal 通过API Key认证的调用方
+type Principal struct {
+	KeyID  uint64
+	Name   string
+	Scopes []string
+}
+
+// HasScope 是否拥有权限
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Usage 一次使用API Key的请求
+// Route为注册的路径模式，Path为实际请求路径；Status为响应状态码，权限不足被拒绝时为403
+type Usage struct {
+	KeyID      uint64
+	Method     string
+	Route      string
+	Path       string
+	Scope      string
+	Status     int
+	RemoteAddr string
+	Duration   time.Duration
+}
+
+// Authenticator 校验API Key并记录每个Key的使用情况
+type Authenticator interface {
+	// Authenticate 校验API Key，不存在、已过期或已吊销时返回错误
+	Authenticate(ctx context.Context, key string) (*Principal, error)
+	// RecordUsage 记录一次请求，失败只应记录日志，不影响响应
+	RecordUsage(ctx context.Context, usage Usage)
+}
+
+type principalKey struct{}
+
+// PrincipalFrom 返回请求的调用方，接口不需要认证或未启用认证时为nil
+func PrincipalFrom(r *http.Request) *Principal {
+	p, _ := r.Context().Value(principalKey{}).(*Principal)
+	return p
+}
+
+// SetAuthenticator 启用API Key认证，之后Scope非空的路由都需要带有该权限的API Key
+// 未设置时所有路由都不校验，仅用于本地开发
+func (rt *Router) SetAuthenticator(auth Authenticator) {
+	rt.auth = auth
+}
+
+// authorize 校验请求的API Key是否拥有scope权限，通过时返回带调用方的请求
+// 缺少或无效的Key返回401，权限不足返回403并记录到该Key的使用记录
+func (rt *Router) authorize(w http.ResponseWriter, r *http.Request, pattern, scope string) (*http.Request, *Principal, bool) {
+	key := requestKey(r)
+	if key == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+		WriteError(w, r, http.StatusUnauthorized, CodeOf(http.StatusUnauthorized), "missing API key")
+		return r, nil, false
+	}
+
+	principal, err := rt.auth.Authenticate(r.Context(), key)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+		WriteAppError(w, r, err)
+		return r, nil, false
+	}
+
+	if !principal.HasScope(scope) {
+		WriteError(w, r, http.StatusForbidden, CodeOf(http.StatusForbidden), "API key lacks scope "+scope)
+		rt.auth.RecordUsage(context.WithoutCancel(r.Context()), Usage{
+			KeyID:      principal.KeyID,
+			Method:     r.Method,
+			Route:      pattern,
+			Path:       r.URL.Path,
+			Scope:      scope,
+			Status:     http.StatusForbidden,
+			RemoteAddr: r.RemoteAddr,
+		})
+		return r, nil, false
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)), principal, true
+}
+
+// serveAuthorized 校验权限后调用h，并记录该Key的使用情况
+func (rt *Router) serveAuthorized(w http.ResponseWriter, r *http.Request, pattern, scope string, h http.HandlerFunc) {
+	r, principal, ok := rt.authorize(w, r, pattern, scope)
+	if !ok {
+		return
+	}
+
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	h(rec, r)
+
+	rt.auth.RecordUsage(context.WithoutCancel(r.Context()), Usage{
+		KeyID:      principal.KeyID,
+		Method:     r.Method,
+		Route:      pattern,
+		Path:       r.URL.Path,
+		Scope:      scope,
+		Status:     rec.status,
+		RemoteAddr: r.RemoteAddr,
+		Duration:   time.Since(start),
+	})
+}
+
+func requestKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, token, ok := strings.Cut(auth, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	return r.Header.Get(apiKeyHeader)
+}
+
+// statusRecorder 记录处理函数写出的状态码
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
+
+// Unwrap 供http.ResponseController访问底层的ResponseWriter
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
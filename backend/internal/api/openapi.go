@@ -0,0 +1,370 @@
+package api
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Document OpenAPI 3.0文档
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info 文档标题、版本与说明
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem 同一路径下按小写方法名索引的操作
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme 认证方式，管理接口使用Bearer形式的API Key
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// securitySchemeName 文档中API Key认证方式的名称
+const securitySchemeName = "apiKey"
+
+// Schema JSON Schema的子集，足以描述接口使用的结构体
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Spec 根据已注册的路由生成OpenAPI文档，首次调用后结果被缓存
+func (rt *Router) Spec() *Document {
+	rt.specOnce.Do(func() {
+		rt.spec = rt.buildSpec()
+	})
+	return rt.spec
+}
+
+func (rt *Router) serveSpec(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, rt.Spec())
+}
+
+func (rt *Router) buildSpec() *Document {
+	b := &schemaBuilder{schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    rt.info,
+		Paths:   make(map[string]PathItem),
+	}
+
+	routes := make([]*Route, len(rt.routes))
+	copy(routes, rt.routes)
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Path < routes[j].Path })
+
+	errorSchema := b.schema(reflect.TypeOf(ErrorResponse{}))
+	legacyErrorSchema := b.schema(reflect.TypeOf(LegacyErrorResponse{}))
+
+	for _, route := range routes {
+		op := &Operation{
+			Summary:     route.Summary,
+			Description: route.Description,
+			OperationID: operationID(route.Method, route.Path),
+			Responses:   make(map[string]*Response),
+			Deprecated:  route.successor != "",
+		}
+		if route.Tag != "" {
+			op.Tags = []string{route.Tag}
+		}
+		if route.Scope != "" {
+			op.Security = []map[string][]string{{securitySchemeName: {}}}
+			op.Description = strings.TrimSpace(op.Description + "\n\n需要API Key权限：" + route.Scope)
+		}
+
+		for _, m := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     m[1],
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+		for _, q := range route.Query {
+			typ := q.Type
+			if typ == "" {
+				typ = "string"
+			}
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        q.Name,
+				In:          "query",
+				Description: q.Description,
+				Required:    q.Required,
+				Schema:      &Schema{Type: typ},
+			})
+		}
+
+		if route.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{"application/json": {Schema: b.schema(reflect.TypeOf(route.Request))}},
+			}
+		}
+
+		success := &Response{Description: http.StatusText(route.Status)}
+		switch {
+		case route.ContentType != "" && route.ContentType != "application/json":
+			success.Content = map[string]MediaType{route.ContentType: {Schema: &Schema{Type: "string"}}}
+		case route.Response != nil:
+			success.Content = map[string]MediaType{"application/json": {Schema: b.schema(reflect.TypeOf(route.Response))}}
+		default:
+			success.Content = map[string]MediaType{"application/json": {Schema: &Schema{}}}
+		}
+		op.Responses[strconv.Itoa(route.Status)] = success
+
+		failure := &Response{Description: "错误", Content: map[string]MediaType{"application/json": {Schema: errorSchema}}}
+		if route.successor != "" {
+			failure.Content = map[string]MediaType{"application/json": {Schema: legacyErrorSchema}}
+			success.Headers = map[string]Header{
+				"Deprecation": {Schema: &Schema{Type: "string"}},
+				"Link":        {Description: "替代接口：<" + route.successor + ">; rel=\"successor-version\"", Schema: &Schema{Type: "string"}},
+			}
+			op.Description = strings.TrimSpace(op.Description + "\n\n已废弃，请使用 " + route.successor)
+		}
+		op.Responses["default"] = failure
+
+		specPath := strings.TrimSuffix(route.Path, "{$}")
+		item, ok := doc.Paths[specPath]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[specPath] = item
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+
+	doc.Components.Schemas = b.schemas
+	doc.Components.SecuritySchemes = map[string]*SecurityScheme{
+		securitySchemeName: {
+			Type:        "http",
+			Scheme:      "bearer",
+			Description: "Authorization: Bearer <API Key>，也可以使用X-API-Key头",
+		},
+	}
+	return doc
+}
+
+// operationID 由方法和路径生成，如 GET /api/v1/balances/{chain} → getApiV1BalancesChain
+func operationID(method, pattern string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	upper := true
+	for _, c := range pattern {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			upper = true
+			continue
+		}
+		if upper {
+			c = unicode.ToUpper(c)
+			upper = false
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaBuilder 通过反射按encoding/json的规则生成结构体的Schema
+// 具名结构体放入components并以"包名.类型名"引用，匿名结构体和泛型实例内联
+type schemaBuilder struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	s := b.build(t)
+	if nullable && s.Ref == "" {
+		s.Nullable = true
+	}
+	return s
+}
+
+func (b *schemaBuilder) build(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case implements(t, jsonMarshalerType):
+		return &Schema{}
+	case implements(t, textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" || strings.Contains(t.Name(), "[") {
+			return b.object(t)
+		}
+		return b.ref(t)
+	default:
+		return &Schema{}
+	}
+}
+
+func (b *schemaBuilder) ref(t reflect.Type) *Schema {
+	name, ok := b.names[t]
+	if !ok {
+		name = path.Base(t.PkgPath()) + "." + t.Name()
+		b.names[t] = name
+		// 先占位，自引用的结构体在生成过程中直接得到引用
+		b.schemas[name] = &Schema{}
+		b.schemas[name] = b.object(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (b *schemaBuilder) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	b.fields(t, s)
+	return s
+}
+
+func (b *schemaBuilder) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// 未指定名称的嵌入结构体，字段提升到外层
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.fields(ft, s)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		omitempty, asString := false, false
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "omitempty":
+				omitempty = true
+			case "string":
+				asString = true
+			}
+		}
+
+		if asString {
+			s.Properties[name] = &Schema{Type: "string"}
+		} else {
+			s.Properties[name] = b.schema(f.Type)
+		}
+		if !omitempty && f.Type.Kind() != reflect.Pointer {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
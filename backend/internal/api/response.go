@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	apperrors "token-points-system/pkg/errors"
+)
+
+// 非业务错误使用的错误码
+const (
+	CodeMethodNotAllowed   = "METHOD_NOT_ALLOWED"
+	CodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+	CodeInternal           = "INTERNAL_ERROR"
+)
+
+// ErrorBody 错误信息，Code为pkg/errors中的业务错误码或上面的通用错误码
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// ErrorResponse /api/v1 下统一的错误响应
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// LegacyErrorResponse 旧路径的错误响应，保持原有格式
+type LegacyErrorResponse struct {
+	Error string `json:"error"`
+}
+
+type legacyKey struct{}
+
+// withLegacy 标记请求来自已废弃的旧路径
+func withLegacy(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), legacyKey{}, true))
+}
+
+// IsLegacy 请求是否来自已废弃的旧路径
+func IsLegacy(r *http.Request) bool {
+	legacy, _ := r.Context().Value(legacyKey{}).(bool)
+	return legacy
+}
+
+// WriteJSON 写出JSON响应
+func WriteJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+// WriteError 写出错误响应，旧路径使用 {"error": message}，其余使用统一的错误信封
+func WriteError(w http.ResponseWriter, r *http.Request, statusCode int, code, message string) {
+	if IsLegacy(r) {
+		WriteJSON(w, statusCode, LegacyErrorResponse{Error: message})
+		return
+	}
+	WriteJSON(w, statusCode, ErrorResponse{Error: ErrorBody{Code: code, Message: message}})
+}
+
+// WriteAppError 根据业务错误码映射HTTP状态码并写出错误响应
+func WriteAppError(w http.ResponseWriter, r *http.Request, err error) {
+	statusCode := StatusOf(err)
+	if IsLegacy(r) {
+		WriteJSON(w, statusCode, LegacyErrorResponse{Error: err.Error()})
+		return
+	}
+
+	body := ErrorBody{Code: CodeInternal, Message: err.Error()}
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) {
+		body.Code = appErr.Code
+		body.Message = appErr.Message
+		if appErr.Err != nil {
+			body.Detail = appErr.Err.Error()
+		}
+	}
+	WriteJSON(w, statusCode, ErrorResponse{Error: body})
+}
+
+// StatusOf 返回业务错误对应的HTTP状态码，非业务错误为500
+func StatusOf(err error) int {
+	var appErr *apperrors.AppError
+	if !errors.As(err, &appErr) {
+		return http.StatusInternalServerError
+	}
+
+	switch appErr.Code {
+	case apperrors.ErrInvalidRequest, apperrors.ErrInvalidChain:
+		return http.StatusBadRequest
+	case apperrors.ErrUnauthorized:
+		return http.StatusUnauthorized
+	case apperrors.ErrForbidden:
+		return http.StatusForbidden
+	case apperrors.ErrNotFound:
+		return http.StatusNotFound
+	case apperrors.ErrConflict:
+		return http.StatusConflict
+	case apperrors.ErrInsufficient:
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CodeOf 返回HTTP状态码对应的通用错误码，用于没有业务错误的场景（如参数解析失败）
+func CodeOf(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return apperrors.ErrInvalidRequest
+	case http.StatusUnauthorized:
+		return apperrors.ErrUnauthorized
+	case http.StatusForbidden:
+		return apperrors.ErrForbidden
+	case http.StatusNotFound:
+		return apperrors.ErrNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return apperrors.ErrConflict
+	case http.StatusServiceUnavailable:
+		return CodeServiceUnavailable
+	default:
+		return CodeInternal
+	}
+}
@@ -0,0 +1,182 @@
+// Package api 版本化REST接口的路由、统一错误响应与OpenAPI文档
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Prefix 当前版本接口的路径前缀
+const Prefix = "/api/v1"
+
+// Param 查询参数说明
+type Param struct {
+	Name string
+	// Type 为string、integer或boolean，默认string
+	Type        string
+	Description string
+	Required    bool
+}
+
+// Route 一个接口的方法、路径、文档与处理函数
+// Path使用net/http的路径模式，{name}为路径参数，处理函数通过r.PathValue读取
+type Route struct {
+	Method      string
+	Path        string
+	Tag         string
+	Summary     string
+	Description string
+	Query       []Param
+	// Request 请求体类型的零值，nil表示没有请求体
+	Request interface{}
+	// Response 成功响应体类型的零值，nil表示任意JSON
+	Response interface{}
+	// Status 成功状态码，默认200
+	Status int
+	// ContentType 成功响应的类型，默认application/json
+	ContentType string
+	// Scope 调用需要的API Key权限，空表示公开接口
+	Scope   string
+	Handler http.HandlerFunc
+
+	// successor 非空表示已废弃的旧路径，值为替代它的路径
+	successor string
+}
+
+// Router 按路径模式和方法分发请求，并根据注册的路由生成OpenAPI文档
+// 同一路径下未注册的方法返回405和Allow头，/api/下未注册的路径返回404，均使用JSON错误响应
+type Router struct {
+	mux    *http.ServeMux
+	info   Info
+	routes []*Route
+	paths  map[string]*pathHandler
+	auth   Authenticator
+
+	specOnce sync.Once
+	spec     *Document
+}
+
+// NewRouter 创建路由并注册 GET /api/v1/openapi.json
+func NewRouter(info Info) *Router {
+	rt := &Router{
+		mux:   http.NewServeMux(),
+		info:  info,
+		paths: make(map[string]*pathHandler),
+	}
+	rt.mux.HandleFunc("/api/", rt.notFound)
+
+	rt.Handle(Route{
+		Method:  http.MethodGet,
+		Path:    Prefix + "/openapi.json",
+		Tag:     "system",
+		Summary: "OpenAPI 3文档",
+		Handler: rt.serveSpec,
+	})
+	return rt
+}
+
+// Handle 注册路由，同一路径和方法重复注册或路径模式冲突时panic
+func (rt *Router) Handle(route Route) {
+	if route.Status == 0 {
+		route.Status = http.StatusOK
+	}
+
+	p, ok := rt.paths[route.Path]
+	if !ok {
+		p = &pathHandler{rt: rt, pattern: route.Path, methods: make(map[string]*Route), legacy: route.successor != ""}
+		rt.paths[route.Path] = p
+		rt.mux.Handle(route.Path, p)
+	}
+	if _, dup := p.methods[route.Method]; dup {
+		panic(fmt.Sprintf("api: duplicate route %s %s", route.Method, route.Path))
+	}
+	p.methods[route.Method] = &route
+	p.allow = append(p.allow, route.Method)
+
+	rt.routes = append(rt.routes, &route)
+}
+
+// Alias 把已废弃的旧路径注册为target路由的别名
+// 旧路径的路径参数名必须与target一致；响应带Deprecation和Link头，错误响应保持旧格式
+func (rt *Router) Alias(method, legacyPath, target string) {
+	for _, route := range rt.routes {
+		if route.Method == method && route.Path == target {
+			alias := *route
+			alias.Path = legacyPath
+			rt.Deprecated(alias, target)
+			return
+		}
+	}
+	panic(fmt.Sprintf("api: alias target %s %s is not registered", method, target))
+}
+
+// Deprecated 注册已废弃的旧路径，successor为替代它的路径
+// 用于处理方式与新路径不同的旧接口（如在请求体而不是路径中传ID）
+func (rt *Router) Deprecated(route Route, successor string) {
+	route.successor = successor
+	route.Handler = deprecate(successor, route.Handler)
+	rt.Handle(route)
+}
+
+// Mount 注册不出现在接口文档中的处理器，如静态页面
+func (rt *Router) Mount(pattern string, h http.Handler) {
+	rt.mux.Handle(pattern, h)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+func (rt *Router) notFound(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, Prefix+"/") {
+		r = withLegacy(r)
+	}
+	WriteError(w, r, http.StatusNotFound, CodeOf(http.StatusNotFound), "unknown path: "+r.URL.Path)
+}
+
+// pathHandler 按方法分发同一路径模式下的请求，需要权限的路由先校验API Key
+type pathHandler struct {
+	rt      *Router
+	pattern string
+	methods map[string]*Route
+	allow   []string
+	legacy  bool
+}
+
+func (p *pathHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route, ok := p.methods[r.Method]
+	if p.legacy {
+		r = withLegacy(r)
+	}
+	if !ok {
+		w.Header().Set("Allow", strings.Join(p.allow, ", "))
+		WriteError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if route.Scope == "" || p.rt.auth == nil {
+		route.Handler(w, r)
+		return
+	}
+	p.rt.serveAuthorized(w, r, p.pattern, route.Scope, route.Handler)
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}.$]+)(\.\.\.)?\}`)
+
+// deprecate 为旧路径的响应加上Deprecation头和指向新路径的Link头，并标记请求使用旧的错误格式
+func deprecate(successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		link := pathParamPattern.ReplaceAllStringFunc(successor, func(param string) string {
+			name := pathParamPattern.FindStringSubmatch(param)[1]
+			if v := r.PathValue(name); v != "" {
+				return v
+			}
+			return param
+		})
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+link+">; rel=\"successor-version\"")
+		next(w, withLegacy(r))
+	}
+}
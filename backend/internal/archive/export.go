@@ -0,0 +1,164 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Export 在一个一致性读事务中导出全部表到dir，chainID不为空时只导出该链的数据
+// dir必须不存在或为空；清单最后写入，存在清单即表示导出完整
+func Export(ctx context.Context, db *gorm.DB, dir, chainID string) (*Manifest, error) {
+	if err := prepareDir(dir); err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		Format:        Format,
+		SchemaVersion: SchemaVersion,
+		ChainID:       chainID,
+		CreatedAt:     time.Now(),
+		Tables:        []TableManifest{},
+	}
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, t := range tables {
+			if chainID != "" && t.ChainColumn == "" {
+				continue
+			}
+
+			tm, err := exportTable(tx, dir, t, chainID)
+			if err != nil {
+				return fmt.Errorf("导出%s失败: %w", t.Name, err)
+			}
+			manifest.Tables = append(manifest.Tables, *tm)
+		}
+		return nil
+	}, &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), raw, 0o644); err != nil {
+		return nil, fmt.Errorf("写入清单失败: %w", err)
+	}
+	return manifest, nil
+}
+
+func exportTable(tx *gorm.DB, dir string, t table, chainID string) (*TableManifest, error) {
+	query := "SELECT * FROM " + quoteIdent(t.Name)
+	var args []interface{}
+	if chainID != "" {
+		query += " WHERE " + quoteIdent(t.ChainColumn) + " = ?"
+		args = append(args, chainID)
+	}
+
+	rows, err := tx.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	tm := &TableManifest{
+		Name:    t.Name,
+		File:    t.Name + ".ndjson.gz",
+		Columns: columns,
+	}
+
+	file, err := os.Create(filepath.Join(dir, tm.File))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// 校验和按压缩后的文件内容计算
+	hash := sha256.New()
+	buffered := bufio.NewWriter(io.MultiWriter(file, hash))
+	gz := gzip.NewWriter(buffered)
+	encoder := json.NewEncoder(gz)
+	encoder.SetEscapeHTML(false)
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			record[column] = exportValue(values[i])
+		}
+		if err := encoder.Encode(record); err != nil {
+			return nil, err
+		}
+		tm.Rows++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if err := buffered.Flush(); err != nil {
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	tm.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return tm, nil
+}
+
+// exportValue 把驱动返回的值转换为JSON值
+// 字符串、DECIMAL和JSON列原样保留为字符串，时间使用RFC3339Nano
+func exportValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case []byte:
+		return string(value)
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	default:
+		return value
+	}
+}
+
+// prepareDir 创建导出目录，目录已存在时必须为空
+func prepareDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	switch {
+	case os.IsNotExist(err):
+		return os.MkdirAll(dir, 0o755)
+	case err != nil:
+		return err
+	case len(entries) > 0:
+		return fmt.Errorf("导出目录不为空: %s", dir)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxImportPlaceholders 单条INSERT语句的占位符上限，低于MySQL的65535
+const maxImportPlaceholders = 60000
+
+// Import 校验归档后导入到空数据库
+// 目标数据库必须由同一版本的schema.sql创建，且归档中的表都没有数据；每张表在一个事务中导入，
+// 中途失败时已导入的表不会回滚，清空目标库后重新导入即可
+func Import(ctx context.Context, db *gorm.DB, dir string) (*Manifest, error) {
+	manifest, err := Verify(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	db = db.WithContext(ctx)
+	if err := checkTargetVersion(db); err != nil {
+		return nil, err
+	}
+
+	for _, t := range manifest.Tables {
+		var exists int
+		if err := db.Raw("SELECT COUNT(*) FROM (SELECT 1 FROM " + quoteIdent(t.Name) + " LIMIT 1) t").Scan(&exists).Error; err != nil {
+			return nil, fmt.Errorf("检查%s失败: %w", t.Name, err)
+		}
+		if exists > 0 {
+			return nil, fmt.Errorf("目标表%s不为空，只能导入到空数据库", t.Name)
+		}
+	}
+
+	for _, t := range manifest.Tables {
+		if err := importTable(db, dir, t); err != nil {
+			return nil, fmt.Errorf("导入%s失败: %w", t.Name, err)
+		}
+	}
+	return manifest, nil
+}
+
+// checkTargetVersion 要求目标数据库的schema版本与当前版本一致
+func checkTargetVersion(db *gorm.DB) error {
+	var versions []string
+	if err := db.Raw("SELECT config_value FROM system_config WHERE config_key = 'schema_version'").Scan(&versions).Error; err != nil {
+		return fmt.Errorf("读取目标数据库schema版本失败: %w", err)
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("目标数据库缺少schema_version，请使用当前的schema.sql创建")
+	}
+	if versions[0] != fmt.Sprint(SchemaVersion) {
+		return fmt.Errorf("目标数据库schema版本为%s，当前版本为%d", versions[0], SchemaVersion)
+	}
+	return nil
+}
+
+func importTable(db *gorm.DB, dir string, t TableManifest) error {
+	timeColumns, err := targetTimeColumns(db, t)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(filepath.Join(dir, t.File))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	decoder := json.NewDecoder(gz)
+	decoder.UseNumber()
+
+	batchSize := maxImportPlaceholders / len(t.Columns)
+	if batchSize > 1000 {
+		batchSize = 1000
+	}
+	rowPlaceholder := "(" + strings.TrimSuffix(strings.Repeat("?,", len(t.Columns)), ",") + ")"
+	quoted := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		quoted[i] = quoteIdent(column)
+	}
+	insertPrefix := "INSERT INTO " + quoteIdent(t.Name) + " (" + strings.Join(quoted, ", ") + ") VALUES "
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rows int64
+		batch := make([]interface{}, 0, batchSize*len(t.Columns))
+		pending := 0
+
+		flush := func() error {
+			if pending == 0 {
+				return nil
+			}
+			placeholders := strings.TrimSuffix(strings.Repeat(rowPlaceholder+",", pending), ",")
+			if err := tx.Exec(insertPrefix+placeholders, batch...).Error; err != nil {
+				return err
+			}
+			batch = batch[:0]
+			pending = 0
+			return nil
+		}
+
+		for {
+			var record map[string]interface{}
+			err := decoder.Decode(&record)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("第%d行解析失败: %w", rows+1, err)
+			}
+
+			for _, column := range t.Columns {
+				value, err := importValue(record[column], timeColumns[column])
+				if err != nil {
+					return fmt.Errorf("第%d行%s列无效: %w", rows+1, column, err)
+				}
+				batch = append(batch, value)
+			}
+			pending++
+			rows++
+
+			if pending == batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+
+		if rows != t.Rows {
+			return fmt.Errorf("读取到%d行，清单记录为%d行", rows, t.Rows)
+		}
+		return nil
+	})
+}
+
+// targetTimeColumns 校验归档的列都存在于目标表，并返回其中的时间列
+func targetTimeColumns(db *gorm.DB, t TableManifest) (map[string]bool, error) {
+	rows, err := db.Raw("SELECT * FROM " + quoteIdent(t.Name) + " LIMIT 0").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(columnTypes))
+	timeColumns := make(map[string]bool)
+	for _, ct := range columnTypes {
+		known[ct.Name()] = true
+		switch strings.ToUpper(ct.DatabaseTypeName()) {
+		case "DATETIME", "TIMESTAMP", "DATE":
+			timeColumns[ct.Name()] = true
+		}
+	}
+
+	if len(t.Columns) == 0 {
+		return nil, fmt.Errorf("清单中没有列")
+	}
+	for _, column := range t.Columns {
+		if !known[column] {
+			return nil, fmt.Errorf("目标表没有列%s", column)
+		}
+	}
+	return timeColumns, nil
+}
+
+// importValue 把NDJSON中的值转换为INSERT参数，数字以字符串传入以保留DECIMAL精度
+func importValue(v interface{}, isTime bool) (interface{}, error) {
+	switch value := v.(type) {
+	case nil:
+		return nil, nil
+	case json.Number:
+		return value.String(), nil
+	case string:
+		if isTime {
+			return time.Parse(time.RFC3339Nano, value)
+		}
+		return value, nil
+	case bool:
+		return value, nil
+	default:
+		return nil, fmt.Errorf("不支持的值类型%T", v)
+	}
+}
@@ -0,0 +1,111 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// AttestationTypedDataType EIP-712中积分证明的结构名，与contracts/PointsAttestation.sol一致
+const AttestationTypedDataType = "PointsAttestation"
+
+// PointsAttestation 积分证明的签名内容
+// ChainID为积分所在链的EVM链ID；Points为按18位小数放大的积分；Season为赛季ID，0表示累计总积分；
+// Nonce按账户递增，合约只接受比已使用的更大的nonce；Expiry为过期的Unix时间（秒）
+type PointsAttestation struct {
+	Account common.Address
+	ChainID uint64
+	Points  *big.Int
+	Season  uint64
+	Nonce   uint64
+	Expiry  int64
+}
+
+// AttestationDomain 积分证明的EIP-712签名域
+// ChainID和VerifyingContract为校验合约所在链的EVM链ID和合约地址，证明只能在该合约中使用，
+// 与消息中积分所在链的ChainID无关
+type AttestationDomain struct {
+	Name              string
+	Version           string
+	ChainID           uint64
+	VerifyingContract common.Address
+}
+
+// TypedData 返回EIP-712结构化数据，签名域包含name、version、chainId和verifyingContract
+func (a *PointsAttestation) TypedData(domain AttestationDomain) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			AttestationTypedDataType: {
+				{Name: "account", Type: "address"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "points", Type: "uint256"},
+				{Name: "season", Type: "uint256"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "expiry", Type: "uint256"},
+			},
+		},
+		PrimaryType: AttestationTypedDataType,
+		Domain: apitypes.TypedDataDomain{
+			Name:              domain.Name,
+			Version:           domain.Version,
+			ChainId:           math.NewHexOrDecimal256(int64(domain.ChainID)),
+			VerifyingContract: domain.VerifyingContract.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"account": a.Account.Hex(),
+			"chainId": strconv.FormatUint(a.ChainID, 10),
+			"points":  a.Points.String(),
+			"season":  strconv.FormatUint(a.Season, 10),
+			"nonce":   strconv.FormatUint(a.Nonce, 10),
+			"expiry":  strconv.FormatInt(a.Expiry, 10),
+		},
+	}
+}
+
+// Signer 用本地私钥签署EIP-712结构化数据
+type Signer struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// LoadSigner 从十六进制私钥文件加载签名者
+func LoadSigner(keyFile string) (*Signer, error) {
+	key, err := crypto.LoadECDSA(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing key: %w", err)
+	}
+	return &Signer{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}, nil
+}
+
+// Address 返回签名者地址
+func (s *Signer) Address() common.Address {
+	return s.address
+}
+
+// SignTypedData 返回EIP-712摘要和签名，签名的v为27/28，与eth_signTypedData_v4一致
+func (s *Signer) SignTypedData(typedData apitypes.TypedData) (string, string, error) {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid typed data: %w", err)
+	}
+
+	sig, err := crypto.Sign(hash, s.key)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to sign typed data: %w", err)
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return hexutil.Encode(hash), hexutil.Encode(sig), nil
+}
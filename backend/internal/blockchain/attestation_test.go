@@ -0,0 +1,224 @@
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// testSigner EIP-712规范示例中的签名者，私钥为keccak256("cow")
+func testSigner(t *testing.T) *Signer {
+	t.Helper()
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte("cow")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Signer{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+var testAttestationDomain = AttestationDomain{
+	Name:              "Token Points Attestation",
+	Version:           "1",
+	ChainID:           1,
+	VerifyingContract: common.HexToAddress("0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"),
+}
+
+func testAttestation() *PointsAttestation {
+	return &PointsAttestation{
+		Account: common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		ChainID: 11155111,
+		Points:  new(big.Int).Mul(big.NewInt(1500), big.NewInt(1e18)),
+		Season:  3,
+		Nonce:   7,
+		Expiry:  1717000000,
+	}
+}
+
+func word(n *big.Int) []byte {
+	return common.LeftPadBytes(n.Bytes(), 32)
+}
+
+// solidityDigest 按contracts/PointsAttestation.sol中PointsAttestationLib的方式计算摘要
+func solidityDigest(domain AttestationDomain, a *PointsAttestation) []byte {
+	domainTypeHash := crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	separator := crypto.Keccak256(
+		domainTypeHash,
+		crypto.Keccak256([]byte(domain.Name)),
+		crypto.Keccak256([]byte(domain.Version)),
+		word(new(big.Int).SetUint64(domain.ChainID)),
+		common.LeftPadBytes(domain.VerifyingContract.Bytes(), 32),
+	)
+
+	typeHash := crypto.Keccak256([]byte("PointsAttestation(address account,uint256 chainId,uint256 points,uint256 season,uint256 nonce,uint256 expiry)"))
+	structHash := crypto.Keccak256(
+		typeHash,
+		common.LeftPadBytes(a.Account.Bytes(), 32),
+		word(new(big.Int).SetUint64(a.ChainID)),
+		word(a.Points),
+		word(new(big.Int).SetUint64(a.Season)),
+		word(new(big.Int).SetUint64(a.Nonce)),
+		word(big.NewInt(a.Expiry)),
+	)
+
+	return crypto.Keccak256([]byte("\x19\x01"), separator, structHash)
+}
+
+func TestSignTypedDataSpecVector(t *testing.T) {
+	// EIP-712规范中的Mail示例，摘要和签名均为规范给出的值
+	mail := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"Person": {
+				{Name: "name", Type: "string"},
+				{Name: "wallet", Type: "address"},
+			},
+			"Mail": {
+				{Name: "from", Type: "Person"},
+				{Name: "to", Type: "Person"},
+				{Name: "contents", Type: "string"},
+			},
+		},
+		PrimaryType: "Mail",
+		Domain: apitypes.TypedDataDomain{
+			Name:              "Ether Mail",
+			Version:           "1",
+			ChainId:           math.NewHexOrDecimal256(1),
+			VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+		},
+		Message: apitypes.TypedDataMessage{
+			"from":     map[string]interface{}{"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+			"to":       map[string]interface{}{"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+			"contents": "Hello, Bob!",
+		},
+	}
+
+	signer := testSigner(t)
+	if got, want := signer.Address().Hex(), "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"; got != want {
+		t.Fatalf("signer = %s, want %s", got, want)
+	}
+
+	digest, signature, err := signer.SignTypedData(mail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"; digest != want {
+		t.Errorf("digest = %s, want %s", digest, want)
+	}
+	// r || s || v，v为28
+	want := "0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d" +
+		"07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562" + "1c"
+	if signature != want {
+		t.Errorf("signature = %s, want %s", signature, want)
+	}
+}
+
+func TestAttestationDigest(t *testing.T) {
+	tests := []struct {
+		name   string
+		domain AttestationDomain
+		mutate func(a *PointsAttestation)
+		want   string
+	}{
+		{
+			name:   "reference",
+			domain: testAttestationDomain,
+			want:   "0xd0886e55bf052116e0264f4b0864eed8f5a739e62666922a14569856dc468b20",
+		},
+		{
+			name:   "cumulative points",
+			domain: testAttestationDomain,
+			mutate: func(a *PointsAttestation) { a.Season = 0 },
+		},
+		{
+			name: "another verifying chain",
+			domain: AttestationDomain{
+				Name:              testAttestationDomain.Name,
+				Version:           testAttestationDomain.Version,
+				ChainID:           8453,
+				VerifyingContract: testAttestationDomain.VerifyingContract,
+			},
+		},
+		{
+			name: "another verifying contract",
+			domain: AttestationDomain{
+				Name:              testAttestationDomain.Name,
+				Version:           testAttestationDomain.Version,
+				ChainID:           testAttestationDomain.ChainID,
+				VerifyingContract: common.HexToAddress("0x2222222222222222222222222222222222222222"),
+			},
+		},
+		{
+			name:   "large points",
+			domain: testAttestationDomain,
+			mutate: func(a *PointsAttestation) { a.Points, _ = new(big.Int).SetString("123456789012345678901234567890", 10) },
+		},
+	}
+
+	reference := ""
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testAttestation()
+			if tt.mutate != nil {
+				tt.mutate(a)
+			}
+
+			hash, _, err := apitypes.TypedDataAndHash(a.TypedData(tt.domain))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := hexutil.Encode(hash)
+
+			// 与合约中按Solidity方式计算的摘要一致
+			if want := hexutil.Encode(solidityDigest(tt.domain, a)); got != want {
+				t.Errorf("digest = %s, solidity digest = %s", got, want)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("digest = %s, want %s", got, tt.want)
+			}
+			if tt.want == "" && got == reference {
+				t.Errorf("digest did not change")
+			}
+			if tt.name == "reference" {
+				reference = got
+			}
+		})
+	}
+}
+
+func TestAttestationSignAndRecover(t *testing.T) {
+	signer := testSigner(t)
+	typedData := testAttestation().TypedData(testAttestationDomain)
+
+	digest, signature, err := signer.SignTypedData(typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "0xd0886e55bf052116e0264f4b0864eed8f5a739e62666922a14569856dc468b20"; digest != want {
+		t.Fatalf("digest = %s, want %s", digest, want)
+	}
+
+	recovered, err := RecoverTypedData(typedData, signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered != signer.Address() {
+		t.Fatalf("recovered = %s, want %s", recovered.Hex(), signer.Address().Hex())
+	}
+
+	// 同一签名不能用于其他合约
+	other := testAttestationDomain
+	other.VerifyingContract = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	if err := VerifyTypedData(testAttestation().TypedData(other), signature, signer.Address()); err != ErrSignatureMismatch {
+		t.Fatalf("verify with another contract: err = %v, want %v", err, ErrSignatureMismatch)
+	}
+}
@@ -202,7 +202,12 @@ func (l *EnhancedEventListener) processNewBlocks(ctx context.Context) error {
 		}
 	}
 	
-	if err := l.blockRepo.MarkProcessed(ctx, l.chainCfg.ID, confirmedBlock); err != nil {
+	blockTime, err := l.client.GetBlockTimestamp(ctx, confirmedBlock)
+	if err != nil {
+		return err
+	}
+
+	if err := l.blockRepo.MarkProcessed(ctx, l.chainCfg.ID, confirmedBlock, blockTime); err != nil {
 		return err
 	}
 	
@@ -17,6 +17,11 @@ type EventListener struct {
 	eventChan    chan *TransferEvent
 	stopChan     chan struct{}
 	isProcessing int32
+	// epoch 每次回退递增，回退前发出的事件视为过期
+	epoch    uint64
+	rewindTo int64
+	// rewindSeq 已处理的数据库回退请求序号，见BlockRepository.GetRewind
+	rewindSeq uint64
 }
 
 func NewEventListener(chainCfg *config.ChainConfig, client *Client, blockRepo *repository.BlockRepository) *EventListener {
@@ -26,6 +31,7 @@ func NewEventListener(chainCfg *config.ChainConfig, client *Client, blockRepo *r
 		blockRepo: blockRepo,
 		eventChan: make(chan *TransferEvent, 1000),
 		stopChan:  make(chan struct{}),
+		rewindTo:  -1,
 	}
 }
 
@@ -36,6 +42,13 @@ func (l *EventListener) Start(ctx context.Context, startBlock int64) {
 
 	lastProcessedBlock := startBlock
 
+	// 启动前的回退请求已体现在startBlock中
+	if seq, _, err := l.blockRepo.GetRewind(ctx, l.chainCfg.ID); err != nil {
+		logger.Error("获取监听器回退请求失败:", err)
+	} else {
+		l.rewindSeq = seq
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -56,6 +69,9 @@ func (l *EventListener) Start(ctx context.Context, startBlock int64) {
 			// 标记为处理中
 			atomic.StoreInt32(&l.isProcessing, 1)
 
+			l.pollRewind(ctx)
+			lastProcessedBlock = l.applyRewind(lastProcessedBlock)
+
 			// 处理新区块
 			block, err := l.processNewBlocks(ctx, lastProcessedBlock)
 			if err != nil {
@@ -64,6 +80,9 @@ func (l *EventListener) Start(ctx context.Context, startBlock int64) {
 				lastProcessedBlock = block
 			}
 
+			// 处理期间发生的回退以回退位置为准
+			lastProcessedBlock = l.applyRewind(lastProcessedBlock)
+
 			// 标记为空闲
 			atomic.StoreInt32(&l.isProcessing, 0)
 		}
@@ -75,6 +94,44 @@ func (l *EventListener) Stop() {
 	close(l.stopChan)
 }
 
+// Rewind 把监听位置回退到block，下一次拉取从block+1开始
+// 回退前已发出但尚未处理的事件随之过期，见TransferEvent.Stale
+func (l *EventListener) Rewind(block int64) {
+	atomic.StoreInt64(&l.rewindTo, block)
+	atomic.AddUint64(&l.epoch, 1)
+}
+
+// pollRewind 检查数据库中的回退请求，有新的请求时回退监听位置
+// 恢复备份或切换数据集可能不在运行监听器的节点上执行，本地的Rewind调用无法送达
+func (l *EventListener) pollRewind(ctx context.Context) {
+	seq, block, err := l.blockRepo.GetRewind(ctx, l.chainCfg.ID)
+	if err != nil {
+		logger.Error("获取监听器回退请求失败:", err)
+		return
+	}
+	if seq == l.rewindSeq {
+		return
+	}
+
+	l.rewindSeq = seq
+	l.Rewind(block)
+}
+
+// applyRewind 有待生效的回退时返回回退位置，否则返回lastBlock
+func (l *EventListener) applyRewind(lastBlock int64) int64 {
+	block := atomic.SwapInt64(&l.rewindTo, -1)
+	if block < 0 {
+		return lastBlock
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"chain_id":   l.chainCfg.ID,
+		"from_block": lastBlock,
+		"to_block":   block,
+	}).Warn("事件监听器已回退")
+	return block
+}
+
 // GetEventChannel 获取事件通道
 func (l *EventListener) GetEventChannel() <-chan *TransferEvent {
 	return l.eventChan
@@ -87,6 +144,8 @@ func (l *EventListener) IsProcessing() bool {
 
 // processNewBlocks 处理新区块
 func (l *EventListener) processNewBlocks(ctx context.Context, lastBlock int64) (int64, error) {
+	epoch := atomic.LoadUint64(&l.epoch)
+
 	confirmedBlock, err := l.client.GetConfirmBlockNumber(ctx)
 	if err != nil {
 		return lastBlock, err
@@ -136,8 +195,17 @@ func (l *EventListener) processNewBlocks(ctx context.Context, lastBlock int64) (
 			"confirmed_block": confirmedBlock,
 		}).Debug("区块范围内无Transfer事件")
 
-		// 标记区块已处理，避免重复拉取
-		if err := l.blockRepo.MarkProcessed(ctx, l.chainCfg.ID, confirmedBlock); err != nil {
+		// 拉取期间发生了回退，不能再把区块游标推进到回退位置之后
+		if atomic.LoadUint64(&l.epoch) != epoch {
+			return lastBlock, nil
+		}
+
+		// 标记区块已处理，避免重复拉取；区块时间戳供积分补算判断余额历史已处理到的时间
+		blockTime, err := l.client.GetBlockTimestamp(ctx, confirmedBlock)
+		if err != nil {
+			return lastBlock, err
+		}
+		if err := l.blockRepo.MarkProcessed(ctx, l.chainCfg.ID, confirmedBlock, blockTime); err != nil {
 			logger.Error("标记区块已处理失败:", err)
 			return lastBlock, err
 		}
@@ -152,6 +220,8 @@ func (l *EventListener) processNewBlocks(ctx context.Context, lastBlock int64) (
 			logger.Error("解析日志失败:", err)
 			continue
 		}
+		event.source = l
+		event.epoch = epoch
 
 		select {
 		case l.eventChan <- event:
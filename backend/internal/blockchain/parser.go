@@ -3,6 +3,7 @@ package blockchain
 import (
 	"math/big"
 	"strings"
+	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -16,6 +17,15 @@ type TransferEvent struct {
 	Value    *big.Int
 	TxHash   string
 	BlockNum int64
+	LogIndex uint
+
+	source *EventListener
+	epoch  uint64
+}
+
+// Stale 事件发出后监听器是否发生过回退，过期事件会在回退后重新拉取，不应再处理
+func (e *TransferEvent) Stale() bool {
+	return e.source != nil && atomic.LoadUint64(&e.source.epoch) != e.epoch
 }
 
 // ParseTransferLog 将区块链日志解析为TransferEvent
@@ -38,6 +48,7 @@ func ParseTransferLog(log types.Log) (*TransferEvent, error) {
 		Value:    value,
 		TxHash:   log.TxHash.Hex(),
 		BlockNum: int64(log.BlockNumber),
+		LogIndex: log.Index,
 	}, nil
 }
 
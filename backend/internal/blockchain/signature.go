@@ -0,0 +1,135 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+var ErrSignatureMismatch = errors.New("signature does not match the expected address")
+
+// linkTypedDataType EIP-712中钱包关联消息的结构名
+const linkTypedDataType = "WalletLink"
+
+// SigningDomain EIP-712签名域，不包含chainId，同一签名可用于所有链上的钱包
+type SigningDomain struct {
+	Name    string
+	Version string
+}
+
+// WalletLinkMessage 关联或解除关联钱包时需要签名的内容
+type WalletLinkMessage struct {
+	Action    string
+	Account   common.Address
+	Wallet    common.Address
+	Nonce     string
+	ExpiresAt time.Time
+}
+
+// Text 返回EIP-191（personal_sign）签名的明文
+func (m *WalletLinkMessage) Text(domain SigningDomain) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s wants you to %s wallet %s and account %s.\n\n", domain.Name, m.Action, m.Wallet.Hex(), m.Account.Hex())
+	fmt.Fprintf(&sb, "Action: %s\n", m.Action)
+	fmt.Fprintf(&sb, "Account: %s\n", m.Account.Hex())
+	fmt.Fprintf(&sb, "Wallet: %s\n", m.Wallet.Hex())
+	fmt.Fprintf(&sb, "Nonce: %s\n", m.Nonce)
+	fmt.Fprintf(&sb, "Expires At: %s", m.ExpiresAt.UTC().Format(time.RFC3339))
+	return sb.String()
+}
+
+// TypedData 返回EIP-712（eth_signTypedData_v4）签名的结构化数据
+func (m *WalletLinkMessage) TypedData(domain SigningDomain) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+			},
+			linkTypedDataType: {
+				{Name: "action", Type: "string"},
+				{Name: "account", Type: "address"},
+				{Name: "wallet", Type: "address"},
+				{Name: "nonce", Type: "string"},
+				{Name: "expiresAt", Type: "uint256"},
+			},
+		},
+		PrimaryType: linkTypedDataType,
+		Domain: apitypes.TypedDataDomain{
+			Name:    domain.Name,
+			Version: domain.Version,
+		},
+		Message: apitypes.TypedDataMessage{
+			"action":    m.Action,
+			"account":   m.Account.Hex(),
+			"wallet":    m.Wallet.Hex(),
+			"nonce":     m.Nonce,
+			"expiresAt": strconv.FormatInt(m.ExpiresAt.Unix(), 10),
+		},
+	}
+}
+
+// VerifyPersonalSign 校验EIP-191签名是否由expected签署
+func VerifyPersonalSign(message string, signature string, expected common.Address) error {
+	return verifyHash(accounts.TextHash([]byte(message)), signature, expected)
+}
+
+// VerifyTypedData 校验EIP-712签名是否由expected签署
+func VerifyTypedData(typedData apitypes.TypedData, signature string, expected common.Address) error {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return fmt.Errorf("invalid typed data: %w", err)
+	}
+	return verifyHash(hash, signature, expected)
+}
+
+// RecoverTypedData 从EIP-712签名恢复签名者地址
+func RecoverTypedData(typedData apitypes.TypedData, signature string) (common.Address, error) {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid typed data: %w", err)
+	}
+	return recoverHash(hash, signature)
+}
+
+// verifyHash 从签名恢复地址并与expected比较
+func verifyHash(hash []byte, signature string, expected common.Address) error {
+	signer, err := recoverHash(hash, signature)
+	if err != nil {
+		return err
+	}
+	if signer != expected {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+// recoverHash 从签名恢复地址，v兼容27/28和0/1两种写法
+func recoverHash(hash []byte, signature string) (common.Address, error) {
+	sig, err := hexutil.Decode(signature)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("invalid signature length: %d", len(sig))
+	}
+
+	sig = append([]byte(nil), sig...)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to recover signer: %w", err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
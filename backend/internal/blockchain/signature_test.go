@@ -0,0 +1,130 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func testLinkMessage() *WalletLinkMessage {
+	return &WalletLinkMessage{
+		Action:    "link",
+		Account:   common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		Wallet:    common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"),
+		Nonce:     "3f2a9c",
+		ExpiresAt: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWalletLinkText(t *testing.T) {
+	domain := SigningDomain{Name: "Token Points System", Version: "1"}
+	want := "Token Points System wants you to link wallet 0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826 and account 0x1111111111111111111111111111111111111111.\n\n" +
+		"Action: link\n" +
+		"Account: 0x1111111111111111111111111111111111111111\n" +
+		"Wallet: 0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826\n" +
+		"Nonce: 3f2a9c\n" +
+		"Expires At: 2024-06-01T12:00:00Z"
+	if got := testLinkMessage().Text(domain); got != want {
+		t.Fatalf("text = %q, want %q", got, want)
+	}
+}
+
+func TestVerifySignatures(t *testing.T) {
+	signer := testSigner(t)
+	domain := SigningDomain{Name: "Token Points System", Version: "1"}
+	msg := testLinkMessage()
+
+	personalHash := accounts.TextHash([]byte(msg.Text(domain)))
+	personalSig, err := crypto.Sign(personalHash, signer.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, typedSig, err := signer.SignTypedData(msg.TypedData(domain))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// v为0/1的写法
+	rawV := hexutil.Encode(personalSig)
+	// v为27/28的写法
+	personalSig[crypto.RecoveryIDOffset] += 27
+	wallet := hexutil.Encode(personalSig)
+
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	tests := []struct {
+		name    string
+		verify  func() error
+		wantErr error
+		anyErr  bool
+	}{
+		{
+			name:   "personal sign",
+			verify: func() error { return VerifyPersonalSign(msg.Text(domain), wallet, signer.Address()) },
+		},
+		{
+			name:   "personal sign with v 0/1",
+			verify: func() error { return VerifyPersonalSign(msg.Text(domain), rawV, signer.Address()) },
+		},
+		{
+			name:    "personal sign by another address",
+			verify:  func() error { return VerifyPersonalSign(msg.Text(domain), wallet, other) },
+			wantErr: ErrSignatureMismatch,
+		},
+		{
+			name: "personal sign over another message",
+			verify: func() error {
+				changed := *msg
+				changed.Nonce = "000000"
+				return VerifyPersonalSign(changed.Text(domain), wallet, signer.Address())
+			},
+			wantErr: ErrSignatureMismatch,
+		},
+		{
+			name:   "typed data",
+			verify: func() error { return VerifyTypedData(msg.TypedData(domain), typedSig, signer.Address()) },
+		},
+		{
+			name: "typed data for another action",
+			verify: func() error {
+				changed := *msg
+				changed.Action = "unlink"
+				return VerifyTypedData(changed.TypedData(domain), typedSig, signer.Address())
+			},
+			wantErr: ErrSignatureMismatch,
+		},
+		{
+			name:   "truncated signature",
+			verify: func() error { return VerifyPersonalSign(msg.Text(domain), wallet[:len(wallet)-2], signer.Address()) },
+			anyErr: true,
+		},
+		{
+			name:   "not hex",
+			verify: func() error { return VerifyPersonalSign(msg.Text(domain), "signature", signer.Address()) },
+			anyErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.verify()
+			switch {
+			case tt.anyErr:
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
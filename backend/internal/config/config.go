@@ -12,6 +12,12 @@ type Config struct {
 	Chains   []ChainConfig    `mapstructure:"chains"`
 	Points   PointsConfig     `mapstructure:"points"`
 	Backup   BackupConfig     `mapstructure:"backup"`
+	Leader   LeaderConfig     `mapstructure:"leader"`
+	Jobs     JobsConfig       `mapstructure:"jobs"`
+	Accounts AccountsConfig   `mapstructure:"accounts"`
+	Leaderboard LeaderboardConfig `mapstructure:"leaderboard"`
+	Attestation AttestationConfig `mapstructure:"attestation"`
+	Auth     AuthConfig       `mapstructure:"auth"`
 	Logging  LoggingConfig    `mapstructure:"logging"`
 }
 
@@ -54,18 +60,135 @@ type ChainConfig struct {
 	BatchSize         int `mapstructure:"batch_size"`
 	MaxRetries        int `mapstructure:"max_retries"`
 	AdaptiveMode      bool `mapstructure:"adaptive_mode"`
+
+	// Period 链自己的积分计算周期，为空时使用points.period
+	Period *PeriodConfig `mapstructure:"period"`
+	// PointsWeight 跨链汇总积分时该链积分的权重，未配置时为1
+	PointsWeight *float64 `mapstructure:"points_weight"`
+}
+
+// GetPointsWeight 返回跨链汇总时的积分权重
+func (c *ChainConfig) GetPointsWeight() float64 {
+	if c.PointsWeight == nil {
+		return 1
+	}
+	return *c.PointsWeight
 }
 
 type PointsConfig struct {
-	CalculationRate     float64 `mapstructure:"calculation_rate"`
-	CalculationInterval int     `mapstructure:"calculation_interval"`
-	CalculationCron     string  `mapstructure:"calculation_cron"`
+	CalculationRate          float64 `mapstructure:"calculation_rate"`
+	// RuleVersion 积分计算规则版本，记录在每条计算记录上：v1（默认）从周期内第一条余额变动开始计时，v2从周期起点以期初余额计时
+	RuleVersion              string  `mapstructure:"rule_version"`
+	CalculationInterval      int     `mapstructure:"calculation_interval"`
+	CalculationCron          string  `mapstructure:"calculation_cron"`
+	RedemptionReservationTTL int     `mapstructure:"redemption_reservation_ttl"`
+	// AdjustmentApprovalThreshold 人工调整绝对值超过该值时需要另一人审批，0表示不需要审批
+	AdjustmentApprovalThreshold float64 `mapstructure:"adjustment_approval_threshold"`
+	// CatchUpConcurrency 同时补算遗漏周期的链数量上限
+	CatchUpConcurrency int `mapstructure:"catchup_concurrency"`
+
+	Period PeriodConfig `mapstructure:"period"`
+
+	Expiry ExpiryConfig `mapstructure:"expiry"`
 }
 
+// PeriodConfig 积分计算周期
+// Unit为minute、hour、day、week或custom；minute/hour可用Every指定倍数（需整除60/24），
+// custom使用Duration（秒）和Anchor（对齐起点）定义；Timezone决定自然边界所在的时区，默认本地时区
+type PeriodConfig struct {
+	Unit     string `mapstructure:"unit"`
+	Every    int    `mapstructure:"every"`
+	Duration int    `mapstructure:"duration"`
+	Anchor   string `mapstructure:"anchor"`
+	Timezone string `mapstructure:"timezone"`
+}
+
+// ExpiryConfig 积分过期与衰减策略
+type ExpiryConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Cron    string `mapstructure:"cron"`
+	// AccrualTTLDays 入账积分的有效天数，先进先出过期；0表示仅按显式过期时间过期
+	AccrualTTLDays int `mapstructure:"accrual_ttl_days"`
+	// 连续DecayInactiveDays天没有任何入账的用户，每天按DecayRate比例衰减
+	DecayEnabled      bool    `mapstructure:"decay_enabled"`
+	DecayInactiveDays int     `mapstructure:"decay_inactive_days"`
+	DecayRate         float64 `mapstructure:"decay_rate"`
+}
+
+// BackupConfig 定时备份与保留策略
+// 保留最近RetentionDays天每天的最后一份、最近KeepWeeks周每周的最后一份和最近KeepMonths个月每月的最后一份备份
 type BackupConfig struct {
 	Enabled      bool `mapstructure:"enabled"`
 	Interval     int  `mapstructure:"interval"`
 	RetentionDays int `mapstructure:"retention_days"`
+	KeepWeeks    int  `mapstructure:"keep_weeks"`
+	KeepMonths   int  `mapstructure:"keep_months"`
+}
+
+// LeaderConfig 多副本部署时的主节点选举，只有主节点运行调度器和链监听
+type LeaderConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	LeaseName string `mapstructure:"lease_name"`
+	// NodeID 节点标识，为空时使用主机名和进程号
+	NodeID string `mapstructure:"node_id"`
+	// 主节点失联后，其他节点最迟在LeaseSeconds + RenewSeconds秒内接管
+	LeaseSeconds int `mapstructure:"lease_seconds"`
+	RenewSeconds int `mapstructure:"renew_seconds"`
+}
+
+// JobsConfig 后台任务执行配置，任务只在主节点执行
+type JobsConfig struct {
+	Workers      int `mapstructure:"workers"`
+	PollInterval int `mapstructure:"poll_interval"`
+}
+
+// AccountsConfig 多钱包账户关联
+// 签名挑战在NonceTTL秒内有效且只能使用一次；DomainName/DomainVersion为EIP-712签名域
+type AccountsConfig struct {
+	NonceTTL      int    `mapstructure:"nonce_ttl"`
+	DomainName    string `mapstructure:"domain_name"`
+	DomainVersion string `mapstructure:"domain_version"`
+	MaxWallets    int    `mapstructure:"max_wallets"`
+}
+
+// AttestationConfig 积分证明签名
+// KeyFile为十六进制私钥文件，为空时不启用；证明默认TTL秒后过期，请求可指定不超过MaxTTL秒的有效期；
+// DomainName/DomainVersion/VerifyingChainID/VerifyingContract为EIP-712签名域，后两者是校验合约所在链的链ID和合约地址
+type AttestationConfig struct {
+	KeyFile           string `mapstructure:"key_file"`
+	DomainName        string `mapstructure:"domain_name"`
+	DomainVersion     string `mapstructure:"domain_version"`
+	VerifyingChainID  uint64 `mapstructure:"verifying_chain_id"`
+	VerifyingContract string `mapstructure:"verifying_contract"`
+	TTL               int    `mapstructure:"ttl"`
+	MaxTTL            int    `mapstructure:"max_ttl"`
+}
+
+// AuthConfig 管理接口的API Key认证
+// 默认启用；Disabled为true时管理接口不校验API Key，仅用于本地开发
+// 轮换后旧Key在RotationGrace秒后过期，默认86400
+type AuthConfig struct {
+	Disabled      bool `mapstructure:"disabled"`
+	RotationGrace int  `mapstructure:"rotation_grace"`
+}
+
+// LeaderboardConfig 排行榜快照与缓存
+// 快照按SnapshotCron（带秒的cron表达式）生成，保留RetentionHours小时，排名变化与24小时前的快照比较；
+// 查询结果在进程内缓存CacheTTL秒
+type LeaderboardConfig struct {
+	SnapshotCron   string           `mapstructure:"snapshot_cron"`
+	RetentionHours int              `mapstructure:"retention_hours"`
+	CacheTTL       int              `mapstructure:"cache_ttl"`
+	Campaigns      []CampaignConfig `mapstructure:"campaigns"`
+}
+
+// CampaignConfig 活动排行榜，按[Start, End)内在Chains上获得的积分排名（RFC3339时间），Chains为空时为全部启用链
+type CampaignConfig struct {
+	ID     string   `mapstructure:"id"`
+	Name   string   `mapstructure:"name"`
+	Chains []string `mapstructure:"chains"`
+	Start  string   `mapstructure:"start"`
+	End    string   `mapstructure:"end"`
 }
 
 type LoggingConfig struct {
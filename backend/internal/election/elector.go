@@ -0,0 +1,210 @@
+package election
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"token-points-system/internal/config"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/logger"
+)
+
+const (
+	defaultLeaseName    = "points-system"
+	defaultLeaseSeconds = 15
+	defaultRenewSeconds = 5
+)
+
+// Elector 基于数据库租约的主节点选举
+// 只有持有租约的节点运行调度器、链监听等单例任务；主节点宕机后，其他节点最迟在
+// lease_seconds + renew_seconds 内接管。主节点若在租约到期前无法续约，会主动退位，
+// 保证同一时刻最多只有一个节点在执行单例任务。
+type Elector struct {
+	leaseRepo *repository.LeaseRepository
+	name      string
+	nodeID    string
+	lease     time.Duration
+	renew     time.Duration
+	enabled   bool
+
+	leader atomic.Bool
+}
+
+// Status 选举状态
+type Status struct {
+	Enabled  bool   `json:"enabled"`
+	NodeID   string `json:"node_id"`
+	IsLeader bool   `json:"is_leader"`
+	Leader   string `json:"leader,omitempty"`
+}
+
+// NewElector 创建选举器，未启用选举时当前节点始终为主节点
+func NewElector(leaseRepo *repository.LeaseRepository, cfg *config.LeaderConfig) *Elector {
+	name := cfg.LeaseName
+	if name == "" {
+		name = defaultLeaseName
+	}
+
+	nodeID := cfg.NodeID
+	if nodeID == "" {
+		hostname, _ := os.Hostname()
+		nodeID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	leaseSeconds := cfg.LeaseSeconds
+	if leaseSeconds <= 0 {
+		leaseSeconds = defaultLeaseSeconds
+	}
+	renewSeconds := cfg.RenewSeconds
+	if renewSeconds <= 0 || renewSeconds*2 > leaseSeconds {
+		renewSeconds = leaseSeconds / 3
+		if renewSeconds == 0 {
+			renewSeconds = 1
+		}
+	}
+
+	return &Elector{
+		leaseRepo: leaseRepo,
+		name:      name,
+		nodeID:    nodeID,
+		lease:     time.Duration(leaseSeconds) * time.Second,
+		renew:     time.Duration(renewSeconds) * time.Second,
+		enabled:   cfg.Enabled,
+	}
+}
+
+// IsLeader 当前节点是否为主节点
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// NodeID 当前节点标识
+func (e *Elector) NodeID() string {
+	return e.nodeID
+}
+
+// Status 返回选举状态，leader为数据库中记录的有效租约持有者
+func (e *Elector) Status(ctx context.Context) (*Status, error) {
+	status := &Status{
+		Enabled:  e.enabled,
+		NodeID:   e.nodeID,
+		IsLeader: e.IsLeader(),
+	}
+	if !e.enabled {
+		status.Leader = e.nodeID
+		return status, nil
+	}
+
+	lease, err := e.leaseRepo.Get(ctx, e.name)
+	if err != nil {
+		return nil, err
+	}
+	if lease != nil && lease.ExpiresAt.After(time.Now()) {
+		status.Leader = lease.Holder
+	}
+	return status, nil
+}
+
+// Run 参与选举直到ctx结束
+// 每次成为主节点时以新的ctx调用lead，失去主节点身份时取消该ctx并等待lead返回后再继续竞选
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	if !e.enabled {
+		e.leader.Store(true)
+		lead(ctx)
+		e.leader.Store(false)
+		return
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"node_id":    e.nodeID,
+		"lease_name": e.name,
+		"lease":      e.lease.String(),
+		"renew":      e.renew.String(),
+	}).Info("参与主节点选举")
+
+	var (
+		leadCancel context.CancelFunc
+		leadWG     sync.WaitGroup
+		deadline   time.Time
+	)
+
+	stepDown := func(reason string) {
+		if leadCancel == nil {
+			return
+		}
+		leadCancel()
+		leadWG.Wait()
+		leadCancel = nil
+		e.leader.Store(false)
+		logger.WithFields(map[string]interface{}{
+			"node_id": e.nodeID,
+			"reason":  reason,
+		}).Warn("已退出主节点身份")
+	}
+
+	ticker := time.NewTicker(e.renew)
+	defer ticker.Stop()
+
+	for {
+		attemptedAt := time.Now()
+		acquired, err := e.tryAcquire(ctx)
+
+		switch {
+		case err != nil:
+			logger.Error("续约主节点租约失败:", err)
+			// 数据库不可用时无法确认租约归属，本地租约到期前退位，避免与新主节点重叠
+			if leadCancel != nil && time.Now().After(deadline) {
+				stepDown("租约续约失败且已到期")
+			}
+		case acquired:
+			// 以发起请求的时间计算本地到期时间，保守估计以抵消网络延迟
+			deadline = attemptedAt.Add(e.lease - e.renew)
+			if leadCancel == nil {
+				var leadCtx context.Context
+				leadCtx, leadCancel = context.WithCancel(ctx)
+				e.leader.Store(true)
+				logger.WithFields(map[string]interface{}{
+					"node_id": e.nodeID,
+				}).Info("当前节点成为主节点")
+
+				leadWG.Add(1)
+				go func() {
+					defer leadWG.Done()
+					lead(leadCtx)
+				}()
+			}
+		default:
+			stepDown("租约已被其他节点持有")
+		}
+
+		select {
+		case <-ctx.Done():
+			wasLeader := leadCancel != nil
+			stepDown("服务停止")
+			if wasLeader {
+				e.release()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) tryAcquire(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.renew)
+	defer cancel()
+	return e.leaseRepo.TryAcquire(ctx, e.name, e.nodeID, e.lease)
+}
+
+// release 主动释放租约，让其他节点立即接管
+func (e *Elector) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), e.renew)
+	defer cancel()
+	if err := e.leaseRepo.Release(ctx, e.name, e.nodeID); err != nil {
+		logger.Error("释放主节点租约失败:", err)
+	}
+}
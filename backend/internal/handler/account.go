@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/models"
+	"token-points-system/internal/service"
+)
+
+// ChallengeRequest 创建签名挑战的请求
+type ChallengeRequest struct {
+	Action  string `json:"action"`
+	Account string `json:"account"`
+	Wallet  string `json:"wallet"`
+}
+
+// LinkRequest 关联钱包的请求，需要账户钱包和被关联钱包双方的签名
+type LinkRequest struct {
+	Nonce            string `json:"nonce"`
+	Scheme           string `json:"scheme"`
+	AccountSignature string `json:"accountSignature"`
+	WalletSignature  string `json:"walletSignature"`
+}
+
+// UnlinkRequest 解除关联的请求，需要账户钱包的签名
+type UnlinkRequest struct {
+	Nonce     string `json:"nonce"`
+	Scheme    string `json:"scheme"`
+	Signature string `json:"signature"`
+}
+
+type AccountHandler struct {
+	accountSvc   *service.AccountService
+	aggregateSvc *service.AggregateService
+}
+
+func NewAccountHandler(accountSvc *service.AccountService, aggregateSvc *service.AggregateService) *AccountHandler {
+	return &AccountHandler{accountSvc: accountSvc, aggregateSvc: aggregateSvc}
+}
+
+// Register 注册账户与钱包关联接口
+func (h *AccountHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/accounts/{address}",
+		Tag:      "accounts",
+		Summary:  "账户（或单个地址）的跨链积分与余额",
+		Response: service.AggregatedPoints{},
+		Handler:  h.GetAccount,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/accounts/{address}/audit",
+		Tag:      "accounts",
+		Summary:  "账户的关联变更记录",
+		Query:    limitQuery,
+		Response: []models.AccountAuditLog{},
+		Handler:  h.GetAuditLog,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/accounts/challenge",
+		Tag:      "accounts",
+		Summary:  "创建签名挑战",
+		Scope:    models.ScopeLink,
+		Request:  ChallengeRequest{},
+		Response: service.AccountChallenge{},
+		Handler:  h.CreateChallenge,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/accounts/link",
+		Tag:      "accounts",
+		Summary:  "提交双方签名关联钱包",
+		Scope:    models.ScopeLink,
+		Request:  LinkRequest{},
+		Response: service.AggregatedPoints{},
+		Handler:  h.Link,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/accounts/unlink",
+		Tag:      "accounts",
+		Summary:  "提交账户钱包签名解除关联",
+		Scope:    models.ScopeLink,
+		Request:  UnlinkRequest{},
+		Response: service.AggregatedPoints{},
+		Handler:  h.Unlink,
+	})
+
+	rt.Alias(http.MethodGet, "/api/accounts/{address}", api.Prefix+"/accounts/{address}")
+	rt.Alias(http.MethodGet, "/api/accounts/{address}/audit", api.Prefix+"/accounts/{address}/audit")
+	rt.Alias(http.MethodPost, "/api/accounts/challenge", api.Prefix+"/accounts/challenge")
+	rt.Alias(http.MethodPost, "/api/accounts/link", api.Prefix+"/accounts/link")
+	rt.Alias(http.MethodPost, "/api/accounts/unlink", api.Prefix+"/accounts/unlink")
+}
+
+// GetAccount 处理 GET /api/v1/accounts/{address}
+func (h *AccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	aggregated, err := h.aggregateSvc.GetAggregatedPoints(r.Context(), r.PathValue("address"))
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, aggregated)
+}
+
+// CreateChallenge 处理 POST /api/v1/accounts/challenge
+func (h *AccountHandler) CreateChallenge(w http.ResponseWriter, r *http.Request) {
+	var req ChallengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	challenge, err := h.accountSvc.CreateChallenge(r.Context(), models.AccountAction(req.Action), req.Account, req.Wallet)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, challenge)
+}
+
+// Link 处理 POST /api/v1/accounts/link
+func (h *AccountHandler) Link(w http.ResponseWriter, r *http.Request) {
+	var req LinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	aggregated, err := h.accountSvc.Link(r.Context(), service.AccountSignatures{
+		Nonce:            req.Nonce,
+		Scheme:           models.SignatureScheme(req.Scheme),
+		AccountSignature: req.AccountSignature,
+		WalletSignature:  req.WalletSignature,
+	})
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, aggregated)
+}
+
+// Unlink 处理 POST /api/v1/accounts/unlink
+func (h *AccountHandler) Unlink(w http.ResponseWriter, r *http.Request) {
+	var req UnlinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	aggregated, err := h.accountSvc.Unlink(r.Context(), service.AccountSignatures{
+		Nonce:            req.Nonce,
+		Scheme:           models.SignatureScheme(req.Scheme),
+		AccountSignature: req.Signature,
+	})
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, aggregated)
+}
+
+// GetAuditLog 处理 GET /api/v1/accounts/{address}/audit?limit=20
+func (h *AccountHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	logs, err := h.accountSvc.GetAuditLog(r.Context(), r.PathValue("address"), limit)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, logs)
+}
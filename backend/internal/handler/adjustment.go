@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/models"
+	"token-points-system/internal/service"
+)
+
+// actorHeader 未启用API Key认证时标识发起管理操作的人员，仅用于本地开发
+const actorHeader = "X-Actor"
+
+// actorOf 返回发起操作的人员
+// 启用认证时只取调用方API Key的名称，忽略客户端传入的X-Actor头，避免同一调用方冒充他人审批；
+// 未启用认证（auth.disabled）时才读取X-Actor头
+func actorOf(r *http.Request) string {
+	if principal := api.PrincipalFrom(r); principal != nil {
+		return principal.Name
+	}
+	return r.Header.Get(actorHeader)
+}
+
+// requireActor 读取发起操作的人员，缺失时返回400
+func requireActor(w http.ResponseWriter, r *http.Request) (string, bool) {
+	actor := actorOf(r)
+	if actor == "" {
+		writeError(w, r, http.StatusBadRequest, "missing API key or "+actorHeader+" header")
+		return "", false
+	}
+	return actor, true
+}
+
+// AdjustmentRequest 人工调整积分的请求，amount为有符号数
+type AdjustmentRequest struct {
+	Chain         string  `json:"chain"`
+	Address       string  `json:"address"`
+	Amount        string  `json:"amount"`
+	ReasonCode    string  `json:"reasonCode"`
+	Note          string  `json:"note"`
+	ExpiresAt     string  `json:"expiresAt,omitempty"`
+	CalculationID *uint64 `json:"calculationId"`
+}
+
+// AdjustmentItem 人工调整记录
+type AdjustmentItem struct {
+	ID               uint64                  `json:"id"`
+	Chain            string                  `json:"chain"`
+	Address          string                  `json:"address"`
+	Amount           string                  `json:"amount"`
+	ReasonCode       string                  `json:"reasonCode"`
+	Note             string                  `json:"note"`
+	Status           models.AdjustmentStatus `json:"status"`
+	RequiresApproval bool                    `json:"requiresApproval"`
+	RequestedBy      string                  `json:"requestedBy"`
+	DecidedBy        string                  `json:"decidedBy"`
+	CreatedAt        string                  `json:"createdAt"`
+	DecidedAt        string                  `json:"decidedAt,omitempty"`
+	ExpiresAt        string                  `json:"expiresAt,omitempty"`
+	CalculationID    *uint64                 `json:"calculationId,omitempty"`
+}
+
+type AdjustmentHandler struct {
+	adjustmentSvc *service.AdjustmentService
+}
+
+func NewAdjustmentHandler(adjustmentSvc *service.AdjustmentService) *AdjustmentHandler {
+	return &AdjustmentHandler{adjustmentSvc: adjustmentSvc}
+}
+
+// Register 注册人工调整接口
+func (h *AdjustmentHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/admin/adjustments",
+		Scope:    models.ScopeRead,
+		Tag:      "admin",
+		Summary:  "按状态列出人工调整",
+		Query:    append([]api.Param{{Name: "status", Description: "pending、applied或rejected"}}, limitQuery...),
+		Response: []AdjustmentItem{},
+		Handler:  h.ListAdjustments,
+	})
+	rt.Handle(api.Route{
+		Method:      http.MethodPost,
+		Path:        api.Prefix + "/admin/adjustments",
+		Scope:       models.ScopeAdminAdjust,
+		Tag:         "admin",
+		Summary:     "创建人工调整",
+		Description: "超过审批阈值的调整进入pending状态并返回202，否则立即生效",
+		Request:     AdjustmentRequest{},
+		Response:    AdjustmentItem{},
+		Handler:     h.CreateAdjustment,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/admin/adjustments/{id}/approve",
+		Scope:    models.ScopeAdminAdjust,
+		Tag:      "admin",
+		Summary:  "批准人工调整",
+		Response: AdjustmentItem{},
+		Handler:  h.ApproveAdjustment,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/admin/adjustments/{id}/reject",
+		Scope:    models.ScopeAdminAdjust,
+		Tag:      "admin",
+		Summary:  "拒绝人工调整",
+		Response: AdjustmentItem{},
+		Handler:  h.RejectAdjustment,
+	})
+
+	rt.Alias(http.MethodGet, "/api/admin/adjustments", api.Prefix+"/admin/adjustments")
+	rt.Alias(http.MethodPost, "/api/admin/adjustments", api.Prefix+"/admin/adjustments")
+	rt.Alias(http.MethodPost, "/api/admin/adjustments/{id}/approve", api.Prefix+"/admin/adjustments/{id}/approve")
+	rt.Alias(http.MethodPost, "/api/admin/adjustments/{id}/reject", api.Prefix+"/admin/adjustments/{id}/reject")
+}
+
+// ApproveAdjustment 处理 POST /api/v1/admin/adjustments/{id}/approve
+func (h *AdjustmentHandler) ApproveAdjustment(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.adjustmentSvc.Approve)
+}
+
+// RejectAdjustment 处理 POST /api/v1/admin/adjustments/{id}/reject
+func (h *AdjustmentHandler) RejectAdjustment(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.adjustmentSvc.Reject)
+}
+
+func (h *AdjustmentHandler) decide(w http.ResponseWriter, r *http.Request, decide func(ctx context.Context, id uint64, approver string) (*models.PointsAdjustment, error)) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid adjustment id")
+		return
+	}
+
+	adj, err := decide(r.Context(), id, actorOf(r))
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, adjustmentItem(adj))
+}
+
+// CreateAdjustment 处理 POST /api/v1/admin/adjustments
+func (h *AdjustmentHandler) CreateAdjustment(w http.ResponseWriter, r *http.Request) {
+	var req AdjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid expiresAt, expected RFC3339 time")
+			return
+		}
+		expiresAt = &t
+	}
+
+	adj, err := h.adjustmentSvc.CreateAdjustment(r.Context(), service.AdjustmentRequest{
+		ChainID:       req.Chain,
+		UserAddress:   req.Address,
+		Amount:        req.Amount,
+		ReasonCode:    req.ReasonCode,
+		Note:          req.Note,
+		Actor:         actorOf(r),
+		ExpiresAt:     expiresAt,
+		CalculationID: req.CalculationID,
+	})
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	statusCode := http.StatusOK
+	if adj.Status == models.AdjustmentPending {
+		statusCode = http.StatusAccepted
+	}
+	writeJSON(w, statusCode, adjustmentItem(adj))
+}
+
+// ListAdjustments 处理 GET /api/v1/admin/adjustments?status=pending&limit=20
+func (h *AdjustmentHandler) ListAdjustments(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	status := models.AdjustmentStatus(r.URL.Query().Get("status"))
+	adjustments, err := h.adjustmentSvc.List(r.Context(), status, limit)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "failed to list adjustments: "+err.Error())
+		return
+	}
+
+	items := make([]AdjustmentItem, 0, len(adjustments))
+	for i := range adjustments {
+		items = append(items, adjustmentItem(&adjustments[i]))
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func adjustmentItem(a *models.PointsAdjustment) AdjustmentItem {
+	item := AdjustmentItem{
+		ID:               a.ID,
+		Chain:            a.ChainID,
+		Address:          a.UserAddress,
+		Amount:           a.Amount,
+		ReasonCode:       a.ReasonCode,
+		Note:             a.Note,
+		Status:           a.Status,
+		RequiresApproval: a.RequiresApproval,
+		RequestedBy:      a.RequestedBy,
+		DecidedBy:        a.DecidedBy,
+		CreatedAt:        a.CreatedAt.Format(time.RFC3339),
+		CalculationID:    a.CalculationID,
+	}
+	if a.DecidedAt != nil {
+		item.DecidedAt = a.DecidedAt.Format(time.RFC3339)
+	}
+	if a.ExpiresAt != nil {
+		item.ExpiresAt = a.ExpiresAt.Format(time.RFC3339)
+	}
+	return item
+}
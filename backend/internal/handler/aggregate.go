@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/service"
+)
+
+type AggregateHandler struct {
+	aggregateSvc *service.AggregateService
+}
+
+func NewAggregateHandler(aggregateSvc *service.AggregateService) *AggregateHandler {
+	return &AggregateHandler{aggregateSvc: aggregateSvc}
+}
+
+// Register 注册跨链积分接口
+func (h *AggregateHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/points/aggregate/{address}",
+		Tag:      "points",
+		Summary:  "各启用链上的积分与加权后的综合积分",
+		Response: service.AggregatedPoints{},
+		Handler:  h.GetAggregatedPoints,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/points/leaderboard",
+		Tag:      "points",
+		Summary:  "按跨链综合积分排名",
+		Query:    pageQuery,
+		Response: Page[service.LeaderboardEntry]{},
+		Handler:  h.GetLeaderboard,
+	})
+
+	rt.Alias(http.MethodGet, "/api/points/aggregate/{address}", api.Prefix+"/points/aggregate/{address}")
+	rt.Alias(http.MethodGet, "/api/points/leaderboard", api.Prefix+"/points/leaderboard")
+}
+
+// GetAggregatedPoints 处理 GET /api/v1/points/aggregate/{address}
+// 返回用户在各启用链上的积分与加权后的综合积分
+func (h *AggregateHandler) GetAggregatedPoints(w http.ResponseWriter, r *http.Request) {
+	aggregated, err := h.aggregateSvc.GetAggregatedPoints(r.Context(), r.PathValue("address"))
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, aggregated)
+}
+
+// GetLeaderboard 处理 GET /api/v1/points/leaderboard?page=1&page_size=20
+// 按跨链综合积分排名
+func (h *AggregateHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	entries, total, err := h.aggregateSvc.Leaderboard(r.Context(), (page-1)*pageSize, pageSize)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Page[service.LeaderboardEntry]{
+		Items:    entries,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
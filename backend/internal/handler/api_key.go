@@ -0,0 +1,307 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/models"
+	"token-points-system/internal/service"
+)
+
+// APIKeyCreateRequest 创建API Key的请求，expiresAt为RFC3339时间，为空表示不过期
+type APIKeyCreateRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt string   `json:"expiresAt,omitempty"`
+}
+
+// APIKeyItem API Key，不含密钥
+type APIKeyItem struct {
+	ID            uint64   `json:"id"`
+	Name          string   `json:"name"`
+	Prefix        string   `json:"prefix"`
+	Scopes        []string `json:"scopes"`
+	Active        bool     `json:"active"`
+	CreatedBy     string   `json:"createdBy"`
+	CreatedAt     string   `json:"createdAt"`
+	ExpiresAt     string   `json:"expiresAt,omitempty"`
+	RevokedAt     string   `json:"revokedAt,omitempty"`
+	LastUsedAt    string   `json:"lastUsedAt,omitempty"`
+	RotatedFromID *uint64  `json:"rotatedFromId,omitempty"`
+}
+
+// IssuedAPIKeyResponse 新创建或轮换得到的API Key，key只返回这一次
+type IssuedAPIKeyResponse struct {
+	APIKeyItem
+	Key string `json:"key"`
+}
+
+type APIKeyHandler struct {
+	keySvc *service.APIKeyService
+}
+
+func NewAPIKeyHandler(keySvc *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{keySvc: keySvc}
+}
+
+// Register 注册API Key管理接口
+func (h *APIKeyHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/admin/api-keys",
+		Tag:      "admin",
+		Summary:  "列出API Key",
+		Query:    pageQuery,
+		Scope:    models.ScopeAdminKeys,
+		Response: Page[APIKeyItem]{},
+		Handler:  h.ListKeys,
+	})
+	rt.Handle(api.Route{
+		Method:      http.MethodPost,
+		Path:        api.Prefix + "/admin/api-keys",
+		Tag:         "admin",
+		Summary:     "创建API Key",
+		Description: "scopes可选read、admin:recalc、admin:backup、admin:adjust、admin:keys、points:redeem、attestations:issue和accounts:link；完整的key只在响应中返回一次",
+		Request:     APIKeyCreateRequest{},
+		Scope:       models.ScopeAdminKeys,
+		Response:    IssuedAPIKeyResponse{},
+		Handler:     h.CreateKey,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/admin/api-keys/{id}",
+		Tag:      "admin",
+		Summary:  "查询API Key",
+		Scope:    models.ScopeAdminKeys,
+		Response: APIKeyItem{},
+		Handler:  h.GetKey,
+	})
+	rt.Handle(api.Route{
+		Method:      http.MethodPost,
+		Path:        api.Prefix + "/admin/api-keys/{id}/rotate",
+		Tag:         "admin",
+		Summary:     "轮换API Key",
+		Description: "生成名称、权限和过期时间相同的新Key，旧Key在auth.rotation_grace秒后过期",
+		Scope:       models.ScopeAdminKeys,
+		Response:    IssuedAPIKeyResponse{},
+		Handler:     h.RotateKey,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/admin/api-keys/{id}/revoke",
+		Tag:      "admin",
+		Summary:  "立即吊销API Key",
+		Scope:    models.ScopeAdminKeys,
+		Response: APIKeyItem{},
+		Handler:  h.RevokeKey,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/admin/api-keys/{id}/usage",
+		Tag:      "admin",
+		Summary:  "API Key的使用记录",
+		Query:    pageQuery,
+		Scope:    models.ScopeAdminKeys,
+		Response: Page[models.APIKeyUsage]{},
+		Handler:  h.ListUsage,
+	})
+}
+
+// Authenticate 实现api.Authenticator，校验请求携带的API Key
+func (h *APIKeyHandler) Authenticate(ctx context.Context, raw string) (*api.Principal, error) {
+	key, err := h.keySvc.Authenticate(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &api.Principal{KeyID: key.ID, Name: key.Name, Scopes: key.ScopeList()}, nil
+}
+
+// RecordUsage 实现api.Authenticator，保存API Key的使用记录
+func (h *APIKeyHandler) RecordUsage(ctx context.Context, usage api.Usage) {
+	h.keySvc.RecordUsage(ctx, &models.APIKeyUsage{
+		KeyID:      usage.KeyID,
+		Method:     usage.Method,
+		Route:      usage.Route,
+		Path:       usage.Path,
+		Scope:      usage.Scope,
+		Status:     usage.Status,
+		RemoteAddr: usage.RemoteAddr,
+		DurationMs: usage.Duration.Milliseconds(),
+	})
+}
+
+// ListKeys 处理 GET /api/v1/admin/api-keys?page=1&page_size=20
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	keys, total, err := h.keySvc.List(r.Context(), (page-1)*pageSize, pageSize)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	items := make([]APIKeyItem, 0, len(keys))
+	for i := range keys {
+		items = append(items, apiKeyItem(&keys[i]))
+	}
+
+	writeJSON(w, http.StatusOK, Page[APIKeyItem]{
+		Items:    items,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// CreateKey 处理 POST /api/v1/admin/api-keys
+func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var req APIKeyCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid expiresAt, expected RFC3339 time")
+			return
+		}
+		expiresAt = &t
+	}
+
+	issued, err := h.keySvc.Create(r.Context(), service.APIKeyRequest{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: expiresAt,
+		Actor:     actorOf(r),
+	})
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, IssuedAPIKeyResponse{APIKeyItem: apiKeyItem(issued.APIKey), Key: issued.Key})
+}
+
+// GetKey 处理 GET /api/v1/admin/api-keys/{id}
+func (h *APIKeyHandler) GetKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiKeyID(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := h.keySvc.Get(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiKeyItem(key))
+}
+
+// RotateKey 处理 POST /api/v1/admin/api-keys/{id}/rotate
+func (h *APIKeyHandler) RotateKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiKeyID(w, r)
+	if !ok {
+		return
+	}
+
+	issued, err := h.keySvc.Rotate(r.Context(), id, actorOf(r))
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, IssuedAPIKeyResponse{APIKeyItem: apiKeyItem(issued.APIKey), Key: issued.Key})
+}
+
+// RevokeKey 处理 POST /api/v1/admin/api-keys/{id}/revoke
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiKeyID(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := h.keySvc.Revoke(r.Context(), id, actorOf(r))
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiKeyItem(key))
+}
+
+// ListUsage 处理 GET /api/v1/admin/api-keys/{id}/usage?page=1&page_size=20
+func (h *APIKeyHandler) ListUsage(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiKeyID(w, r)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	usage, total, err := h.keySvc.ListUsage(r.Context(), id, (page-1)*pageSize, pageSize)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Page[models.APIKeyUsage]{
+		Items:    usage,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+func apiKeyID(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid api key id")
+		return 0, false
+	}
+	return id, true
+}
+
+func apiKeyItem(k *models.APIKey) APIKeyItem {
+	item := APIKeyItem{
+		ID:            k.ID,
+		Name:          k.Name,
+		Prefix:        k.Prefix,
+		Scopes:        k.ScopeList(),
+		Active:        k.Active(time.Now()),
+		CreatedBy:     k.CreatedBy,
+		CreatedAt:     k.CreatedAt.Format(time.RFC3339),
+		RotatedFromID: k.RotatedFromID,
+	}
+	if k.ExpiresAt != nil {
+		item.ExpiresAt = k.ExpiresAt.Format(time.RFC3339)
+	}
+	if k.RevokedAt != nil {
+		item.RevokedAt = k.RevokedAt.Format(time.RFC3339)
+	}
+	if k.LastUsedAt != nil {
+		item.LastUsedAt = k.LastUsedAt.Format(time.RFC3339)
+	}
+	return item
+}
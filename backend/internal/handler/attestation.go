@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/models"
+	"token-points-system/internal/service"
+)
+
+// AttestationIssueRequest 签发积分证明的请求，season为空时证明累计总积分，ttl单位为秒
+type AttestationIssueRequest struct {
+	Chain   string `json:"chain"`
+	Address string `json:"address"`
+	Season  string `json:"season"`
+	TTL     int64  `json:"ttl"`
+}
+
+// AttestationVerifyRequest 校验积分证明的请求，数值均为十进制字符串
+type AttestationVerifyRequest struct {
+	Account   string `json:"account"`
+	ChainID   string `json:"chainId"`
+	Points    string `json:"points"`
+	Season    string `json:"season"`
+	Nonce     string `json:"nonce"`
+	Expiry    string `json:"expiry"`
+	Signature string `json:"signature"`
+}
+
+type AttestationHandler struct {
+	attestationSvc *service.AttestationService
+}
+
+func NewAttestationHandler(attestationSvc *service.AttestationService) *AttestationHandler {
+	return &AttestationHandler{attestationSvc: attestationSvc}
+}
+
+// Register 注册积分证明接口，未配置签名私钥时均返回503
+// 签发记录包含可直接使用的签名，查询签发记录与签发一样需要attestations:issue权限
+func (h *AttestationHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/attestations",
+		Tag:      "attestations",
+		Summary:  "积分证明签发记录",
+		Scope:    models.ScopeAttest,
+		Query:    append([]api.Param{{Name: "address"}, {Name: "chain"}}, pageQuery...),
+		Response: Page[models.PointsAttestation]{},
+		Handler:  h.enabled(h.ListAttestations),
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/attestations",
+		Tag:      "attestations",
+		Summary:  "签发EIP-712积分证明",
+		Scope:    models.ScopeAttest,
+		Request:  AttestationIssueRequest{},
+		Response: service.SignedAttestation{},
+		Handler:  h.enabled(h.Issue),
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/attestations/signer",
+		Tag:      "attestations",
+		Summary:  "签名者地址和EIP-712签名域",
+		Response: service.AttestationSigner{},
+		Handler:  h.enabled(h.GetSigner),
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/attestations/verify",
+		Tag:      "attestations",
+		Summary:  "校验证明签名",
+		Request:  AttestationVerifyRequest{},
+		Response: service.AttestationVerification{},
+		Handler:  h.enabled(h.Verify),
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/attestations/{id}",
+		Tag:      "attestations",
+		Summary:  "查询签发记录",
+		Scope:    models.ScopeAttest,
+		Response: models.PointsAttestation{},
+		Handler:  h.enabled(h.GetAttestation),
+	})
+
+	rt.Alias(http.MethodGet, "/api/attestations", api.Prefix+"/attestations")
+	rt.Alias(http.MethodPost, "/api/attestations", api.Prefix+"/attestations")
+	rt.Alias(http.MethodGet, "/api/attestations/signer", api.Prefix+"/attestations/signer")
+	rt.Alias(http.MethodPost, "/api/attestations/verify", api.Prefix+"/attestations/verify")
+	rt.Alias(http.MethodGet, "/api/attestations/{id}", api.Prefix+"/attestations/{id}")
+}
+
+// ListAttestations 处理 GET /api/v1/attestations?address=0x...&chain=sepolia&page=1&page_size=20
+func (h *AttestationHandler) ListAttestations(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	attestations, total, err := h.attestationSvc.ListAttestations(r.Context(), r.URL.Query().Get("address"), r.URL.Query().Get("chain"), (page-1)*pageSize, pageSize)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Page[models.PointsAttestation]{
+		Items:    attestations,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// Issue 处理 POST /api/v1/attestations
+func (h *AttestationHandler) Issue(w http.ResponseWriter, r *http.Request) {
+	var req AttestationIssueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if req.TTL < 0 {
+		writeError(w, r, http.StatusBadRequest, "invalid ttl")
+		return
+	}
+
+	attestation, err := h.attestationSvc.Issue(r.Context(), service.AttestationRequest{
+		ChainID:     req.Chain,
+		Address:     req.Address,
+		Season:      req.Season,
+		TTL:         time.Duration(req.TTL) * time.Second,
+		RequestedBy: actorOf(r),
+	})
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, attestation)
+}
+
+// GetSigner 处理 GET /api/v1/attestations/signer
+func (h *AttestationHandler) GetSigner(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.attestationSvc.Signer())
+}
+
+// GetAttestation 处理 GET /api/v1/attestations/{id}
+func (h *AttestationHandler) GetAttestation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid attestation id")
+		return
+	}
+
+	attestation, err := h.attestationSvc.GetAttestation(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, attestation)
+}
+
+// Verify 处理 POST /api/v1/attestations/verify
+func (h *AttestationHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	var req AttestationVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	result, err := h.attestationSvc.Verify(r.Context(), service.AttestationVerifyRequest{
+		Account:   req.Account,
+		ChainID:   req.ChainID,
+		Points:    req.Points,
+		Season:    req.Season,
+		Nonce:     req.Nonce,
+		Expiry:    req.Expiry,
+		Signature: req.Signature,
+	})
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// enabled 未配置签名私钥时返回503
+func (h *AttestationHandler) enabled(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.attestationSvc.Enabled() {
+			writeError(w, r, http.StatusServiceUnavailable, "attestation signer is not configured")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,358 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/config"
+	"token-points-system/internal/jobs"
+	"token-points-system/internal/models"
+	"token-points-system/internal/service"
+)
+
+// BackupItem 备份记录
+type BackupItem struct {
+	ID           uint64                    `json:"id"`
+	Chain        string                    `json:"chain"`
+	BlockHeight  int64                     `json:"blockHeight"`
+	Checksum     string                    `json:"checksum"`
+	Trigger      models.BackupTrigger      `json:"trigger"`
+	BalanceCount int                       `json:"balanceCount"`
+	PointsCount  int                       `json:"pointsCount"`
+	CreatedBy    string                    `json:"createdBy"`
+	CreatedAt    string                    `json:"createdAt"`
+	VerifyStatus models.BackupVerifyStatus `json:"verifyStatus"`
+	// Flagged 备份已损坏或无法校验，不应作为恢复点
+	Flagged    bool   `json:"flagged"`
+	VerifyNote string `json:"verifyNote,omitempty"`
+	VerifiedAt string `json:"verifiedAt,omitempty"`
+	RestoredAt string `json:"restoredAt,omitempty"`
+	RestoredBy string `json:"restoredBy,omitempty"`
+}
+
+// ChainRequest 只包含链ID的请求
+type ChainRequest struct {
+	Chain string `json:"chain"`
+}
+
+// BackupAccepted 已提交的备份任务
+type BackupAccepted struct {
+	JobAccepted
+	CreatedAt string `json:"createdAt"`
+}
+
+// RestoreRequest 旧接口的恢复请求
+type RestoreRequest struct {
+	BackupID uint64 `json:"backupId"`
+}
+
+// RestoreAccepted 已提交的恢复任务
+type RestoreAccepted struct {
+	JobAccepted
+	BackupID    uint64 `json:"backupId"`
+	BlockHeight int64  `json:"blockHeight"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+// RebuildRequest 重建请求
+type RebuildRequest struct {
+	Chain     string `json:"chain"`
+	Apply     bool   `json:"apply"`
+	AllowGaps bool   `json:"allowGaps"`
+}
+
+// RebuildAccepted 已提交的重建任务
+type RebuildAccepted struct {
+	JobAccepted
+	Apply     bool   `json:"apply"`
+	AllowGaps bool   `json:"allowGaps"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type BackupHandler struct {
+	recoverySvc *service.RecoveryService
+	jobManager  *jobs.Manager
+	cfg         *config.Config
+}
+
+func NewBackupHandler(recoverySvc *service.RecoveryService, jobManager *jobs.Manager, cfg *config.Config) *BackupHandler {
+	return &BackupHandler{recoverySvc: recoverySvc, jobManager: jobManager, cfg: cfg}
+}
+
+// Register 注册备份、恢复与重建接口
+func (h *BackupHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/admin/backups",
+		Scope:    models.ScopeRead,
+		Tag:      "admin",
+		Summary:  "分页列出备份",
+		Query:    append([]api.Param{{Name: "chain"}, {Name: "status", Description: "unverified、verified、corrupted或unverifiable"}}, pageQuery...),
+		Response: Page[BackupItem]{},
+		Handler:  h.ListBackups,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/admin/backups",
+		Scope:    models.ScopeAdminBackup,
+		Tag:      "admin",
+		Summary:  "提交备份任务",
+		Request:  ChainRequest{},
+		Response: BackupAccepted{},
+		Status:   http.StatusAccepted,
+		Handler:  h.CreateBackup,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/admin/backups/{id}",
+		Scope:    models.ScopeRead,
+		Tag:      "admin",
+		Summary:  "查询备份",
+		Response: BackupItem{},
+		Handler:  h.GetBackup,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/admin/backups/{id}/verify",
+		Scope:    models.ScopeAdminBackup,
+		Tag:      "admin",
+		Summary:  "校验备份并记录结果",
+		Response: service.BackupVerifyReport{},
+		Handler:  h.VerifyBackup,
+	})
+	rt.Handle(api.Route{
+		Method:      http.MethodPost,
+		Path:        api.Prefix + "/admin/backups/{id}/restore",
+		Scope:       models.ScopeAdminBackup,
+		Tag:         "admin",
+		Summary:     "提交恢复任务",
+		Description: "恢复以任务形式在主节点执行，操作人为API Key的名称",
+		Response:    RestoreAccepted{},
+		Status:      http.StatusAccepted,
+		Handler:     h.RestoreBackup,
+	})
+	rt.Handle(api.Route{
+		Method:      http.MethodPost,
+		Path:        api.Prefix + "/admin/rebuild",
+		Scope:       models.ScopeAdminRecalc,
+		Tag:         "admin",
+		Summary:     "提交重建任务",
+		Description: "按余额历史重放余额、按积分流水汇总积分到影子表并校验；apply为true且校验通过时替换正式表。操作人为API Key的名称",
+		Request:     RebuildRequest{},
+		Response:    RebuildAccepted{},
+		Status:      http.StatusAccepted,
+		Handler:     h.RebuildState,
+	})
+
+	rt.Alias(http.MethodPost, "/api/backup", api.Prefix+"/admin/backups")
+	rt.Alias(http.MethodGet, "/api/backups", api.Prefix+"/admin/backups")
+	rt.Alias(http.MethodGet, "/api/backups/{id}", api.Prefix+"/admin/backups/{id}")
+	rt.Alias(http.MethodPost, "/api/backups/{id}/verify", api.Prefix+"/admin/backups/{id}/verify")
+	rt.Deprecated(api.Route{
+		Method:   http.MethodPost,
+		Path:     "/api/backup/restore",
+		Scope:    models.ScopeAdminBackup,
+		Tag:      "admin",
+		Summary:  "提交恢复任务",
+		Request:  RestoreRequest{},
+		Response: RestoreAccepted{},
+		Status:   http.StatusAccepted,
+		Handler:  h.legacyRestoreBackup,
+	}, api.Prefix+"/admin/backups/{id}/restore")
+	rt.Alias(http.MethodPost, "/api/admin/rebuild", api.Prefix+"/admin/rebuild")
+}
+
+// CreateBackup 处理 POST /api/v1/admin/backups
+func (h *BackupHandler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	var req ChainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	if _, err := h.cfg.GetChainConfig(req.Chain); err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	job, err := h.jobManager.Submit(r.Context(), models.JobTypeBackup, service.BackupParams{ChainID: req.Chain}, actorOf(r))
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, BackupAccepted{
+		JobAccepted: JobAccepted{Message: "backup job submitted", JobID: job.ID, Chain: req.Chain},
+		CreatedAt:   job.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+// ListBackups 处理 GET /api/v1/admin/backups?chain=ethereum&status=corrupted&page=1&page_size=20
+func (h *BackupHandler) ListBackups(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	backups, total, err := h.recoverySvc.ListBackups(r.Context(), r.URL.Query().Get("chain"), models.BackupVerifyStatus(r.URL.Query().Get("status")), (page-1)*pageSize, pageSize)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "failed to list backups: "+err.Error())
+		return
+	}
+
+	items := make([]BackupItem, 0, len(backups))
+	for i := range backups {
+		items = append(items, backupItem(&backups[i]))
+	}
+
+	writeJSON(w, http.StatusOK, Page[BackupItem]{
+		Items:    items,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// GetBackup 处理 GET /api/v1/admin/backups/{id}
+func (h *BackupHandler) GetBackup(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid backup id")
+		return
+	}
+
+	backup, err := h.recoverySvc.GetBackup(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, backupItem(backup))
+}
+
+// VerifyBackup 处理 POST /api/v1/admin/backups/{id}/verify
+func (h *BackupHandler) VerifyBackup(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid backup id")
+		return
+	}
+
+	report, err := h.recoverySvc.VerifyBackup(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// RestoreBackup 处理 POST /api/v1/admin/backups/{id}/restore，恢复以任务形式在主节点执行
+func (h *BackupHandler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid backup id")
+		return
+	}
+	h.restore(w, r, id)
+}
+
+// legacyRestoreBackup 处理 POST /api/backup/restore，备份ID在请求体中
+func (h *BackupHandler) legacyRestoreBackup(w http.ResponseWriter, r *http.Request) {
+	var req RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	h.restore(w, r, req.BackupID)
+}
+
+func (h *BackupHandler) restore(w http.ResponseWriter, r *http.Request, id uint64) {
+	actor, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+
+	backup, err := h.recoverySvc.GetBackup(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	job, err := h.jobManager.Submit(r.Context(), models.JobTypeRestore, service.RestoreParams{BackupID: backup.ID}, actor)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, RestoreAccepted{
+		JobAccepted: JobAccepted{Message: "restore job submitted", JobID: job.ID, Chain: backup.ChainID},
+		BackupID:    backup.ID,
+		BlockHeight: backup.BlockHeight,
+		CreatedAt:   job.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+// RebuildState 处理 POST /api/v1/admin/rebuild
+// 提交重建任务：按余额历史重放余额、按积分流水汇总积分到影子表并校验；apply为true且校验通过时替换正式表
+func (h *BackupHandler) RebuildState(w http.ResponseWriter, r *http.Request) {
+	var req RebuildRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	actor, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.cfg.GetChainConfig(req.Chain); err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	params := service.StateRebuildParams{ChainID: req.Chain, Apply: req.Apply, AllowGaps: req.AllowGaps}
+	job, err := h.jobManager.Submit(r.Context(), models.JobTypeRebuild, params, actor)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, RebuildAccepted{
+		JobAccepted: JobAccepted{Message: "rebuild job submitted", JobID: job.ID, Chain: req.Chain},
+		Apply:       req.Apply,
+		AllowGaps:   req.AllowGaps,
+		CreatedAt:   job.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+func backupItem(b *models.CalculationBackup) BackupItem {
+	item := BackupItem{
+		ID:           b.ID,
+		Chain:        b.ChainID,
+		BlockHeight:  b.BlockHeight,
+		Checksum:     b.Checksum,
+		Trigger:      b.Trigger,
+		BalanceCount: b.BalanceCount,
+		PointsCount:  b.PointsCount,
+		CreatedBy:    b.CreatedBy,
+		CreatedAt:    b.CreatedAt.Format(time.RFC3339),
+		VerifyStatus: b.VerifyStatus,
+		Flagged:      b.VerifyStatus == models.BackupCorrupted || b.VerifyStatus == models.BackupUnverifiable,
+		VerifyNote:   b.VerifyNote,
+	}
+	if b.VerifiedAt != nil {
+		item.VerifiedAt = b.VerifiedAt.Format(time.RFC3339)
+	}
+	if b.RestoredAt != nil {
+		item.RestoredAt = b.RestoredAt.Format(time.RFC3339)
+		item.RestoredBy = b.RestoredBy
+	}
+	return item
+}
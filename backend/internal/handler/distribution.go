@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/models"
+	"token-points-system/internal/service"
+)
+
+// DistributionCreateRequest 创建空投分配的请求
+// source为chain、season或campaign；ref为赛季ID或活动ID；budget和规则中的数量为代币最小单位
+type DistributionCreateRequest struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Ref    string `json:"ref"`
+	Chain  string `json:"chain"`
+	Budget string `json:"budget"`
+	Rules  struct {
+		MinPoints string `json:"minPoints"`
+		MinAmount string `json:"minAmount"`
+		MaxAmount string `json:"maxAmount"`
+	} `json:"rules"`
+}
+
+// DistributionAllocationsResponse 分配明细的一页
+type DistributionAllocationsResponse struct {
+	Distribution service.DistributionView        `json:"distribution"`
+	Items        []models.DistributionAllocation `json:"items"`
+	Total        int64                           `json:"total"`
+	Page         int                             `json:"page"`
+	PageSize     int                             `json:"pageSize"`
+}
+
+type DistributionHandler struct {
+	distributionSvc *service.DistributionService
+}
+
+func NewDistributionHandler(distributionSvc *service.DistributionService) *DistributionHandler {
+	return &DistributionHandler{distributionSvc: distributionSvc}
+}
+
+// Register 注册空投分配接口
+func (h *DistributionHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:      http.MethodPost,
+		Path:        api.Prefix + "/admin/distributions",
+		Scope:       models.ScopeAdminAdjust,
+		Tag:         "admin",
+		Summary:     "按积分生成Merkle空投分配",
+		Description: "操作人为API Key的名称",
+		Request:     DistributionCreateRequest{},
+		Response:    service.DistributionView{},
+		Handler:     h.CreateDistribution,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/distributions",
+		Tag:      "distributions",
+		Summary:  "分页列出空投分配",
+		Query:    pageQuery,
+		Response: Page[service.DistributionView]{},
+		Handler:  h.ListDistributions,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/distributions/{id}",
+		Tag:      "distributions",
+		Summary:  "查询空投分配",
+		Response: service.DistributionView{},
+		Handler:  h.GetDistribution,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/distributions/{id}/allocations",
+		Tag:      "distributions",
+		Summary:  "分页列出分配明细",
+		Query:    pageQuery,
+		Response: DistributionAllocationsResponse{},
+		Handler:  h.GetAllocations,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/distributions/{id}/proof/{address}",
+		Tag:      "distributions",
+		Summary:  "地址的分配数量和Merkle证明",
+		Response: service.DistributionProof{},
+		Handler:  h.GetProof,
+	})
+
+	rt.Alias(http.MethodPost, "/api/admin/distributions", api.Prefix+"/admin/distributions")
+	rt.Alias(http.MethodGet, "/api/distributions", api.Prefix+"/distributions")
+	rt.Alias(http.MethodGet, "/api/distributions/{id}", api.Prefix+"/distributions/{id}")
+	rt.Alias(http.MethodGet, "/api/distributions/{id}/allocations", api.Prefix+"/distributions/{id}/allocations")
+	rt.Alias(http.MethodGet, "/api/distributions/{id}/proof/{address}", api.Prefix+"/distributions/{id}/proof/{address}")
+}
+
+// CreateDistribution 处理 POST /api/v1/admin/distributions
+func (h *DistributionHandler) CreateDistribution(w http.ResponseWriter, r *http.Request) {
+	var req DistributionCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	actor, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+
+	distribution, err := h.distributionSvc.CreateDistribution(r.Context(), service.DistributionRequest{
+		Name:      req.Name,
+		Source:    models.DistributionSource(req.Source),
+		SourceRef: req.Ref,
+		ChainID:   req.Chain,
+		Budget:    req.Budget,
+		Rules: service.DistributionRules{
+			MinPoints: req.Rules.MinPoints,
+			MinAmount: req.Rules.MinAmount,
+			MaxAmount: req.Rules.MaxAmount,
+		},
+	}, actor)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, distribution)
+}
+
+// ListDistributions 处理 GET /api/v1/distributions?page=1&page_size=20
+func (h *DistributionHandler) ListDistributions(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	distributions, total, err := h.distributionSvc.ListDistributions(r.Context(), (page-1)*pageSize, pageSize)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Page[service.DistributionView]{
+		Items:    distributions,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// GetDistribution 处理 GET /api/v1/distributions/{id}
+func (h *DistributionHandler) GetDistribution(w http.ResponseWriter, r *http.Request) {
+	id, ok := distributionID(w, r)
+	if !ok {
+		return
+	}
+
+	distribution, err := h.distributionSvc.GetDistribution(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, distribution)
+}
+
+// GetAllocations 处理 GET /api/v1/distributions/{id}/allocations?page=1&page_size=20
+func (h *DistributionHandler) GetAllocations(w http.ResponseWriter, r *http.Request) {
+	id, ok := distributionID(w, r)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	result, err := h.distributionSvc.Allocations(r.Context(), id, (page-1)*pageSize, pageSize)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, DistributionAllocationsResponse{
+		Distribution: result.Distribution,
+		Items:        result.Items,
+		Total:        result.Distribution.Recipients,
+		Page:         page,
+		PageSize:     pageSize,
+	})
+}
+
+// GetProof 处理 GET /api/v1/distributions/{id}/proof/{address}
+func (h *DistributionHandler) GetProof(w http.ResponseWriter, r *http.Request) {
+	id, ok := distributionID(w, r)
+	if !ok {
+		return
+	}
+
+	proof, err := h.distributionSvc.Proof(r.Context(), id, r.PathValue("address"))
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, proof)
+}
+
+func distributionID(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid distribution id")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/service"
+)
+
+// ExpirationsResponse 即将过期的积分
+type ExpirationsResponse struct {
+	Chain       string                       `json:"chain"`
+	Address     string                       `json:"address"`
+	Days        int                          `json:"days"`
+	Enabled     bool                         `json:"enabled"`
+	Expirations []service.UpcomingExpiration `json:"expirations"`
+}
+
+type ExpiryHandler struct {
+	expirySvc *service.ExpiryService
+}
+
+func NewExpiryHandler(expirySvc *service.ExpiryService) *ExpiryHandler {
+	return &ExpiryHandler{expirySvc: expirySvc}
+}
+
+// Register 注册积分过期接口
+func (h *ExpiryHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/points/{chain}/{address}/expirations",
+		Tag:      "points",
+		Summary:  "即将过期的积分",
+		Query:    []api.Param{{Name: "days", Type: "integer", Description: "查询未来多少天内过期的积分，默认30，最大365"}},
+		Response: ExpirationsResponse{},
+		Handler:  h.GetUpcomingExpirations,
+	})
+
+	rt.Alias(http.MethodGet, "/api/points/expirations/{chain}/{address}", api.Prefix+"/points/{chain}/{address}/expirations")
+}
+
+// GetUpcomingExpirations 处理 GET /api/v1/points/{chain}/{address}/expirations?days=30
+func (h *ExpiryHandler) GetUpcomingExpirations(w http.ResponseWriter, r *http.Request) {
+	chainID := r.PathValue("chain")
+	userAddress := r.PathValue("address")
+
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days < 1 || days > 365 {
+		days = 30
+	}
+
+	upcoming, err := h.expirySvc.UpcomingExpirations(r.Context(), chainID, userAddress, days)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "failed to get expirations: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ExpirationsResponse{
+		Chain:       chainID,
+		Address:     userAddress,
+		Days:        days,
+		Enabled:     h.expirySvc.Enabled(),
+		Expirations: upcoming,
+	})
+}
@@ -5,10 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
+	"token-points-system/internal/api"
 	"token-points-system/internal/config"
+	"token-points-system/internal/jobs"
 	"token-points-system/internal/models"
 	"token-points-system/internal/repository"
 	"token-points-system/internal/scheduler"
@@ -16,13 +17,49 @@ import (
 )
 
 func writeJSON(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(data)
+	api.WriteJSON(w, statusCode, data)
 }
 
-func writeError(w http.ResponseWriter, statusCode int, message string) {
-	writeJSON(w, statusCode, map[string]string{"error": message})
+// writeError 写出错误响应，错误码由HTTP状态码决定
+func writeError(w http.ResponseWriter, r *http.Request, statusCode int, message string) {
+	api.WriteError(w, r, statusCode, api.CodeOf(statusCode), message)
+}
+
+// writeAppError 根据业务错误码映射HTTP状态码
+func writeAppError(w http.ResponseWriter, r *http.Request, err error) {
+	api.WriteAppError(w, r, err)
+}
+
+// Page 分页列表
+type Page[T any] struct {
+	Items    []T   `json:"items"`
+	Total    int64 `json:"total"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"pageSize"`
+}
+
+// pageQuery page与page_size查询参数的文档
+var pageQuery = []api.Param{
+	{Name: "page", Type: "integer", Description: "页码，从1开始"},
+	{Name: "page_size", Type: "integer", Description: "每页条数，默认20，最大100"},
+}
+
+// limitQuery limit查询参数的文档
+var limitQuery = []api.Param{{Name: "limit", Type: "integer", Description: "返回条数，默认20，最大100"}}
+
+// BalanceResponse 当前余额
+type BalanceResponse struct {
+	Balance   string `json:"balance"`
+	Chain     string `json:"chain"`
+	Address   string `json:"address"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// HoldersResponse 持有人快照的一页
+type HoldersResponse struct {
+	*service.HolderSnapshot
+	Page     int `json:"page"`
+	PageSize int `json:"pageSize"`
 }
 
 type BalanceHandler struct {
@@ -33,50 +70,110 @@ func NewBalanceHandler(balanceSvc *service.BalanceService) *BalanceHandler {
 	return &BalanceHandler{balanceSvc: balanceSvc}
 }
 
-func (h *BalanceHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
-		return
-	}
-
-	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(pathParts) < 4 {
-		writeError(w, http.StatusBadRequest, "invalid path format, expected /api/balance/{chain_id}/{address}")
-		return
-	}
+// Register 注册余额与持有人接口
+func (h *BalanceHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/balances",
+		Tag:      "balances",
+		Summary:  "分页列出链上余额",
+		Query:    append([]api.Param{{Name: "chain_id", Required: true}}, pageQuery...),
+		Response: Page[models.UserBalance]{},
+		Handler:  h.ListBalances,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/balances/{chain}/{address}",
+		Tag:      "balances",
+		Summary:  "查询当前余额",
+		Response: BalanceResponse{},
+		Handler:  h.GetBalance,
+	})
+	rt.Handle(api.Route{
+		Method:  http.MethodGet,
+		Path:    api.Prefix + "/balances/{chain}/{address}/at",
+		Tag:     "balances",
+		Summary: "查询历史区块或时间点的余额",
+		Query: []api.Param{
+			{Name: "block", Type: "integer", Description: "区块高度，与at二选一"},
+			{Name: "at", Description: "RFC3339时间，与block二选一"},
+		},
+		Response: service.PointInTimeBalance{},
+		Handler:  h.GetBalanceAt,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/holders/{chain}",
+		Tag:      "balances",
+		Summary:  "指定区块处理完成时的持有人",
+		Query:    append(holderQueryParams, pageQuery...),
+		Response: HoldersResponse{},
+		Handler:  h.GetHolders,
+	})
+	rt.Handle(api.Route{
+		Method:      http.MethodGet,
+		Path:        api.Prefix + "/holders/{chain}/export",
+		Tag:         "balances",
+		Summary:     "以CSV导出全部持有人",
+		Query:       holderQueryParams,
+		ContentType: "text/csv",
+		Handler:     h.ExportHolders,
+	})
 
-	chainID := pathParts[2]
-	userAddress := pathParts[3]
+	rt.Alias(http.MethodGet, "/api/balance/list", api.Prefix+"/balances")
+	rt.Deprecated(api.Route{
+		Method:   http.MethodGet,
+		Path:     "/api/balance/{chain}/{address}",
+		Tag:      "balances",
+		Summary:  "查询余额，带block或at参数时查询历史余额",
+		Response: BalanceResponse{},
+		Handler:  h.legacyGetBalance,
+	}, api.Prefix+"/balances/{chain}/{address}")
+	rt.Deprecated(api.Route{
+		Method:   http.MethodGet,
+		Path:     "/api/holders/{chain}",
+		Tag:      "balances",
+		Summary:  "持有人快照，format=csv时导出全部持有人",
+		Response: HoldersResponse{},
+		Handler:  h.legacyGetHolders,
+	}, api.Prefix+"/holders/{chain}")
+}
 
-	if chainID == "" || userAddress == "" {
-		writeError(w, http.StatusBadRequest, "chain_id and address are required")
-		return
-	}
+// GetBalance 处理 GET /api/v1/balances/{chain}/{address}
+func (h *BalanceHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
+	chainID := r.PathValue("chain")
+	userAddress := r.PathValue("address")
 
 	ctx := r.Context()
 	balance, err := h.balanceSvc.GetUserBalance(ctx, chainID, userAddress)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to get balance: "+err.Error())
+		writeError(w, r, http.StatusInternalServerError, "failed to get balance: "+err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"balance":   balance,
-		"chain":     chainID,
-		"address":   userAddress,
-		"updatedAt": time.Now().Format(time.RFC3339),
+	writeJSON(w, http.StatusOK, BalanceResponse{
+		Balance:   balance,
+		Chain:     chainID,
+		Address:   userAddress,
+		UpdatedAt: time.Now().Format(time.RFC3339),
 	})
 }
 
-func (h *BalanceHandler) ListBalances(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+// legacyGetBalance 处理 GET /api/balance/{chain}/{address}，?block=123 或 ?at=RFC3339 查询历史时间点的余额
+func (h *BalanceHandler) legacyGetBalance(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("block") != "" || query.Get("at") != "" {
+		h.GetBalanceAt(w, r)
 		return
 	}
+	h.GetBalance(w, r)
+}
 
+// ListBalances 处理 GET /api/v1/balances?chain_id=sepolia&page=1&page_size=20
+func (h *BalanceHandler) ListBalances(w http.ResponseWriter, r *http.Request) {
 	chainID := r.URL.Query().Get("chain_id")
 	if chainID == "" {
-		writeError(w, http.StatusBadRequest, "chain_id is required")
+		writeError(w, r, http.StatusBadRequest, "chain_id is required")
 		return
 	}
 
@@ -94,24 +191,61 @@ func (h *BalanceHandler) ListBalances(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	balances, err := h.balanceSvc.ListBalances(ctx, chainID, offset, pageSize)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to list balances: "+err.Error())
+		writeError(w, r, http.StatusInternalServerError, "failed to list balances: "+err.Error())
 		return
 	}
 
 	total, err := h.balanceSvc.CountBalances(ctx, chainID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to count balances: "+err.Error())
+		writeError(w, r, http.StatusInternalServerError, "failed to count balances: "+err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"items":    balances,
-		"total":    total,
-		"page":     page,
-		"pageSize": pageSize,
+	writeJSON(w, http.StatusOK, Page[models.UserBalance]{
+		Items:    balances,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
 	})
 }
 
+// PointsResponse 积分概览
+type PointsResponse struct {
+	TotalPoints      string `json:"totalPoints"`
+	AvailablePoints  string `json:"availablePoints"`
+	ReservedPoints   string `json:"reservedPoints"`
+	RedeemedPoints   string `json:"redeemedPoints"`
+	LifetimePoints   string `json:"lifetimePoints"`
+	Chain            string `json:"chain"`
+	Address          string `json:"address"`
+	LastCalculatedAt string `json:"lastCalculatedAt"`
+}
+
+// PointsAtResponse 历史时间点的积分
+type PointsAtResponse struct {
+	TotalPoints string `json:"totalPoints"`
+	Chain       string `json:"chain"`
+	Address     string `json:"address"`
+	At          string `json:"at"`
+}
+
+// PointsHistoryResponse 最近每天发放的积分
+type PointsHistoryResponse struct {
+	Labels []string  `json:"labels"`
+	Values []float64 `json:"values"`
+}
+
+// LedgerItem 积分流水
+type LedgerItem struct {
+	EntryType   models.LedgerEntryType `json:"entryType"`
+	Amount      string                 `json:"amount"`
+	SourceType  string                 `json:"sourceType"`
+	SourceRef   string                 `json:"sourceRef"`
+	Memo        string                 `json:"memo"`
+	EffectiveAt string                 `json:"effectiveAt"`
+	CreatedAt   string                 `json:"createdAt"`
+}
+
 type PointsHandler struct {
 	pointsSvc  *service.PointsService
 	pointsRepo *repository.PointsRepository
@@ -122,50 +256,185 @@ func NewPointsHandler(pointsSvc *service.PointsService, pointsRepo *repository.P
 	return &PointsHandler{pointsSvc: pointsSvc, pointsRepo: pointsRepo, calcRepo: calcRepo}
 }
 
+// Register 注册积分查询与规则模拟接口
+func (h *PointsHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/points",
+		Tag:      "points",
+		Summary:  "分页列出链上积分",
+		Query:    append([]api.Param{{Name: "chain_id", Required: true}}, pageQuery...),
+		Response: Page[models.UserPoints]{},
+		Handler:  h.ListPoints,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/points/daily",
+		Tag:      "points",
+		Summary:  "最近每天发放的积分",
+		Query:    []api.Param{{Name: "days", Type: "integer", Description: "天数，默认7，最大30"}},
+		Response: PointsHistoryResponse{},
+		Handler:  h.GetPointsHistory,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/points/{chain}/{address}",
+		Tag:      "points",
+		Summary:  "查询积分概览",
+		Response: PointsResponse{},
+		Handler:  h.GetPoints,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/points/{chain}/{address}/at",
+		Tag:      "points",
+		Summary:  "查询历史时间点的积分",
+		Query:    []api.Param{{Name: "at", Description: "RFC3339时间", Required: true}},
+		Response: PointsAtResponse{},
+		Handler:  h.GetPointsAt,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/points/{chain}/{address}/ledger",
+		Tag:      "points",
+		Summary:  "积分流水",
+		Query:    limitQuery,
+		Response: []LedgerItem{},
+		Handler:  h.GetLedger,
+	})
+	rt.Handle(api.Route{
+		Method:      http.MethodGet,
+		Path:        api.Prefix + "/points/{chain}/{address}/activity",
+		Tag:         "points",
+		Summary:     "积分变动记录",
+		Description: "按时间倒序合并周期计算与人工调整记录",
+		Query:       limitQuery,
+		Response:    []service.PointsActivity{},
+		Handler:     h.GetPointsActivity,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/points/{chain}/{address}/breakdown",
+		Tag:      "points",
+		Summary:  "包含指定时刻的周期计算明细",
+		Query:    []api.Param{{Name: "at", Description: "RFC3339时间", Required: true}},
+		Response: service.PointsBreakdown{},
+		Handler:  h.GetPointsBreakdown,
+	})
+	rt.Handle(api.Route{
+		Method:      http.MethodPost,
+		Path:        api.Prefix + "/admin/points/simulate",
+		Scope:       models.ScopeAdminRecalc,
+		Tag:         "admin",
+		Summary:     "模拟候选积分规则",
+		Description: "用候选规则重新计算历史窗口内的积分并与当前规则比较，不写入任何数据",
+		Request:     SimulateRulesRequest{},
+		Response:    service.SimulationReport{},
+		Handler:     h.SimulateRules,
+	})
+	rt.Handle(api.Route{
+		Method:      http.MethodPost,
+		Path:        api.Prefix + "/admin/points/simulate/export",
+		Scope:       models.ScopeAdminRecalc,
+		Tag:         "admin",
+		Summary:     "以CSV导出模拟结果中每个用户的差异",
+		Request:     SimulateRulesRequest{},
+		ContentType: "text/csv",
+		Handler:     h.ExportSimulation,
+	})
+
+	rt.Alias(http.MethodGet, "/api/points/list", api.Prefix+"/points")
+	rt.Alias(http.MethodGet, "/api/points/history", api.Prefix+"/points/daily")
+	rt.Deprecated(api.Route{
+		Method:   http.MethodGet,
+		Path:     "/api/points/{chain}/{address}",
+		Tag:      "points",
+		Summary:  "查询积分概览，带at参数时查询历史时间点的积分",
+		Response: PointsResponse{},
+		Handler:  h.legacyGetPoints,
+	}, api.Prefix+"/points/{chain}/{address}")
+	rt.Alias(http.MethodGet, "/api/ledger/{chain}/{address}", api.Prefix+"/points/{chain}/{address}/ledger")
+	rt.Alias(http.MethodGet, "/api/points/activity/{chain}/{address}", api.Prefix+"/points/{chain}/{address}/activity")
+	rt.Alias(http.MethodGet, "/api/points/breakdown/{chain}/{address}", api.Prefix+"/points/{chain}/{address}/breakdown")
+	rt.Deprecated(api.Route{
+		Method:   http.MethodPost,
+		Path:     "/api/admin/points/simulate",
+		Scope:    models.ScopeAdminRecalc,
+		Tag:      "admin",
+		Summary:  "模拟候选积分规则，format=csv时导出差异",
+		Request:  SimulateRulesRequest{},
+		Response: service.SimulationReport{},
+		Handler:  h.legacySimulateRules,
+	}, api.Prefix+"/admin/points/simulate")
+}
+
+// GetPoints 处理 GET /api/v1/points/{chain}/{address}
 func (h *PointsHandler) GetPoints(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	chainID := r.PathValue("chain")
+	userAddress := r.PathValue("address")
+
+	summary, err := h.pointsSvc.GetUserPoints(r.Context(), chainID, userAddress)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "failed to get points: "+err.Error())
 		return
 	}
 
-	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(pathParts) < 4 {
-		writeError(w, http.StatusBadRequest, "invalid path format, expected /api/points/{chain_id}/{address}")
-		return
+	var lastCalculatedAt string
+	if summary.LastCalculatedAt != nil {
+		lastCalculatedAt = summary.LastCalculatedAt.Format(time.RFC3339)
 	}
 
-	chainID := pathParts[2]
-	userAddress := pathParts[3]
+	writeJSON(w, http.StatusOK, PointsResponse{
+		TotalPoints:      summary.Total,
+		AvailablePoints:  summary.Available,
+		ReservedPoints:   summary.Reserved,
+		RedeemedPoints:   summary.Redeemed,
+		LifetimePoints:   summary.Lifetime,
+		Chain:            chainID,
+		Address:          userAddress,
+		LastCalculatedAt: lastCalculatedAt,
+	})
+}
+
+// GetPointsAt 处理 GET /api/v1/points/{chain}/{address}/at?at=RFC3339
+func (h *PointsHandler) GetPointsAt(w http.ResponseWriter, r *http.Request) {
+	chainID := r.PathValue("chain")
+	userAddress := r.PathValue("address")
 
-	if chainID == "" || userAddress == "" {
-		writeError(w, http.StatusBadRequest, "chain_id and address are required")
+	atTime, err := time.Parse(time.RFC3339, r.URL.Query().Get("at"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid at, expected RFC3339 time")
 		return
 	}
 
-	ctx := r.Context()
-	points, err := h.pointsSvc.GetUserPoints(ctx, chainID, userAddress)
+	points, err := h.pointsSvc.GetUserPointsAt(r.Context(), chainID, userAddress, atTime)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to get points: "+err.Error())
+		writeError(w, r, http.StatusInternalServerError, "failed to get points: "+err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"totalPoints":      points,
-		"chain":            chainID,
-		"address":          userAddress,
-		"lastCalculatedAt": time.Now().Format(time.RFC3339),
+	writeJSON(w, http.StatusOK, PointsAtResponse{
+		TotalPoints: points,
+		Chain:       chainID,
+		Address:     userAddress,
+		At:          atTime.Format(time.RFC3339),
 	})
 }
 
-func (h *PointsHandler) ListPoints(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+// legacyGetPoints 处理 GET /api/points/{chain}/{address}[?at=RFC3339]
+func (h *PointsHandler) legacyGetPoints(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("at") != "" {
+		h.GetPointsAt(w, r)
 		return
 	}
+	h.GetPoints(w, r)
+}
 
+// ListPoints 处理 GET /api/v1/points?chain_id=sepolia&page=1&page_size=20
+func (h *PointsHandler) ListPoints(w http.ResponseWriter, r *http.Request) {
 	chainID := r.URL.Query().Get("chain_id")
 	if chainID == "" {
-		writeError(w, http.StatusBadRequest, "chain_id is required")
+		writeError(w, r, http.StatusBadRequest, "chain_id is required")
 		return
 	}
 
@@ -183,30 +452,26 @@ func (h *PointsHandler) ListPoints(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	points, err := h.pointsSvc.ListPoints(ctx, chainID, offset, pageSize)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to list points: "+err.Error())
+		writeError(w, r, http.StatusInternalServerError, "failed to list points: "+err.Error())
 		return
 	}
 
 	total, err := h.pointsSvc.CountPoints(ctx, chainID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to count points: "+err.Error())
+		writeError(w, r, http.StatusInternalServerError, "failed to count points: "+err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"items":    points,
-		"total":    total,
-		"page":     page,
-		"pageSize": pageSize,
+	writeJSON(w, http.StatusOK, Page[models.UserPoints]{
+		Items:    points,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
 	})
 }
 
+// GetPointsHistory 处理 GET /api/v1/points/daily?days=7
 func (h *PointsHandler) GetPointsHistory(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
-		return
-	}
-
 	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
 	if days < 1 || days > 30 {
 		days = 7
@@ -215,7 +480,7 @@ func (h *PointsHandler) GetPointsHistory(w http.ResponseWriter, r *http.Request)
 	ctx := r.Context()
 	dailyPoints, err := h.calcRepo.GetDailyPoints(ctx, days)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to get points history: "+err.Error())
+		writeError(w, r, http.StatusInternalServerError, "failed to get points history: "+err.Error())
 		return
 	}
 
@@ -235,40 +500,125 @@ func (h *PointsHandler) GetPointsHistory(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"labels": labels,
-		"values": values,
+	writeJSON(w, http.StatusOK, PointsHistoryResponse{
+		Labels: labels,
+		Values: values,
 	})
 }
 
-type HistoryHandler struct {
-	historyRepo *repository.HistoryRepository
-}
+// GetLedger 处理 GET /api/v1/points/{chain}/{address}/ledger?limit=20
+func (h *PointsHandler) GetLedger(w http.ResponseWriter, r *http.Request) {
+	chainID := r.PathValue("chain")
+	userAddress := r.PathValue("address")
 
-func NewHistoryHandler(historyRepo *repository.HistoryRepository) *HistoryHandler {
-	return &HistoryHandler{historyRepo: historyRepo}
-}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
 
-func (h *HistoryHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	ctx := r.Context()
+	entries, err := h.pointsSvc.GetLedger(ctx, chainID, userAddress, limit)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "failed to get ledger: "+err.Error())
 		return
 	}
 
-	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(pathParts) < 4 {
-		writeError(w, http.StatusBadRequest, "invalid path format, expected /api/history/{chain_id}/{address}")
+	items := make([]LedgerItem, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, LedgerItem{
+			EntryType:   e.EntryType,
+			Amount:      e.Amount,
+			SourceType:  e.SourceType,
+			SourceRef:   e.SourceRef,
+			Memo:        e.Memo,
+			EffectiveAt: e.EffectiveAt.Format(time.RFC3339),
+			CreatedAt:   e.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// GetPointsActivity 处理 GET /api/v1/points/{chain}/{address}/activity
+// 按时间倒序合并周期计算与人工调整记录
+func (h *PointsHandler) GetPointsActivity(w http.ResponseWriter, r *http.Request) {
+	chainID := r.PathValue("chain")
+	userAddress := r.PathValue("address")
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	activities, err := h.pointsSvc.GetPointsActivity(r.Context(), chainID, userAddress, limit)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "failed to get points activity: "+err.Error())
 		return
 	}
 
-	chainID := pathParts[2]
-	userAddress := pathParts[3]
+	writeJSON(w, http.StatusOK, activities)
+}
 
-	if chainID == "" || userAddress == "" {
-		writeError(w, http.StatusBadRequest, "chain_id and address are required")
+// GetPointsBreakdown 处理 GET /api/v1/points/{chain}/{address}/breakdown?at=RFC3339
+// 返回包含at时刻的周期计算明细
+func (h *PointsHandler) GetPointsBreakdown(w http.ResponseWriter, r *http.Request) {
+	chainID := r.PathValue("chain")
+	userAddress := r.PathValue("address")
+
+	at, err := time.Parse(time.RFC3339, r.URL.Query().Get("at"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "at is required, expected RFC3339 time")
 		return
 	}
 
+	breakdown, err := h.pointsSvc.GetPointsBreakdown(r.Context(), chainID, userAddress, at)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, breakdown)
+}
+
+// HistoryItem 余额变动记录
+type HistoryItem struct {
+	Timestamp     string            `json:"timestamp"`
+	ChangeType    models.ChangeType `json:"changeType"`
+	BalanceBefore string            `json:"balanceBefore"`
+	BalanceAfter  string            `json:"balanceAfter"`
+	ChangeAmount  string            `json:"changeAmount"`
+	TxHash        string            `json:"txHash"`
+	BlockNumber   int64             `json:"blockNumber"`
+}
+
+type HistoryHandler struct {
+	historyRepo *repository.HistoryRepository
+}
+
+func NewHistoryHandler(historyRepo *repository.HistoryRepository) *HistoryHandler {
+	return &HistoryHandler{historyRepo: historyRepo}
+}
+
+// Register 注册余额历史接口
+func (h *HistoryHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/balances/{chain}/{address}/history",
+		Tag:      "balances",
+		Summary:  "余额变动记录",
+		Query:    limitQuery,
+		Response: []HistoryItem{},
+		Handler:  h.GetHistory,
+	})
+
+	rt.Alias(http.MethodGet, "/api/history/{chain}/{address}", api.Prefix+"/balances/{chain}/{address}/history")
+}
+
+// GetHistory 处理 GET /api/v1/balances/{chain}/{address}/history?limit=20
+func (h *HistoryHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	chainID := r.PathValue("chain")
+	userAddress := r.PathValue("address")
+
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	if limit < 1 || limit > 100 {
 		limit = 20
@@ -277,26 +627,35 @@ func (h *HistoryHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	histories, err := h.historyRepo.GetByUser(ctx, chainID, userAddress, limit)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to get history: "+err.Error())
+		writeError(w, r, http.StatusInternalServerError, "failed to get history: "+err.Error())
 		return
 	}
 
-	items := make([]map[string]interface{}, 0, len(histories))
+	items := make([]HistoryItem, 0, len(histories))
 	for _, h := range histories {
-		items = append(items, map[string]interface{}{
-			"timestamp":     h.Timestamp.Format(time.RFC3339),
-			"changeType":    h.ChangeType,
-			"balanceBefore": h.BalanceBefore,
-			"balanceAfter":  h.BalanceAfter,
-			"changeAmount":  h.ChangeAmount,
-			"txHash":        h.TxHash,
-			"blockNumber":   h.BlockNumber,
+		items = append(items, HistoryItem{
+			Timestamp:     h.Timestamp.Format(time.RFC3339),
+			ChangeType:    h.ChangeType,
+			BalanceBefore: h.BalanceBefore,
+			BalanceAfter:  h.BalanceAfter,
+			ChangeAmount:  h.ChangeAmount,
+			TxHash:        h.TxHash,
+			BlockNumber:   h.BlockNumber,
 		})
 	}
 
 	writeJSON(w, http.StatusOK, items)
 }
 
+// StatsResponse 全局统计
+type StatsResponse struct {
+	TotalUsers        int64   `json:"totalUsers"`
+	TotalPoints       float64 `json:"totalPoints"`
+	TotalTransactions int64   `json:"totalTransactions"`
+	SepoliaBlock      int64   `json:"sepoliaBlock"`
+	BaseBlock         int64   `json:"baseBlock"`
+}
+
 type StatsHandler struct {
 	balanceRepo *repository.BalanceRepository
 	pointsRepo  *repository.PointsRepository
@@ -321,12 +680,21 @@ func NewStatsHandler(
 	}
 }
 
-func (h *StatsHandler) GetStats(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
-		return
-	}
+// Register 注册统计接口
+func (h *StatsHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/stats",
+		Tag:      "system",
+		Summary:  "全局统计",
+		Response: StatsResponse{},
+		Handler:  h.GetStats,
+	})
+
+	rt.Alias(http.MethodGet, "/api/stats", api.Prefix+"/stats")
+}
 
+func (h *StatsHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	var totalUsers int64
@@ -361,12 +729,12 @@ func (h *StatsHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	sepoliaBlock, _ := h.blockRepo.GetLastProcessed(ctx, "sepolia")
 	baseBlock, _ := h.blockRepo.GetLastProcessed(ctx, "base-sepolia")
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"totalUsers":        totalUsers,
-		"totalPoints":       totalPoints,
-		"totalTransactions": totalTransactions,
-		"sepoliaBlock":      sepoliaBlock,
-		"baseBlock":         baseBlock,
+	writeJSON(w, http.StatusOK, StatsResponse{
+		TotalUsers:        totalUsers,
+		TotalPoints:       totalPoints,
+		TotalTransactions: totalTransactions,
+		SepoliaBlock:      sepoliaBlock,
+		BaseBlock:         baseBlock,
 	})
 }
 
@@ -375,38 +743,77 @@ func sscanf(str, format string, v interface{}) (int, error) {
 	return 0, err
 }
 
+// JobAccepted 已提交的后台任务
+type JobAccepted struct {
+	Message string `json:"message"`
+	JobID   uint64 `json:"jobId"`
+	Chain   string `json:"chain"`
+}
+
+// RecalculateRequest 补算请求，时间为RFC3339或2006-01-02T15:04
+type RecalculateRequest struct {
+	Chain     string `json:"chain"`
+	StartTime string `json:"startTime"`
+	EndTime   string `json:"endTime"`
+}
+
+// RecalculateAccepted 已提交的补算任务
+type RecalculateAccepted struct {
+	JobAccepted
+	StartTime string `json:"startTime"`
+	EndTime   string `json:"endTime"`
+}
+
 type RecalculateHandler struct {
-	scheduler   *scheduler.PointsScheduler
-	balanceRepo *repository.BalanceRepository
-	cfg         *config.Config
+	scheduler  *scheduler.PointsScheduler
+	jobManager *jobs.Manager
+	cfg        *config.Config
 }
 
 func NewRecalculateHandler(
 	scheduler *scheduler.PointsScheduler,
-	balanceRepo *repository.BalanceRepository,
+	jobManager *jobs.Manager,
 	cfg *config.Config,
 ) *RecalculateHandler {
 	return &RecalculateHandler{
-		scheduler:   scheduler,
-		balanceRepo: balanceRepo,
-		cfg:         cfg,
+		scheduler:  scheduler,
+		jobManager: jobManager,
+		cfg:        cfg,
 	}
 }
 
-func (h *RecalculateHandler) TriggerRecalculate(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
-		return
-	}
+// Register 注册补算与调度状态接口
+func (h *RecalculateHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/admin/recalculate",
+		Scope:    models.ScopeAdminRecalc,
+		Tag:      "admin",
+		Summary:  "提交积分补算任务",
+		Request:  RecalculateRequest{},
+		Response: RecalculateAccepted{},
+		Status:   http.StatusAccepted,
+		Handler:  h.TriggerRecalculate,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/scheduler/status",
+		Scope:    models.ScopeRead,
+		Tag:      "system",
+		Summary:  "各链积分周期的补算进度",
+		Response: []scheduler.CatchUpProgress{},
+		Handler:  h.GetSchedulerStatus,
+	})
 
-	var req struct {
-		Chain     string `json:"chain"`
-		StartTime string `json:"startTime"`
-		EndTime   string `json:"endTime"`
-	}
+	rt.Alias(http.MethodPost, "/api/recalculate", api.Prefix+"/admin/recalculate")
+	rt.Alias(http.MethodGet, "/api/scheduler/status", api.Prefix+"/scheduler/status")
+}
 
+// TriggerRecalculate 处理 POST /api/v1/admin/recalculate
+func (h *RecalculateHandler) TriggerRecalculate(w http.ResponseWriter, r *http.Request) {
+	var req RecalculateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
 		return
 	}
 
@@ -418,7 +825,11 @@ func (h *RecalculateHandler) TriggerRecalculate(w http.ResponseWriter, r *http.R
 		chainID = r.URL.Query().Get("chain")
 	}
 	if chainID == "" {
-		writeError(w, http.StatusBadRequest, "chain is required")
+		writeError(w, r, http.StatusBadRequest, "chain is required")
+		return
+	}
+	if _, err := h.cfg.GetChainConfig(chainID); err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -445,19 +856,40 @@ func (h *RecalculateHandler) TriggerRecalculate(w http.ResponseWriter, r *http.R
 		periodEnd = time.Now()
 	}
 
-	ctx := r.Context()
-	go func() {
-		_ = h.scheduler.TriggerManualCalculation(ctx, chainID, periodStart, periodEnd)
-	}()
-
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"message":   "recalculation triggered",
-		"chain":     chainID,
-		"startTime": periodStart.Format(time.RFC3339),
-		"endTime":   periodEnd.Format(time.RFC3339),
+	params, err := h.scheduler.NewRecalculateParams(r.Context(), chainID, periodStart, periodEnd)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	job, err := h.jobManager.Submit(r.Context(), models.JobTypeRecalculate, params, actorOf(r))
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, RecalculateAccepted{
+		JobAccepted: JobAccepted{Message: "recalculation job submitted", JobID: job.ID, Chain: chainID},
+		StartTime:   params.Start.Format(time.RFC3339),
+		EndTime:     params.End.Format(time.RFC3339),
 	})
 }
 
+// GetSchedulerStatus 返回各链积分周期的补算进度
+func (h *RecalculateHandler) GetSchedulerStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.scheduler.Progress())
+}
+
+// TransactionItem 最近的余额变动
+type TransactionItem struct {
+	Chain     string `json:"chain"`
+	Type      string `json:"type"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Amount    string `json:"amount"`
+	Timestamp string `json:"timestamp"`
+}
+
 type TransactionHandler struct {
 	historyRepo *repository.HistoryRepository
 }
@@ -466,12 +898,22 @@ func NewTransactionHandler(historyRepo *repository.HistoryRepository) *Transacti
 	return &TransactionHandler{historyRepo: historyRepo}
 }
 
-func (h *TransactionHandler) GetRecentTransactions(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
-		return
-	}
+// Register 注册最近交易接口
+func (h *TransactionHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/transactions/recent",
+		Tag:      "balances",
+		Summary:  "所有链最近的余额变动",
+		Query:    []api.Param{{Name: "limit", Type: "integer", Description: "返回条数，默认10，最大100"}},
+		Response: []TransactionItem{},
+		Handler:  h.GetRecentTransactions,
+	})
+
+	rt.Alias(http.MethodGet, "/api/transactions/recent", api.Prefix+"/transactions/recent")
+}
 
+func (h *TransactionHandler) GetRecentTransactions(w http.ResponseWriter, r *http.Request) {
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	if limit < 1 || limit > 100 {
 		limit = 10
@@ -480,90 +922,48 @@ func (h *TransactionHandler) GetRecentTransactions(w http.ResponseWriter, r *htt
 	ctx := r.Context()
 	histories, err := h.historyRepo.GetRecent(ctx, limit)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to get transactions: "+err.Error())
+		writeError(w, r, http.StatusInternalServerError, "failed to get transactions: "+err.Error())
 		return
 	}
 
-	items := make([]map[string]interface{}, 0, len(histories))
+	items := make([]TransactionItem, 0, len(histories))
 	for _, h := range histories {
-		items = append(items, map[string]interface{}{
-			"chain":     h.ChainID,
-			"type":      string(h.ChangeType),
-			"from":      h.UserAddress,
-			"to":        "",
-			"amount":    h.ChangeAmount,
-			"timestamp": h.Timestamp.Format(time.RFC3339),
+		items = append(items, TransactionItem{
+			Chain:     h.ChainID,
+			Type:      string(h.ChangeType),
+			From:      h.UserAddress,
+			To:        "",
+			Amount:    h.ChangeAmount,
+			Timestamp: h.Timestamp.Format(time.RFC3339),
 		})
 	}
 
 	writeJSON(w, http.StatusOK, items)
 }
 
-type BackupHandler struct {
-	calcRepo *repository.CalculationRepository
-	cfg      *config.Config
-}
-
-func NewBackupHandler(calcRepo *repository.CalculationRepository, cfg *config.Config) *BackupHandler {
-	return &BackupHandler{calcRepo: calcRepo, cfg: cfg}
+// HealthResponse 健康检查
+type HealthResponse struct {
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
 }
 
-func (h *BackupHandler) CreateBackup(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
-		return
-	}
-
-	var req struct {
-		Chain string `json:"chain"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
-		return
-	}
-
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"message":   "backup created",
-		"chain":     req.Chain,
-		"createdAt": time.Now().Format(time.RFC3339),
+// RegisterHealth 注册健康检查接口
+func RegisterHealth(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/health",
+		Tag:      "system",
+		Summary:  "健康检查",
+		Response: HealthResponse{},
+		Handler:  HandleHealth,
 	})
-}
-
-func (h *BackupHandler) ListBackups(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
-		return
-	}
 
-	backups := make([]map[string]interface{}, 0)
-	writeJSON(w, http.StatusOK, backups)
-}
-
-func (h *BackupHandler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
-		return
-	}
-
-	var req struct {
-		BackupID string `json:"backupId"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
-		return
-	}
-
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"message":  "backup restored",
-		"backupId": req.BackupID,
-	})
+	rt.Alias(http.MethodGet, "/health", api.Prefix+"/health")
 }
 
 func HandleHealth(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().Format(time.RFC3339),
+	writeJSON(w, http.StatusOK, HealthResponse{
+		Status:    "healthy",
+		Timestamp: time.Now().Format(time.RFC3339),
 	})
 }
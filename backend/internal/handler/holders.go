@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/repository"
+	"token-points-system/internal/service"
+	"token-points-system/pkg/logger"
+)
+
+// holderQueryParams 持有人快照查询参数的文档
+var holderQueryParams = []api.Param{
+	{Name: "block", Type: "integer", Description: "区块高度，默认最新已处理区块"},
+	{Name: "min_balance", Description: "最小余额（代币最小单位）"},
+}
+
+// GetBalanceAt 处理 GET /api/v1/balances/{chain}/{address}/at?block=123 或 ?at=2024-01-01T00:00:00Z
+func (h *BalanceHandler) GetBalanceAt(w http.ResponseWriter, r *http.Request) {
+	chainID := r.PathValue("chain")
+	userAddress := r.PathValue("address")
+
+	query := r.URL.Query()
+	if query.Get("block") != "" && query.Get("at") != "" {
+		writeError(w, r, http.StatusBadRequest, "block and at are mutually exclusive")
+		return
+	}
+
+	var (
+		result *service.PointInTimeBalance
+		err    error
+	)
+	if raw := query.Get("block"); raw != "" {
+		block, perr := strconv.ParseInt(raw, 10, 64)
+		if perr != nil || block < 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid block")
+			return
+		}
+		result, err = h.balanceSvc.BalanceAtBlock(r.Context(), chainID, userAddress, block)
+	} else {
+		at, perr := time.Parse(time.RFC3339, query.Get("at"))
+		if perr != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid at, expected RFC3339 time")
+			return
+		}
+		result, err = h.balanceSvc.BalanceAtTime(r.Context(), chainID, userAddress, at)
+	}
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// GetHolders 处理 GET /api/v1/holders/{chain}?block=123&min_balance=1000&page=1&page_size=20
+// 返回指定区块处理完成时的持有人，未指定block时使用最新已处理区块
+func (h *BalanceHandler) GetHolders(w http.ResponseWriter, r *http.Request) {
+	holderQuery, ok := parseHolderQuery(w, r)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	holderQuery.Offset = (page - 1) * pageSize
+	holderQuery.Limit = pageSize
+
+	snapshot, err := h.balanceSvc.HoldersAtBlock(r.Context(), holderQuery)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, HoldersResponse{
+		HolderSnapshot: snapshot,
+		Page:           page,
+		PageSize:       pageSize,
+	})
+}
+
+// legacyGetHolders 处理 GET /api/holders/{chain}，format=csv时导出全部持有人
+func (h *BalanceHandler) legacyGetHolders(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") == "csv" {
+		h.ExportHolders(w, r)
+		return
+	}
+	h.GetHolders(w, r)
+}
+
+// ExportHolders 处理 GET /api/v1/holders/{chain}/export，以CSV导出全部持有人，写出第一行后出错只能中断响应
+func (h *BalanceHandler) ExportHolders(w http.ResponseWriter, r *http.Request) {
+	query, ok := parseHolderQuery(w, r)
+	if !ok {
+		return
+	}
+
+	// 先确定区块，文件名中包含实际使用的区块
+	if err := h.balanceSvc.ResolveHolderQuery(r.Context(), &query); err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=holders-%s-%d.csv", query.ChainID, query.Block))
+	writer := csv.NewWriter(w)
+	if err := writer.Write(service.HolderCSVHeader); err != nil {
+		return
+	}
+
+	err := h.balanceSvc.EachHolderAtBlock(r.Context(), query, func(holder *repository.HolderBalance) error {
+		return writer.Write([]string{holder.UserAddress, holder.Balance})
+	})
+	if err != nil {
+		logger.Error("导出持有人失败:", err)
+		return
+	}
+	writer.Flush()
+}
+
+func parseHolderQuery(w http.ResponseWriter, r *http.Request) (service.HolderQuery, bool) {
+	query := r.URL.Query()
+	holderQuery := service.HolderQuery{
+		ChainID:    r.PathValue("chain"),
+		MinBalance: query.Get("min_balance"),
+	}
+	if raw := query.Get("block"); raw != "" {
+		block, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || block <= 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid block")
+			return holderQuery, false
+		}
+		holderQuery.Block = block
+	}
+	return holderQuery, true
+}
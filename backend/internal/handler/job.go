@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/jobs"
+	"token-points-system/internal/models"
+)
+
+// JobItem 后台任务
+type JobItem struct {
+	ID              uint64          `json:"id"`
+	Type            string          `json:"type"`
+	Params          string          `json:"params"`
+	State           models.JobState `json:"state"`
+	Total           int64           `json:"total"`
+	Processed       int64           `json:"processed"`
+	Failed          int64           `json:"failed"`
+	CancelRequested bool            `json:"cancelRequested"`
+	Error           string          `json:"error"`
+	CreatedBy       string          `json:"createdBy"`
+	CreatedAt       string          `json:"createdAt"`
+	Result          json.RawMessage `json:"result,omitempty"`
+	StartedAt       string          `json:"startedAt,omitempty"`
+	FinishedAt      string          `json:"finishedAt,omitempty"`
+}
+
+type JobHandler struct {
+	jobManager *jobs.Manager
+}
+
+func NewJobHandler(jobManager *jobs.Manager) *JobHandler {
+	return &JobHandler{jobManager: jobManager}
+}
+
+// Register 注册后台任务接口
+func (h *JobHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:  http.MethodGet,
+		Path:    api.Prefix + "/jobs",
+		Scope:   models.ScopeRead,
+		Tag:     "jobs",
+		Summary: "列出后台任务",
+		Query: append([]api.Param{
+			{Name: "type", Description: "任务类型"},
+			{Name: "state", Description: "pending、running、succeeded、failed或cancelled"},
+		}, limitQuery...),
+		Response: []JobItem{},
+		Handler:  h.ListJobs,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/jobs/{id}",
+		Scope:    models.ScopeRead,
+		Tag:      "jobs",
+		Summary:  "查询后台任务",
+		Response: JobItem{},
+		Handler:  h.GetJob,
+	})
+	rt.Handle(api.Route{
+		Method:      http.MethodPost,
+		Path:        api.Prefix + "/jobs/{id}/cancel",
+		Scope:       models.ScopeRead,
+		Tag:         "jobs",
+		Summary:     "请求取消后台任务",
+		Description: "还需要提交该类任务的权限：backup和restore为admin:backup，其余为admin:recalc",
+		Response:    JobItem{},
+		Handler:     h.CancelJob,
+	})
+
+	rt.Alias(http.MethodGet, "/api/jobs", api.Prefix+"/jobs")
+	rt.Alias(http.MethodGet, "/api/jobs/{id}", api.Prefix+"/jobs/{id}")
+	rt.Alias(http.MethodPost, "/api/jobs/{id}/cancel", api.Prefix+"/jobs/{id}/cancel")
+}
+
+// ListJobs 处理 GET /api/v1/jobs?type=&state=&limit=
+func (h *JobHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	list, err := h.jobManager.List(r.Context(), r.URL.Query().Get("type"), models.JobState(r.URL.Query().Get("state")), limit)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "failed to list jobs: "+err.Error())
+		return
+	}
+
+	items := make([]JobItem, 0, len(list))
+	for i := range list {
+		items = append(items, jobItem(&list[i]))
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// GetJob 处理 GET /api/v1/jobs/{id}
+func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid job id")
+		return
+	}
+
+	job, err := h.jobManager.Get(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, jobItem(job))
+}
+
+// CancelJob 处理 POST /api/v1/jobs/{id}/cancel
+func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid job id")
+		return
+	}
+
+	// 取消任务需要提交该类任务的权限
+	if principal := api.PrincipalFrom(r); principal != nil {
+		job, err := h.jobManager.Get(r.Context(), id)
+		if err != nil {
+			writeAppError(w, r, err)
+			return
+		}
+		if scope := jobScope(job.Type); !principal.HasScope(scope) {
+			writeError(w, r, http.StatusForbidden, "API key lacks scope "+scope)
+			return
+		}
+	}
+
+	job, err := h.jobManager.Cancel(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, jobItem(job))
+}
+
+// jobScope 返回提交该类任务需要的API Key权限
+func jobScope(jobType string) string {
+	switch jobType {
+	case models.JobTypeBackup, models.JobTypeRestore:
+		return models.ScopeAdminBackup
+	default:
+		return models.ScopeAdminRecalc
+	}
+}
+
+func jobItem(j *models.Job) JobItem {
+	item := JobItem{
+		ID:              j.ID,
+		Type:            j.Type,
+		Params:          j.Params,
+		State:           j.State,
+		Total:           j.Total,
+		Processed:       j.Processed,
+		Failed:          j.Failed,
+		CancelRequested: j.CancelRequested,
+		Error:           j.Error,
+		CreatedBy:       j.CreatedBy,
+		CreatedAt:       j.CreatedAt.Format(time.RFC3339),
+	}
+	if j.Result != "" {
+		item.Result = json.RawMessage(j.Result)
+	}
+	if j.StartedAt != nil {
+		item.StartedAt = j.StartedAt.Format(time.RFC3339)
+	}
+	if j.FinishedAt != nil {
+		item.FinishedAt = j.FinishedAt.Format(time.RFC3339)
+	}
+	return item
+}
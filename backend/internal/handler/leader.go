@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/election"
+)
+
+type LeaderHandler struct {
+	elector *election.Elector
+}
+
+func NewLeaderHandler(elector *election.Elector) *LeaderHandler {
+	return &LeaderHandler{elector: elector}
+}
+
+// Register 注册主节点状态接口
+func (h *LeaderHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/leader",
+		Tag:      "system",
+		Summary:  "当前节点身份及主节点信息",
+		Response: election.Status{},
+		Handler:  h.GetLeader,
+	})
+
+	rt.Alias(http.MethodGet, "/api/leader", api.Prefix+"/leader")
+}
+
+// GetLeader 返回当前节点身份及主节点信息
+func (h *LeaderHandler) GetLeader(w http.ResponseWriter, r *http.Request) {
+	status, err := h.elector.Status(r.Context())
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "failed to get leader status: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
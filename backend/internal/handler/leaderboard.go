@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/service"
+)
+
+// defaultRankNeighbours 查询名次时默认返回的前后相邻条目数
+const defaultRankNeighbours = 5
+
+// LeaderboardResponse 排行榜的一页
+type LeaderboardResponse struct {
+	Scope      string                `json:"scope"`
+	Mode       service.RankMode      `json:"mode"`
+	SnapshotAt time.Time             `json:"snapshotAt"`
+	ComparedTo *time.Time            `json:"comparedTo"`
+	Items      []service.RankedEntry `json:"items"`
+	Total      int64                 `json:"total"`
+	Page       int                   `json:"page"`
+	PageSize   int                   `json:"pageSize"`
+}
+
+// modeQuery mode查询参数的文档
+var modeQuery = api.Param{Name: "mode", Description: "并列名次的计算方式，默认dense"}
+
+type LeaderboardHandler struct {
+	leaderboardSvc *service.LeaderboardService
+}
+
+func NewLeaderboardHandler(leaderboardSvc *service.LeaderboardService) *LeaderboardHandler {
+	return &LeaderboardHandler{leaderboardSvc: leaderboardSvc}
+}
+
+// Register 注册排行榜接口，全局、链和活动排行榜使用相同的处理函数
+func (h *LeaderboardHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/leaderboards",
+		Tag:      "leaderboards",
+		Summary:  "列出排行榜",
+		Response: []service.LeaderboardInfo{},
+		Handler:  h.ListLeaderboards,
+	})
+
+	for _, scope := range []struct{ path, summary string }{
+		{"/leaderboards/global", "全局排行榜"},
+		{"/leaderboards/chain/{chain}", "链排行榜"},
+		{"/leaderboards/campaign/{campaign}", "活动排行榜"},
+	} {
+		rt.Handle(api.Route{
+			Method:   http.MethodGet,
+			Path:     api.Prefix + scope.path,
+			Tag:      "leaderboards",
+			Summary:  scope.summary,
+			Query:    append([]api.Param{modeQuery}, pageQuery...),
+			Response: LeaderboardResponse{},
+			Handler:  h.GetLeaderboard,
+		})
+		rt.Handle(api.Route{
+			Method:  http.MethodGet,
+			Path:    api.Prefix + scope.path + "/rank/{address}",
+			Tag:     "leaderboards",
+			Summary: scope.summary + "中地址的名次及前后相邻的条目",
+			Query: []api.Param{
+				modeQuery,
+				{Name: "neighbours", Type: "integer", Description: "前后相邻条目数，默认5，最大50"},
+			},
+			Response: service.RankLookup{},
+			Handler:  h.GetRank,
+		})
+
+		rt.Alias(http.MethodGet, "/api"+scope.path, api.Prefix+scope.path)
+		rt.Alias(http.MethodGet, "/api"+scope.path+"/rank/{address}", api.Prefix+scope.path+"/rank/{address}")
+	}
+
+	rt.Alias(http.MethodGet, "/api/leaderboards", api.Prefix+"/leaderboards")
+}
+
+// ListLeaderboards 处理 GET /api/v1/leaderboards
+func (h *LeaderboardHandler) ListLeaderboards(w http.ResponseWriter, r *http.Request) {
+	infos, err := h.leaderboardSvc.Scopes(r.Context())
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+// GetLeaderboard 处理排行榜查询
+// GET /api/v1/leaderboards/global?mode=dense&page=1&page_size=20
+// GET /api/v1/leaderboards/chain/{chain}
+// GET /api/v1/leaderboards/campaign/{campaign}
+func (h *LeaderboardHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	mode, err := service.ParseRankMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	result, err := h.leaderboardSvc.Leaderboard(r.Context(), leaderboardScope(r), mode, (page-1)*pageSize, pageSize)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, LeaderboardResponse{
+		Scope:      result.Scope,
+		Mode:       result.Mode,
+		SnapshotAt: result.SnapshotAt,
+		ComparedTo: result.ComparedTo,
+		Items:      result.Items,
+		Total:      result.Total,
+		Page:       page,
+		PageSize:   pageSize,
+	})
+}
+
+// GetRank 处理 GET /api/v1/leaderboards/{...}/rank/{address}?neighbours=5
+func (h *LeaderboardHandler) GetRank(w http.ResponseWriter, r *http.Request) {
+	mode, err := service.ParseRankMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	neighbours := defaultRankNeighbours
+	if v := r.URL.Query().Get("neighbours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 50 {
+			writeError(w, r, http.StatusBadRequest, "neighbours must be between 0 and 50")
+			return
+		}
+		neighbours = n
+	}
+
+	result, err := h.leaderboardSvc.Rank(r.Context(), leaderboardScope(r), mode, r.PathValue("address"), neighbours)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// leaderboardScope 根据路径参数确定排行榜范围，没有chain和campaign参数时为全局排行榜
+func leaderboardScope(r *http.Request) string {
+	if chainID := r.PathValue("chain"); chainID != "" {
+		return service.ChainScope(chainID)
+	}
+	if campaign := r.PathValue("campaign"); campaign != "" {
+		return service.CampaignScope(campaign)
+	}
+	return service.LeaderboardScopeGlobal
+}
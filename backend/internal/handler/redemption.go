@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/models"
+	"token-points-system/internal/service"
+)
+
+// RedemptionRequest 兑换请求，幂等键可以放在Idempotency-Key头或请求体中
+// mode为direct（默认）或reserve
+type RedemptionRequest struct {
+	Chain          string `json:"chain"`
+	Address        string `json:"address"`
+	Amount         string `json:"amount"`
+	IdempotencyKey string `json:"idempotencyKey"`
+	Reference      string `json:"reference"`
+	Mode           string `json:"mode"`
+}
+
+// RedemptionItem 兑换记录，已过期的预留状态为expired
+type RedemptionItem struct {
+	ID             uint64 `json:"id"`
+	Chain          string `json:"chain"`
+	Address        string `json:"address"`
+	Amount         string `json:"amount"`
+	Status         string `json:"status"`
+	IdempotencyKey string `json:"idempotencyKey"`
+	Reference      string `json:"reference"`
+	CreatedAt      string `json:"createdAt"`
+	ExpiresAt      string `json:"expiresAt,omitempty"`
+	CommittedAt    string `json:"committedAt,omitempty"`
+	CancelledAt    string `json:"cancelledAt,omitempty"`
+}
+
+type RedemptionHandler struct {
+	redemptionSvc *service.RedemptionService
+}
+
+func NewRedemptionHandler(redemptionSvc *service.RedemptionService) *RedemptionHandler {
+	return &RedemptionHandler{redemptionSvc: redemptionSvc}
+}
+
+// Register 注册积分兑换接口
+func (h *RedemptionHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:      http.MethodPost,
+		Path:        api.Prefix + "/redemptions",
+		Tag:         "redemptions",
+		Summary:     "创建兑换",
+		Description: "mode=reserve时只冻结积分，需要调用commit或cancel完成两阶段流程",
+		Request:     RedemptionRequest{},
+		Scope:       models.ScopeRedeem,
+		Response:    RedemptionItem{},
+		Handler:     h.CreateRedemption,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/redemptions/{id}/commit",
+		Tag:      "redemptions",
+		Summary:  "确认预留的兑换",
+		Scope:    models.ScopeRedeem,
+		Response: RedemptionItem{},
+		Handler:  h.CommitRedemption,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/redemptions/{id}/cancel",
+		Tag:      "redemptions",
+		Summary:  "取消预留的兑换",
+		Scope:    models.ScopeRedeem,
+		Response: RedemptionItem{},
+		Handler:  h.CancelRedemption,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/points/{chain}/{address}/redemptions",
+		Tag:      "redemptions",
+		Summary:  "兑换记录",
+		Query:    limitQuery,
+		Response: []RedemptionItem{},
+		Handler:  h.ListRedemptions,
+	})
+
+	rt.Alias(http.MethodPost, "/api/redemptions", api.Prefix+"/redemptions")
+	rt.Alias(http.MethodPost, "/api/redemptions/{id}/commit", api.Prefix+"/redemptions/{id}/commit")
+	rt.Alias(http.MethodPost, "/api/redemptions/{id}/cancel", api.Prefix+"/redemptions/{id}/cancel")
+	rt.Alias(http.MethodGet, "/api/redemptions/{chain}/{address}", api.Prefix+"/points/{chain}/{address}/redemptions")
+}
+
+// CreateRedemption 创建兑换
+// mode=reserve 时只冻结积分，需要调用 commit 或 cancel 完成两阶段流程
+func (h *RedemptionHandler) CreateRedemption(w http.ResponseWriter, r *http.Request) {
+	var req RedemptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" {
+		idempotencyKey = req.IdempotencyKey
+	}
+	if idempotencyKey == "" {
+		writeError(w, r, http.StatusBadRequest, "idempotency key is required")
+		return
+	}
+
+	if req.Mode != "" && req.Mode != "direct" && req.Mode != "reserve" {
+		writeError(w, r, http.StatusBadRequest, "mode must be direct or reserve")
+		return
+	}
+
+	redemption, err := h.redemptionSvc.Redeem(r.Context(), service.RedeemRequest{
+		ChainID:        req.Chain,
+		UserAddress:    req.Address,
+		Amount:         req.Amount,
+		IdempotencyKey: idempotencyKey,
+		Reference:      req.Reference,
+		Reserve:        req.Mode == "reserve",
+	})
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, redemptionItem(redemption))
+}
+
+// CommitRedemption 处理 POST /api/v1/redemptions/{id}/commit
+func (h *RedemptionHandler) CommitRedemption(w http.ResponseWriter, r *http.Request) {
+	h.transitionRedemption(w, r, h.redemptionSvc.Commit)
+}
+
+// CancelRedemption 处理 POST /api/v1/redemptions/{id}/cancel
+func (h *RedemptionHandler) CancelRedemption(w http.ResponseWriter, r *http.Request) {
+	h.transitionRedemption(w, r, h.redemptionSvc.Cancel)
+}
+
+func (h *RedemptionHandler) transitionRedemption(w http.ResponseWriter, r *http.Request, transition func(ctx context.Context, id uint64) (*models.PointsRedemption, error)) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid redemption id")
+		return
+	}
+
+	redemption, err := transition(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, redemptionItem(redemption))
+}
+
+// ListRedemptions 处理 GET /api/v1/points/{chain}/{address}/redemptions?limit=20
+func (h *RedemptionHandler) ListRedemptions(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	redemptions, err := h.redemptionSvc.History(r.Context(), r.PathValue("chain"), r.PathValue("address"), limit)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "failed to get redemptions: "+err.Error())
+		return
+	}
+
+	items := make([]RedemptionItem, 0, len(redemptions))
+	for i := range redemptions {
+		items = append(items, redemptionItem(&redemptions[i]))
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func redemptionItem(r *models.PointsRedemption) RedemptionItem {
+	status := string(r.Status)
+	if r.IsExpired(time.Now()) {
+		status = "expired"
+	}
+
+	item := RedemptionItem{
+		ID:             r.ID,
+		Chain:          r.ChainID,
+		Address:        r.UserAddress,
+		Amount:         r.Amount,
+		Status:         status,
+		IdempotencyKey: r.IdempotencyKey,
+		Reference:      r.Reference,
+		CreatedAt:      r.CreatedAt.Format(time.RFC3339),
+	}
+	if r.ExpiresAt != nil {
+		item.ExpiresAt = r.ExpiresAt.Format(time.RFC3339)
+	}
+	if r.CommittedAt != nil {
+		item.CommittedAt = r.CommittedAt.Format(time.RFC3339)
+	}
+	if r.CancelledAt != nil {
+		item.CancelledAt = r.CancelledAt.Format(time.RFC3339)
+	}
+	return item
+}
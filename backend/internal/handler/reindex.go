@@ -0,0 +1,298 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/jobs"
+	"token-points-system/internal/models"
+	"token-points-system/internal/service"
+)
+
+// ReindexAccepted 已提交的同步或切换任务
+type ReindexAccepted struct {
+	JobAccepted
+	DatasetID uint64 `json:"datasetId"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type ReindexHandler struct {
+	reindexSvc *service.ReindexService
+	jobManager *jobs.Manager
+}
+
+func NewReindexHandler(reindexSvc *service.ReindexService, jobManager *jobs.Manager) *ReindexHandler {
+	return &ReindexHandler{reindexSvc: reindexSvc, jobManager: jobManager}
+}
+
+// Register 注册重新索引数据集接口，提交任务的接口以API Key的名称作为操作人
+func (h *ReindexHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/admin/reindex",
+		Scope:    models.ScopeRead,
+		Tag:      "admin",
+		Summary:  "分页列出重新索引数据集",
+		Query:    append([]api.Param{{Name: "chain"}}, pageQuery...),
+		Response: Page[models.ReindexDataset]{},
+		Handler:  h.ListDatasets,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/admin/reindex",
+		Scope:    models.ScopeAdminRecalc,
+		Tag:      "admin",
+		Summary:  "创建数据集并提交同步任务",
+		Request:  ChainRequest{},
+		Response: ReindexAccepted{},
+		Status:   http.StatusAccepted,
+		Handler:  h.CreateDataset,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/admin/reindex/rollback",
+		Scope:    models.ScopeAdminRecalc,
+		Tag:      "admin",
+		Summary:  "切换回当前正式数据集替换掉的快照",
+		Request:  ChainRequest{},
+		Response: ReindexAccepted{},
+		Status:   http.StatusAccepted,
+		Handler:  h.Rollback,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/admin/reindex/{id}",
+		Scope:    models.ScopeRead,
+		Tag:      "admin",
+		Summary:  "查询数据集",
+		Response: models.ReindexDataset{},
+		Handler:  h.GetDataset,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodDelete,
+		Path:     api.Prefix + "/admin/reindex/{id}",
+		Scope:    models.ScopeAdminRecalc,
+		Tag:      "admin",
+		Summary:  "丢弃数据集",
+		Response: models.ReindexDataset{},
+		Handler:  h.DiscardDataset,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/admin/reindex/{id}/diff",
+		Scope:    models.ScopeRead,
+		Tag:      "admin",
+		Summary:  "比较数据集与正式数据",
+		Response: service.ReindexDiffReport{},
+		Handler:  h.DiffDataset,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/admin/reindex/{id}/sync",
+		Scope:    models.ScopeAdminRecalc,
+		Tag:      "admin",
+		Summary:  "提交同步任务",
+		Response: ReindexAccepted{},
+		Status:   http.StatusAccepted,
+		Handler:  h.SyncDataset,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/admin/reindex/{id}/cutover",
+		Scope:    models.ScopeAdminRecalc,
+		Tag:      "admin",
+		Summary:  "提交切换任务",
+		Response: ReindexAccepted{},
+		Status:   http.StatusAccepted,
+		Handler:  h.CutoverDataset,
+	})
+
+	rt.Alias(http.MethodGet, "/api/admin/reindex", api.Prefix+"/admin/reindex")
+	rt.Alias(http.MethodPost, "/api/admin/reindex", api.Prefix+"/admin/reindex")
+	rt.Alias(http.MethodPost, "/api/admin/reindex/rollback", api.Prefix+"/admin/reindex/rollback")
+	rt.Alias(http.MethodGet, "/api/admin/reindex/{id}", api.Prefix+"/admin/reindex/{id}")
+	rt.Alias(http.MethodDelete, "/api/admin/reindex/{id}", api.Prefix+"/admin/reindex/{id}")
+	rt.Alias(http.MethodGet, "/api/admin/reindex/{id}/diff", api.Prefix+"/admin/reindex/{id}/diff")
+	rt.Alias(http.MethodPost, "/api/admin/reindex/{id}/sync", api.Prefix+"/admin/reindex/{id}/sync")
+	rt.Alias(http.MethodPost, "/api/admin/reindex/{id}/cutover", api.Prefix+"/admin/reindex/{id}/cutover")
+}
+
+// ListDatasets 处理 GET /api/v1/admin/reindex?chain=sepolia&page=1&page_size=20
+func (h *ReindexHandler) ListDatasets(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	datasets, total, err := h.reindexSvc.ListDatasets(r.Context(), r.URL.Query().Get("chain"), (page-1)*pageSize, pageSize)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Page[models.ReindexDataset]{
+		Items:    datasets,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// CreateDataset 处理 POST /api/v1/admin/reindex，创建数据集并提交同步任务
+func (h *ReindexHandler) CreateDataset(w http.ResponseWriter, r *http.Request) {
+	var req ChainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	actor, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+
+	dataset, err := h.reindexSvc.CreateDataset(r.Context(), req.Chain, actor)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	h.submit(w, r, models.JobTypeReindex, dataset, actor, "reindex job submitted")
+}
+
+// Rollback 处理 POST /api/v1/admin/reindex/rollback
+// 提交切换任务，把链切换回当前正式数据集替换掉的快照
+func (h *ReindexHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	var req ChainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	actor, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+
+	target, err := h.reindexSvc.RollbackTarget(r.Context(), req.Chain)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	h.submit(w, r, models.JobTypeCutover, target, actor, "rollback job submitted")
+}
+
+// GetDataset 处理 GET /api/v1/admin/reindex/{id}
+func (h *ReindexHandler) GetDataset(w http.ResponseWriter, r *http.Request) {
+	id, ok := datasetID(w, r)
+	if !ok {
+		return
+	}
+
+	dataset, err := h.reindexSvc.GetDataset(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dataset)
+}
+
+// DiscardDataset 处理 DELETE /api/v1/admin/reindex/{id}
+func (h *ReindexHandler) DiscardDataset(w http.ResponseWriter, r *http.Request) {
+	id, ok := datasetID(w, r)
+	if !ok {
+		return
+	}
+
+	dataset, err := h.reindexSvc.Discard(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dataset)
+}
+
+// DiffDataset 处理 GET /api/v1/admin/reindex/{id}/diff
+func (h *ReindexHandler) DiffDataset(w http.ResponseWriter, r *http.Request) {
+	id, ok := datasetID(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.reindexSvc.Diff(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// SyncDataset 处理 POST /api/v1/admin/reindex/{id}/sync
+func (h *ReindexHandler) SyncDataset(w http.ResponseWriter, r *http.Request) {
+	id, ok := datasetID(w, r)
+	if !ok {
+		return
+	}
+	actor, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+
+	dataset, err := h.reindexSvc.CheckSync(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	h.submit(w, r, models.JobTypeReindex, dataset, actor, "reindex job submitted")
+}
+
+// CutoverDataset 处理 POST /api/v1/admin/reindex/{id}/cutover
+func (h *ReindexHandler) CutoverDataset(w http.ResponseWriter, r *http.Request) {
+	id, ok := datasetID(w, r)
+	if !ok {
+		return
+	}
+	actor, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+
+	dataset, err := h.reindexSvc.CheckCutover(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	h.submit(w, r, models.JobTypeCutover, dataset, actor, "cutover job submitted")
+}
+
+// submit 为数据集提交同步或切换任务
+func (h *ReindexHandler) submit(w http.ResponseWriter, r *http.Request, jobType string, dataset *models.ReindexDataset, actor, message string) {
+	job, err := h.jobManager.Submit(r.Context(), jobType, service.ReindexParams{DatasetID: dataset.ID}, actor)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, ReindexAccepted{
+		JobAccepted: JobAccepted{Message: message, JobID: job.ID, Chain: dataset.ChainID},
+		DatasetID:   dataset.ID,
+		CreatedAt:   job.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+func datasetID(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid dataset id")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"token-points-system/internal/api"
+	"token-points-system/internal/models"
+	"token-points-system/internal/service"
+)
+
+// SeasonCreateRequest 创建赛季的请求，时间为RFC3339
+type SeasonCreateRequest struct {
+	Name          string `json:"name"`
+	StartAt       string `json:"startAt"`
+	EndAt         string `json:"endAt"`
+	CarryOverRate string `json:"carryOverRate"`
+}
+
+// SeasonStandingsResponse 赛季排名的一页
+type SeasonStandingsResponse struct {
+	Season   service.SeasonView      `json:"season"`
+	Chain    string                  `json:"chain"`
+	Frozen   bool                    `json:"frozen"`
+	Items    []models.SeasonStanding `json:"items"`
+	Total    int64                   `json:"total"`
+	Page     int                     `json:"page"`
+	PageSize int                     `json:"pageSize"`
+}
+
+type SeasonHandler struct {
+	seasonSvc *service.SeasonService
+}
+
+func NewSeasonHandler(seasonSvc *service.SeasonService) *SeasonHandler {
+	return &SeasonHandler{seasonSvc: seasonSvc}
+}
+
+// Register 注册赛季接口，{season}为赛季ID或current
+func (h *SeasonHandler) Register(rt *api.Router) {
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/seasons",
+		Tag:      "seasons",
+		Summary:  "列出赛季",
+		Response: []service.SeasonView{},
+		Handler:  h.ListSeasons,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/seasons/{season}",
+		Tag:      "seasons",
+		Summary:  "查询赛季",
+		Response: service.SeasonView{},
+		Handler:  h.GetSeason,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/seasons/{season}/standings/{chain}",
+		Tag:      "seasons",
+		Summary:  "赛季排名",
+		Query:    pageQuery,
+		Response: SeasonStandingsResponse{},
+		Handler:  h.GetStandings,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     api.Prefix + "/seasons/{season}/points/{chain}/{address}",
+		Tag:      "seasons",
+		Summary:  "用户在赛季中的积分和名次",
+		Response: service.SeasonUserStanding{},
+		Handler:  h.GetUserStanding,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/admin/seasons",
+		Scope:    models.ScopeAdminAdjust,
+		Tag:      "admin",
+		Summary:  "创建赛季",
+		Request:  SeasonCreateRequest{},
+		Response: service.SeasonView{},
+		Handler:  h.CreateSeason,
+	})
+	rt.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     api.Prefix + "/admin/seasons/{id}/close",
+		Scope:    models.ScopeAdminAdjust,
+		Tag:      "admin",
+		Summary:  "结束赛季并冻结排名",
+		Response: service.SeasonView{},
+		Handler:  h.CloseSeason,
+	})
+
+	rt.Alias(http.MethodGet, "/api/seasons", api.Prefix+"/seasons")
+	rt.Alias(http.MethodGet, "/api/seasons/{season}", api.Prefix+"/seasons/{season}")
+	rt.Alias(http.MethodGet, "/api/seasons/{season}/standings/{chain}", api.Prefix+"/seasons/{season}/standings/{chain}")
+	rt.Alias(http.MethodGet, "/api/seasons/{season}/points/{chain}/{address}", api.Prefix+"/seasons/{season}/points/{chain}/{address}")
+	rt.Alias(http.MethodPost, "/api/admin/seasons", api.Prefix+"/admin/seasons")
+	rt.Alias(http.MethodPost, "/api/admin/seasons/{id}/close", api.Prefix+"/admin/seasons/{id}/close")
+}
+
+// ListSeasons 处理 GET /api/v1/seasons
+func (h *SeasonHandler) ListSeasons(w http.ResponseWriter, r *http.Request) {
+	seasons, err := h.seasonSvc.ListSeasons(r.Context())
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, seasons)
+}
+
+// GetSeason 处理 GET /api/v1/seasons/{season}
+func (h *SeasonHandler) GetSeason(w http.ResponseWriter, r *http.Request) {
+	season, err := h.seasonSvc.GetSeason(r.Context(), r.PathValue("season"))
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, season)
+}
+
+// GetStandings 处理 GET /api/v1/seasons/{season}/standings/{chain}?page=1&page_size=20
+func (h *SeasonHandler) GetStandings(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	standings, err := h.seasonSvc.Standings(r.Context(), r.PathValue("season"), r.PathValue("chain"), (page-1)*pageSize, pageSize)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, SeasonStandingsResponse{
+		Season:   standings.Season,
+		Chain:    standings.ChainID,
+		Frozen:   standings.Frozen,
+		Items:    standings.Items,
+		Total:    standings.Total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// GetUserStanding 处理 GET /api/v1/seasons/{season}/points/{chain}/{address}
+func (h *SeasonHandler) GetUserStanding(w http.ResponseWriter, r *http.Request) {
+	standing, err := h.seasonSvc.UserStanding(r.Context(), r.PathValue("season"), r.PathValue("chain"), r.PathValue("address"))
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, standing)
+}
+
+// CreateSeason 处理 POST /api/v1/admin/seasons
+func (h *SeasonHandler) CreateSeason(w http.ResponseWriter, r *http.Request) {
+	var req SeasonCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	startAt, err := time.Parse(time.RFC3339, req.StartAt)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid startAt, expected RFC3339 time")
+		return
+	}
+	endAt, err := time.Parse(time.RFC3339, req.EndAt)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid endAt, expected RFC3339 time")
+		return
+	}
+
+	season, err := h.seasonSvc.CreateSeason(r.Context(), service.SeasonRequest{
+		Name:          req.Name,
+		StartAt:       startAt,
+		EndAt:         endAt,
+		CarryOverRate: req.CarryOverRate,
+		Actor:         actorOf(r),
+	})
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, season)
+}
+
+// CloseSeason 处理 POST /api/v1/admin/seasons/{id}/close
+func (h *SeasonHandler) CloseSeason(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid season id")
+		return
+	}
+
+	season, err := h.seasonSvc.CloseSeason(r.Context(), id)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, season)
+}
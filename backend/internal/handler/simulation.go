@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"token-points-system/internal/service"
+)
+
+// SimulateRulesRequest 规则模拟请求，start和end为RFC3339时间，top为返回差异最大的用户数
+type SimulateRulesRequest struct {
+	Chain       string `json:"chain"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	Rate        string `json:"rate"`
+	MinBalance  string `json:"minBalance"`
+	BalanceCap  string `json:"balanceCap"`
+	RuleVersion string `json:"ruleVersion"`
+	Top         int    `json:"top"`
+}
+
+// SimulateRules 处理 POST /api/v1/admin/points/simulate
+// 用候选规则重新计算历史窗口内的积分并与当前规则比较，不写入任何数据
+func (h *PointsHandler) SimulateRules(w http.ResponseWriter, r *http.Request) {
+	simulation, ok := parseSimulation(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.pointsSvc.SimulateRules(r.Context(), simulation, nil)
+	if err != nil {
+		writeAppError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// legacySimulateRules 处理 POST /api/admin/points/simulate[?format=csv]
+func (h *PointsHandler) legacySimulateRules(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") == "csv" {
+		h.ExportSimulation(w, r)
+		return
+	}
+	h.SimulateRules(w, r)
+}
+
+// ExportSimulation 处理 POST /api/v1/admin/points/simulate/export，以CSV导出每个用户的差异
+func (h *PointsHandler) ExportSimulation(w http.ResponseWriter, r *http.Request) {
+	simulation, ok := parseSimulation(w, r)
+	if !ok {
+		return
+	}
+
+	// CSV边计算边输出，写出第一行后出错只能中断响应
+	var writer *csv.Writer
+	_, err := h.pointsSvc.SimulateRules(r.Context(), simulation, func(d *service.UserDiff) error {
+		if writer == nil {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=simulation-%s.csv", simulation.ChainID))
+			writer = csv.NewWriter(w)
+			if err := writer.Write(service.SimulationCSVHeader); err != nil {
+				return err
+			}
+		}
+		return writer.Write(d.CSVRecord())
+	})
+	if writer == nil {
+		if err != nil {
+			writeAppError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		writer = csv.NewWriter(w)
+		writer.Write(service.SimulationCSVHeader)
+	}
+	writer.Flush()
+}
+
+func parseSimulation(w http.ResponseWriter, r *http.Request) (service.SimulationRequest, bool) {
+	var req SimulateRulesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return service.SimulationRequest{}, false
+	}
+
+	start, err := time.Parse(time.RFC3339, req.Start)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid start, expected RFC3339 time")
+		return service.SimulationRequest{}, false
+	}
+	end, err := time.Parse(time.RFC3339, req.End)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid end, expected RFC3339 time")
+		return service.SimulationRequest{}, false
+	}
+
+	return service.SimulationRequest{
+		ChainID: req.Chain,
+		Start:   start,
+		End:     end,
+		Candidate: service.RuleSet{
+			Rate:        req.Rate,
+			MinBalance:  req.MinBalance,
+			BalanceCap:  req.BalanceCap,
+			RuleVersion: req.RuleVersion,
+		},
+		Top: req.Top,
+	}, true
+}
@@ -0,0 +1,285 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"token-points-system/internal/config"
+	"token-points-system/internal/models"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+	"token-points-system/pkg/logger"
+)
+
+const (
+	defaultWorkers      = 2
+	defaultPollInterval = 2 * time.Second
+)
+
+// ErrCancelled 任务被取消，处理器收到后应立即返回
+var ErrCancelled = stderrors.New("job cancelled")
+
+// Handler 任务处理器
+// 处理器应定期调用Progress.Save保存进度；重启后以相同的job（含检查点）再次调用，需从检查点继续
+type Handler func(ctx context.Context, job *models.Job, progress *Progress) error
+
+// Progress 任务进度上报
+type Progress struct {
+	jobRepo *repository.JobRepository
+	job     *models.Job
+}
+
+// SetTotal 设置任务总量
+func (p *Progress) SetTotal(total int64) {
+	p.job.Total = total
+}
+
+// Add 累加已处理和失败的数量
+func (p *Progress) Add(processed, failed int64) {
+	p.job.Processed += processed
+	p.job.Failed += failed
+}
+
+// SetResult 设置任务结果报告，随下一次Save或任务结束时保存
+func (p *Progress) SetResult(result interface{}) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("序列化任务结果失败: %w", err)
+	}
+	p.job.Result = string(data)
+	return nil
+}
+
+// Save 持久化进度和检查点，任务已被请求取消时返回ErrCancelled
+func (p *Progress) Save(ctx context.Context, checkpoint string) error {
+	p.job.Checkpoint = checkpoint
+	cancelled, err := p.jobRepo.UpdateProgress(ctx, p.job)
+	if err != nil {
+		return fmt.Errorf("保存任务进度失败: %w", err)
+	}
+	if cancelled {
+		return ErrCancelled
+	}
+	return nil
+}
+
+// Manager 任务管理器
+// 任何节点都可以提交和查询任务，只有主节点调用Run执行任务
+type Manager struct {
+	jobRepo      *repository.JobRepository
+	handlers     map[string]Handler
+	owner        string
+	workers      int
+	pollInterval time.Duration
+}
+
+func NewManager(jobRepo *repository.JobRepository, owner string, cfg *config.JobsConfig) *Manager {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	pollInterval := time.Duration(cfg.PollInterval) * time.Second
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	return &Manager{
+		jobRepo:      jobRepo,
+		handlers:     make(map[string]Handler),
+		owner:        owner,
+		workers:      workers,
+		pollInterval: pollInterval,
+	}
+}
+
+// Register 注册任务类型的处理器，需在Run之前调用
+func (m *Manager) Register(jobType string, handler Handler) {
+	m.handlers[jobType] = handler
+}
+
+// Submit 提交任务
+func (m *Manager) Submit(ctx context.Context, jobType string, params interface{}, createdBy string) (*models.Job, error) {
+	if _, ok := m.handlers[jobType]; !ok {
+		return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("未知的任务类型: %s", jobType), nil)
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, errors.New(errors.ErrInvalidRequest, "任务参数无效", err)
+	}
+
+	job := &models.Job{
+		Type:      jobType,
+		Params:    string(data),
+		State:     models.JobPending,
+		CreatedBy: createdBy,
+	}
+	if err := m.jobRepo.Create(ctx, job); err != nil {
+		return nil, errors.New(errors.ErrJob, "创建任务失败", err)
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"job_id":     job.ID,
+		"type":       job.Type,
+		"params":     job.Params,
+		"created_by": createdBy,
+	}).Info("任务已提交")
+
+	return job, nil
+}
+
+// Get 获取任务
+func (m *Manager) Get(ctx context.Context, id uint64) (*models.Job, error) {
+	job, err := m.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.New(errors.ErrJob, "获取任务失败", err)
+	}
+	if job == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("任务不存在: %d", id), nil)
+	}
+	return job, nil
+}
+
+// List 获取任务列表
+func (m *Manager) List(ctx context.Context, jobType string, state models.JobState, limit int) ([]models.Job, error) {
+	return m.jobRepo.List(ctx, jobType, state, limit)
+}
+
+// Cancel 取消任务，已结束的任务不能取消
+func (m *Manager) Cancel(ctx context.Context, id uint64) (*models.Job, error) {
+	job, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.IsFinished() {
+		return nil, errors.New(errors.ErrConflict, fmt.Sprintf("任务已结束: %s", job.State), nil)
+	}
+
+	if err := m.jobRepo.RequestCancel(ctx, id); err != nil {
+		return nil, errors.New(errors.ErrJob, "取消任务失败", err)
+	}
+	return m.Get(ctx, id)
+}
+
+// Run 执行任务直到ctx结束
+// 启动时先将运行中的任务放回队列（上一个主节点中断的任务），随后按提交顺序并发执行
+func (m *Manager) Run(ctx context.Context) {
+	if n, err := m.jobRepo.RequeueAllRunning(ctx); err != nil {
+		logger.Error("恢复中断的任务失败:", err)
+	} else if n > 0 {
+		logger.WithFields(map[string]interface{}{
+			"jobs": n,
+		}).Info("已恢复中断的任务")
+	}
+
+	types := make([]string, 0, len(m.handlers))
+	for t := range m.handlers {
+		types = append(types, t)
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, m.workers)
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	logger.Info("任务执行器已启动")
+
+	for {
+		// 有空闲槽位时持续领取任务，直到队列为空
+		for len(slots) < cap(slots) {
+			job, err := m.jobRepo.ClaimNext(ctx, types, m.owner)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Error("领取任务失败:", err)
+				}
+				break
+			}
+			if job == nil {
+				break
+			}
+
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(job *models.Job) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				m.execute(ctx, job)
+			}(job)
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			logger.Info("任务执行器已停止")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) execute(ctx context.Context, job *models.Job) {
+	fields := map[string]interface{}{
+		"job_id": job.ID,
+		"type":   job.Type,
+	}
+	logger.WithFields(fields).Info("开始执行任务")
+
+	progress := &Progress{jobRepo: m.jobRepo, job: job}
+	err := m.runHandler(ctx, job, progress)
+
+	// 使用独立的ctx写入最终状态，避免主节点退位时无法落库
+	finishCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	switch {
+	case ctx.Err() != nil:
+		// 服务停止或失去主节点身份，任务放回队列由下一个主节点从检查点继续
+		if err := m.jobRepo.Requeue(finishCtx, job.ID); err != nil {
+			logger.Error("任务放回队列失败:", job.ID, err)
+		}
+		logger.WithFields(fields).Warn("任务已中断，等待恢复")
+	case stderrors.Is(err, ErrCancelled):
+		if err := m.jobRepo.Finish(finishCtx, job.ID, models.JobCancelled, ""); err != nil {
+			logger.Error("更新任务状态失败:", job.ID, err)
+		}
+		logger.WithFields(fields).Info("任务已取消")
+	case err != nil:
+		// 失败的任务也保留处理器写入的结果报告
+		if job.Result != "" {
+			if _, err := m.jobRepo.UpdateProgress(finishCtx, job); err != nil {
+				logger.Error("保存任务进度失败:", job.ID, err)
+			}
+		}
+		if err := m.jobRepo.Finish(finishCtx, job.ID, models.JobFailed, err.Error()); err != nil {
+			logger.Error("更新任务状态失败:", job.ID, err)
+		}
+		logger.WithFields(fields).Error("任务执行失败:", err)
+	default:
+		if _, err := m.jobRepo.UpdateProgress(finishCtx, job); err != nil {
+			logger.Error("保存任务进度失败:", job.ID, err)
+		}
+		if err := m.jobRepo.Finish(finishCtx, job.ID, models.JobSucceeded, ""); err != nil {
+			logger.Error("更新任务状态失败:", job.ID, err)
+		}
+		logger.WithFields(fields).Info("任务执行完成")
+	}
+}
+
+// runHandler 执行处理器，处理器panic时任务标记为失败
+func (m *Manager) runHandler(ctx context.Context, job *models.Job, progress *Progress) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("任务处理器panic: %v", r)
+		}
+	}()
+
+	handler, ok := m.handlers[job.Type]
+	if !ok {
+		return fmt.Errorf("未注册的任务类型: %s", job.Type)
+	}
+	return handler(ctx, job, progress)
+}
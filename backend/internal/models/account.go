@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+)
+
+type AccountAction string
+
+const (
+	AccountActionLink   AccountAction = "link"
+	AccountActionUnlink AccountAction = "unlink"
+)
+
+// SignatureScheme 钱包签名方式
+type SignatureScheme string
+
+const (
+	SignatureEIP191 SignatureScheme = "eip191"
+	SignatureEIP712 SignatureScheme = "eip712"
+)
+
+// Account 用户账户，可关联多个钱包，积分和余额按账户汇总
+type Account struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (Account) TableName() string {
+	return "accounts"
+}
+
+// AccountWallet 账户关联的钱包，一个钱包同时只能属于一个账户
+// Address统一存储为小写
+type AccountWallet struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountID uint64    `gorm:"not null;index" json:"account_id"`
+	Address   string    `gorm:"size:42;not null;uniqueIndex" json:"address"`
+	LinkedAt  time.Time `gorm:"not null" json:"linked_at"`
+}
+
+func (AccountWallet) TableName() string {
+	return "account_wallets"
+}
+
+// AccountNonce 关联/解除关联的一次性签名挑战
+// 挑战绑定账户钱包、目标钱包和操作，使用后或过期即失效，防止签名重放
+type AccountNonce struct {
+	Nonce          string        `gorm:"primaryKey;size:64" json:"nonce"`
+	Action         AccountAction `gorm:"type:enum('link','unlink');not null" json:"action"`
+	AccountAddress string        `gorm:"size:42;not null" json:"account_address"`
+	WalletAddress  string        `gorm:"size:42;not null" json:"wallet_address"`
+	ExpiresAt      time.Time     `gorm:"not null;index" json:"expires_at"`
+	UsedAt         *time.Time    `json:"used_at"`
+	CreatedAt      time.Time     `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (AccountNonce) TableName() string {
+	return "account_nonces"
+}
+
+// AccountAuditLog 账户钱包关联变更记录（只追加）
+type AccountAuditLog struct {
+	ID             uint64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountID      uint64          `gorm:"not null;index" json:"account_id"`
+	Action         AccountAction   `gorm:"type:enum('link','unlink');not null" json:"action"`
+	WalletAddress  string          `gorm:"size:42;not null;index" json:"wallet_address"`
+	AccountAddress string          `gorm:"size:42;not null" json:"account_address"`
+	Scheme         SignatureScheme `gorm:"type:enum('eip191','eip712');not null" json:"scheme"`
+	Nonce          string          `gorm:"size:64;not null" json:"nonce"`
+	CreatedAt      time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (AccountAuditLog) TableName() string {
+	return "account_audit_logs"
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// API Key权限
+const (
+	ScopeRead        = "read"
+	ScopeAdminRecalc = "admin:recalc"
+	ScopeAdminBackup = "admin:backup"
+	ScopeAdminAdjust = "admin:adjust"
+	ScopeAdminKeys   = "admin:keys"
+	ScopeRedeem      = "points:redeem"
+	ScopeAttest      = "attestations:issue"
+	ScopeLink        = "accounts:link"
+)
+
+// APIKeyScopes 全部有效的API Key权限
+var APIKeyScopes = []string{ScopeRead, ScopeAdminRecalc, ScopeAdminBackup, ScopeAdminAdjust, ScopeAdminKeys,
+	ScopeRedeem, ScopeAttest, ScopeLink}
+
+// APIKey 调用管理接口的API Key，只保存密钥的SHA-256摘要
+// Prefix为密钥中公开的部分，用于查找和识别；Scopes为逗号分隔的权限；ExpiresAt为空表示不过期
+// 轮换生成的新Key通过RotatedFromID指向旧Key，旧Key在宽限期结束时过期
+type APIKey struct {
+	ID            uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name          string     `gorm:"size:100;not null" json:"name"`
+	Prefix        string     `gorm:"size:16;not null;uniqueIndex:uk_prefix" json:"prefix"`
+	KeyHash       string     `gorm:"size:64;not null" json:"-"`
+	Scopes        string     `gorm:"size:255;not null" json:"scopes"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at"`
+	LastUsedAt    *time.Time `json:"last_used_at"`
+	RotatedFromID *uint64    `json:"rotated_from_id"`
+	CreatedBy     string     `gorm:"size:100" json:"created_by"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// ScopeList 返回权限列表
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return nil
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// Active Key在at时刻是否可用
+func (k *APIKey) Active(at time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || at.Before(*k.ExpiresAt)
+}
+
+// APIKeyUsage API Key的一次使用记录
+// Route为接口的路径模式，Path为实际请求路径；Status为响应状态码，权限不足被拒绝时为403
+type APIKeyUsage struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	KeyID      uint64    `gorm:"not null;index:idx_key_created" json:"key_id"`
+	Method     string    `gorm:"size:10;not null" json:"method"`
+	Route      string    `gorm:"size:255;not null" json:"route"`
+	Path       string    `gorm:"size:255;not null" json:"path"`
+	Scope      string    `gorm:"size:50;not null" json:"scope"`
+	Status     int       `gorm:"not null" json:"status"`
+	RemoteAddr string    `gorm:"size:64" json:"remote_addr"`
+	DurationMs int64     `gorm:"not null;default:0" json:"duration_ms"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;index:idx_key_created" json:"created_at"`
+}
+
+func (APIKeyUsage) TableName() string {
+	return "api_key_usage"
+}
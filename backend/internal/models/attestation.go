@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// PointsAttestation 已签发的积分证明记录
+// Points为签名时的积分，PointsUnits为签名中按18位小数放大的整数；SeasonID为0表示累计总积分
+// Nonce在同一账户内从1开始递增，Digest为EIP-712摘要
+type PointsAttestation struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Signer      string    `gorm:"size:42;not null" json:"signer"`
+	UserAddress string    `gorm:"size:42;not null;uniqueIndex:uk_user_nonce" json:"user_address"`
+	ChainID     string    `gorm:"size:50;not null" json:"chain_id"`
+	EVMChainID  uint64    `gorm:"column:evm_chain_id;not null" json:"evm_chain_id"`
+	SeasonID    uint64    `gorm:"not null;default:0" json:"season_id"`
+	Points      string    `gorm:"type:decimal(65,18);not null" json:"points"`
+	PointsUnits string    `gorm:"type:decimal(65,0);not null" json:"points_units"`
+	Nonce       uint64    `gorm:"not null;uniqueIndex:uk_user_nonce" json:"nonce"`
+	ExpiresAt   time.Time `gorm:"not null" json:"expires_at"`
+	Digest      string    `gorm:"size:66;not null" json:"digest"`
+	Signature   string    `gorm:"size:132;not null" json:"signature"`
+	RequestedBy string    `gorm:"size:100" json:"requested_by"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (PointsAttestation) TableName() string {
+	return "points_attestations"
+}
+
+// AttestationNonce 账户最近一次签发证明使用的nonce
+type AttestationNonce struct {
+	UserAddress string    `gorm:"primaryKey;size:42" json:"user_address"`
+	LastNonce   uint64    `gorm:"not null" json:"last_nonce"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (AttestationNonce) TableName() string {
+	return "attestation_nonces"
+}
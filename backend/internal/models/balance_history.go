@@ -12,17 +12,19 @@ const (
 	ChangeTypeBurn     ChangeType = "burn"
 )
 
+// BalanceHistory 余额变动记录，同一区块内按LogIndex即事件在区块中的日志序号排序
 type BalanceHistory struct {
 	ID            uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
-	ChainID       string    `gorm:"size:50;not null;index:idx_chain_user_time" json:"chain_id"`
-	UserAddress   string    `gorm:"size:42;not null;index:idx_chain_user_time" json:"user_address"`
+	ChainID       string    `gorm:"size:50;not null;index:idx_chain_user_time;index:idx_chain_time;uniqueIndex:uk_chain_event" json:"chain_id"`
+	UserAddress   string    `gorm:"size:42;not null;index:idx_chain_user_time;uniqueIndex:uk_chain_event" json:"user_address"`
 	BalanceBefore string    `gorm:"type:decimal(65,0);not null" json:"balance_before"`
 	BalanceAfter  string    `gorm:"type:decimal(65,0);not null" json:"balance_after"`
 	ChangeAmount  string    `gorm:"type:decimal(65,0);not null" json:"change_amount"`
 	ChangeType    ChangeType `gorm:"type:enum('transfer','mint','burn');not null" json:"change_type"`
-	TxHash        string    `gorm:"size:66;not null;uniqueIndex:uk_tx" json:"tx_hash"`
+	TxHash        string    `gorm:"size:66;not null;index;uniqueIndex:uk_chain_event" json:"tx_hash"`
+	LogIndex      uint      `gorm:"not null;default:0;uniqueIndex:uk_chain_event" json:"log_index"`
 	BlockNumber   int64     `gorm:"not null;index" json:"block_number"`
-	Timestamp     time.Time `gorm:"not null;index:idx_chain_user_time" json:"timestamp"`
+	Timestamp     time.Time `gorm:"not null;index:idx_chain_user_time;index:idx_chain_time" json:"timestamp"`
 	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
 
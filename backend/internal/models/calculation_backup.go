@@ -7,34 +7,78 @@ import (
 	"time"
 )
 
-type BackupType string
+type BackupTrigger string
 
 const (
-	BackupTypeBalanceSnapshot BackupType = "balance_snapshot"
-	BackupTypePointsSnapshot  BackupType = "points_snapshot"
-	BackupTypeCalcState       BackupType = "calculation_state"
+	BackupTriggerManual    BackupTrigger = "manual"
+	BackupTriggerScheduled BackupTrigger = "scheduled"
 )
 
-type JSONB map[string]interface{}
+// BackupVerifyStatus 备份的校验结果
+type BackupVerifyStatus string
 
-func (j JSONB) Value() (driver.Value, error) {
-	return json.Marshal(j)
+const (
+	BackupUnverified   BackupVerifyStatus = "unverified"
+	BackupVerified     BackupVerifyStatus = "verified"
+	BackupCorrupted    BackupVerifyStatus = "corrupted"
+	BackupUnverifiable BackupVerifyStatus = "unverifiable"
+)
+
+// BackupBalance 备份中的单个用户余额
+type BackupBalance struct {
+	UserAddress string `json:"user_address"`
+	Balance     string `json:"balance"`
+}
+
+// BackupPoints 备份中的单个用户积分
+type BackupPoints struct {
+	UserAddress      string     `json:"user_address"`
+	TotalPoints      string     `json:"total_points"`
+	LastCalculatedAt *time.Time `json:"last_calculated_at"`
+}
+
+// BackupData 一条链在同一时刻的余额、积分和计算状态
+// 余额对应BlockHeight区块处理完成时的状态；LedgerWatermark为备份时积分流水的最大ID，
+// 恢复时在备份积分上重放此后的流水，保证user_points仍是流水的汇总
+type BackupData struct {
+	BlockHeight     int64           `json:"block_height"`
+	LedgerWatermark uint64          `json:"ledger_watermark"`
+	LastPeriodEnd   *time.Time      `json:"last_period_end"`
+	Balances        []BackupBalance `json:"balances"`
+	Points          []BackupPoints  `json:"points"`
+}
+
+func (d BackupData) Value() (driver.Value, error) {
+	return json.Marshal(d)
 }
 
-func (j *JSONB) Scan(value interface{}) error {
+func (d *BackupData) Scan(value interface{}) error {
 	bytes, ok := value.([]byte)
 	if !ok {
 		return errors.New("type assertion to []byte failed")
 	}
-	return json.Unmarshal(bytes, j)
+	return json.Unmarshal(bytes, d)
 }
 
+// CalculationBackup 链状态备份
+// Checksum为BackupData规范JSON的SHA-256，恢复前校验
 type CalculationBackup struct {
-	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
-	ChainID     string    `gorm:"size:50;not null;index:idx_chain_type_time" json:"chain_id"`
-	BackupType  BackupType `gorm:"type:enum('balance_snapshot','points_snapshot','calculation_state');not null;index:idx_chain_type_time" json:"backup_type"`
-	BackupData  JSONB     `gorm:"type:json;not null" json:"backup_data"`
-	CreatedAt   time.Time `gorm:"autoCreateTime;index:idx_chain_type_time" json:"created_at"`
+	ID           uint64        `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChainID      string        `gorm:"size:50;not null;index:idx_chain_time" json:"chain_id"`
+	BlockHeight  int64         `gorm:"not null" json:"block_height"`
+	Checksum     string        `gorm:"size:64;not null" json:"checksum"`
+	Trigger      BackupTrigger `gorm:"column:trigger_type;type:enum('manual','scheduled');not null" json:"trigger"`
+	BalanceCount int           `gorm:"not null" json:"balance_count"`
+	PointsCount  int           `gorm:"not null" json:"points_count"`
+	BackupData   BackupData    `gorm:"type:json;not null" json:"-"`
+	CreatedBy    string        `gorm:"size:100" json:"created_by"`
+	CreatedAt    time.Time     `gorm:"autoCreateTime;index:idx_chain_time" json:"created_at"`
+	RestoredAt   *time.Time    `json:"restored_at"`
+	RestoredBy   string        `gorm:"size:100" json:"restored_by"`
+	// VerifyStatus 最近一次校验的结果，VerifyNote说明损坏或无法校验的原因
+	VerifyStatus BackupVerifyStatus `gorm:"type:enum('unverified','verified','corrupted','unverifiable');not null;default:unverified" json:"verify_status"`
+	VerifyNote   string             `gorm:"size:255" json:"verify_note"`
+	VerifiedAt   *time.Time         `json:"verified_at"`
 }
 
 func (CalculationBackup) TableName() string {
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// CalculationCursor 记录每条链最后一个已完整计算的积分周期
+type CalculationCursor struct {
+	ID            uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChainID       string    `gorm:"size:50;not null;uniqueIndex" json:"chain_id"`
+	LastPeriodEnd time.Time `gorm:"not null" json:"last_period_end"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (CalculationCursor) TableName() string {
+	return "calculation_cursors"
+}
+
+// ExpiryCursor 记录每条链最后一个已执行过期与衰减的UTC日期
+type ExpiryCursor struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChainID   string    `gorm:"size:50;not null;uniqueIndex" json:"chain_id"`
+	LastDay   time.Time `gorm:"type:date;not null" json:"last_day"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (ExpiryCursor) TableName() string {
+	return "expiry_cursors"
+}
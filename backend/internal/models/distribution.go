@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+)
+
+type DistributionSource string
+
+const (
+	// DistributionSourceChain 一条链当前的用户积分
+	DistributionSourceChain DistributionSource = "chain"
+	// DistributionSourceSeason 已关闭赛季在一条链上的冻结排名
+	DistributionSourceSeason DistributionSource = "season"
+	// DistributionSourceCampaign 已结束活动的最终排行榜快照
+	DistributionSourceCampaign DistributionSource = "campaign"
+)
+
+// Distribution 按积分快照分配代币预算生成的Merkle空投
+// SourceRef为赛季ID或活动ID，链积分来源为空；Rules为分配规则JSON
+// Budget与各地址Amount均为代币最小单位的整数，Amount之和等于Budget
+type Distribution struct {
+	ID          uint64             `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string             `gorm:"size:100;not null" json:"name"`
+	Source      DistributionSource `gorm:"type:enum('chain','season','campaign');not null" json:"source"`
+	SourceRef   string             `gorm:"size:64" json:"source_ref"`
+	ChainID     string             `gorm:"size:50" json:"chain_id"`
+	Budget      string             `gorm:"type:decimal(65,0);not null" json:"budget"`
+	Rules       string             `gorm:"type:json;not null" json:"rules"`
+	TotalPoints string             `gorm:"type:decimal(65,18);not null" json:"total_points"`
+	Recipients  int64              `gorm:"not null" json:"recipients"`
+	MerkleRoot  string             `gorm:"size:66;not null" json:"merkle_root"`
+	CreatedBy   string             `gorm:"size:100;not null" json:"created_by"`
+	CreatedAt   time.Time          `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (Distribution) TableName() string {
+	return "distributions"
+}
+
+// DistributionAllocation 分配结果中的一个地址，Seq为按地址排序从1开始的序号
+// Leaf为Merkle叶子哈希，Proof为验证路径的JSON数组
+type DistributionAllocation struct {
+	DistributionID uint64 `gorm:"primaryKey" json:"distribution_id"`
+	Seq            int64  `gorm:"primaryKey" json:"seq"`
+	UserAddress    string `gorm:"size:42;not null" json:"user_address"`
+	Points         string `gorm:"type:decimal(65,18);not null" json:"points"`
+	Amount         string `gorm:"type:decimal(65,0);not null" json:"amount"`
+	Leaf           string `gorm:"size:66;not null" json:"leaf"`
+	Proof          string `gorm:"type:json;not null" json:"-"`
+}
+
+func (DistributionAllocation) TableName() string {
+	return "distribution_allocations"
+}
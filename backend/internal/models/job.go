@@ -0,0 +1,62 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type JobState string
+
+const (
+	JobPending   JobState = "pending"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+const (
+	JobTypeRecalculate = "recalculate"
+	JobTypeBackup      = "backup"
+	JobTypeRestore     = "restore"
+	JobTypeRebuild     = "rebuild"
+	JobTypeReindex     = "reindex"
+	JobTypeCutover     = "cutover"
+)
+
+// Job 持久化的后台任务
+// Checkpoint由任务处理器定义，重启后从检查点继续执行
+type Job struct {
+	ID              uint64   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Type            string   `gorm:"size:32;not null;index:idx_type_state" json:"type"`
+	Params          string   `gorm:"type:json;not null" json:"params"`
+	State           JobState `gorm:"type:enum('pending','running','succeeded','failed','cancelled');not null;index:idx_type_state;index:idx_state" json:"state"`
+	Total           int64    `gorm:"not null;default:0" json:"total"`
+	Processed       int64    `gorm:"not null;default:0" json:"processed"`
+	Failed          int64    `gorm:"not null;default:0" json:"failed"`
+	Checkpoint      string   `gorm:"size:255" json:"checkpoint"`
+	CancelRequested bool     `gorm:"not null;default:false" json:"cancel_requested"`
+	Error           string   `gorm:"type:text" json:"error"`
+	// Result 处理器写入的结果报告（JSON），没有报告时为空
+	Result     string     `gorm:"type:json" json:"result"`
+	CreatedBy  string     `gorm:"size:100" json:"created_by"`
+	Owner      string     `gorm:"size:128" json:"owner"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (Job) TableName() string {
+	return "jobs"
+}
+
+// DecodeParams 解析任务参数
+func (j *Job) DecodeParams(v interface{}) error {
+	return json.Unmarshal([]byte(j.Params), v)
+}
+
+// IsFinished 任务是否已结束
+func (j *Job) IsFinished() bool {
+	return j.State == JobSucceeded || j.State == JobFailed || j.State == JobCancelled
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// LeaderLease 主节点租约，每个name同一时刻只有一个持有者
+type LeaderLease struct {
+	Name      string    `gorm:"primaryKey;size:64" json:"name"`
+	Holder    string    `gorm:"size:128;not null" json:"holder"`
+	ExpiresAt time.Time `gorm:"type:datetime(3);not null" json:"expires_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (LeaderLease) TableName() string {
+	return "leader_leases"
+}
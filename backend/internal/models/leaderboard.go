@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// LeaderboardSnapshot 排行榜快照，Scope为global、chain:{链ID}或campaign:{活动ID}
+type LeaderboardSnapshot struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Scope     string    `gorm:"size:64;not null;index:idx_scope_taken" json:"scope"`
+	TakenAt   time.Time `gorm:"not null;index:idx_scope_taken" json:"taken_at"`
+	Entries   int64     `gorm:"not null;default:0" json:"entries"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (LeaderboardSnapshot) TableName() string {
+	return "leaderboard_snapshots"
+}
+
+// LeaderboardEntry 快照中的一个排名单位（关联了账户的钱包按账户合并）
+// Seq为快照内从1开始的连续序号，分页按序号区间读取；积分相同时两种排名方式给出相同名次
+type LeaderboardEntry struct {
+	SnapshotID      uint64  `gorm:"primaryKey" json:"snapshot_id"`
+	Seq             int64   `gorm:"primaryKey" json:"seq"`
+	RankKey         string  `gorm:"size:64;not null" json:"rank_key"`
+	UserAddress     string  `gorm:"size:42;not null" json:"user_address"`
+	AccountID       *uint64 `json:"account_id"`
+	Score           string  `gorm:"type:decimal(65,18);not null" json:"score"`
+	RankCompetition int64   `gorm:"not null" json:"rank_competition"`
+	RankDense       int64   `gorm:"not null" json:"rank_dense"`
+}
+
+func (LeaderboardEntry) TableName() string {
+	return "leaderboard_entries"
+}
@@ -12,7 +12,10 @@ type PointCalculation struct {
 	PeriodEnd       time.Time `gorm:"not null;index:idx_chain_user_period" json:"period_end"`
 	PointsEarned    string    `gorm:"type:decimal(65,18);not null" json:"points_earned"`
 	CalculationHash string    `gorm:"size:64;not null;uniqueIndex" json:"calculation_hash"`
-	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
+	// Rate 计算时使用的费率，早期记录为空
+	Rate        *string   `gorm:"type:decimal(65,18)" json:"rate"`
+	RuleVersion string    `gorm:"size:32;not null;default:''" json:"rule_version"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
 
 func (PointCalculation) TableName() string {
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+)
+
+type AdjustmentStatus string
+
+const (
+	AdjustmentPending  AdjustmentStatus = "pending"
+	AdjustmentApplied  AdjustmentStatus = "applied"
+	AdjustmentRejected AdjustmentStatus = "rejected"
+)
+
+const (
+	AdjustmentReasonCompensation = "compensation"
+	AdjustmentReasonPromotion    = "promotion"
+	AdjustmentReasonExploit      = "exploit_clawback"
+	AdjustmentReasonCorrection   = "error_correction"
+	AdjustmentReasonOther        = "other"
+)
+
+const (
+	LedgerSourceAdjustment = "adjustment"
+)
+
+// PointsAdjustment 人工积分调整记录
+// 正数为补发（grant流水），负数为扣回（correction流水）
+type PointsAdjustment struct {
+	ID               uint64           `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChainID          string           `gorm:"size:50;not null;index:idx_chain_user" json:"chain_id"`
+	UserAddress      string           `gorm:"size:42;not null;index:idx_chain_user" json:"user_address"`
+	Amount           string           `gorm:"type:decimal(65,18);not null" json:"amount"`
+	ReasonCode       string           `gorm:"size:32;not null" json:"reason_code"`
+	Note             string           `gorm:"size:255" json:"note"`
+	Status           AdjustmentStatus `gorm:"type:enum('pending','applied','rejected');not null;index" json:"status"`
+	RequiresApproval bool             `gorm:"not null;default:false" json:"requires_approval"`
+	RequestedBy      string           `gorm:"size:100;not null" json:"requested_by"`
+	DecidedBy        string           `gorm:"size:100" json:"decided_by"`
+	DecidedAt        *time.Time       `json:"decided_at"`
+	ExpiresAt        *time.Time       `json:"expires_at"`
+	// CalculationID 被更正的周期计算记录，可为空
+	CalculationID *uint64   `gorm:"index" json:"calculation_id"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (PointsAdjustment) TableName() string {
+	return "points_adjustments"
+}
+
+// IsCredit 是否为补发积分
+func (a *PointsAdjustment) IsCredit() bool {
+	return len(a.Amount) > 0 && a.Amount[0] != '-'
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+type LedgerEntryType string
+
+const (
+	LedgerEntryAccrual    LedgerEntryType = "accrual"
+	LedgerEntryGrant      LedgerEntryType = "grant"
+	LedgerEntryCorrection LedgerEntryType = "correction"
+	LedgerEntryRedemption LedgerEntryType = "redemption"
+	LedgerEntryExpiration LedgerEntryType = "expiration"
+)
+
+const (
+	LedgerSourceCalculation = "calculation"
+)
+
+// PointsLedgerEntry 积分流水（只追加）
+// Amount为有符号数：入账为正，出账为负；user_points为流水的汇总投影
+type PointsLedgerEntry struct {
+	ID          uint64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChainID     string          `gorm:"size:50;not null;index:idx_chain_user_effective" json:"chain_id"`
+	UserAddress string          `gorm:"size:42;not null;index:idx_chain_user_effective" json:"user_address"`
+	EntryType   LedgerEntryType `gorm:"type:enum('accrual','grant','correction','redemption','expiration');not null;uniqueIndex:uk_source" json:"entry_type"`
+	Amount      string          `gorm:"type:decimal(65,18);not null" json:"amount"`
+	SourceType  string          `gorm:"size:32;not null;uniqueIndex:uk_source" json:"source_type"`
+	SourceRef   string          `gorm:"size:128;not null;uniqueIndex:uk_source" json:"source_ref"`
+	Memo        string          `gorm:"size:255" json:"memo"`
+	EffectiveAt time.Time       `gorm:"not null;index:idx_chain_user_effective" json:"effective_at"`
+	ExpiresAt   *time.Time      `json:"expires_at"`
+	CreatedAt   time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (PointsLedgerEntry) TableName() string {
+	return "points_ledger"
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+type RedemptionStatus string
+
+const (
+	RedemptionReserved  RedemptionStatus = "reserved"
+	RedemptionCommitted RedemptionStatus = "committed"
+	RedemptionCancelled RedemptionStatus = "cancelled"
+)
+
+const (
+	LedgerSourceRedemption = "redemption"
+)
+
+// PointsRedemption 积分兑换记录
+// reserved状态的记录会冻结可用积分，提交后写入redemption流水
+type PointsRedemption struct {
+	ID             uint64           `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChainID        string           `gorm:"size:50;not null;index:idx_chain_user_status" json:"chain_id"`
+	UserAddress    string           `gorm:"size:42;not null;index:idx_chain_user_status" json:"user_address"`
+	Amount         string           `gorm:"type:decimal(65,18);not null" json:"amount"`
+	Status         RedemptionStatus `gorm:"type:enum('reserved','committed','cancelled');not null;index:idx_chain_user_status" json:"status"`
+	IdempotencyKey string           `gorm:"size:128;not null;uniqueIndex" json:"idempotency_key"`
+	Reference      string           `gorm:"size:128" json:"reference"`
+	ExpiresAt      *time.Time       `json:"expires_at"`
+	CommittedAt    *time.Time       `json:"committed_at"`
+	CancelledAt    *time.Time       `json:"cancelled_at"`
+	CreatedAt      time.Time        `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time        `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (PointsRedemption) TableName() string {
+	return "points_redemptions"
+}
+
+// IsExpired 预留是否已过期
+func (r *PointsRedemption) IsExpired(now time.Time) bool {
+	return r.Status == RedemptionReserved && r.ExpiresAt != nil && !now.Before(*r.ExpiresAt)
+}
@@ -4,13 +4,30 @@ import (
 	"time"
 )
 
+// ProcessedBlock 链的区块处理游标
+// BlockTime为该区块的时间戳，表示余额历史已处理到的时间；从备份恢复或切换数据集后未知，为空
 type ProcessedBlock struct {
-	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
-	ChainID     string    `gorm:"uniqueIndex:uk_chain_block;size:50;not null" json:"chain_id"`
-	BlockNumber int64     `gorm:"uniqueIndex:uk_chain_block;not null" json:"block_number"`
-	ProcessedAt time.Time `gorm:"autoCreateTime" json:"processed_at"`
+	ID          uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChainID     string     `gorm:"uniqueIndex:uk_chain_block;size:50;not null" json:"chain_id"`
+	BlockNumber int64      `gorm:"uniqueIndex:uk_chain_block;not null" json:"block_number"`
+	BlockTime   *time.Time `json:"block_time"`
+	ProcessedAt time.Time  `gorm:"autoCreateTime" json:"processed_at"`
 }
 
 func (ProcessedBlock) TableName() string {
 	return "processed_blocks"
 }
+
+// ListenerRewind 链监听器的回退请求，恢复备份和切换重新索引数据集时在同一事务中写入
+// 每次请求Seq加1；主节点的监听器发现Seq变化后回退到BlockNumber，不依赖请求在哪个节点上执行
+type ListenerRewind struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChainID     string    `gorm:"uniqueIndex:uk_chain;size:50;not null" json:"chain_id"`
+	BlockNumber int64     `gorm:"not null" json:"block_number"`
+	Seq         uint64    `gorm:"not null;default:0" json:"seq"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (ListenerRewind) TableName() string {
+	return "listener_rewinds"
+}
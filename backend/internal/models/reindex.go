@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+)
+
+type ReindexStatus string
+
+const (
+	// ReindexSyncing 正在从链上同步
+	ReindexSyncing ReindexStatus = "syncing"
+	// ReindexReady 已同步到确认区块，可以切换
+	ReindexReady  ReindexStatus = "ready"
+	ReindexFailed ReindexStatus = "failed"
+	// ReindexLive 已切换为正式数据，影子表中不再保留数据
+	ReindexLive ReindexStatus = "live"
+	// ReindexRetired 曾经是正式数据，已被其他数据集替换
+	ReindexRetired   ReindexStatus = "retired"
+	ReindexDiscarded ReindexStatus = "discarded"
+)
+
+type ReindexSource string
+
+const (
+	// ReindexSourceChain 从StartBlock开始重新同步的数据集
+	ReindexSourceChain ReindexSource = "chain"
+	// ReindexSourceSnapshot 切换时保存的被替换的正式数据，用于回滚
+	ReindexSourceSnapshot ReindexSource = "snapshot"
+)
+
+// ReindexDataset 一条链余额和余额历史的影子数据集，版本即ID
+// SyncedBlock之前（含）的区块已同步；ReplacesID为切换时保存被替换正式数据的快照数据集
+// PointsStaleFrom为切换时新旧余额历史出现差异的最早时间，此后结束的StaleCalculations条积分计算记录
+// 是按被替换的余额历史计算的；没有差异时为空
+type ReindexDataset struct {
+	ID                uint64        `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChainID           string        `gorm:"size:50;not null;index:idx_chain_status" json:"chain_id"`
+	Status            ReindexStatus `gorm:"type:enum('syncing','ready','failed','live','retired','discarded');not null;index:idx_chain_status" json:"status"`
+	Source            ReindexSource `gorm:"type:enum('chain','snapshot');not null" json:"source"`
+	StartBlock        int64         `gorm:"not null" json:"start_block"`
+	SyncedBlock       int64         `gorm:"not null;default:0" json:"synced_block"`
+	ReplacesID        *uint64       `json:"replaces_id"`
+	Error             string        `gorm:"type:text" json:"error"`
+	CreatedBy         string        `gorm:"size:100" json:"created_by"`
+	CutoverBy         string        `gorm:"size:100" json:"cutover_by"`
+	CutoverAt         *time.Time    `json:"cutover_at"`
+	PointsStaleFrom   *time.Time    `json:"points_stale_from"`
+	StaleCalculations int64         `gorm:"not null;default:0" json:"stale_calculations"`
+	CreatedAt         time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (ReindexDataset) TableName() string {
+	return "reindex_datasets"
+}
+
+// ReindexBalance 影子数据集中的用户余额
+type ReindexBalance struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	DatasetID   uint64    `gorm:"uniqueIndex:uk_dataset_user;not null" json:"dataset_id"`
+	UserAddress string    `gorm:"uniqueIndex:uk_dataset_user;size:42;not null" json:"user_address"`
+	Balance     string    `gorm:"type:decimal(65,0);not null;default:0" json:"balance"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (ReindexBalance) TableName() string {
+	return "reindex_balances"
+}
+
+// ReindexHistory 影子数据集中的余额变动记录，LogIndex用于同一区块内排序，快照数据集中取自正式余额历史
+type ReindexHistory struct {
+	ID            uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	DatasetID     uint64     `gorm:"not null;index:idx_dataset_block" json:"dataset_id"`
+	UserAddress   string     `gorm:"size:42;not null" json:"user_address"`
+	BalanceBefore string     `gorm:"type:decimal(65,0);not null" json:"balance_before"`
+	BalanceAfter  string     `gorm:"type:decimal(65,0);not null" json:"balance_after"`
+	ChangeAmount  string     `gorm:"type:decimal(65,0);not null" json:"change_amount"`
+	ChangeType    ChangeType `gorm:"type:enum('transfer','mint','burn');not null" json:"change_type"`
+	TxHash        string     `gorm:"size:66;not null" json:"tx_hash"`
+	LogIndex      uint       `gorm:"not null;default:0" json:"log_index"`
+	BlockNumber   int64      `gorm:"not null;index:idx_dataset_block" json:"block_number"`
+	Timestamp     time.Time  `gorm:"not null" json:"timestamp"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (ReindexHistory) TableName() string {
+	return "reindex_balance_history"
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+)
+
+type SeasonStatus string
+
+const (
+	SeasonScheduled SeasonStatus = "scheduled"
+	SeasonActive    SeasonStatus = "active"
+	// SeasonClosing 已结束，等待所有链的周期计算覆盖结束时间后冻结排名
+	SeasonClosing SeasonStatus = "closing"
+	SeasonClosed  SeasonStatus = "closed"
+)
+
+// Season 积分赛季，周期开始时间落在[StartAt, EndAt)内的周期计算计入该赛季
+// CarryOverRate为赛季结束时最终积分结转到下一赛季的比例（0-1）
+type Season struct {
+	ID            uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name          string     `gorm:"size:100;not null" json:"name"`
+	StartAt       time.Time  `gorm:"not null;index" json:"start_at"`
+	EndAt         time.Time  `gorm:"not null" json:"end_at"`
+	CarryOverRate string     `gorm:"type:decimal(10,6);not null;default:0" json:"carry_over_rate"`
+	ClosedAt      *time.Time `json:"closed_at"`
+	Entries       int64      `gorm:"not null;default:0" json:"entries"`
+	CreatedBy     string     `gorm:"size:100;not null" json:"created_by"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (Season) TableName() string {
+	return "seasons"
+}
+
+// Status 返回赛季在now时刻的状态
+func (s *Season) Status(now time.Time) SeasonStatus {
+	switch {
+	case s.ClosedAt != nil:
+		return SeasonClosed
+	case now.Before(s.StartAt):
+		return SeasonScheduled
+	case now.Before(s.EndAt):
+		return SeasonActive
+	default:
+		return SeasonClosing
+	}
+}
+
+// SeasonStanding 赛季中用户在一条链上的积分与排名，赛季关闭时冻结
+// Total = Earned（赛季内周期计算积分之和）+ CarriedIn（上一赛季结转）；Seq为链内从1开始的连续序号
+type SeasonStanding struct {
+	SeasonID        uint64 `gorm:"primaryKey" json:"season_id"`
+	ChainID         string `gorm:"primaryKey;size:50" json:"chain_id"`
+	Seq             int64  `gorm:"primaryKey" json:"seq"`
+	UserAddress     string `gorm:"size:42;not null" json:"user_address"`
+	Earned          string `gorm:"type:decimal(65,18);not null" json:"earned"`
+	CarriedIn       string `gorm:"type:decimal(65,18);not null" json:"carried_in"`
+	Total           string `gorm:"type:decimal(65,18);not null" json:"total"`
+	RankCompetition int64  `gorm:"not null" json:"rank_competition"`
+	RankDense       int64  `gorm:"not null" json:"rank_dense"`
+}
+
+func (SeasonStanding) TableName() string {
+	return "season_standings"
+}
@@ -0,0 +1,242 @@
+package period
+
+import (
+	"fmt"
+	"time"
+
+	"token-points-system/internal/config"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	UnitMinute = "minute"
+	UnitHour   = "hour"
+	UnitDay    = "day"
+	UnitWeek   = "week"
+	UnitCustom = "custom"
+)
+
+// minCustomDuration 自定义周期的最小长度
+const minCustomDuration = time.Minute
+
+// cronCheckPeriods 校验显式cron时比对的周期边界数量
+const cronCheckPeriods = 64
+
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Period 积分计算周期，决定周期边界和调度时间
+// minute/hour按所在时区的自然日对齐，day为时区内的零点，week从周一零点开始，
+// custom为从anchor开始的固定时长
+type Period struct {
+	unit     string
+	every    int
+	duration time.Duration
+	anchor   time.Time
+	loc      *time.Location
+}
+
+// New 根据配置创建周期，配置不合法时返回错误
+func New(cfg config.PeriodConfig) (*Period, error) {
+	unit := cfg.Unit
+	if unit == "" {
+		unit = UnitHour
+	}
+	every := cfg.Every
+	if every == 0 {
+		every = 1
+	}
+
+	loc := time.Local
+	if cfg.Timezone != "" {
+		l, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("无效的时区 %q: %w", cfg.Timezone, err)
+		}
+		loc = l
+	}
+
+	p := &Period{unit: unit, every: every, loc: loc}
+
+	if unit != UnitCustom && (cfg.Duration != 0 || cfg.Anchor != "") {
+		return nil, fmt.Errorf("duration和anchor仅适用于custom周期")
+	}
+
+	switch unit {
+	case UnitMinute:
+		if every < 1 || 60%every != 0 {
+			return nil, fmt.Errorf("minute周期的every必须整除60，当前为%d", every)
+		}
+		p.duration = time.Duration(every) * time.Minute
+	case UnitHour:
+		if every < 1 || 24%every != 0 {
+			return nil, fmt.Errorf("hour周期的every必须整除24，当前为%d", every)
+		}
+		p.duration = time.Duration(every) * time.Hour
+	case UnitDay, UnitWeek:
+		if every != 1 {
+			return nil, fmt.Errorf("%s周期不支持every=%d，请使用custom周期", unit, every)
+		}
+	case UnitCustom:
+		if cfg.Every != 0 {
+			return nil, fmt.Errorf("custom周期请使用duration指定长度")
+		}
+		p.duration = time.Duration(cfg.Duration) * time.Second
+		if p.duration < minCustomDuration {
+			return nil, fmt.Errorf("custom周期的duration不能小于%d秒", int(minCustomDuration/time.Second))
+		}
+		if cfg.Anchor == "" {
+			return nil, fmt.Errorf("custom周期必须指定anchor")
+		}
+		anchor, err := time.ParseInLocation("2006-01-02T15:04:05", cfg.Anchor, loc)
+		if err != nil {
+			anchor, err = time.Parse(time.RFC3339, cfg.Anchor)
+			if err != nil {
+				return nil, fmt.Errorf("无效的anchor %q，应为RFC3339或2006-01-02T15:04:05格式", cfg.Anchor)
+			}
+		}
+		p.anchor = anchor
+	default:
+		return nil, fmt.Errorf("未知的周期单位: %s", unit)
+	}
+
+	return p, nil
+}
+
+// ForChain 获取链的计算周期，链未配置时使用全局周期
+func ForChain(points *config.PointsConfig, chain *config.ChainConfig) (*Period, error) {
+	cfg := points.Period
+	if chain.Period != nil {
+		cfg = *chain.Period
+	}
+
+	p, err := New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("链 %s 的积分周期配置无效: %w", chain.ID, err)
+	}
+	return p, nil
+}
+
+// Floor 返回t所在周期的起点
+func (p *Period) Floor(t time.Time) time.Time {
+	t = t.In(p.loc)
+	switch p.unit {
+	case UnitMinute:
+		minute := t.Minute() - t.Minute()%p.every
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), minute, 0, 0, p.loc)
+	case UnitHour:
+		hour := t.Hour() - t.Hour()%p.every
+		return time.Date(t.Year(), t.Month(), t.Day(), hour, 0, 0, 0, p.loc)
+	case UnitDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, p.loc)
+	case UnitWeek:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, p.loc)
+	default:
+		n := t.Sub(p.anchor) / p.duration
+		start := p.anchor.Add(n * p.duration)
+		if start.After(t) {
+			start = start.Add(-p.duration)
+		}
+		return start.In(p.loc)
+	}
+}
+
+// Next 返回以start为起点的周期的结束时间，start需为周期边界
+func (p *Period) Next(start time.Time) time.Time {
+	start = start.In(p.loc)
+	switch p.unit {
+	case UnitDay:
+		return start.AddDate(0, 0, 1)
+	case UnitWeek:
+		return start.AddDate(0, 0, 7)
+	case UnitHour:
+		// 按墙上时间推进，与CRON_TZ下的cron在夏令时切换日保持一致
+		next := time.Date(start.Year(), start.Month(), start.Day(), start.Hour()+p.every, 0, 0, 0, p.loc)
+		if next = p.Floor(next); next.After(start) {
+			return next
+		}
+		return start.Add(p.duration)
+	default:
+		return start.Add(p.duration)
+	}
+}
+
+// Previous 返回t所在周期的上一个完整周期的起点
+func (p *Period) Previous(t time.Time) time.Time {
+	return p.Floor(p.Floor(t).Add(-time.Nanosecond))
+}
+
+// End 返回t之后（不含t）的第一个周期边界；t不在边界上时返回所在周期的终点
+func (p *Period) End(t time.Time) time.Time {
+	return p.Next(p.Floor(t))
+}
+
+// Count 返回[from, to)内的周期数，from不在边界上时第一段按不完整周期计数
+func (p *Period) Count(from, to time.Time) int {
+	n := 0
+	for start := from; start.Before(to); start = p.End(start) {
+		n++
+	}
+	return n
+}
+
+// Cron 返回与周期边界一致的调度表达式（带秒）
+// custom周期无法用cron表达，每分钟整点检查一次是否有已结束的周期
+func (p *Period) Cron() string {
+	tz := "CRON_TZ=" + p.loc.String() + " "
+	switch p.unit {
+	case UnitMinute:
+		if p.every == 1 {
+			return tz + "0 * * * * *"
+		}
+		return tz + fmt.Sprintf("0 */%d * * * *", p.every)
+	case UnitHour:
+		if p.every == 1 {
+			return tz + "0 0 * * * *"
+		}
+		return tz + fmt.Sprintf("0 0 */%d * * *", p.every)
+	case UnitDay:
+		return tz + "0 0 0 * * *"
+	case UnitWeek:
+		return tz + "0 0 0 * * 1"
+	default:
+		return "0 * * * * *"
+	}
+}
+
+// ValidateCron 校验显式配置的cron是否恰好在每个周期边界触发
+// 触发时间早于或晚于边界、漏掉边界或在周期中间额外触发都视为不一致
+func (p *Period) ValidateCron(expr string) error {
+	if p.unit == UnitCustom {
+		return fmt.Errorf("custom周期不支持显式cron，请清空calculation_cron")
+	}
+
+	schedule, err := cronParser.Parse(expr)
+	if err != nil {
+		return fmt.Errorf("无效的cron表达式 %q: %w", expr, err)
+	}
+
+	boundary := p.End(time.Now())
+	fire := schedule.Next(boundary.Add(-time.Second))
+	for i := 0; i < cronCheckPeriods; i++ {
+		if !fire.Equal(boundary) {
+			return fmt.Errorf("cron表达式 %q 与%s周期不一致：期望在 %s 触发，实际为 %s",
+				expr, p, boundary.Format(time.RFC3339), fire.Format(time.RFC3339))
+		}
+		boundary = p.Next(boundary)
+		fire = schedule.Next(fire)
+	}
+	return nil
+}
+
+func (p *Period) String() string {
+	switch p.unit {
+	case UnitCustom:
+		return fmt.Sprintf("custom(%s from %s)", p.duration, p.anchor.Format(time.RFC3339))
+	case UnitDay, UnitWeek:
+		return fmt.Sprintf("%s(%s)", p.unit, p.loc)
+	default:
+		return fmt.Sprintf("%d %s(%s)", p.every, p.unit, p.loc)
+	}
+}
@@ -0,0 +1,208 @@
+package period
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"token-points-system/internal/config"
+)
+
+func mustNew(t *testing.T, cfg config.PeriodConfig) *Period {
+	t.Helper()
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New(%+v): %v", cfg, err)
+	}
+	return p
+}
+
+func mustTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	ts, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestNewValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.PeriodConfig
+		wantErr bool
+	}{
+		{name: "default hour", cfg: config.PeriodConfig{}},
+		{name: "15 minutes", cfg: config.PeriodConfig{Unit: UnitMinute, Every: 15}},
+		{name: "minutes not dividing an hour", cfg: config.PeriodConfig{Unit: UnitMinute, Every: 7}, wantErr: true},
+		{name: "4 hours", cfg: config.PeriodConfig{Unit: UnitHour, Every: 4, Timezone: "Asia/Shanghai"}},
+		{name: "hours not dividing a day", cfg: config.PeriodConfig{Unit: UnitHour, Every: 5}, wantErr: true},
+		{name: "every 2 days", cfg: config.PeriodConfig{Unit: UnitDay, Every: 2}, wantErr: true},
+		{name: "week", cfg: config.PeriodConfig{Unit: UnitWeek}},
+		{name: "custom", cfg: config.PeriodConfig{Unit: UnitCustom, Duration: 5400, Anchor: "2024-01-01T00:00:00Z"}},
+		{name: "custom without anchor", cfg: config.PeriodConfig{Unit: UnitCustom, Duration: 5400}, wantErr: true},
+		{name: "custom shorter than a minute", cfg: config.PeriodConfig{Unit: UnitCustom, Duration: 30, Anchor: "2024-01-01T00:00:00Z"}, wantErr: true},
+		{name: "custom with every", cfg: config.PeriodConfig{Unit: UnitCustom, Every: 2, Duration: 5400, Anchor: "2024-01-01T00:00:00Z"}, wantErr: true},
+		{name: "duration on hour", cfg: config.PeriodConfig{Unit: UnitHour, Duration: 3600}, wantErr: true},
+		{name: "unknown unit", cfg: config.PeriodConfig{Unit: "month"}, wantErr: true},
+		{name: "unknown timezone", cfg: config.PeriodConfig{Timezone: "Mars/Olympus"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFloorAndEnd(t *testing.T) {
+	utc := config.PeriodConfig{Timezone: "UTC"}
+	custom := config.PeriodConfig{Unit: UnitCustom, Duration: 5400, Anchor: "2024-01-01T00:00:00Z", Timezone: "UTC"}
+
+	tests := []struct {
+		name      string
+		cfg       config.PeriodConfig
+		at        string
+		wantFloor string
+		wantEnd   string
+	}{
+		{
+			name:      "15 minutes",
+			cfg:       config.PeriodConfig{Unit: UnitMinute, Every: 15, Timezone: "UTC"},
+			at:        "2024-01-01T10:37:12Z",
+			wantFloor: "2024-01-01T10:30:00Z",
+			wantEnd:   "2024-01-01T10:45:00Z",
+		},
+		{
+			name:      "on a boundary",
+			cfg:       utc,
+			at:        "2024-01-01T10:00:00Z",
+			wantFloor: "2024-01-01T10:00:00Z",
+			wantEnd:   "2024-01-01T11:00:00Z",
+		},
+		{
+			name:      "last hours of the day",
+			cfg:       config.PeriodConfig{Unit: UnitHour, Every: 4, Timezone: "UTC"},
+			at:        "2024-01-01T23:59:59Z",
+			wantFloor: "2024-01-01T20:00:00Z",
+			wantEnd:   "2024-01-02T00:00:00Z",
+		},
+		{
+			name:      "hour in another timezone",
+			cfg:       config.PeriodConfig{Unit: UnitHour, Timezone: "Asia/Shanghai"},
+			at:        "2024-01-01T10:30:00Z",
+			wantFloor: "2024-01-01T18:00:00+08:00",
+			wantEnd:   "2024-01-01T19:00:00+08:00",
+		},
+		{
+			name:      "day starts at local midnight",
+			cfg:       config.PeriodConfig{Unit: UnitDay, Timezone: "Asia/Shanghai"},
+			at:        "2024-01-01T20:00:00Z",
+			wantFloor: "2024-01-02T00:00:00+08:00",
+			wantEnd:   "2024-01-03T00:00:00+08:00",
+		},
+		{
+			name:      "day across daylight saving change",
+			cfg:       config.PeriodConfig{Unit: UnitDay, Timezone: "America/New_York"},
+			at:        "2024-03-10T12:00:00-04:00",
+			wantFloor: "2024-03-10T00:00:00-05:00",
+			wantEnd:   "2024-03-11T00:00:00-04:00",
+		},
+		{
+			name:      "week starts on monday",
+			cfg:       config.PeriodConfig{Unit: UnitWeek, Timezone: "UTC"},
+			at:        "2024-01-07T12:00:00Z",
+			wantFloor: "2024-01-01T00:00:00Z",
+			wantEnd:   "2024-01-08T00:00:00Z",
+		},
+		{
+			name:      "custom after anchor",
+			cfg:       custom,
+			at:        "2024-01-01T04:00:00Z",
+			wantFloor: "2024-01-01T03:00:00Z",
+			wantEnd:   "2024-01-01T04:30:00Z",
+		},
+		{
+			name:      "custom before anchor",
+			cfg:       custom,
+			at:        "2023-12-31T23:00:00Z",
+			wantFloor: "2023-12-31T22:30:00Z",
+			wantEnd:   "2024-01-01T00:00:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := mustNew(t, tt.cfg)
+			at := mustTime(t, tt.at)
+
+			if got, want := p.Floor(at), mustTime(t, tt.wantFloor); !got.Equal(want) {
+				t.Errorf("Floor = %s, want %s", got.Format(time.RFC3339), tt.wantFloor)
+			}
+			if got, want := p.End(at), mustTime(t, tt.wantEnd); !got.Equal(want) {
+				t.Errorf("End = %s, want %s", got.Format(time.RFC3339), tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestPreviousAndCount(t *testing.T) {
+	p := mustNew(t, config.PeriodConfig{Timezone: "UTC"})
+
+	if got, want := p.Previous(mustTime(t, "2024-01-01T10:30:00Z")), mustTime(t, "2024-01-01T09:00:00Z"); !got.Equal(want) {
+		t.Errorf("Previous = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		from, to string
+		want     int
+	}{
+		{"2024-01-01T10:00:00Z", "2024-01-01T10:00:00Z", 0},
+		{"2024-01-01T10:00:00Z", "2024-01-01T13:00:00Z", 3},
+		// 起点不在边界上时第一段按不完整周期计数
+		{"2024-01-01T10:30:00Z", "2024-01-01T13:00:00Z", 3},
+		{"2024-01-01T10:00:00Z", "2024-01-01T12:30:00Z", 3},
+	}
+	for _, tt := range tests {
+		if got := p.Count(mustTime(t, tt.from), mustTime(t, tt.to)); got != tt.want {
+			t.Errorf("Count(%s, %s) = %d, want %d", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCron(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.PeriodConfig
+		expr    string
+		wantErr bool
+	}{
+		{name: "generated minute", cfg: config.PeriodConfig{Unit: UnitMinute, Every: 15, Timezone: "UTC"}},
+		{name: "generated hour", cfg: config.PeriodConfig{Unit: UnitHour, Every: 6, Timezone: "Asia/Shanghai"}},
+		{name: "generated day", cfg: config.PeriodConfig{Unit: UnitDay, Timezone: "America/New_York"}},
+		{name: "generated week", cfg: config.PeriodConfig{Unit: UnitWeek, Timezone: "UTC"}},
+		{name: "offset from boundary", cfg: config.PeriodConfig{Timezone: "UTC"}, expr: "CRON_TZ=UTC 0 30 * * * *", wantErr: true},
+		{name: "fires mid-period", cfg: config.PeriodConfig{Unit: UnitHour, Every: 2, Timezone: "UTC"}, expr: "CRON_TZ=UTC 0 0 * * * *", wantErr: true},
+		{name: "misses boundaries", cfg: config.PeriodConfig{Timezone: "UTC"}, expr: "CRON_TZ=UTC 0 0 */2 * * *", wantErr: true},
+		{name: "wrong timezone", cfg: config.PeriodConfig{Unit: UnitDay, Timezone: "Asia/Shanghai"}, expr: "CRON_TZ=UTC 0 0 0 * * *", wantErr: true},
+		{name: "custom", cfg: config.PeriodConfig{Unit: UnitCustom, Duration: 5400, Anchor: "2024-01-01T00:00:00Z"}, expr: "0 * * * * *", wantErr: true},
+		{name: "invalid expression", cfg: config.PeriodConfig{Timezone: "UTC"}, expr: "every hour", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := mustNew(t, tt.cfg)
+			expr := tt.expr
+			if expr == "" {
+				expr = p.Cron()
+			}
+			err := p.ValidateCron(expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateCron(%q) err = %v, wantErr %v", expr, err, tt.wantErr)
+			}
+		})
+	}
+}
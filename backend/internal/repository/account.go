@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNonceInvalid    = errors.New("nonce is unknown, expired or already used")
+	ErrWalletLinked    = errors.New("wallet is already linked to another account")
+	ErrAlreadyLinked   = errors.New("wallets are already linked to the same account")
+	ErrWalletNotLinked = errors.New("wallet is not linked to the account")
+	ErrTooManyWallets  = errors.New("account has reached the maximum number of wallets")
+)
+
+type AccountRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountRepository(db *gorm.DB) *AccountRepository {
+	return &AccountRepository{db: db}
+}
+
+// CreateNonce 保存签名挑战
+func (r *AccountRepository) CreateNonce(ctx context.Context, nonce *models.AccountNonce) error {
+	return r.db.WithContext(ctx).Create(nonce).Error
+}
+
+// GetNonce 获取签名挑战，不存在时返回nil
+func (r *AccountRepository) GetNonce(ctx context.Context, nonce string) (*models.AccountNonce, error) {
+	var n models.AccountNonce
+	err := r.db.WithContext(ctx).Where("nonce = ?", nonce).First(&n).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &n, err
+}
+
+// GetWallet 获取钱包的关联记录，未关联时返回nil
+func (r *AccountRepository) GetWallet(ctx context.Context, address string) (*models.AccountWallet, error) {
+	var w models.AccountWallet
+	err := r.db.WithContext(ctx).Where("address = ?", address).First(&w).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &w, err
+}
+
+// GetWallets 获取账户关联的全部钱包，按关联时间排序
+func (r *AccountRepository) GetWallets(ctx context.Context, accountID uint64) ([]models.AccountWallet, error) {
+	var wallets []models.AccountWallet
+	err := r.db.WithContext(ctx).
+		Where("account_id = ?", accountID).
+		Order("linked_at ASC, id ASC").
+		Find(&wallets).Error
+	return wallets, err
+}
+
+// GetWalletsByAccounts 批量获取多个账户关联的钱包
+func (r *AccountRepository) GetWalletsByAccounts(ctx context.Context, accountIDs []uint64) ([]models.AccountWallet, error) {
+	var wallets []models.AccountWallet
+	if len(accountIDs) == 0 {
+		return wallets, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("account_id IN ?", accountIDs).
+		Order("linked_at ASC, id ASC").
+		Find(&wallets).Error
+	return wallets, err
+}
+
+// GetAuditLogs 按时间倒序获取账户的关联变更记录
+func (r *AccountRepository) GetAuditLogs(ctx context.Context, accountID uint64, limit int) ([]models.AccountAuditLog, error) {
+	var logs []models.AccountAuditLog
+	query := r.db.WithContext(ctx).
+		Where("account_id = ?", accountID).
+		Order("created_at DESC, id DESC")
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	err := query.Find(&logs).Error
+	return logs, err
+}
+
+// Link 消费挑战并把两个钱包关联到同一账户，返回账户ID
+// 两个钱包都未关联时创建新账户；只有一个已关联时把另一个加入该账户；分别属于不同账户时返回ErrWalletLinked
+func (r *AccountRepository) Link(ctx context.Context, nonce *models.AccountNonce, scheme models.SignatureScheme, maxWallets int) (uint64, error) {
+	var accountID uint64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := consumeNonce(tx, nonce.Nonce, models.AccountActionLink); err != nil {
+			return err
+		}
+
+		linked, err := lockWallets(tx, nonce.AccountAddress, nonce.WalletAddress)
+		if err != nil {
+			return err
+		}
+		anchor, wallet := linked[nonce.AccountAddress], linked[nonce.WalletAddress]
+
+		var pending []string
+		switch {
+		case anchor != nil && wallet != nil:
+			if anchor.AccountID == wallet.AccountID {
+				return ErrAlreadyLinked
+			}
+			return ErrWalletLinked
+		case anchor != nil:
+			accountID = anchor.AccountID
+			pending = []string{nonce.WalletAddress}
+		case wallet != nil:
+			accountID = wallet.AccountID
+			pending = []string{nonce.AccountAddress}
+		default:
+			account := &models.Account{}
+			if err := tx.Create(account).Error; err != nil {
+				return err
+			}
+			accountID = account.ID
+			pending = []string{nonce.AccountAddress}
+			if nonce.WalletAddress != nonce.AccountAddress {
+				pending = append(pending, nonce.WalletAddress)
+			}
+		}
+
+		if maxWallets > 0 {
+			var count int64
+			if err := tx.Model(&models.AccountWallet{}).Where("account_id = ?", accountID).Count(&count).Error; err != nil {
+				return err
+			}
+			if int(count)+len(pending) > maxWallets {
+				return ErrTooManyWallets
+			}
+		}
+
+		now := time.Now()
+		for _, address := range pending {
+			if err := tx.Create(&models.AccountWallet{AccountID: accountID, Address: address, LinkedAt: now}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.AccountAuditLog{
+				AccountID:      accountID,
+				Action:         models.AccountActionLink,
+				WalletAddress:  address,
+				AccountAddress: nonce.AccountAddress,
+				Scheme:         scheme,
+				Nonce:          nonce.Nonce,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Account{}).Where("id = ?", accountID).Update("updated_at", now).Error
+	})
+	return accountID, err
+}
+
+// Unlink 消费挑战并把钱包从账户移除，账户钱包与目标钱包必须属于同一账户，返回账户ID
+func (r *AccountRepository) Unlink(ctx context.Context, nonce *models.AccountNonce, scheme models.SignatureScheme) (uint64, error) {
+	var accountID uint64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := consumeNonce(tx, nonce.Nonce, models.AccountActionUnlink); err != nil {
+			return err
+		}
+
+		linked, err := lockWallets(tx, nonce.AccountAddress, nonce.WalletAddress)
+		if err != nil {
+			return err
+		}
+		anchor, wallet := linked[nonce.AccountAddress], linked[nonce.WalletAddress]
+		if anchor == nil || wallet == nil || anchor.AccountID != wallet.AccountID {
+			return ErrWalletNotLinked
+		}
+		accountID = wallet.AccountID
+
+		if err := tx.Delete(&models.AccountWallet{}, wallet.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.AccountAuditLog{
+			AccountID:      accountID,
+			Action:         models.AccountActionUnlink,
+			WalletAddress:  nonce.WalletAddress,
+			AccountAddress: nonce.AccountAddress,
+			Scheme:         scheme,
+			Nonce:          nonce.Nonce,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Account{}).Where("id = ?", accountID).Update("updated_at", time.Now()).Error
+	})
+	return accountID, err
+}
+
+// consumeNonce 原子地把未使用且未过期的挑战标记为已使用
+func consumeNonce(tx *gorm.DB, nonce string, action models.AccountAction) error {
+	result := tx.Model(&models.AccountNonce{}).
+		Where("nonce = ? AND action = ? AND used_at IS NULL AND expires_at > ?", nonce, action, time.Now()).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNonceInvalid
+	}
+	return nil
+}
+
+// lockWallets 锁定读取钱包的关联记录（含间隙锁，防止并发关联同一钱包），按地址返回
+func lockWallets(tx *gorm.DB, addresses ...string) (map[string]*models.AccountWallet, error) {
+	var wallets []models.AccountWallet
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("address IN ?", addresses).
+		Find(&wallets).Error
+	if err != nil {
+		return nil, err
+	}
+
+	linked := make(map[string]*models.AccountWallet, len(wallets))
+	for i := range wallets {
+		linked[wallets[i].Address] = &wallets[i]
+	}
+	return linked, nil
+}
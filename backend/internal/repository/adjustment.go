@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrAdjustmentState = errors.New("adjustment is not pending")
+
+type AdjustmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAdjustmentRepository(db *gorm.DB) *AdjustmentRepository {
+	return &AdjustmentRepository{db: db}
+}
+
+// Create 创建调整记录，状态为applied时在同一事务中写入积分流水
+func (r *AdjustmentRepository) Create(ctx context.Context, adj *models.PointsAdjustment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(adj).Error; err != nil {
+			return err
+		}
+		if adj.Status == models.AdjustmentApplied {
+			return appendAdjustmentEntry(tx, adj)
+		}
+		return nil
+	})
+}
+
+// Decide 审批待处理的调整，通过时写入积分流水
+func (r *AdjustmentRepository) Decide(ctx context.Context, id uint64, status models.AdjustmentStatus, decidedBy string, check func(*models.PointsAdjustment) error) (*models.PointsAdjustment, error) {
+	var adj models.PointsAdjustment
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&adj, id).Error; err != nil {
+			return err
+		}
+		if adj.Status != models.AdjustmentPending {
+			return ErrAdjustmentState
+		}
+		if check != nil {
+			if err := check(&adj); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		if err := tx.Model(&adj).Updates(map[string]interface{}{
+			"status":     status,
+			"decided_by": decidedBy,
+			"decided_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		if status == models.AdjustmentApplied {
+			return appendAdjustmentEntry(tx, &adj)
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &adj, err
+}
+
+// GetByID 根据ID获取调整记录
+func (r *AdjustmentRepository) GetByID(ctx context.Context, id uint64) (*models.PointsAdjustment, error) {
+	var adj models.PointsAdjustment
+	err := r.db.WithContext(ctx).First(&adj, id).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &adj, err
+}
+
+// List 按状态获取调整记录，status为空时返回全部
+func (r *AdjustmentRepository) List(ctx context.Context, status models.AdjustmentStatus, limit int) ([]models.PointsAdjustment, error) {
+	var adjustments []models.PointsAdjustment
+	query := r.db.WithContext(ctx).Order("created_at DESC, id DESC")
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	err := query.Find(&adjustments).Error
+	return adjustments, err
+}
+
+// GetByUser 按创建时间倒序获取用户的调整记录
+func (r *AdjustmentRepository) GetByUser(ctx context.Context, chainID, userAddress string, limit int) ([]models.PointsAdjustment, error) {
+	var adjustments []models.PointsAdjustment
+	query := r.db.WithContext(ctx).
+		Where("chain_id = ? AND user_address = ?", chainID, userAddress).
+		Order("created_at DESC, id DESC")
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	err := query.Find(&adjustments).Error
+	return adjustments, err
+}
+
+// GetByCalculation 获取针对指定计算记录的调整，按创建时间排序
+func (r *AdjustmentRepository) GetByCalculation(ctx context.Context, calculationID uint64) ([]models.PointsAdjustment, error) {
+	var adjustments []models.PointsAdjustment
+	err := r.db.WithContext(ctx).
+		Where("calculation_id = ?", calculationID).
+		Order("created_at ASC, id ASC").
+		Find(&adjustments).Error
+	return adjustments, err
+}
+
+func appendAdjustmentEntry(tx *gorm.DB, adj *models.PointsAdjustment) error {
+	entryType := models.LedgerEntryCorrection
+	if adj.IsCredit() {
+		entryType = models.LedgerEntryGrant
+	}
+
+	_, err := appendLedgerEntry(tx, &models.PointsLedgerEntry{
+		ChainID:     adj.ChainID,
+		UserAddress: adj.UserAddress,
+		EntryType:   entryType,
+		Amount:      adj.Amount,
+		SourceType:  models.LedgerSourceAdjustment,
+		SourceRef:   fmt.Sprintf("%d", adj.ID),
+		Memo:        adj.ReasonCode,
+		ExpiresAt:   adj.ExpiresAt,
+	})
+	return err
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// APIKeyRepository API Key及其使用记录
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+// GetByID 获取API Key，不存在时返回nil
+func (r *APIKeyRepository) GetByID(ctx context.Context, id uint64) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.WithContext(ctx).First(&key, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &key, err
+}
+
+// GetByPrefix 按公开前缀获取API Key，不存在时返回nil
+func (r *APIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.WithContext(ctx).Where("prefix = ?", prefix).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &key, err
+}
+
+// List 按ID倒序分页获取API Key
+func (r *APIKeyRepository) List(ctx context.Context, offset, limit int) ([]models.APIKey, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.APIKey{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var keys []models.APIKey
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&keys).Error
+	return keys, total, err
+}
+
+// Rotate 在一个事务中创建新Key，并让旧Key最晚在retireAt过期
+// check在锁定旧Key后调用，返回错误时不做任何修改；旧Key不存在时返回nil
+func (r *APIKeyRepository) Rotate(ctx context.Context, oldID uint64, retireAt time.Time, check func(old *models.APIKey) error, newKey *models.APIKey) (*models.APIKey, error) {
+	var old models.APIKey
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&old, oldID).Error; err != nil {
+			return err
+		}
+		if err := check(&old); err != nil {
+			return err
+		}
+
+		if old.ExpiresAt == nil || retireAt.Before(*old.ExpiresAt) {
+			old.ExpiresAt = &retireAt
+			if err := tx.Model(&old).Update("expires_at", retireAt).Error; err != nil {
+				return err
+			}
+		}
+		newKey.RotatedFromID = &old.ID
+		return tx.Create(newKey).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &old, nil
+}
+
+// Revoke 吊销API Key，已吊销的Key不变；返回是否有Key被吊销
+func (r *APIKeyRepository) Revoke(ctx context.Context, id uint64, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+// RecordUsage 保存使用记录并更新Key的最后使用时间
+func (r *APIKeyRepository) RecordUsage(ctx context.Context, usage *models.APIKeyUsage) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(usage).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.APIKey{}).Where("id = ?", usage.KeyID).
+			UpdateColumn("last_used_at", usage.CreatedAt).Error
+	})
+}
+
+// ListUsage 按时间倒序分页获取Key的使用记录
+func (r *APIKeyRepository) ListUsage(ctx context.Context, keyID uint64, offset, limit int) ([]models.APIKeyUsage, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.APIKeyUsage{}).Where("key_id = ?", keyID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var usage []models.APIKeyUsage
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&usage).Error
+	return usage, total, err
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// AttestationRepository 积分证明记录及各账户的nonce
+type AttestationRepository struct {
+	db *gorm.DB
+}
+
+func NewAttestationRepository(db *gorm.DB) *AttestationRepository {
+	return &AttestationRepository{db: db}
+}
+
+// Create 为账户分配下一个nonce，调用sign签名后保存证明记录
+// nonce计数行在事务内加锁，同一账户的签发串行执行；sign失败或保存失败时nonce不会被占用
+func (r *AttestationRepository) Create(ctx context.Context, attestation *models.PointsAttestation, sign func(*models.PointsAttestation) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			INSERT INTO attestation_nonces (user_address, last_nonce) VALUES (?, 1)
+			ON DUPLICATE KEY UPDATE last_nonce = last_nonce + 1`, attestation.UserAddress).Error; err != nil {
+			return err
+		}
+
+		var nonce models.AttestationNonce
+		if err := tx.Where("user_address = ?", attestation.UserAddress).First(&nonce).Error; err != nil {
+			return err
+		}
+		attestation.Nonce = nonce.LastNonce
+
+		if err := sign(attestation); err != nil {
+			return err
+		}
+		return tx.Create(attestation).Error
+	})
+}
+
+// GetByID 获取证明记录，不存在时返回nil
+func (r *AttestationRepository) GetByID(ctx context.Context, id uint64) (*models.PointsAttestation, error) {
+	var attestation models.PointsAttestation
+	err := r.db.WithContext(ctx).First(&attestation, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &attestation, err
+}
+
+// GetByNonce 获取账户使用nonce签发的证明记录，不存在时返回nil
+func (r *AttestationRepository) GetByNonce(ctx context.Context, userAddress string, nonce uint64) (*models.PointsAttestation, error) {
+	var attestation models.PointsAttestation
+	err := r.db.WithContext(ctx).
+		Where("user_address = ? AND nonce = ?", userAddress, nonce).
+		First(&attestation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &attestation, err
+}
+
+// List 按ID倒序分页获取证明记录，userAddress和chainID为空时不过滤
+func (r *AttestationRepository) List(ctx context.Context, userAddress, chainID string, offset, limit int) ([]models.PointsAttestation, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.PointsAttestation{})
+	if userAddress != "" {
+		query = query.Where("user_address = ?", userAddress)
+	}
+	if chainID != "" {
+		query = query.Where("chain_id = ?", chainID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var attestations []models.PointsAttestation
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&attestations).Error
+	return attestations, total, err
+}
@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BackupRepository struct {
+	db *gorm.DB
+}
+
+func NewBackupRepository(db *gorm.DB) *BackupRepository {
+	return &BackupRepository{db: db}
+}
+
+// Capture 在同一个一致性读事务中读取链的余额、积分和计算状态
+// 余额与积分更新不在同一事务中，已处理区块之后的变动按余额历史回退，使余额与BlockHeight对应
+func (r *BackupRepository) Capture(ctx context.Context, chainID string) (*models.BackupData, error) {
+	data := &models.BackupData{
+		Balances: []models.BackupBalance{},
+		Points:   []models.BackupPoints{},
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(`
+			SELECT COALESCE(MAX(block_number), 0) FROM processed_blocks WHERE chain_id = ?
+		`, chainID).Scan(&data.BlockHeight).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Raw(`
+			SELECT COALESCE(MAX(id), 0) FROM points_ledger
+		`).Scan(&data.LedgerWatermark).Error; err != nil {
+			return err
+		}
+
+		var cursor models.CalculationCursor
+		err := tx.Where("chain_id = ?", chainID).First(&cursor).Error
+		switch {
+		case err == nil:
+			data.LastPeriodEnd = &cursor.LastPeriodEnd
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		if err := tx.Raw(`
+			SELECT b.user_address, COALESCE(h.balance_before, b.balance) AS balance
+			FROM user_balances b
+			LEFT JOIN (
+				SELECT user_address, balance_before,
+					ROW_NUMBER() OVER (PARTITION BY user_address ORDER BY block_number ASC, log_index ASC, id ASC) AS rn
+				FROM balance_history
+				WHERE chain_id = ? AND block_number > ?
+			) h ON h.user_address = b.user_address AND h.rn = 1
+			WHERE b.chain_id = ?
+			ORDER BY b.user_address ASC
+		`, chainID, data.BlockHeight, chainID).Scan(&data.Balances).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.UserPoints{}).
+			Select("user_address, total_points, last_calculated_at").
+			Where("chain_id = ?", chainID).
+			Order("user_address ASC").
+			Scan(&data.Points).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Create 保存备份
+func (r *BackupRepository) Create(ctx context.Context, backup *models.CalculationBackup) error {
+	return r.db.WithContext(ctx).Create(backup).Error
+}
+
+// GetByID 获取备份，withData为false时不读取备份内容；不存在时返回nil
+func (r *BackupRepository) GetByID(ctx context.Context, id uint64, withData bool) (*models.CalculationBackup, error) {
+	query := r.db.WithContext(ctx)
+	if !withData {
+		query = query.Omit("backup_data")
+	}
+
+	var backup models.CalculationBackup
+	err := query.First(&backup, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &backup, err
+}
+
+// GetLatest 获取指定链最新的备份（不含备份内容），不存在时返回nil
+func (r *BackupRepository) GetLatest(ctx context.Context, chainID string) (*models.CalculationBackup, error) {
+	var backup models.CalculationBackup
+	err := r.db.WithContext(ctx).
+		Omit("backup_data").
+		Where("chain_id = ?", chainID).
+		Order("id DESC").
+		First(&backup).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &backup, err
+}
+
+// List 按创建时间倒序分页获取备份（不含备份内容），chainID和status为空时不过滤
+func (r *BackupRepository) List(ctx context.Context, chainID string, status models.BackupVerifyStatus, offset, limit int) ([]models.CalculationBackup, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.CalculationBackup{})
+	if chainID != "" {
+		query = query.Where("chain_id = ?", chainID)
+	}
+	if status != "" {
+		query = query.Where("verify_status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var backups []models.CalculationBackup
+	err := query.Omit("backup_data").
+		Order("id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&backups).Error
+	return backups, total, err
+}
+
+// Restore 在同一事务中用备份替换链的余额和积分，并把区块游标和计算游标回退到备份时的位置
+// 备份区块之后的余额历史被删除，由链监听重新处理；备份之后写入的积分流水在备份积分上重放
+func (r *BackupRepository) Restore(ctx context.Context, backup *models.CalculationBackup, actor string) error {
+	data := &backup.BackupData
+	chainID := backup.ChainID
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("chain_id = ?", chainID).Delete(&models.UserBalance{}).Error; err != nil {
+			return err
+		}
+		balances := make([]models.UserBalance, 0, len(data.Balances))
+		for _, b := range data.Balances {
+			balances = append(balances, models.UserBalance{ChainID: chainID, UserAddress: b.UserAddress, Balance: b.Balance})
+		}
+		if len(balances) > 0 {
+			if err := tx.CreateInBatches(balances, batchInsertSize).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("chain_id = ? AND block_number > ?", chainID, data.BlockHeight).
+			Delete(&models.BalanceHistory{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("chain_id = ?", chainID).Delete(&models.ProcessedBlock{}).Error; err != nil {
+			return err
+		}
+		if data.BlockHeight > 0 {
+			if err := tx.Create(&models.ProcessedBlock{ChainID: chainID, BlockNumber: data.BlockHeight}).Error; err != nil {
+				return err
+			}
+		}
+		if err := requestRewind(tx, chainID, data.BlockHeight); err != nil {
+			return err
+		}
+
+		if err := tx.Where("chain_id = ?", chainID).Delete(&models.UserPoints{}).Error; err != nil {
+			return err
+		}
+		points := make([]models.UserPoints, 0, len(data.Points))
+		for _, p := range data.Points {
+			points = append(points, models.UserPoints{
+				ChainID:          chainID,
+				UserAddress:      p.UserAddress,
+				TotalPoints:      p.TotalPoints,
+				LastCalculatedAt: p.LastCalculatedAt,
+			})
+		}
+		if len(points) > 0 {
+			if err := tx.CreateInBatches(points, batchInsertSize).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec(`
+			INSERT INTO user_points (chain_id, user_address, total_points, last_calculated_at, updated_at)
+			SELECT chain_id, user_address, SUM(amount),
+				MAX(CASE WHEN entry_type = ? THEN created_at END), NOW()
+			FROM points_ledger
+			WHERE chain_id = ? AND id > ?
+			GROUP BY chain_id, user_address
+			ON DUPLICATE KEY UPDATE
+				total_points = total_points + VALUES(total_points),
+				last_calculated_at = COALESCE(VALUES(last_calculated_at), last_calculated_at),
+				updated_at = NOW()
+		`, models.LedgerEntryAccrual, chainID, data.LedgerWatermark).Error; err != nil {
+			return err
+		}
+
+		if data.LastPeriodEnd != nil {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "chain_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"last_period_end", "updated_at"}),
+			}).Create(&models.CalculationCursor{ChainID: chainID, LastPeriodEnd: *data.LastPeriodEnd}).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		return tx.Model(&models.CalculationBackup{}).
+			Where("id = ?", backup.ID).
+			Updates(map[string]interface{}{"restored_at": now, "restored_by": actor}).Error
+	})
+}
+
+// ReplayBalances 按余额历史重放出每个用户在height区块处理完成时的余额
+// 取每个用户在height及之前的最后一条变动的变动后余额
+func (r *BackupRepository) ReplayBalances(ctx context.Context, chainID string, height int64) ([]models.BackupBalance, error) {
+	var balances []models.BackupBalance
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT user_address, balance_after AS balance FROM (
+			SELECT user_address, balance_after,
+				ROW_NUMBER() OVER (PARTITION BY user_address ORDER BY block_number DESC, log_index DESC, id DESC) AS rn
+			FROM balance_history
+			WHERE chain_id = ? AND block_number <= ?
+		) t
+		WHERE rn = 1
+	`, chainID, height).Scan(&balances).Error
+	return balances, err
+}
+
+// UpdateVerification 记录备份的校验结果
+func (r *BackupRepository) UpdateVerification(ctx context.Context, id uint64, status models.BackupVerifyStatus, note string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.CalculationBackup{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"verify_status": status,
+			"verify_note":   note,
+			"verified_at":   at,
+		}).Error
+}
+
+// ListAll 获取全部备份的元数据（不含备份内容），用于执行保留策略
+func (r *BackupRepository) ListAll(ctx context.Context) ([]models.CalculationBackup, error) {
+	var backups []models.CalculationBackup
+	err := r.db.WithContext(ctx).
+		Select("id, chain_id, created_at, verify_status").
+		Order("chain_id ASC, created_at DESC, id DESC").
+		Find(&backups).Error
+	return backups, err
+}
+
+// DeleteByIDs 按ID删除备份
+func (r *BackupRepository) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	var deleted int64
+	for start := 0; start < len(ids); start += batchInsertSize {
+		end := start + batchInsertSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		result := r.db.WithContext(ctx).Where("id IN ?", ids[start:end]).Delete(&models.CalculationBackup{})
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+	}
+	return deleted, nil
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"token-points-system/internal/models"
 
@@ -47,6 +48,18 @@ func (r *BalanceRepository) UpdateBalance(ctx context.Context, chainID, userAddr
 	})
 }
 
+// GetByAddresses 批量获取多个用户在各条链上的余额
+func (r *BalanceRepository) GetByAddresses(ctx context.Context, chainIDs, userAddresses []string) ([]models.UserBalance, error) {
+	var balances []models.UserBalance
+	if len(userAddresses) == 0 {
+		return balances, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("chain_id IN ? AND user_address IN ?", chainIDs, userAddresses).
+		Find(&balances).Error
+	return balances, err
+}
+
 // GetAllByChain 获取指定链上所有用户的余额
 // 警告：可能返回大量数据，生产环境请使用GetByChainPaginated
 func (r *BalanceRepository) GetAllByChain(ctx context.Context, chainID string) ([]models.UserBalance, error) {
@@ -80,32 +93,113 @@ func (r *BalanceRepository) CountByChain(ctx context.Context, chainID string) (i
 }
 
 // GetBalancesAtTime 重建指定时间点的用户余额
-// 使用余额历史确定给定时间戳的状态
+// 使用余额历史确定给定时间戳的状态；同一秒内的多条变动按区块号和写入顺序取最后一条
 func (r *BalanceRepository) GetBalancesAtTime(ctx context.Context, chainID string, timestamp int64) (map[string]string, error) {
-	var histories []models.BalanceHistory
+	var holders []HolderBalance
 	err := r.db.WithContext(ctx).
 		Raw(`
-			SELECT bh.* 
-			FROM balance_history bh
-			INNER JOIN (
-				SELECT user_address, MAX(timestamp) as max_time
+			SELECT user_address, balance_after AS balance FROM (
+				SELECT user_address, balance_after,
+					ROW_NUMBER() OVER (PARTITION BY user_address ORDER BY timestamp DESC, block_number DESC, log_index DESC, id DESC) AS rn
 				FROM balance_history
 				WHERE chain_id = ? AND timestamp <= FROM_UNIXTIME(?)
-				GROUP BY user_address
-			) latest ON bh.user_address = latest.user_address 
-				AND bh.timestamp = latest.max_time
-				AND bh.chain_id = ?
-		`, chainID, timestamp, chainID).
-		Scan(&histories).Error
+			) t
+			WHERE rn = 1
+		`, chainID, timestamp).
+		Scan(&holders).Error
 
 	if err != nil {
 		return nil, err
 	}
 
-	balances := make(map[string]string)
-	for _, h := range histories {
-		balances[h.UserAddress] = h.BalanceAfter
+	balances := make(map[string]string, len(holders))
+	for _, h := range holders {
+		balances[h.UserAddress] = h.Balance
 	}
 
 	return balances, nil
 }
+
+// HolderBalance 持有人在某一区块处理完成时的余额
+type HolderBalance struct {
+	UserAddress string `json:"address"`
+	Balance     string `json:"balance"`
+}
+
+// GetHistoryAtBlock 获取用户在block及之前的最后一条余额变动，没有变动时返回nil
+func (r *BalanceRepository) GetHistoryAtBlock(ctx context.Context, chainID, userAddress string, block int64) (*models.BalanceHistory, error) {
+	return r.lastHistory(r.db.WithContext(ctx).
+		Where("chain_id = ? AND user_address = ? AND block_number <= ?", chainID, userAddress, block))
+}
+
+// GetHistoryAtTime 获取用户在at及之前的最后一条余额变动，同一秒内按区块号和写入顺序取最后一条；没有变动时返回nil
+func (r *BalanceRepository) GetHistoryAtTime(ctx context.Context, chainID, userAddress string, at time.Time) (*models.BalanceHistory, error) {
+	return r.lastHistory(r.db.WithContext(ctx).
+		Where("chain_id = ? AND user_address = ? AND timestamp <= ?", chainID, userAddress, at).
+		Order("timestamp DESC"))
+}
+
+func (r *BalanceRepository) lastHistory(query *gorm.DB) (*models.BalanceHistory, error) {
+	var history models.BalanceHistory
+	err := query.Order("block_number DESC, log_index DESC, id DESC").First(&history).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &history, err
+}
+
+// holdersAtBlockSQL 每个用户在指定区块处理完成时余额大于0且不低于最小余额的记录
+// 参数依次为chain_id、区块号和最小余额；最小余额按DECIMAL比较以保留精度
+const holdersAtBlockSQL = `
+	SELECT user_address, balance FROM (
+		SELECT user_address, balance_after AS balance,
+			ROW_NUMBER() OVER (PARTITION BY user_address ORDER BY block_number DESC, log_index DESC, id DESC) AS rn
+		FROM balance_history
+		WHERE chain_id = ? AND block_number <= ?
+	) t
+	WHERE rn = 1 AND balance > 0 AND balance >= CAST(? AS DECIMAL(65,0))`
+
+// CountHoldersAtBlock 统计指定区块时的持有人数和余额合计
+func (r *BalanceRepository) CountHoldersAtBlock(ctx context.Context, chainID string, block int64, minBalance string) (int64, string, error) {
+	var result struct {
+		Holders int64
+		Total   string
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT COUNT(*) AS holders, CAST(COALESCE(SUM(balance), 0) AS CHAR) AS total
+		FROM (`+holdersAtBlockSQL+`) h
+	`, chainID, block, minBalance).Scan(&result).Error
+	return result.Holders, result.Total, err
+}
+
+// GetHoldersAtBlock 按余额倒序分页获取指定区块时的持有人
+func (r *BalanceRepository) GetHoldersAtBlock(ctx context.Context, chainID string, block int64, minBalance string, offset, limit int) ([]HolderBalance, error) {
+	var holders []HolderBalance
+	err := r.db.WithContext(ctx).Raw(holdersAtBlockSQL+`
+		ORDER BY balance DESC, user_address ASC
+		LIMIT ? OFFSET ?
+	`, chainID, block, minBalance, limit, offset).Scan(&holders).Error
+	return holders, err
+}
+
+// EachHolderAtBlock 按余额倒序逐个读取指定区块时的全部持有人，fn返回错误时停止
+func (r *BalanceRepository) EachHolderAtBlock(ctx context.Context, chainID string, block int64, minBalance string, fn func(*HolderBalance) error) error {
+	rows, err := r.db.WithContext(ctx).Raw(holdersAtBlockSQL+`
+		ORDER BY balance DESC, user_address ASC
+	`, chainID, block, minBalance).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var holder HolderBalance
+		if err := rows.Scan(&holder.UserAddress, &holder.Balance); err != nil {
+			return err
+		}
+		if err := fn(&holder); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
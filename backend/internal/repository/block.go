@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"token-points-system/internal/models"
 
@@ -32,9 +33,27 @@ func (r *BlockRepository) GetLastProcessed(ctx context.Context, chainID string)
 	return block.BlockNumber, err
 }
 
-// UpdateLastProcessed 更新或创建指定链的处理区块记录
+// GetLastProcessedTime 获取指定链最后处理的区块的时间戳，即余额历史已完整处理到的时间
+// 没有处理过区块或时间戳未知时返回零值
+func (r *BlockRepository) GetLastProcessedTime(ctx context.Context, chainID string) (time.Time, error) {
+	var block models.ProcessedBlock
+	err := r.db.WithContext(ctx).
+		Where("chain_id = ?", chainID).
+		Order("block_number DESC").
+		First(&block).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && block.BlockTime == nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return *block.BlockTime, nil
+}
+
+// UpdateLastProcessed 更新或创建指定链的处理区块记录及其时间戳
 // 使用upsert保持每条链只有一条记录
-func (r *BlockRepository) UpdateLastProcessed(ctx context.Context, chainID string, blockNumber int64) error {
+func (r *BlockRepository) UpdateLastProcessed(ctx context.Context, chainID string, blockNumber int64, blockTime time.Time) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		var existing models.ProcessedBlock
 		err := tx.Where("chain_id = ?", chainID).First(&existing).Error
@@ -43,6 +62,7 @@ func (r *BlockRepository) UpdateLastProcessed(ctx context.Context, chainID strin
 			block := &models.ProcessedBlock{
 				ChainID:     chainID,
 				BlockNumber: blockNumber,
+				BlockTime:   &blockTime,
 			}
 			return tx.Create(block).Error
 		}
@@ -51,14 +71,42 @@ func (r *BlockRepository) UpdateLastProcessed(ctx context.Context, chainID strin
 			return err
 		}
 
-		return tx.Model(&existing).Update("block_number", blockNumber).Error
+		return tx.Model(&existing).Updates(map[string]interface{}{
+			"block_number": blockNumber,
+			"block_time":   blockTime,
+		}).Error
 	})
 }
 
 // MarkProcessed 标记区块已处理
 // 已废弃：请使用UpdateLastProcessed以获得更好的性能
-func (r *BlockRepository) MarkProcessed(ctx context.Context, chainID string, blockNumber int64) error {
-	return r.UpdateLastProcessed(ctx, chainID, blockNumber)
+func (r *BlockRepository) MarkProcessed(ctx context.Context, chainID string, blockNumber int64, blockTime time.Time) error {
+	return r.UpdateLastProcessed(ctx, chainID, blockNumber, blockTime)
+}
+
+// GetRewind 获取指定链最近一次监听器回退请求的序号和回退位置，没有请求时序号为0
+func (r *BlockRepository) GetRewind(ctx context.Context, chainID string) (uint64, int64, error) {
+	var rewind models.ListenerRewind
+	err := r.db.WithContext(ctx).
+		Where("chain_id = ?", chainID).
+		First(&rewind).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, 0, nil
+	}
+	return rewind.Seq, rewind.BlockNumber, err
+}
+
+// requestRewind 在tx中记录指定链的监听器回退请求，与重置processed_blocks在同一事务中提交
+func requestRewind(tx *gorm.DB, chainID string, block int64) error {
+	return tx.Exec(`
+		INSERT INTO listener_rewinds (chain_id, block_number, seq, updated_at)
+		VALUES (?, ?, 1, NOW())
+		ON DUPLICATE KEY UPDATE
+			block_number = VALUES(block_number),
+			seq = seq + 1,
+			updated_at = NOW()
+	`, chainID, block).Error
 }
 
 // IsProcessed 检查区块是否已处理
@@ -40,6 +40,26 @@ func (r *CalculationRepository) GenerateHash(chainID, userAddress string, period
 	return hex.EncodeToString(hash[:])
 }
 
+// GetLastStartedBefore 获取users中每个用户在before之前开始的最后一条计算记录
+// 同一用户的计算记录互不重叠，因此与以before结束的任一时间段重叠的已有记录只可能是这一条
+func (r *CalculationRepository) GetLastStartedBefore(ctx context.Context, chainID string, users []string, before time.Time) ([]models.PointCalculation, error) {
+	var calcs []models.PointCalculation
+	if len(users) == 0 {
+		return calcs, nil
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT c.* FROM point_calculations c
+		JOIN (
+			SELECT user_address, MAX(period_start) AS period_start
+			FROM point_calculations
+			WHERE chain_id = ? AND user_address IN ? AND period_start < ?
+			GROUP BY user_address
+		) last ON last.user_address = c.user_address AND last.period_start = c.period_start
+		WHERE c.chain_id = ?
+	`, chainID, users, before, chainID).Scan(&calcs).Error
+	return calcs, err
+}
+
 func (r *CalculationRepository) GetByUser(ctx context.Context, chainID, userAddress string, limit int) ([]models.PointCalculation, error) {
 	var calcs []models.PointCalculation
 	query := r.db.WithContext(ctx).
@@ -67,6 +87,31 @@ func (r *CalculationRepository) GetLastCalculation(ctx context.Context, chainID,
 	return &calc, err
 }
 
+// GetCovering 获取用户包含at时刻的周期计算记录，不存在时返回nil
+func (r *CalculationRepository) GetCovering(ctx context.Context, chainID, userAddress string, at time.Time) (*models.PointCalculation, error) {
+	var calc models.PointCalculation
+	err := r.db.WithContext(ctx).
+		Where("chain_id = ? AND user_address = ? AND period_start <= ? AND period_end > ?", chainID, userAddress, at, at).
+		Order("period_start DESC").
+		First(&calc).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &calc, err
+}
+
+// GetByID 按ID获取计算记录，不存在时返回nil
+func (r *CalculationRepository) GetByID(ctx context.Context, id uint64) (*models.PointCalculation, error) {
+	var calc models.PointCalculation
+	err := r.db.WithContext(ctx).First(&calc, id).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &calc, err
+}
+
 func (r *CalculationRepository) GetDailyPoints(ctx context.Context, days int) (map[string]float64, error) {
 	type DailyPoints struct {
 		Date  string
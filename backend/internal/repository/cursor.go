@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type CursorRepository struct {
+	db *gorm.DB
+}
+
+func NewCursorRepository(db *gorm.DB) *CursorRepository {
+	return &CursorRepository{db: db}
+}
+
+// GetLastPeriodEnd 获取指定链最后完成的积分周期结束时间
+// 如果还没有记录，返回零值
+func (r *CursorRepository) GetLastPeriodEnd(ctx context.Context, chainID string) (time.Time, error) {
+	var cursor models.CalculationCursor
+	err := r.db.WithContext(ctx).
+		Where("chain_id = ?", chainID).
+		First(&cursor).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+	return cursor.LastPeriodEnd, err
+}
+
+// GetLastExpiryDay 获取指定链最后执行过期处理的UTC日期，还没有记录时返回零值
+func (r *CursorRepository) GetLastExpiryDay(ctx context.Context, chainID string) (time.Time, error) {
+	var cursor models.ExpiryCursor
+	err := r.db.WithContext(ctx).
+		Where("chain_id = ?", chainID).
+		First(&cursor).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+	return cursor.LastDay, err
+}
+
+// AdvanceExpiry 推进指定链的过期处理游标，游标只会前进不会后退
+func (r *CursorRepository) AdvanceExpiry(ctx context.Context, chainID string, day time.Time) error {
+	return r.db.WithContext(ctx).Exec(`
+		INSERT INTO expiry_cursors (chain_id, last_day, updated_at)
+		VALUES (?, ?, NOW())
+		ON DUPLICATE KEY UPDATE
+			last_day = GREATEST(last_day, VALUES(last_day)),
+			updated_at = NOW()
+	`, chainID, day.Format("2006-01-02")).Error
+}
+
+// Advance 推进指定链的计算游标，游标只会前进不会后退
+func (r *CursorRepository) Advance(ctx context.Context, chainID string, periodEnd time.Time) error {
+	return r.db.WithContext(ctx).Exec(`
+		INSERT INTO calculation_cursors (chain_id, last_period_end, updated_at)
+		VALUES (?, ?, NOW())
+		ON DUPLICATE KEY UPDATE
+			last_period_end = GREATEST(last_period_end, VALUES(last_period_end)),
+			updated_at = NOW()
+	`, chainID, periodEnd).Error
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// PointsEntry 积分快照中一个地址的积分
+type PointsEntry struct {
+	UserAddress string
+	Points      string
+}
+
+// DistributionRepository Merkle分配及其各地址分配结果
+type DistributionRepository struct {
+	db *gorm.DB
+}
+
+func NewDistributionRepository(db *gorm.DB) *DistributionRepository {
+	return &DistributionRepository{db: db}
+}
+
+// ChainPoints 获取一条链上积分大于0的用户当前积分
+func (r *DistributionRepository) ChainPoints(ctx context.Context, chainID string) ([]PointsEntry, error) {
+	var entries []PointsEntry
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT user_address, total_points AS points
+		FROM user_points
+		WHERE chain_id = ? AND total_points > 0`, chainID).
+		Scan(&entries).Error
+	return entries, err
+}
+
+// SeasonPoints 获取已关闭赛季在一条链上积分大于0的冻结排名
+func (r *DistributionRepository) SeasonPoints(ctx context.Context, seasonID uint64, chainID string) ([]PointsEntry, error) {
+	var entries []PointsEntry
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT user_address, total AS points
+		FROM season_standings
+		WHERE season_id = ? AND chain_id = ? AND total > 0`, seasonID, chainID).
+		Scan(&entries).Error
+	return entries, err
+}
+
+// SnapshotPoints 获取排行榜快照中积分大于0的条目，按账户合并的条目使用其代表钱包
+func (r *DistributionRepository) SnapshotPoints(ctx context.Context, snapshotID uint64) ([]PointsEntry, error) {
+	var entries []PointsEntry
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT user_address, score AS points
+		FROM leaderboard_entries
+		WHERE snapshot_id = ? AND score > 0`, snapshotID).
+		Scan(&entries).Error
+	return entries, err
+}
+
+// Create 在一个事务中保存分配及全部分配结果，分配结果的DistributionID由这里填写
+func (r *DistributionRepository) Create(ctx context.Context, distribution *models.Distribution, allocations []models.DistributionAllocation) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(distribution).Error; err != nil {
+			return err
+		}
+		for i := range allocations {
+			allocations[i].DistributionID = distribution.ID
+		}
+		if len(allocations) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(allocations, batchInsertSize).Error
+	})
+}
+
+// GetByID 获取分配，不存在时返回nil
+func (r *DistributionRepository) GetByID(ctx context.Context, id uint64) (*models.Distribution, error) {
+	var distribution models.Distribution
+	err := r.db.WithContext(ctx).First(&distribution, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &distribution, err
+}
+
+// List 按ID倒序分页获取分配
+func (r *DistributionRepository) List(ctx context.Context, offset, limit int) ([]models.Distribution, int64, error) {
+	var total int64
+	if err := r.db.WithContext(ctx).Model(&models.Distribution{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var distributions []models.Distribution
+	err := r.db.WithContext(ctx).Order("id DESC").Offset(offset).Limit(limit).Find(&distributions).Error
+	return distributions, total, err
+}
+
+// GetAllocations 按序号获取第offset+1到offset+limit个分配结果，走主键区间扫描
+func (r *DistributionRepository) GetAllocations(ctx context.Context, distributionID uint64, offset, limit int) ([]models.DistributionAllocation, error) {
+	var allocations []models.DistributionAllocation
+	err := r.db.WithContext(ctx).
+		Where("distribution_id = ? AND seq > ? AND seq <= ?", distributionID, offset, offset+limit).
+		Order("seq ASC").
+		Find(&allocations).Error
+	return allocations, err
+}
+
+// GetAllocation 获取地址的分配结果，不在分配中时返回nil
+func (r *DistributionRepository) GetAllocation(ctx context.Context, distributionID uint64, userAddress string) (*models.DistributionAllocation, error) {
+	var allocation models.DistributionAllocation
+	err := r.db.WithContext(ctx).
+		Where("distribution_id = ? AND user_address = ?", distributionID, userAddress).
+		First(&allocation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &allocation, err
+}
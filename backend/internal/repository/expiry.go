@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ExpiryCandidate 到期积分超过累计出账的用户
+type ExpiryCandidate struct {
+	UserAddress    string
+	ExpiredCredits string
+	Debits         string
+}
+
+// DecayCandidate 长期无入账的用户
+type DecayCandidate struct {
+	UserAddress string
+	TotalPoints string
+}
+
+// GetExpiryCandidates 按先进先出规则查找存在到期积分的用户
+// 入账的到期时间优先取expires_at，否则为effective_at + ttlDays（ttlDays<=0时不过期）
+// 出账（兑换、扣回、过期）先消耗最早到期的入账，因此应过期数量 = 已到期入账 - 累计出账
+func (r *LedgerRepository) GetExpiryCandidates(ctx context.Context, chainID string, ttlDays int, cutoff time.Time) ([]ExpiryCandidate, error) {
+	var candidates []ExpiryCandidate
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT user_address, expired_credits, debits FROM (
+			SELECT user_address,
+				CAST(SUM(CASE
+					WHEN amount > 0 AND COALESCE(expires_at,
+						CASE WHEN ? > 0 THEN effective_at + INTERVAL ? DAY END) <= ?
+					THEN amount ELSE 0 END) AS DECIMAL(65,18)) AS expired_credits,
+				CAST(SUM(CASE WHEN amount < 0 THEN -amount ELSE 0 END) AS DECIMAL(65,18)) AS debits
+			FROM points_ledger
+			WHERE chain_id = ? AND effective_at <= ?
+			GROUP BY user_address
+		) t
+		WHERE expired_credits > debits
+		ORDER BY user_address
+	`, ttlDays, ttlDays, cutoff, chainID, cutoff).Scan(&candidates).Error
+	return candidates, err
+}
+
+// GetDecayCandidates 查找截至day仍有积分、且在(since, day]内没有任何入账的用户
+// TotalPoints按day及之前生效的流水汇总，补跑过去的日期时结果与当天执行相同
+func (r *LedgerRepository) GetDecayCandidates(ctx context.Context, chainID string, since, day time.Time) ([]DecayCandidate, error) {
+	var candidates []DecayCandidate
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT user_address, CAST(SUM(amount) AS DECIMAL(65,18)) AS total_points
+		FROM points_ledger
+		WHERE chain_id = ? AND effective_at <= ?
+		GROUP BY user_address
+		HAVING SUM(amount) > 0
+			AND SUM(CASE WHEN amount > 0 AND effective_at > ? THEN 1 ELSE 0 END) = 0
+		ORDER BY user_address
+	`, chainID, day, since).Scan(&candidates).Error
+	return candidates, err
+}
+
+// AppendDebit 在锁定用户积分行的事务中写入出账流水
+// 扣减数量（entry.Amount的绝对值）先按entry.EffectiveAt及之前生效的流水汇总出的余额封顶，
+// 补跑过去的日期时与当天执行写入的流水相同；再按当前总积分封顶，之后的兑换已消耗的积分不再扣减，
+// 此时补跑的流水会少于当天执行。按积分精度截断后为0时不写入
+func (r *LedgerRepository) AppendDebit(ctx context.Context, entry *models.PointsLedgerEntry) (bool, error) {
+	var created bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var totalPoints string
+		if err := lockUserPoints(tx, entry.ChainID, entry.UserAddress, &totalPoints); err != nil {
+			return err
+		}
+
+		var balanceAsOf string
+		if err := tx.Raw(`
+			SELECT CAST(COALESCE(SUM(amount), 0) AS DECIMAL(65,18))
+			FROM points_ledger
+			WHERE chain_id = ? AND user_address = ? AND effective_at <= ?
+		`, entry.ChainID, entry.UserAddress, entry.EffectiveAt).Scan(&balanceAsOf).Error; err != nil {
+			return err
+		}
+
+		requested, ok := new(big.Rat).SetString(entry.Amount)
+		if !ok {
+			return fmt.Errorf("invalid ledger amount: %s", entry.Amount)
+		}
+		amount := capDebit(new(big.Rat).Neg(requested), parseRat(balanceAsOf), parseRat(totalPoints))
+		if amount.Sign() <= 0 {
+			return nil
+		}
+
+		entry.Amount = new(big.Rat).Neg(amount).FloatString(18)
+		var err error
+		created, err = appendLedgerEntry(tx, entry)
+		return err
+	})
+	return created, err
+}
+
+// capDebit 按生效时的余额和当前总积分封顶扣减数量，并按积分精度截断
+func capDebit(requested, balanceAsOf, total *big.Rat) *big.Rat {
+	amount := new(big.Rat).Set(requested)
+	for _, limit := range []*big.Rat{balanceAsOf, total} {
+		if amount.Cmp(limit) > 0 {
+			amount.Set(limit)
+		}
+	}
+	amount, _ = new(big.Rat).SetString(amount.FloatString(18))
+	return amount
+}
+
+// parseRat 解析数据库返回的十进制字符串，空值按0处理
+func parseRat(value string) *big.Rat {
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+// GetCredits 获取用户全部入账流水，按生效时间升序
+func (r *LedgerRepository) GetCredits(ctx context.Context, chainID, userAddress string) ([]models.PointsLedgerEntry, error) {
+	var entries []models.PointsLedgerEntry
+	err := r.db.WithContext(ctx).
+		Where("chain_id = ? AND user_address = ? AND amount > 0", chainID, userAddress).
+		Order("effective_at ASC, id ASC").
+		Find(&entries).Error
+	return entries, err
+}
+
+// SumDebits 获取用户累计出账的绝对值
+func (r *LedgerRepository) SumDebits(ctx context.Context, chainID, userAddress string) (string, error) {
+	var total string
+	err := r.db.WithContext(ctx).
+		Model(&models.PointsLedgerEntry{}).
+		Select("CAST(COALESCE(SUM(-amount), 0) AS DECIMAL(65,18))").
+		Where("chain_id = ? AND user_address = ? AND amount < 0", chainID, userAddress).
+		Scan(&total).Error
+	return total, err
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCapDebit(t *testing.T) {
+	tests := []struct {
+		name        string
+		requested   string
+		balanceAsOf string
+		total       string
+		want        string
+	}{
+		{
+			name:        "timely run",
+			requested:   "80",
+			balanceAsOf: "100",
+			total:       "100",
+			want:        "80",
+		},
+		{
+			// 补跑时用户已在之后获得更多积分，按当天的余额封顶，与当天执行相同
+			name:        "catch-up after later accruals",
+			requested:   "80",
+			balanceAsOf: "50",
+			total:       "500",
+			want:        "50",
+		},
+		{
+			// 当天执行同样按当天的余额封顶
+			name:        "timely run with the same ledger",
+			requested:   "80",
+			balanceAsOf: "50",
+			total:       "50",
+			want:        "50",
+		},
+		{
+			// 补跑时之后的兑换已消耗了积分，按当前总积分封顶，不会使总积分为负
+			name:        "catch-up after later redemptions",
+			requested:   "80",
+			balanceAsOf: "100",
+			total:       "30",
+			want:        "30",
+		},
+		{
+			name:        "nothing left",
+			requested:   "80",
+			balanceAsOf: "100",
+			total:       "0",
+			want:        "0",
+		},
+		{
+			name:        "truncated to points precision",
+			requested:   "1/3",
+			balanceAsOf: "100",
+			total:       "100",
+			want:        "0.333333333333333333",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := capDebit(parseRat(tt.requested), parseRat(tt.balanceAsOf), parseRat(tt.total))
+			if want := parseRat(tt.want); got.Cmp(want) != 0 {
+				t.Fatalf("capDebit = %s, want %s", got.FloatString(18), tt.want)
+			}
+		})
+	}
+}
+
+func TestCapDebitDoesNotModifyArguments(t *testing.T) {
+	requested := big.NewRat(80, 1)
+	capDebit(requested, big.NewRat(50, 1), big.NewRat(30, 1))
+	if requested.Cmp(big.NewRat(80, 1)) != 0 {
+		t.Fatalf("requested changed to %s", requested.FloatString(0))
+	}
+}
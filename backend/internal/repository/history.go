@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"gorm.io/gorm"
@@ -48,16 +49,17 @@ func (r *HistoryRepository) GetUserHistoryInRange(ctx context.Context, chainID,
 	err := r.db.WithContext(ctx).
 		Where("chain_id = ? AND user_address = ? AND timestamp >= ? AND timestamp < ?",
 			chainID, userAddress, start, end).
-		Order("timestamp ASC").
+		Order("timestamp ASC, block_number ASC, log_index ASC, id ASC").
 		Find(&histories).Error
 	return histories, err
 }
 
-func (r *HistoryRepository) ExistsByTxHash(ctx context.Context, txHash string) (bool, error) {
+// ExistsByEvent 检查用户在链上某个Transfer事件（交易哈希+日志序号）中的余额变动是否已记录
+func (r *HistoryRepository) ExistsByEvent(ctx context.Context, chainID, txHash string, logIndex uint, userAddress string) (bool, error) {
 	var count int64
 	err := r.db.WithContext(ctx).
 		Model(&models.BalanceHistory{}).
-		Where("tx_hash = ?", txHash).
+		Where("chain_id = ? AND tx_hash = ? AND log_index = ? AND user_address = ?", chainID, txHash, logIndex, userAddress).
 		Count(&count).Error
 	return count > 0, err
 }
@@ -108,3 +110,123 @@ func (r *HistoryRepository) GetDailyTransactionCounts(ctx context.Context, days
 	}
 	return counts, nil
 }
+
+// GetOpeningBalance 获取用户在at时刻的余额，与StreamPeriodBalances的期初余额一致
+// 取at之前最后一条变动的变动后余额；没有更早的变动时取at之后第一条变动的变动前余额，都没有时为当前余额
+func (r *HistoryRepository) GetOpeningBalance(ctx context.Context, chainID, userAddress string, at time.Time) (string, error) {
+	var balance string
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT COALESCE(
+			(SELECT balance_after FROM balance_history
+				WHERE chain_id = ? AND user_address = ? AND timestamp < ?
+				ORDER BY timestamp DESC, block_number DESC, log_index DESC, id DESC LIMIT 1),
+			(SELECT balance_before FROM balance_history
+				WHERE chain_id = ? AND user_address = ? AND timestamp >= ?
+				ORDER BY timestamp ASC, block_number ASC, log_index ASC, id ASC LIMIT 1),
+			(SELECT balance FROM user_balances WHERE chain_id = ? AND user_address = ?),
+			0)
+	`, chainID, userAddress, at, chainID, userAddress, at, chainID, userAddress).Scan(&balance).Error
+	return balance, err
+}
+
+// GetLastBefore 获取用户在at之前的最后一条余额变动，不存在时返回nil
+func (r *HistoryRepository) GetLastBefore(ctx context.Context, chainID, userAddress string, at time.Time) (*models.BalanceHistory, error) {
+	var history []models.BalanceHistory
+	err := r.db.WithContext(ctx).
+		Where("chain_id = ? AND user_address = ? AND timestamp < ?", chainID, userAddress, at).
+		Order("timestamp DESC, block_number DESC, log_index DESC, id DESC").
+		Limit(1).
+		Find(&history).Error
+	if err != nil || len(history) == 0 {
+		return nil, err
+	}
+	return &history[0], nil
+}
+
+// UserPeriodBalance 用户在一个周期内的余额：期初余额与周期内的变动
+type UserPeriodBalance struct {
+	UserAddress string
+	Opening     string
+	Events      []models.BalanceHistory
+}
+
+// StreamPeriodBalances 按地址顺序逐个返回链上所有用户在[start, end)内的余额
+// 所有读取在同一个只读事务（一致性快照）中完成，不受期间新写入的变动影响。
+// 用户余额和周期内的变动在一个按地址排序的查询中连接后逐行读取，内存中只保留当前用户的变动；
+// 期初余额按用户在(chain_id, user_address, timestamp)索引上查找start之前的最后一条变动，
+// 不扫描start之后的历史，没有更早变动时取start之后第一条变动的变动前余额，都没有时取当前余额
+func (r *HistoryRepository) StreamPeriodBalances(ctx context.Context, chainID string, start, end time.Time, fn func(b *UserPeriodBalance) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rows, err := tx.Raw(`
+			SELECT u.user_address, u.opening,
+				h.id, h.balance_before, h.balance_after, h.change_amount, h.change_type,
+				h.tx_hash, h.log_index, h.block_number, h.timestamp
+			FROM (
+				SELECT b.user_address, COALESCE(
+					(SELECT p.balance_after FROM balance_history p
+						WHERE p.chain_id = b.chain_id AND p.user_address = b.user_address AND p.timestamp < ?
+						ORDER BY p.timestamp DESC, p.block_number DESC, p.log_index DESC, p.id DESC LIMIT 1),
+					(SELECT n.balance_before FROM balance_history n
+						WHERE n.chain_id = b.chain_id AND n.user_address = b.user_address AND n.timestamp >= ?
+						ORDER BY n.timestamp ASC, n.block_number ASC, n.log_index ASC, n.id ASC LIMIT 1),
+					b.balance) AS opening
+				FROM user_balances b
+				WHERE b.chain_id = ?
+			) u
+			LEFT JOIN balance_history h
+				ON h.chain_id = ? AND h.user_address = u.user_address AND h.timestamp >= ? AND h.timestamp < ?
+			ORDER BY u.user_address ASC, h.timestamp ASC, h.block_number ASC, h.log_index ASC, h.id ASC
+		`, start, start, chainID, chainID, start, end).Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var current *UserPeriodBalance
+		for rows.Next() {
+			var (
+				userAddress, opening                                          string
+				id, logIndex, blockNumber                                     sql.NullInt64
+				balanceBefore, balanceAfter, changeAmount, changeType, txHash sql.NullString
+				timestamp                                                     sql.NullTime
+			)
+			if err := rows.Scan(&userAddress, &opening, &id, &balanceBefore, &balanceAfter, &changeAmount,
+				&changeType, &txHash, &logIndex, &blockNumber, &timestamp); err != nil {
+				return err
+			}
+
+			if current == nil || current.UserAddress != userAddress {
+				if current != nil {
+					if err := fn(current); err != nil {
+						return err
+					}
+				}
+				current = &UserPeriodBalance{UserAddress: userAddress, Opening: opening}
+			}
+			if !id.Valid {
+				continue
+			}
+
+			current.Events = append(current.Events, models.BalanceHistory{
+				ID:            uint64(id.Int64),
+				ChainID:       chainID,
+				UserAddress:   userAddress,
+				BalanceBefore: balanceBefore.String,
+				BalanceAfter:  balanceAfter.String,
+				ChangeAmount:  changeAmount.String,
+				ChangeType:    models.ChangeType(changeType.String),
+				TxHash:        txHash.String,
+				LogIndex:      uint(logIndex.Int64),
+				BlockNumber:   blockNumber.Int64,
+				Timestamp:     timestamp.Time,
+			})
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if current != nil {
+			return fn(current)
+		}
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type JobRepository struct {
+	db *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+func (r *JobRepository) Create(ctx context.Context, job *models.Job) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+// GetByID 根据ID获取任务
+func (r *JobRepository) GetByID(ctx context.Context, id uint64) (*models.Job, error) {
+	var job models.Job
+	err := r.db.WithContext(ctx).First(&job, id).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &job, err
+}
+
+// List 按创建时间倒序获取任务，jobType和state为空时不过滤
+func (r *JobRepository) List(ctx context.Context, jobType string, state models.JobState, limit int) ([]models.Job, error) {
+	var jobs []models.Job
+	query := r.db.WithContext(ctx).Order("id DESC")
+
+	if jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+	if state != "" {
+		query = query.Where("state = ?", state)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	err := query.Find(&jobs).Error
+	return jobs, err
+}
+
+// ClaimNext 领取最早的待执行任务并标记为运行中，没有可领取的任务时返回nil
+func (r *JobRepository) ClaimNext(ctx context.Context, types []string, owner string) (*models.Job, error) {
+	for {
+		var job models.Job
+		err := r.db.WithContext(ctx).
+			Where("state = ? AND type IN ?", models.JobPending, types).
+			Order("id ASC").
+			First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// 条件更新保证同一任务只会被领取一次
+		now := time.Now()
+		result := r.db.WithContext(ctx).
+			Model(&models.Job{}).
+			Where("id = ? AND state = ?", job.ID, models.JobPending).
+			Updates(map[string]interface{}{
+				"state":      models.JobRunning,
+				"owner":      owner,
+				"started_at": gorm.Expr("COALESCE(started_at, ?)", now),
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		return r.GetByID(ctx, job.ID)
+	}
+}
+
+// UpdateProgress 保存任务进度和检查点，返回任务是否已被请求取消
+func (r *JobRepository) UpdateProgress(ctx context.Context, job *models.Job) (bool, error) {
+	updates := map[string]interface{}{
+		"total":      job.Total,
+		"processed":  job.Processed,
+		"failed":     job.Failed,
+		"checkpoint": job.Checkpoint,
+	}
+	if job.Result != "" {
+		updates["result"] = job.Result
+	}
+
+	err := r.db.WithContext(ctx).
+		Model(&models.Job{}).
+		Where("id = ?", job.ID).
+		Updates(updates).Error
+	if err != nil {
+		return false, err
+	}
+
+	var cancelRequested bool
+	err = r.db.WithContext(ctx).
+		Model(&models.Job{}).
+		Select("cancel_requested").
+		Where("id = ?", job.ID).
+		Scan(&cancelRequested).Error
+	return cancelRequested, err
+}
+
+// Finish 将运行中的任务标记为结束状态
+func (r *JobRepository) Finish(ctx context.Context, id uint64, state models.JobState, errMsg string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Job{}).
+		Where("id = ? AND state = ?", id, models.JobRunning).
+		Updates(map[string]interface{}{
+			"state":       state,
+			"error":       errMsg,
+			"finished_at": time.Now(),
+		}).Error
+}
+
+// Requeue 将运行中的任务放回待执行队列，保留进度和检查点
+func (r *JobRepository) Requeue(ctx context.Context, id uint64) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Job{}).
+		Where("id = ? AND state = ?", id, models.JobRunning).
+		Update("state", models.JobPending).Error
+}
+
+// RequeueAllRunning 将所有运行中的任务放回待执行队列，用于新主节点接管时恢复中断的任务
+func (r *JobRepository) RequeueAllRunning(ctx context.Context) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.Job{}).
+		Where("state = ?", models.JobRunning).
+		Update("state", models.JobPending)
+	return result.RowsAffected, result.Error
+}
+
+// RequestCancel 请求取消任务
+// 待执行的任务直接取消；运行中的任务设置取消标记，由执行方在下一个检查点退出
+func (r *JobRepository) RequestCancel(ctx context.Context, id uint64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Job{}).
+			Where("id = ? AND state = ?", id, models.JobPending).
+			Updates(map[string]interface{}{
+				"state":            models.JobCancelled,
+				"cancel_requested": true,
+				"finished_at":      time.Now(),
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Job{}).
+			Where("id = ? AND state = ?", id, models.JobRunning).
+			Update("cancel_requested", true).Error
+	})
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// pruneBatchSize 清理过期快照时每次删除的条目数，避免长事务
+const pruneBatchSize = 10000
+
+type LeaderboardRepository struct {
+	db *gorm.DB
+}
+
+func NewLeaderboardRepository(db *gorm.DB) *LeaderboardRepository {
+	return &LeaderboardRepository{db: db}
+}
+
+// LedgerWindow 按流水统计积分时的时间窗口[Start, End)和计入的流水类型
+type LedgerWindow struct {
+	Start      time.Time
+	End        time.Time
+	EntryTypes []models.LedgerEntryType
+}
+
+// ScoreSource 排名积分的来源
+// Ledger为空时使用user_points的总积分，否则汇总窗口内的积分流水；各链积分按Weights加权，未列出的链不计入
+type ScoreSource struct {
+	Weights []ChainWeight
+	Ledger  *LedgerWindow
+}
+
+// scoreQuery 生成按排名单位汇总积分的子查询，输出rank_key、user_address、account_id、score
+// 关联了账户的钱包按账户合并，rank_key为account:{账户ID}，其余为小写地址；user_address为单位内地址最小的钱包
+func scoreQuery(src ScoreSource) (string, []interface{}) {
+	chainIDs := make([]string, 0, len(src.Weights))
+	for _, w := range src.Weights {
+		chainIDs = append(chainIDs, w.ChainID)
+	}
+
+	var sb strings.Builder
+	args := make([]interface{}, 0, len(src.Weights)*2+4)
+	sb.WriteString(`SELECT LOWER(COALESCE(CONCAT('account:', aw.account_id), src.user_address)) AS rank_key,
+		MIN(src.user_address) AS user_address, aw.account_id,
+		CAST(SUM(src.amount * CASE src.chain_id`)
+	for _, w := range src.Weights {
+		sb.WriteString(" WHEN ? THEN CAST(? AS DECIMAL(30,12))")
+		args = append(args, w.ChainID, w.Weight)
+	}
+	sb.WriteString(" ELSE 0 END) AS DECIMAL(65,18)) AS score FROM (")
+
+	if src.Ledger == nil {
+		sb.WriteString("SELECT chain_id, user_address, total_points AS amount FROM user_points WHERE chain_id IN ?")
+		args = append(args, chainIDs)
+	} else {
+		sb.WriteString(`SELECT chain_id, user_address, amount FROM points_ledger
+			WHERE chain_id IN ? AND entry_type IN ? AND effective_at >= ? AND effective_at < ?`)
+		args = append(args, chainIDs, src.Ledger.EntryTypes, src.Ledger.Start, src.Ledger.End)
+	}
+
+	sb.WriteString(`) src
+		LEFT JOIN account_wallets aw ON aw.address = src.user_address
+		GROUP BY rank_key, aw.account_id`)
+	return sb.String(), args
+}
+
+// CreateSnapshot 计算src的完整排名并保存为scope在takenAt的快照
+// 排名由数据库窗口函数一次算出，快照在事务提交后才对读取可见
+func (r *LeaderboardRepository) CreateSnapshot(ctx context.Context, scope string, src ScoreSource, takenAt time.Time) (*models.LeaderboardSnapshot, error) {
+	snapshot := &models.LeaderboardSnapshot{Scope: scope, TakenAt: takenAt}
+	if len(src.Weights) == 0 {
+		return snapshot, r.db.WithContext(ctx).Create(snapshot).Error
+	}
+
+	query, args := scoreQuery(src)
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(snapshot).Error; err != nil {
+			return err
+		}
+
+		result := tx.Exec(`
+			INSERT INTO leaderboard_entries
+				(snapshot_id, seq, rank_key, user_address, account_id, score, rank_competition, rank_dense)
+			SELECT ?, ROW_NUMBER() OVER (ORDER BY s.score DESC, s.user_address ASC),
+				s.rank_key, s.user_address, s.account_id, s.score,
+				RANK() OVER (ORDER BY s.score DESC), DENSE_RANK() OVER (ORDER BY s.score DESC)
+			FROM (`+query+`) s
+		`, append([]interface{}{snapshot.ID}, args...)...)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		snapshot.Entries = result.RowsAffected
+		return tx.Model(snapshot).Update("entries", snapshot.Entries).Error
+	})
+	return snapshot, err
+}
+
+// GetLatestSnapshot 获取scope最新的快照，没有快照时返回nil
+func (r *LeaderboardRepository) GetLatestSnapshot(ctx context.Context, scope string) (*models.LeaderboardSnapshot, error) {
+	var snapshot models.LeaderboardSnapshot
+	err := r.db.WithContext(ctx).
+		Where("scope = ?", scope).
+		Order("taken_at DESC, id DESC").
+		First(&snapshot).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &snapshot, err
+}
+
+// GetSnapshotAtOrBefore 获取scope在at时刻或之前最近的快照，没有时返回nil
+func (r *LeaderboardRepository) GetSnapshotAtOrBefore(ctx context.Context, scope string, at time.Time) (*models.LeaderboardSnapshot, error) {
+	var snapshot models.LeaderboardSnapshot
+	err := r.db.WithContext(ctx).
+		Where("scope = ? AND taken_at <= ?", scope, at).
+		Order("taken_at DESC, id DESC").
+		First(&snapshot).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &snapshot, err
+}
+
+// GetEntries 按序号获取快照中第offset+1到offset+limit名，走主键区间扫描，不受偏移量大小影响
+func (r *LeaderboardRepository) GetEntries(ctx context.Context, snapshotID uint64, offset, limit int) ([]models.LeaderboardEntry, error) {
+	var entries []models.LeaderboardEntry
+	err := r.db.WithContext(ctx).
+		Where("snapshot_id = ? AND seq > ? AND seq <= ?", snapshotID, offset, offset+limit).
+		Order("seq ASC").
+		Find(&entries).Error
+	return entries, err
+}
+
+// GetEntry 获取排名单位在快照中的记录，不在快照中时返回nil
+func (r *LeaderboardRepository) GetEntry(ctx context.Context, snapshotID uint64, rankKey string) (*models.LeaderboardEntry, error) {
+	var entry models.LeaderboardEntry
+	err := r.db.WithContext(ctx).
+		Where("snapshot_id = ? AND rank_key = ?", snapshotID, rankKey).
+		First(&entry).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &entry, err
+}
+
+// GetEntriesByKeys 批量获取多个排名单位在快照中的记录
+func (r *LeaderboardRepository) GetEntriesByKeys(ctx context.Context, snapshotID uint64, rankKeys []string) ([]models.LeaderboardEntry, error) {
+	var entries []models.LeaderboardEntry
+	if len(rankKeys) == 0 {
+		return entries, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("snapshot_id = ? AND rank_key IN ?", snapshotID, rankKeys).
+		Find(&entries).Error
+	return entries, err
+}
+
+// PruneSnapshots 删除before之前的快照，每个scope最新的快照始终保留（已结束活动的最终排名），返回删除的快照数
+func (r *LeaderboardRepository) PruneSnapshots(ctx context.Context, before time.Time) (int, error) {
+	var ids []uint64
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT s.id FROM leaderboard_snapshots s
+		WHERE s.taken_at < ?
+		  AND s.id NOT IN (SELECT latest.id FROM (
+			SELECT MAX(id) AS id FROM leaderboard_snapshots GROUP BY scope
+		  ) latest)
+	`, before).Scan(&ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		for {
+			result := r.db.WithContext(ctx).Exec(
+				"DELETE FROM leaderboard_entries WHERE snapshot_id = ? LIMIT ?", id, pruneBatchSize)
+			if result.Error != nil {
+				return 0, result.Error
+			}
+			if result.RowsAffected < pruneBatchSize {
+				break
+			}
+		}
+		if err := r.db.WithContext(ctx).Delete(&models.LeaderboardSnapshot{}, id).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
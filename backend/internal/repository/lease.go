@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type LeaseRepository struct {
+	db *gorm.DB
+}
+
+func NewLeaseRepository(db *gorm.DB) *LeaseRepository {
+	return &LeaseRepository{db: db}
+}
+
+// TryAcquire 尝试获取或续约租约，返回当前是否由holder持有
+// 租约不存在、已过期或本就属于holder时写入新的到期时间；到期时间以数据库时钟为准，避免节点间时钟偏差
+func (r *LeaseRepository) TryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	// MySQL按从左到右的顺序执行赋值，expires_at判断时holder已是更新后的值
+	err := r.db.WithContext(ctx).Exec(`
+		INSERT INTO leader_leases (name, holder, expires_at, updated_at)
+		VALUES (?, ?, NOW(3) + INTERVAL ? MICROSECOND, NOW(3))
+		ON DUPLICATE KEY UPDATE
+			holder = IF(holder = VALUES(holder) OR expires_at < NOW(3), VALUES(holder), holder),
+			expires_at = IF(holder = VALUES(holder), VALUES(expires_at), expires_at),
+			updated_at = IF(holder = VALUES(holder), VALUES(updated_at), updated_at)
+	`, name, holder, ttl.Microseconds()).Error
+	if err != nil {
+		return false, err
+	}
+
+	lease, err := r.Get(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	return lease != nil && lease.Holder == holder, nil
+}
+
+// Release 主动释放租约，使其他节点无需等待过期即可接管
+func (r *LeaseRepository) Release(ctx context.Context, name, holder string) error {
+	return r.db.WithContext(ctx).Exec(`
+		UPDATE leader_leases SET expires_at = NOW(3) - INTERVAL 1 SECOND
+		WHERE name = ? AND holder = ?
+	`, name, holder).Error
+}
+
+// Get 获取租约，不存在时返回nil
+func (r *LeaseRepository) Get(ctx context.Context, name string) (*models.LeaderLease, error) {
+	var lease models.LeaderLease
+	err := r.db.WithContext(ctx).Where("name = ?", name).First(&lease).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
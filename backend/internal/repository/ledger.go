@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"time"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// batchInsertSize 批量写入时每条INSERT语句的行数
+const batchInsertSize = 500
+
+type LedgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+// Append 追加一条积分流水并同步更新user_points投影
+// 相同(entry_type, source_type, source_ref)的流水只会写入一次，重复写入返回false
+func (r *LedgerRepository) Append(ctx context.Context, entry *models.PointsLedgerEntry) (bool, error) {
+	var created bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		created, err = appendLedgerEntry(tx, entry)
+		return err
+	})
+	return created, err
+}
+
+// RecordAccrual 在同一事务中写入计算记录、入账流水和积分投影
+// 依赖calculation_hash唯一索引保证幂等，已存在时返回false
+func (r *LedgerRepository) RecordAccrual(ctx context.Context, calc *models.PointCalculation) (bool, error) {
+	var created bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(calc)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		created = true
+
+		if isZeroDecimal(calc.PointsEarned) {
+			return touchLastCalculated(tx, calc.ChainID, calc.UserAddress)
+		}
+
+		_, err := appendLedgerEntry(tx, &models.PointsLedgerEntry{
+			ChainID:     calc.ChainID,
+			UserAddress: calc.UserAddress,
+			EntryType:   models.LedgerEntryAccrual,
+			Amount:      calc.PointsEarned,
+			SourceType:  models.LedgerSourceCalculation,
+			SourceRef:   calc.CalculationHash,
+			EffectiveAt: calc.PeriodEnd,
+		})
+		return err
+	})
+	return created, err
+}
+
+// RecordAccrualBatch 在同一事务中批量写入计算记录、入账流水和积分投影，返回新写入的记录数
+// 已存在的计算哈希会被跳过；锁定读取同时持有唯一索引的间隙锁，防止并发写入同一批哈希
+func (r *LedgerRepository) RecordAccrualBatch(ctx context.Context, calcs []*models.PointCalculation) (int, error) {
+	if len(calcs) == 0 {
+		return 0, nil
+	}
+
+	var created int
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		hashes := make([]string, 0, len(calcs))
+		for _, c := range calcs {
+			hashes = append(hashes, c.CalculationHash)
+		}
+
+		var existing []string
+		err := tx.Model(&models.PointCalculation{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("calculation_hash IN ?", hashes).
+			Pluck("calculation_hash", &existing).Error
+		if err != nil {
+			return err
+		}
+
+		skip := make(map[string]bool, len(existing))
+		for _, h := range existing {
+			skip[h] = true
+		}
+
+		pending := make([]*models.PointCalculation, 0, len(calcs))
+		entries := make([]*models.PointsLedgerEntry, 0, len(calcs))
+		for _, c := range calcs {
+			if skip[c.CalculationHash] {
+				continue
+			}
+			pending = append(pending, c)
+			if isZeroDecimal(c.PointsEarned) {
+				continue
+			}
+			entries = append(entries, &models.PointsLedgerEntry{
+				ChainID:     c.ChainID,
+				UserAddress: c.UserAddress,
+				EntryType:   models.LedgerEntryAccrual,
+				Amount:      c.PointsEarned,
+				SourceType:  models.LedgerSourceCalculation,
+				SourceRef:   c.CalculationHash,
+				EffectiveAt: c.PeriodEnd,
+			})
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		if err := tx.CreateInBatches(pending, batchInsertSize).Error; err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			if err := tx.CreateInBatches(entries, batchInsertSize).Error; err != nil {
+				return err
+			}
+		}
+		if err := upsertProjectionBatch(tx, pending); err != nil {
+			return err
+		}
+
+		created = len(pending)
+		return nil
+	})
+	return created, err
+}
+
+// GetByUser 按生效时间倒序获取用户的积分流水
+func (r *LedgerRepository) GetByUser(ctx context.Context, chainID, userAddress string, limit int) ([]models.PointsLedgerEntry, error) {
+	var entries []models.PointsLedgerEntry
+	query := r.db.WithContext(ctx).
+		Where("chain_id = ? AND user_address = ?", chainID, userAddress).
+		Order("effective_at DESC, id DESC")
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	err := query.Find(&entries).Error
+	return entries, err
+}
+
+// GetTotalAt 汇总用户在指定时间点（含）之前生效的全部流水
+func (r *LedgerRepository) GetTotalAt(ctx context.Context, chainID, userAddress string, at time.Time) (string, error) {
+	var total string
+	err := r.db.WithContext(ctx).
+		Model(&models.PointsLedgerEntry{}).
+		Select("CAST(COALESCE(SUM(amount), 0) AS DECIMAL(65,18))").
+		Where("chain_id = ? AND user_address = ? AND effective_at <= ?", chainID, userAddress, at).
+		Scan(&total).Error
+	return total, err
+}
+
+// SumByType 按流水类型汇总用户的积分
+func (r *LedgerRepository) SumByType(ctx context.Context, chainID, userAddress string) (map[models.LedgerEntryType]string, error) {
+	type typeSum struct {
+		EntryType models.LedgerEntryType
+		Total     string
+	}
+
+	var results []typeSum
+	err := r.db.WithContext(ctx).
+		Model(&models.PointsLedgerEntry{}).
+		Select("entry_type, CAST(SUM(amount) AS DECIMAL(65,18)) as total").
+		Where("chain_id = ? AND user_address = ?", chainID, userAddress).
+		Group("entry_type").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sums := make(map[models.LedgerEntryType]string, len(results))
+	for _, s := range results {
+		sums[s.EntryType] = s.Total
+	}
+	return sums, nil
+}
+
+// SumByChain 按用户汇总指定链的全部流水
+func (r *LedgerRepository) SumByChain(ctx context.Context, chainID string) (map[string]string, error) {
+	type userSum struct {
+		UserAddress string
+		Total       string
+	}
+
+	var results []userSum
+	err := r.db.WithContext(ctx).
+		Model(&models.PointsLedgerEntry{}).
+		Select("user_address, CAST(SUM(amount) AS DECIMAL(65,18)) as total").
+		Where("chain_id = ?", chainID).
+		Group("user_address").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sums := make(map[string]string, len(results))
+	for _, s := range results {
+		sums[s.UserAddress] = s.Total
+	}
+	return sums, nil
+}
+
+// BackfillAccruals 为尚未入账的历史计算记录补写入账流水
+// 用于流水表上线前产生的point_calculations
+func (r *LedgerRepository) BackfillAccruals(ctx context.Context, chainID string) (int64, error) {
+	result := r.db.WithContext(ctx).Exec(`
+		INSERT IGNORE INTO points_ledger
+			(chain_id, user_address, entry_type, amount, source_type, source_ref, effective_at, created_at)
+		SELECT chain_id, user_address, ?, points_earned, ?, calculation_hash, period_end, created_at
+		FROM point_calculations
+		WHERE chain_id = ? AND points_earned <> 0
+	`, models.LedgerEntryAccrual, models.LedgerSourceCalculation, chainID)
+	return result.RowsAffected, result.Error
+}
+
+// RebuildProjection 用流水汇总覆盖指定链的user_points
+func (r *LedgerRepository) RebuildProjection(ctx context.Context, chainID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			INSERT INTO user_points (chain_id, user_address, total_points, updated_at)
+			SELECT chain_id, user_address, SUM(amount), NOW()
+			FROM points_ledger
+			WHERE chain_id = ?
+			GROUP BY chain_id, user_address
+			ON DUPLICATE KEY UPDATE total_points = VALUES(total_points), updated_at = NOW()
+		`, chainID).Error; err != nil {
+			return err
+		}
+
+		return tx.Exec(`
+			UPDATE user_points up
+			LEFT JOIN (
+				SELECT DISTINCT user_address FROM points_ledger WHERE chain_id = ?
+			) l ON up.user_address = l.user_address
+			SET up.total_points = 0, up.updated_at = NOW()
+			WHERE up.chain_id = ? AND l.user_address IS NULL AND up.total_points <> 0
+		`, chainID, chainID).Error
+	})
+}
+
+// appendLedgerEntry 在事务中写入流水并更新投影
+func appendLedgerEntry(tx *gorm.DB, entry *models.PointsLedgerEntry) (bool, error) {
+	if entry.EffectiveAt.IsZero() {
+		entry.EffectiveAt = time.Now()
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(entry)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	var lastCalculatedAt interface{}
+	if entry.EntryType == models.LedgerEntryAccrual {
+		lastCalculatedAt = time.Now()
+	}
+
+	err := tx.Exec(`
+		INSERT INTO user_points (chain_id, user_address, total_points, last_calculated_at, updated_at)
+		VALUES (?, ?, ?, ?, NOW())
+		ON DUPLICATE KEY UPDATE
+			total_points = total_points + ?,
+			last_calculated_at = COALESCE(?, last_calculated_at),
+			updated_at = NOW()
+	`, entry.ChainID, entry.UserAddress, entry.Amount, lastCalculatedAt, entry.Amount, lastCalculatedAt).Error
+	return err == nil, err
+}
+
+// upsertProjectionBatch 批量累加积分投影并更新最后计算时间
+func upsertProjectionBatch(tx *gorm.DB, calcs []*models.PointCalculation) error {
+	now := time.Now()
+	for i := 0; i < len(calcs); i += batchInsertSize {
+		end := i + batchInsertSize
+		if end > len(calcs) {
+			end = len(calcs)
+		}
+
+		var sb strings.Builder
+		args := make([]interface{}, 0, (end-i)*4)
+		sb.WriteString("INSERT INTO user_points (chain_id, user_address, total_points, last_calculated_at, updated_at) VALUES ")
+		for j, c := range calcs[i:end] {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString("(?, ?, ?, ?, NOW())")
+			args = append(args, c.ChainID, c.UserAddress, c.PointsEarned, now)
+		}
+		sb.WriteString(` ON DUPLICATE KEY UPDATE
+			total_points = total_points + VALUES(total_points),
+			last_calculated_at = VALUES(last_calculated_at),
+			updated_at = NOW()`)
+
+		if err := tx.Exec(sb.String(), args...).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touchLastCalculated 仅更新积分投影的最后计算时间
+func touchLastCalculated(tx *gorm.DB, chainID, userAddress string) error {
+	return tx.Exec(`
+		INSERT INTO user_points (chain_id, user_address, total_points, last_calculated_at, updated_at)
+		VALUES (?, ?, 0, NOW(), NOW())
+		ON DUPLICATE KEY UPDATE last_calculated_at = NOW(), updated_at = NOW()
+	`, chainID, userAddress).Error
+}
+
+func isZeroDecimal(value string) bool {
+	r, ok := new(big.Rat).SetString(value)
+	return !ok || r.Sign() == 0
+}
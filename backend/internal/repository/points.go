@@ -57,12 +57,13 @@ func (r *PointsRepository) GetAllByChain(ctx context.Context, chainID string) ([
 	return points, err
 }
 
-// GetByChainPaginated 分页获取用户积分
-// 生产环境使用此方法避免大数据集导致的内存问题
+// GetByChainPaginated 按总积分倒序分页获取用户积分，积分相同时按地址排序
+// 生产环境使用此方法避免大数据集导致的内存问题；带名次的排行请使用LeaderboardService
 func (r *PointsRepository) GetByChainPaginated(ctx context.Context, chainID string, offset, limit int) ([]models.UserPoints, error) {
 	var points []models.UserPoints
 	err := r.db.WithContext(ctx).
 		Where("chain_id = ?", chainID).
+		Order("total_points DESC, user_address ASC").
 		Offset(offset).
 		Limit(limit).
 		Find(&points).Error
@@ -79,8 +80,63 @@ func (r *PointsRepository) CountByChain(ctx context.Context, chainID string) (in
 	return count, err
 }
 
+// GetByAddresses 批量获取多个用户在各条链上的总积分
+func (r *PointsRepository) GetByAddresses(ctx context.Context, chainIDs, userAddresses []string) ([]models.UserPoints, error) {
+	var points []models.UserPoints
+	if len(userAddresses) == 0 {
+		return points, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("chain_id IN ? AND user_address IN ?", chainIDs, userAddresses).
+		Find(&points).Error
+	return points, err
+}
+
+// ChainWeight 跨链汇总时链的积分权重，Weight为十进制字符串
+type ChainWeight struct {
+	ChainID string
+	Weight  string
+}
+
+// WeightedScore 跨链加权积分，关联了账户的钱包按账户合并
+// AccountID为空时是单个地址；否则UserAddress为账户中地址最小的钱包
+type WeightedScore struct {
+	UserAddress string
+	AccountID   *uint64
+	Score       string
+}
+
+// RankByWeightedTotal 按跨链加权积分倒序分页获取排名单位，积分相同时按地址排序
+func (r *PointsRepository) RankByWeightedTotal(ctx context.Context, weights []ChainWeight, offset, limit int) ([]WeightedScore, error) {
+	var scores []WeightedScore
+	if len(weights) == 0 {
+		return scores, nil
+	}
+
+	query, args := scoreQuery(ScoreSource{Weights: weights})
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT s.user_address, s.account_id, s.score FROM (`+query+`) s
+		ORDER BY s.score DESC, s.user_address ASC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...).Scan(&scores).Error
+	return scores, err
+}
+
+// CountRanked 返回在指定链上有积分记录的排名单位数
+func (r *PointsRepository) CountRanked(ctx context.Context, chainIDs []string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT COUNT(DISTINCT LOWER(COALESCE(CONCAT('account:', aw.account_id), up.user_address)))
+		FROM user_points up
+		LEFT JOIN account_wallets aw ON aw.address = up.user_address
+		WHERE up.chain_id IN ?
+	`, chainIDs).Scan(&count).Error
+	return count, err
+}
+
 // AddPoints 原子性增加用户积分
 // 使用INSERT ... ON DUPLICATE KEY UPDATE实现upsert
+// 已废弃：积分变动请通过LedgerRepository写入流水，user_points仅作为流水投影
 func (r *PointsRepository) AddPoints(ctx context.Context, chainID, userAddress, pointsEarned string) error {
 	return r.db.WithContext(ctx).Exec(`
 		INSERT INTO user_points (chain_id, user_address, total_points, last_calculated_at, updated_at)
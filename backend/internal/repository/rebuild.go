@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// StateDiff 影子表与正式表的差异统计
+// Changed为两边都有但数值不同的地址数，Missing为只在影子表中的地址数，Extra为只在正式表中的地址数
+type StateDiff struct {
+	Changed int64            `json:"changed"`
+	Missing int64            `json:"missing"`
+	Extra   int64            `json:"extra"`
+	Samples []StateDiffEntry `json:"samples"`
+}
+
+// StateDiffEntry 一个有差异的地址，不存在的一边为空字符串
+type StateDiffEntry struct {
+	UserAddress string `json:"user_address"`
+	Live        string `json:"live"`
+	Rebuilt     string `json:"rebuilt"`
+}
+
+// HistoryPosition 余额历史在(block_number, log_index, id)顺序中的位置
+type HistoryPosition struct {
+	BlockNumber int64
+	LogIndex    uint
+	ID          uint64
+}
+
+// RebuildRepository 按余额历史和积分流水重建余额与积分，重建结果先写入影子表，校验通过后替换正式表
+type RebuildRepository struct {
+	db *gorm.DB
+}
+
+func NewRebuildRepository(db *gorm.DB) *RebuildRepository {
+	return &RebuildRepository{db: db}
+}
+
+// MaxHistoryID 返回指定链余额历史的最大ID，作为重放的水位
+func (r *RebuildRepository) MaxHistoryID(ctx context.Context, chainID string) (uint64, error) {
+	var id uint64
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT COALESCE(MAX(id), 0) FROM balance_history WHERE chain_id = ?
+	`, chainID).Scan(&id).Error
+	return id, err
+}
+
+// HistoryPage 按(block_number, log_index, id)顺序读取ID不超过maxID的余额历史，从after之后开始
+// 同一区块内按日志序号排列；log_index相同（同一事件的双方）时按写入顺序
+func (r *RebuildRepository) HistoryPage(ctx context.Context, chainID string, after HistoryPosition, maxID uint64, limit int) ([]models.BalanceHistory, error) {
+	var rows []models.BalanceHistory
+	err := r.db.WithContext(ctx).
+		Where("chain_id = ? AND id <= ?", chainID, maxID).
+		Where("(block_number, log_index, id) > (?, ?, ?)", after.BlockNumber, after.LogIndex, after.ID).
+		Order("block_number ASC, log_index ASC, id ASC").
+		Limit(limit).
+		Find(&rows).Error
+	return rows, err
+}
+
+// HistoryAfter 按ID顺序读取ID大于afterID的余额历史，用于追赶重放期间新写入的记录
+func (r *RebuildRepository) HistoryAfter(ctx context.Context, chainID string, afterID uint64, limit int) ([]models.BalanceHistory, error) {
+	var rows []models.BalanceHistory
+	err := r.db.WithContext(ctx).
+		Where("chain_id = ? AND id > ?", chainID, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&rows).Error
+	return rows, err
+}
+
+// ResetShadow 清空指定链在影子表中的数据
+func (r *RebuildRepository) ResetShadow(ctx context.Context, chainID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM user_balances_shadow WHERE chain_id = ?", chainID).Error; err != nil {
+			return err
+		}
+		return tx.Exec("DELETE FROM user_points_shadow WHERE chain_id = ?", chainID).Error
+	})
+}
+
+// SaveShadowBalances 批量写入或覆盖影子余额，balances以地址为键
+func (r *RebuildRepository) SaveShadowBalances(ctx context.Context, chainID string, balances map[string]string) error {
+	rows := make([]interface{}, 0, batchInsertSize*3)
+	pending := 0
+
+	flush := func() error {
+		if pending == 0 {
+			return nil
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("(?, ?, ?, NOW()), ", pending), ", ")
+		err := r.db.WithContext(ctx).Exec(`
+			INSERT INTO user_balances_shadow (chain_id, user_address, balance, updated_at)
+			VALUES `+placeholders+`
+			ON DUPLICATE KEY UPDATE balance = VALUES(balance), updated_at = NOW()
+		`, rows...).Error
+		rows = rows[:0]
+		pending = 0
+		return err
+	}
+
+	for address, balance := range balances {
+		rows = append(rows, chainID, address, balance)
+		pending++
+		if pending == batchInsertSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// BuildShadowPoints 按积分流水汇总写入影子积分
+// 正式表中有记录但没有流水的用户保留为0，与RebuildProjection的结果一致
+func (r *RebuildRepository) BuildShadowPoints(ctx context.Context, chainID string) error {
+	return buildShadowPoints(r.db.WithContext(ctx), chainID)
+}
+
+func buildShadowPoints(tx *gorm.DB, chainID string) error {
+	if err := tx.Exec("DELETE FROM user_points_shadow WHERE chain_id = ?", chainID).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Exec(`
+		INSERT INTO user_points_shadow (chain_id, user_address, total_points, last_calculated_at, updated_at)
+		SELECT chain_id, user_address, SUM(amount),
+			MAX(CASE WHEN entry_type = ? THEN created_at END), NOW()
+		FROM points_ledger
+		WHERE chain_id = ?
+		GROUP BY chain_id, user_address
+	`, models.LedgerEntryAccrual, chainID).Error; err != nil {
+		return err
+	}
+
+	return tx.Exec(`
+		INSERT IGNORE INTO user_points_shadow (chain_id, user_address, total_points, last_calculated_at, updated_at)
+		SELECT chain_id, user_address, 0, last_calculated_at, NOW()
+		FROM user_points
+		WHERE chain_id = ?
+	`, chainID).Error
+}
+
+// CountMissingAccruals 统计积分不为0但没有对应入账流水的计算记录数
+func (r *RebuildRepository) CountMissingAccruals(ctx context.Context, chainID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT COUNT(*)
+		FROM point_calculations pc
+		LEFT JOIN points_ledger pl
+			ON pl.entry_type = ? AND pl.source_type = ? AND pl.source_ref = pc.calculation_hash
+		WHERE pc.chain_id = ? AND pc.points_earned <> 0 AND pl.id IS NULL
+	`, models.LedgerEntryAccrual, models.LedgerSourceCalculation, chainID).Scan(&count).Error
+	return count, err
+}
+
+// DiffBalances 比较影子余额与正式余额，最多返回sampleLimit个差异样例
+func (r *RebuildRepository) DiffBalances(ctx context.Context, chainID string, sampleLimit int) (*StateDiff, error) {
+	return r.diff(ctx, "user_balances", "user_balances_shadow", "balance", chainID, sampleLimit)
+}
+
+// DiffPoints 比较影子积分与正式积分，最多返回sampleLimit个差异样例
+func (r *RebuildRepository) DiffPoints(ctx context.Context, chainID string, sampleLimit int) (*StateDiff, error) {
+	return r.diff(ctx, "user_points", "user_points_shadow", "total_points", chainID, sampleLimit)
+}
+
+func (r *RebuildRepository) diff(ctx context.Context, live, shadow, column, chainID string, sampleLimit int) (*StateDiff, error) {
+	db := r.db.WithContext(ctx)
+	diff := &StateDiff{Samples: []StateDiffEntry{}}
+
+	// MySQL不支持FULL JOIN，分别从两边左连接
+	changed := `
+		SELECT s.user_address, CAST(l.` + column + ` AS CHAR) AS live, CAST(s.` + column + ` AS CHAR) AS rebuilt
+		FROM ` + shadow + ` s
+		JOIN ` + live + ` l ON l.chain_id = s.chain_id AND l.user_address = s.user_address
+		WHERE s.chain_id = ? AND l.` + column + ` <> s.` + column
+	missing := `
+		SELECT s.user_address, '' AS live, CAST(s.` + column + ` AS CHAR) AS rebuilt
+		FROM ` + shadow + ` s
+		LEFT JOIN ` + live + ` l ON l.chain_id = s.chain_id AND l.user_address = s.user_address
+		WHERE s.chain_id = ? AND l.id IS NULL`
+	extra := `
+		SELECT l.user_address, CAST(l.` + column + ` AS CHAR) AS live, '' AS rebuilt
+		FROM ` + live + ` l
+		LEFT JOIN ` + shadow + ` s ON s.chain_id = l.chain_id AND s.user_address = l.user_address
+		WHERE l.chain_id = ? AND s.id IS NULL`
+
+	for _, q := range []struct {
+		query string
+		count *int64
+	}{
+		{changed, &diff.Changed},
+		{missing, &diff.Missing},
+		{extra, &diff.Extra},
+	} {
+		if err := db.Raw("SELECT COUNT(*) FROM ("+q.query+") t", chainID).Scan(q.count).Error; err != nil {
+			return nil, err
+		}
+
+		remaining := sampleLimit - len(diff.Samples)
+		if *q.count == 0 || remaining <= 0 {
+			continue
+		}
+		var samples []StateDiffEntry
+		if err := db.Raw(q.query+" ORDER BY user_address LIMIT ?", chainID, remaining).Scan(&samples).Error; err != nil {
+			return nil, err
+		}
+		diff.Samples = append(diff.Samples, samples...)
+	}
+	return diff, nil
+}
+
+// Swap 在同一事务中用影子表替换指定链的正式余额和积分，然后清空影子表
+// 积分在事务中按流水重新汇总，避免遗漏重建之后写入的流水
+func (r *RebuildRepository) Swap(ctx context.Context, chainID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := buildShadowPoints(tx, chainID); err != nil {
+			return err
+		}
+
+		if err := tx.Where("chain_id = ?", chainID).Delete(&models.UserBalance{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`
+			INSERT INTO user_balances (chain_id, user_address, balance, updated_at)
+			SELECT chain_id, user_address, balance, NOW()
+			FROM user_balances_shadow
+			WHERE chain_id = ?
+		`, chainID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("chain_id = ?", chainID).Delete(&models.UserPoints{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`
+			INSERT INTO user_points (chain_id, user_address, total_points, last_calculated_at, updated_at)
+			SELECT chain_id, user_address, total_points, last_calculated_at, NOW()
+			FROM user_points_shadow
+			WHERE chain_id = ?
+		`, chainID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec("DELETE FROM user_balances_shadow WHERE chain_id = ?", chainID).Error; err != nil {
+			return err
+		}
+		return tx.Exec("DELETE FROM user_points_shadow WHERE chain_id = ?", chainID).Error
+	})
+}
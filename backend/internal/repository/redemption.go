@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInsufficientPoints = errors.New("insufficient available points")
+	ErrRedemptionState    = errors.New("redemption is not in a valid state for this operation")
+)
+
+type RedemptionRepository struct {
+	db *gorm.DB
+}
+
+func NewRedemptionRepository(db *gorm.DB) *RedemptionRepository {
+	return &RedemptionRepository{db: db}
+}
+
+// GetByID 根据ID获取兑换记录
+func (r *RedemptionRepository) GetByID(ctx context.Context, id uint64) (*models.PointsRedemption, error) {
+	var redemption models.PointsRedemption
+	err := r.db.WithContext(ctx).First(&redemption, id).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &redemption, err
+}
+
+// GetByIdempotencyKey 根据幂等键获取兑换记录
+func (r *RedemptionRepository) GetByIdempotencyKey(ctx context.Context, key string) (*models.PointsRedemption, error) {
+	var redemption models.PointsRedemption
+	err := r.db.WithContext(ctx).
+		Where("idempotency_key = ?", key).
+		First(&redemption).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &redemption, err
+}
+
+// Create 校验可用积分后创建兑换记录
+// 锁定用户积分行，可用积分 = 总积分 - 未过期的预留；状态为committed时同时写入兑换流水
+func (r *RedemptionRepository) Create(ctx context.Context, redemption *models.PointsRedemption) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkAvailable(tx, redemption, 0); err != nil {
+			return err
+		}
+
+		if err := tx.Create(redemption).Error; err != nil {
+			return err
+		}
+
+		if redemption.Status == models.RedemptionCommitted {
+			return appendRedemptionEntry(tx, redemption)
+		}
+		return nil
+	})
+}
+
+// Commit 提交预留并写入兑换流水，已提交的记录直接返回
+// 预留期间积分可能因过期、衰减或人工调整减少，提交前按与Create相同的顺序先锁用户积分行再锁兑换记录，
+// 重新校验扣除其他预留后的可用积分
+func (r *RedemptionRepository) Commit(ctx context.Context, id uint64) (*models.PointsRedemption, error) {
+	var redemption models.PointsRedemption
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&redemption, id).Error; err != nil {
+			return err
+		}
+		if err := lockUserPoints(tx, redemption.ChainID, redemption.UserAddress, new(string)); err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&redemption, id).Error; err != nil {
+			return err
+		}
+
+		switch {
+		case redemption.Status == models.RedemptionCommitted:
+			return nil
+		case redemption.Status != models.RedemptionReserved, redemption.IsExpired(time.Now()):
+			return ErrRedemptionState
+		}
+
+		if err := checkAvailable(tx, &redemption, redemption.ID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&redemption).Updates(map[string]interface{}{
+			"status":       models.RedemptionCommitted,
+			"committed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		return appendRedemptionEntry(tx, &redemption)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &redemption, err
+}
+
+// Cancel 取消预留，已取消的记录直接返回
+func (r *RedemptionRepository) Cancel(ctx context.Context, id uint64) (*models.PointsRedemption, error) {
+	var redemption models.PointsRedemption
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&redemption, id).Error; err != nil {
+			return err
+		}
+
+		switch redemption.Status {
+		case models.RedemptionCancelled:
+			return nil
+		case models.RedemptionCommitted:
+			return ErrRedemptionState
+		}
+
+		return tx.Model(&redemption).Updates(map[string]interface{}{
+			"status":       models.RedemptionCancelled,
+			"cancelled_at": time.Now(),
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &redemption, err
+}
+
+// GetByUser 按创建时间倒序获取用户的兑换记录
+func (r *RedemptionRepository) GetByUser(ctx context.Context, chainID, userAddress string, limit int) ([]models.PointsRedemption, error) {
+	var redemptions []models.PointsRedemption
+	query := r.db.WithContext(ctx).
+		Where("chain_id = ? AND user_address = ?", chainID, userAddress).
+		Order("created_at DESC, id DESC")
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	err := query.Find(&redemptions).Error
+	return redemptions, err
+}
+
+// SumReserved 获取用户未过期预留的积分总和
+func (r *RedemptionRepository) SumReserved(ctx context.Context, chainID, userAddress string) (string, error) {
+	return sumReserved(r.db.WithContext(ctx), chainID, userAddress)
+}
+
+func sumReserved(db *gorm.DB, chainID, userAddress string) (string, error) {
+	return sumReservedExcept(db, chainID, userAddress, 0)
+}
+
+// sumReservedExcept 未过期预留的积分总和，不包括ID为excludeID的记录
+func sumReservedExcept(db *gorm.DB, chainID, userAddress string, excludeID uint64) (string, error) {
+	var reserved string
+	err := db.Raw(`
+		SELECT CAST(COALESCE(SUM(amount), 0) AS DECIMAL(65,18)) FROM points_redemptions
+		WHERE chain_id = ? AND user_address = ? AND status = ? AND id <> ?
+			AND (expires_at IS NULL OR expires_at > NOW())
+	`, chainID, userAddress, models.RedemptionReserved, excludeID).Scan(&reserved).Error
+	return reserved, err
+}
+
+// lockUserPoints 锁定用户积分行并读取总积分，用户没有积分记录时totalPoints为空
+func lockUserPoints(tx *gorm.DB, chainID, userAddress string, totalPoints *string) error {
+	return tx.Raw(`
+		SELECT total_points FROM user_points
+		WHERE chain_id = ? AND user_address = ?
+		FOR UPDATE
+	`, chainID, userAddress).Scan(totalPoints).Error
+}
+
+// checkAvailable 锁定用户积分行，校验总积分减去其他未过期预留后不少于兑换数量
+// excludeID为正在提交的预留本身，不计入已冻结的积分
+func checkAvailable(tx *gorm.DB, redemption *models.PointsRedemption, excludeID uint64) error {
+	var totalPoints string
+	if err := lockUserPoints(tx, redemption.ChainID, redemption.UserAddress, &totalPoints); err != nil {
+		return err
+	}
+
+	reserved, err := sumReservedExcept(tx, redemption.ChainID, redemption.UserAddress, excludeID)
+	if err != nil {
+		return err
+	}
+
+	available := new(big.Rat)
+	if totalPoints != "" {
+		available.SetString(totalPoints)
+	}
+	reservedRat, _ := new(big.Rat).SetString(reserved)
+	available.Sub(available, reservedRat)
+
+	amount, ok := new(big.Rat).SetString(redemption.Amount)
+	if !ok {
+		return fmt.Errorf("invalid redemption amount: %s", redemption.Amount)
+	}
+	if available.Cmp(amount) < 0 {
+		return ErrInsufficientPoints
+	}
+	return nil
+}
+
+func appendRedemptionEntry(tx *gorm.DB, redemption *models.PointsRedemption) error {
+	amount, _ := new(big.Rat).SetString(redemption.Amount)
+	_, err := appendLedgerEntry(tx, &models.PointsLedgerEntry{
+		ChainID:     redemption.ChainID,
+		UserAddress: redemption.UserAddress,
+		EntryType:   models.LedgerEntryRedemption,
+		Amount:      new(big.Rat).Neg(amount).FloatString(18),
+		SourceType:  models.LedgerSourceRedemption,
+		SourceRef:   fmt.Sprintf("%d", redemption.ID),
+		Memo:        redemption.Reference,
+	})
+	return err
+}
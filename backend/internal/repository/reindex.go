@@ -0,0 +1,410 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReindexTxDiff 只出现在一边的交易，Side为dataset或live
+type ReindexTxDiff struct {
+	TxHash      string `json:"tx_hash"`
+	BlockNumber int64  `json:"block_number"`
+	Side        string `json:"side"`
+}
+
+// ReindexRepository 重新索引数据集及其影子余额和余额历史
+type ReindexRepository struct {
+	db *gorm.DB
+}
+
+func NewReindexRepository(db *gorm.DB) *ReindexRepository {
+	return &ReindexRepository{db: db}
+}
+
+// Create 创建数据集
+func (r *ReindexRepository) Create(ctx context.Context, dataset *models.ReindexDataset) error {
+	return r.db.WithContext(ctx).Create(dataset).Error
+}
+
+// GetByID 获取数据集，不存在时返回nil
+func (r *ReindexRepository) GetByID(ctx context.Context, id uint64) (*models.ReindexDataset, error) {
+	var dataset models.ReindexDataset
+	err := r.db.WithContext(ctx).First(&dataset, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &dataset, err
+}
+
+// GetLive 获取链当前作为正式数据的数据集，从未切换过时返回nil
+func (r *ReindexRepository) GetLive(ctx context.Context, chainID string) (*models.ReindexDataset, error) {
+	var dataset models.ReindexDataset
+	err := r.db.WithContext(ctx).
+		Where("chain_id = ? AND status = ?", chainID, models.ReindexLive).
+		Order("id DESC").
+		First(&dataset).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &dataset, err
+}
+
+// List 按ID倒序分页获取数据集，chainID为空时不过滤
+func (r *ReindexRepository) List(ctx context.Context, chainID string, offset, limit int) ([]models.ReindexDataset, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.ReindexDataset{})
+	if chainID != "" {
+		query = query.Where("chain_id = ?", chainID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var datasets []models.ReindexDataset
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&datasets).Error
+	return datasets, total, err
+}
+
+// UpdateStatus 更新数据集状态和错误信息
+func (r *ReindexRepository) UpdateStatus(ctx context.Context, id uint64, status models.ReindexStatus, errMsg string) error {
+	return r.db.WithContext(ctx).Model(&models.ReindexDataset{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"status": status, "error": errMsg}).Error
+}
+
+// GetBalances 获取数据集中指定地址的余额，没有记录的地址不在结果中
+func (r *ReindexRepository) GetBalances(ctx context.Context, datasetID uint64, addresses []string) (map[string]string, error) {
+	balances := make(map[string]string, len(addresses))
+	for start := 0; start < len(addresses); start += batchInsertSize {
+		end := start + batchInsertSize
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+
+		var rows []models.ReindexBalance
+		if err := r.db.WithContext(ctx).
+			Where("dataset_id = ? AND user_address IN ?", datasetID, addresses[start:end]).
+			Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			balances[row.UserAddress] = row.Balance
+		}
+	}
+	return balances, nil
+}
+
+// ApplyBatch 在同一事务中写入一批区块的余额历史和余额，并把数据集推进到syncedBlock
+func (r *ReindexRepository) ApplyBatch(ctx context.Context, datasetID uint64, history []models.ReindexHistory, balances map[string]string, syncedBlock int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(history) > 0 {
+			if err := tx.CreateInBatches(history, batchInsertSize).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(balances) > 0 {
+			rows := make([]models.ReindexBalance, 0, len(balances))
+			for address, balance := range balances {
+				rows = append(rows, models.ReindexBalance{DatasetID: datasetID, UserAddress: address, Balance: balance})
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "dataset_id"}, {Name: "user_address"}},
+				DoUpdates: clause.AssignmentColumns([]string{"balance", "updated_at"}),
+			}).CreateInBatches(rows, batchInsertSize).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.ReindexDataset{}).
+			Where("id = ?", datasetID).
+			Update("synced_block", syncedBlock).Error
+	})
+}
+
+// Cutover 在同一事务中把链的正式余额和余额历史保存为快照数据集，用数据集替换正式数据，
+// 并把区块游标设为数据集的同步位置；切换后数据集的影子数据被删除，返回快照数据集。
+// 已发放的积分不会重算：切换前比较新旧余额历史，把出现差异的最早时间和此后结束的积分计算记录数
+// 记录在数据集的PointsStaleFrom和StaleCalculations中
+func (r *ReindexRepository) Cutover(ctx context.Context, dataset *models.ReindexDataset, liveBlock, startBlock int64, actor string) (*models.ReindexDataset, error) {
+	chainID := dataset.ChainID
+	snapshot := &models.ReindexDataset{
+		ChainID:     chainID,
+		Status:      models.ReindexReady,
+		Source:      models.ReindexSourceSnapshot,
+		StartBlock:  startBlock,
+		SyncedBlock: liveBlock,
+		CreatedBy:   actor,
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(snapshot).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec(`
+			INSERT INTO reindex_balances (dataset_id, user_address, balance, updated_at)
+			SELECT ?, user_address, balance, NOW()
+			FROM user_balances
+			WHERE chain_id = ?
+		`, snapshot.ID, chainID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`
+			INSERT INTO reindex_balance_history
+				(dataset_id, user_address, balance_before, balance_after, change_amount, change_type, tx_hash, log_index, block_number, timestamp, created_at)
+			SELECT ?, user_address, balance_before, balance_after, change_amount, change_type, tx_hash, log_index, block_number, timestamp, created_at
+			FROM balance_history
+			WHERE chain_id = ?
+			ORDER BY block_number ASC, log_index ASC, id ASC
+		`, snapshot.ID, chainID).Error; err != nil {
+			return err
+		}
+
+		staleFrom, err := historyDivergence(tx, snapshot.ID, dataset.ID, liveBlock)
+		if err != nil {
+			return err
+		}
+		var staleCalculations int64
+		if staleFrom != nil {
+			if err := tx.Model(&models.PointCalculation{}).
+				Where("chain_id = ? AND period_end > ?", chainID, *staleFrom).
+				Count(&staleCalculations).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("chain_id = ?", chainID).Delete(&models.UserBalance{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`
+			INSERT INTO user_balances (chain_id, user_address, balance, updated_at)
+			SELECT ?, user_address, balance, NOW()
+			FROM reindex_balances
+			WHERE dataset_id = ?
+		`, chainID, dataset.ID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("chain_id = ?", chainID).Delete(&models.BalanceHistory{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`
+			INSERT INTO balance_history
+				(chain_id, user_address, balance_before, balance_after, change_amount, change_type, tx_hash, log_index, block_number, timestamp, created_at)
+			SELECT ?, user_address, balance_before, balance_after, change_amount, change_type, tx_hash, log_index, block_number, timestamp, created_at
+			FROM reindex_balance_history
+			WHERE dataset_id = ?
+			ORDER BY block_number ASC, log_index ASC, id ASC
+		`, chainID, dataset.ID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("chain_id = ?", chainID).Delete(&models.ProcessedBlock{}).Error; err != nil {
+			return err
+		}
+		if dataset.SyncedBlock > 0 {
+			if err := tx.Create(&models.ProcessedBlock{ChainID: chainID, BlockNumber: dataset.SyncedBlock}).Error; err != nil {
+				return err
+			}
+		}
+		if err := requestRewind(tx, chainID, dataset.SyncedBlock); err != nil {
+			return err
+		}
+
+		if err := deleteDatasetRows(tx, dataset.ID); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.ReindexDataset{}).
+			Where("chain_id = ? AND status = ?", chainID, models.ReindexLive).
+			Update("status", models.ReindexRetired).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		dataset.PointsStaleFrom = staleFrom
+		dataset.StaleCalculations = staleCalculations
+		return tx.Model(&models.ReindexDataset{}).
+			Where("id = ?", dataset.ID).
+			Updates(map[string]interface{}{
+				"status":             models.ReindexLive,
+				"replaces_id":        snapshot.ID,
+				"cutover_by":         actor,
+				"cutover_at":         now,
+				"error":              "",
+				"points_stale_from":  staleFrom,
+				"stale_calculations": staleCalculations,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// historyDivergence 返回两个数据集在height及之前的余额历史中出现差异的最早时间，没有差异时返回nil
+// 按(交易, 地址)比较变动条数和变动后余额之和：升级前写入的记录日志序号为0，不能按日志序号逐条对应，
+// 同一交易中多出或缺少的事件、重复写入的记录和余额不同的变动都算差异
+func historyDivergence(tx *gorm.DB, datasetA, datasetB uint64, height int64) (*time.Time, error) {
+	const grouped = `
+		SELECT tx_hash, user_address, COUNT(*) AS n, SUM(balance_after) AS total, MIN(timestamp) AS ts
+		FROM reindex_balance_history
+		WHERE dataset_id = ? AND block_number <= ?
+		GROUP BY tx_hash, user_address`
+
+	var staleFrom sql.NullTime
+	err := tx.Raw(`
+		SELECT MIN(ts) FROM (
+			SELECT LEAST(a.ts, COALESCE(b.ts, a.ts)) AS ts
+			FROM (`+grouped+`) a
+			LEFT JOIN (`+grouped+`) b
+				ON b.tx_hash = a.tx_hash AND b.user_address = a.user_address
+			WHERE b.tx_hash IS NULL OR b.n <> a.n OR b.total <> a.total
+			UNION ALL
+			SELECT b.ts
+			FROM (`+grouped+`) b
+			LEFT JOIN (`+grouped+`) a
+				ON a.tx_hash = b.tx_hash AND a.user_address = b.user_address
+			WHERE a.tx_hash IS NULL
+		) t
+	`, datasetA, height, datasetB, height, datasetB, height, datasetA, height).Scan(&staleFrom).Error
+	if err != nil || !staleFrom.Valid {
+		return nil, err
+	}
+	return &staleFrom.Time, nil
+}
+
+// Discard 删除数据集的影子数据并标记为discarded
+func (r *ReindexRepository) Discard(ctx context.Context, id uint64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := deleteDatasetRows(tx, id); err != nil {
+			return err
+		}
+		return tx.Model(&models.ReindexDataset{}).
+			Where("id = ?", id).
+			Update("status", models.ReindexDiscarded).Error
+	})
+}
+
+func deleteDatasetRows(tx *gorm.DB, datasetID uint64) error {
+	if err := tx.Where("dataset_id = ?", datasetID).Delete(&models.ReindexBalance{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("dataset_id = ?", datasetID).Delete(&models.ReindexHistory{}).Error
+}
+
+// CountHistory 分别统计数据集和正式数据在height及之前的余额历史条数
+func (r *ReindexRepository) CountHistory(ctx context.Context, dataset *models.ReindexDataset, height int64) (int64, int64, error) {
+	var datasetRows, liveRows int64
+	db := r.db.WithContext(ctx)
+	if err := db.Model(&models.ReindexHistory{}).
+		Where("dataset_id = ? AND block_number <= ?", dataset.ID, height).
+		Count(&datasetRows).Error; err != nil {
+		return 0, 0, err
+	}
+	if err := db.Model(&models.BalanceHistory{}).
+		Where("chain_id = ? AND block_number <= ?", dataset.ChainID, height).
+		Count(&liveRows).Error; err != nil {
+		return 0, 0, err
+	}
+	return datasetRows, liveRows, nil
+}
+
+// DiffTransactions 比较数据集和正式数据在height及之前的交易，返回只在数据集中、只在正式数据中的交易数，
+// 以及最多sampleLimit个样例
+func (r *ReindexRepository) DiffTransactions(ctx context.Context, dataset *models.ReindexDataset, height int64, sampleLimit int) (int64, int64, []ReindexTxDiff, error) {
+	db := r.db.WithContext(ctx)
+	missing := `
+		SELECT DISTINCT s.tx_hash, s.block_number, 'dataset' AS side
+		FROM reindex_balance_history s
+		LEFT JOIN balance_history l ON l.chain_id = ? AND l.tx_hash = s.tx_hash
+		WHERE s.dataset_id = ? AND s.block_number <= ? AND l.id IS NULL`
+	extra := `
+		SELECT DISTINCT l.tx_hash, l.block_number, 'live' AS side
+		FROM balance_history l
+		LEFT JOIN reindex_balance_history s ON s.dataset_id = ? AND s.tx_hash = l.tx_hash
+		WHERE l.chain_id = ? AND l.block_number <= ? AND s.id IS NULL`
+
+	samples := []ReindexTxDiff{}
+	var counts [2]int64
+	for i, q := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{missing, []interface{}{dataset.ChainID, dataset.ID, height}},
+		{extra, []interface{}{dataset.ID, dataset.ChainID, height}},
+	} {
+		if err := db.Raw("SELECT COUNT(*) FROM ("+q.query+") t", q.args...).Scan(&counts[i]).Error; err != nil {
+			return 0, 0, nil, err
+		}
+
+		remaining := sampleLimit - len(samples)
+		if counts[i] == 0 || remaining <= 0 {
+			continue
+		}
+		var rows []ReindexTxDiff
+		args := append(append([]interface{}{}, q.args...), remaining)
+		if err := db.Raw(q.query+" ORDER BY block_number ASC LIMIT ?", args...).Scan(&rows).Error; err != nil {
+			return 0, 0, nil, err
+		}
+		samples = append(samples, rows...)
+	}
+	return counts[0], counts[1], samples, nil
+}
+
+// DiffBalances 比较数据集余额与链的正式余额，最多返回sampleLimit个差异样例
+func (r *ReindexRepository) DiffBalances(ctx context.Context, dataset *models.ReindexDataset, sampleLimit int) (*StateDiff, error) {
+	db := r.db.WithContext(ctx)
+	diff := &StateDiff{Samples: []StateDiffEntry{}}
+
+	changed := `
+		SELECT s.user_address, CAST(l.balance AS CHAR) AS live, CAST(s.balance AS CHAR) AS rebuilt
+		FROM reindex_balances s
+		JOIN user_balances l ON l.chain_id = ? AND l.user_address = s.user_address
+		WHERE s.dataset_id = ? AND l.balance <> s.balance`
+	missing := `
+		SELECT s.user_address, '' AS live, CAST(s.balance AS CHAR) AS rebuilt
+		FROM reindex_balances s
+		LEFT JOIN user_balances l ON l.chain_id = ? AND l.user_address = s.user_address
+		WHERE s.dataset_id = ? AND l.id IS NULL`
+	extra := `
+		SELECT l.user_address, CAST(l.balance AS CHAR) AS live, '' AS rebuilt
+		FROM user_balances l
+		LEFT JOIN reindex_balances s ON s.dataset_id = ? AND s.user_address = l.user_address
+		WHERE l.chain_id = ? AND s.id IS NULL`
+
+	for _, q := range []struct {
+		query string
+		args  []interface{}
+		count *int64
+	}{
+		{changed, []interface{}{dataset.ChainID, dataset.ID}, &diff.Changed},
+		{missing, []interface{}{dataset.ChainID, dataset.ID}, &diff.Missing},
+		{extra, []interface{}{dataset.ID, dataset.ChainID}, &diff.Extra},
+	} {
+		if err := db.Raw("SELECT COUNT(*) FROM ("+q.query+") t", q.args...).Scan(q.count).Error; err != nil {
+			return nil, err
+		}
+
+		remaining := sampleLimit - len(diff.Samples)
+		if *q.count == 0 || remaining <= 0 {
+			continue
+		}
+		var samples []StateDiffEntry
+		args := append(append([]interface{}{}, q.args...), remaining)
+		if err := db.Raw(q.query+" ORDER BY user_address LIMIT ?", args...).Scan(&samples).Error; err != nil {
+			return nil, err
+		}
+		diff.Samples = append(diff.Samples, samples...)
+	}
+	return diff, nil
+}
@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"token-points-system/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrSeasonClosed = errors.New("season is already closed")
+
+type SeasonRepository struct {
+	db *gorm.DB
+}
+
+func NewSeasonRepository(db *gorm.DB) *SeasonRepository {
+	return &SeasonRepository{db: db}
+}
+
+// Create 创建赛季
+func (r *SeasonRepository) Create(ctx context.Context, season *models.Season) error {
+	return r.db.WithContext(ctx).Create(season).Error
+}
+
+// GetByID 获取赛季，不存在时返回nil
+func (r *SeasonRepository) GetByID(ctx context.Context, id uint64) (*models.Season, error) {
+	var season models.Season
+	err := r.db.WithContext(ctx).First(&season, id).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &season, err
+}
+
+// GetAt 获取at时刻所在的赛季，不存在时返回nil
+func (r *SeasonRepository) GetAt(ctx context.Context, at time.Time) (*models.Season, error) {
+	var season models.Season
+	err := r.db.WithContext(ctx).
+		Where("start_at <= ? AND end_at > ?", at, at).
+		First(&season).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &season, err
+}
+
+// List 按开始时间倒序获取全部赛季
+func (r *SeasonRepository) List(ctx context.Context) ([]models.Season, error) {
+	var seasons []models.Season
+	err := r.db.WithContext(ctx).Order("start_at DESC").Find(&seasons).Error
+	return seasons, err
+}
+
+// CountOverlapping 返回与[start, end)有重叠的赛季数
+func (r *SeasonRepository) CountOverlapping(ctx context.Context, start, end time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.Season{}).
+		Where("start_at < ? AND end_at > ?", end, start).
+		Count(&count).Error
+	return count, err
+}
+
+// GetPrevious 获取在at之前结束的最近一个赛季（用于结转），不存在时返回nil
+func (r *SeasonRepository) GetPrevious(ctx context.Context, at time.Time) (*models.Season, error) {
+	var season models.Season
+	err := r.db.WithContext(ctx).
+		Where("end_at <= ?", at).
+		Order("end_at DESC").
+		First(&season).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &season, err
+}
+
+// GetEndedOpen 获取在now之前结束但尚未关闭的赛季，按结束时间排序
+func (r *SeasonRepository) GetEndedOpen(ctx context.Context, now time.Time) ([]models.Season, error) {
+	var seasons []models.Season
+	err := r.db.WithContext(ctx).
+		Where("closed_at IS NULL AND end_at <= ?", now).
+		Order("end_at ASC").
+		Find(&seasons).Error
+	return seasons, err
+}
+
+// seasonTotalsQuery 生成赛季内每条链每个用户积分的子查询，输出chain_id、user_address、earned、carried_in、total
+// earned为周期开始时间落在赛季内的周期计算积分之和；previous不为空时加上其冻结积分按结转比例折算的部分
+func seasonTotalsQuery(season, previous *models.Season, chainIDs []string) (string, []interface{}) {
+	query := `SELECT t.chain_id, MIN(t.user_address) AS user_address,
+		CAST(SUM(t.earned) AS DECIMAL(65,18)) AS earned,
+		CAST(SUM(t.carried_in) AS DECIMAL(65,18)) AS carried_in,
+		CAST(SUM(t.earned) + SUM(t.carried_in) AS DECIMAL(65,18)) AS total
+		FROM (
+			SELECT chain_id, user_address, points_earned AS earned, 0 AS carried_in
+			FROM point_calculations
+			WHERE chain_id IN ? AND period_start >= ? AND period_start < ?`
+	args := []interface{}{chainIDs, season.StartAt, season.EndAt}
+
+	if previous != nil {
+		query += `
+			UNION ALL
+			SELECT chain_id, user_address, 0, total * CAST(? AS DECIMAL(10,6))
+			FROM season_standings
+			WHERE season_id = ? AND chain_id IN ?`
+		args = append(args, previous.CarryOverRate, previous.ID, chainIDs)
+	}
+
+	query += `
+		) t
+		GROUP BY t.chain_id, LOWER(t.user_address)`
+	return query, args
+}
+
+// rankedSeasonQuery 在赛季积分上按链计算序号和两种名次，积分相同时按地址排序
+func rankedSeasonQuery(season, previous *models.Season, chainIDs []string) (string, []interface{}) {
+	totals, args := seasonTotalsQuery(season, previous, chainIDs)
+	return `SELECT s.chain_id, s.user_address, s.earned, s.carried_in, s.total,
+		ROW_NUMBER() OVER (PARTITION BY s.chain_id ORDER BY s.total DESC, s.user_address ASC) AS seq,
+		RANK() OVER (PARTITION BY s.chain_id ORDER BY s.total DESC) AS rank_competition,
+		DENSE_RANK() OVER (PARTITION BY s.chain_id ORDER BY s.total DESC) AS rank_dense
+		FROM (` + totals + `) s`, args
+}
+
+// Close 冻结赛季的最终排名并标记为已关闭，返回冻结的条目数
+func (r *SeasonRepository) Close(ctx context.Context, season, previous *models.Season, chainIDs []string, closedAt time.Time) (int64, error) {
+	var entries int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked models.Season
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, season.ID).Error; err != nil {
+			return err
+		}
+		if locked.ClosedAt != nil {
+			return ErrSeasonClosed
+		}
+
+		if len(chainIDs) > 0 {
+			ranked, args := rankedSeasonQuery(season, previous, chainIDs)
+			result := tx.Exec(`
+				INSERT INTO season_standings
+					(season_id, chain_id, user_address, earned, carried_in, total, seq, rank_competition, rank_dense)
+				SELECT ?, r.chain_id, r.user_address, r.earned, r.carried_in, r.total, r.seq, r.rank_competition, r.rank_dense
+				FROM (`+ranked+`) r
+			`, append([]interface{}{season.ID}, args...)...)
+			if result.Error != nil {
+				return result.Error
+			}
+			entries = result.RowsAffected
+		}
+
+		return tx.Model(&models.Season{}).Where("id = ?", season.ID).Updates(map[string]interface{}{
+			"closed_at": closedAt,
+			"entries":   entries,
+		}).Error
+	})
+	return entries, err
+}
+
+// GetStandings 分页获取已关闭赛季在一条链上的冻结排名
+func (r *SeasonRepository) GetStandings(ctx context.Context, seasonID uint64, chainID string, offset, limit int) ([]models.SeasonStanding, error) {
+	var standings []models.SeasonStanding
+	err := r.db.WithContext(ctx).
+		Where("season_id = ? AND chain_id = ? AND seq > ? AND seq <= ?", seasonID, chainID, offset, offset+limit).
+		Order("seq ASC").
+		Find(&standings).Error
+	return standings, err
+}
+
+// GetStanding 获取用户在已关闭赛季中的冻结排名，不存在时返回nil
+func (r *SeasonRepository) GetStanding(ctx context.Context, seasonID uint64, chainID, userAddress string) (*models.SeasonStanding, error) {
+	var standing models.SeasonStanding
+	err := r.db.WithContext(ctx).
+		Where("season_id = ? AND chain_id = ? AND user_address = ?", seasonID, chainID, userAddress).
+		First(&standing).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &standing, err
+}
+
+// CountStandings 返回已关闭赛季在一条链上的冻结排名条目数
+func (r *SeasonRepository) CountStandings(ctx context.Context, seasonID uint64, chainID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.SeasonStanding{}).
+		Where("season_id = ? AND chain_id = ?", seasonID, chainID).
+		Count(&count).Error
+	return count, err
+}
+
+// GetLiveStandings 分页实时计算未关闭赛季在一条链上的排名
+func (r *SeasonRepository) GetLiveStandings(ctx context.Context, season, previous *models.Season, chainID string, offset, limit int) ([]models.SeasonStanding, error) {
+	var standings []models.SeasonStanding
+	ranked, args := rankedSeasonQuery(season, previous, []string{chainID})
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT ? AS season_id, r.* FROM (`+ranked+`) r
+		ORDER BY r.seq ASC
+		LIMIT ? OFFSET ?
+	`, append(append([]interface{}{season.ID}, args...), limit, offset)...).Scan(&standings).Error
+	return standings, err
+}
+
+// GetLiveStanding 实时计算用户在未关闭赛季中的排名，没有积分时返回nil
+func (r *SeasonRepository) GetLiveStanding(ctx context.Context, season, previous *models.Season, chainID, userAddress string) (*models.SeasonStanding, error) {
+	var standings []models.SeasonStanding
+	ranked, args := rankedSeasonQuery(season, previous, []string{chainID})
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT ? AS season_id, r.* FROM (`+ranked+`) r
+		WHERE r.user_address = ?
+	`, append(append([]interface{}{season.ID}, args...), userAddress)...).Scan(&standings).Error
+	if err != nil || len(standings) == 0 {
+		return nil, err
+	}
+	return &standings[0], nil
+}
+
+// CountLiveStandings 返回未关闭赛季在一条链上有积分记录的用户数
+func (r *SeasonRepository) CountLiveStandings(ctx context.Context, season, previous *models.Season, chainID string) (int64, error) {
+	var count int64
+	totals, args := seasonTotalsQuery(season, previous, []string{chainID})
+	err := r.db.WithContext(ctx).Raw(`SELECT COUNT(*) FROM (`+totals+`) s`, args...).Scan(&count).Error
+	return count, err
+}
@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"token-points-system/internal/config"
+	"token-points-system/internal/models"
+	"token-points-system/internal/service"
+	"token-points-system/pkg/logger"
+
+	"github.com/robfig/cron/v3"
+)
+
+// defaultBackupInterval 未配置备份间隔时每天备份一次
+const defaultBackupInterval = 24 * time.Hour
+
+type BackupScheduler struct {
+	cron        *cron.Cron
+	recoverySvc *service.RecoveryService
+	chains      []config.ChainConfig
+	interval    time.Duration
+}
+
+// NewBackupScheduler 创建定时备份调度器，interval单位为秒
+func NewBackupScheduler(recoverySvc *service.RecoveryService, chains []config.ChainConfig, interval int) *BackupScheduler {
+	d := time.Duration(interval) * time.Second
+	if d <= 0 {
+		d = defaultBackupInterval
+	}
+
+	return &BackupScheduler{
+		recoverySvc: recoverySvc,
+		chains:      chains,
+		interval:    d,
+	}
+}
+
+// Start 启动定时备份调度器，并立即为超过一个间隔没有备份的链补做备份
+func (s *BackupScheduler) Start() error {
+	s.cron = cron.New(cron.WithSeconds())
+	spec := fmt.Sprintf("@every %s", s.interval)
+	if _, err := s.cron.AddFunc(spec, func() { s.runBackups(false) }); err != nil {
+		return err
+	}
+
+	s.cron.Start()
+	go s.runBackups(true)
+	logger.Info("定时备份调度器已启动")
+	return nil
+}
+
+// Stop 停止定时备份调度器
+func (s *BackupScheduler) Stop() {
+	ctx := s.cron.Stop()
+	<-ctx.Done()
+	logger.Info("定时备份调度器已停止")
+}
+
+// runBackups 备份并校验所有启用的链，然后执行保留策略；onlyDue为true时跳过一个间隔内已有备份的链
+func (s *BackupScheduler) runBackups(onlyDue bool) {
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, chain := range s.chains {
+		if !chain.Enabled {
+			continue
+		}
+
+		if onlyDue {
+			latest, err := s.recoverySvc.LatestBackup(ctx, chain.ID)
+			if err != nil {
+				logger.Error("获取最新备份失败:", chain.ID, err)
+				continue
+			}
+			if latest != nil && now.Sub(latest.CreatedAt) < s.interval {
+				continue
+			}
+		}
+
+		backup, err := s.recoverySvc.CreateBackup(ctx, chain.ID, models.BackupTriggerScheduled, "")
+		if err != nil {
+			logger.Error("定时备份失败:", chain.ID, err)
+			continue
+		}
+
+		// 新备份的区块之前的余额历史不会再变化，立即校验
+		if _, err := s.recoverySvc.VerifyBackup(ctx, backup.ID); err != nil {
+			logger.Error("备份校验失败:", backup.ID, err)
+		}
+	}
+
+	if _, err := s.recoverySvc.ApplyRetention(ctx, now); err != nil {
+		logger.Error("执行备份保留策略失败:", err)
+	}
+}
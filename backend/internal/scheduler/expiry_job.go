@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"token-points-system/internal/config"
+	"token-points-system/internal/service"
+	"token-points-system/pkg/logger"
+
+	"github.com/robfig/cron/v3"
+)
+
+type ExpiryScheduler struct {
+	cron      *cron.Cron
+	expirySvc *service.ExpiryService
+	chains    []config.ChainConfig
+	cronExpr  string
+}
+
+// NewExpiryScheduler 创建积分过期调度器
+func NewExpiryScheduler(expirySvc *service.ExpiryService, chains []config.ChainConfig, cronExpr string) *ExpiryScheduler {
+	if cronExpr == "" {
+		cronExpr = "0 10 0 * * *"
+	}
+
+	return &ExpiryScheduler{
+		expirySvc: expirySvc,
+		chains:    chains,
+		cronExpr:  cronExpr,
+	}
+}
+
+// Start 启动过期调度器，并立即补跑上次处理之后直到当天的过期处理
+func (s *ExpiryScheduler) Start() error {
+	s.cron = cron.New(cron.WithSeconds())
+	if _, err := s.cron.AddFunc(s.cronExpr, s.applyExpirations); err != nil {
+		return err
+	}
+
+	s.cron.Start()
+	go s.applyExpirations()
+	logger.Info("积分过期调度器已启动")
+	return nil
+}
+
+// Stop 停止过期调度器
+func (s *ExpiryScheduler) Stop() {
+	ctx := s.cron.Stop()
+	<-ctx.Done()
+	logger.Info("积分过期调度器已停止")
+}
+
+func (s *ExpiryScheduler) applyExpirations() {
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, chain := range s.chains {
+		if !chain.Enabled {
+			continue
+		}
+
+		if _, err := s.expirySvc.ApplyExpirations(ctx, chain.ID, now); err != nil {
+			logger.Error("积分过期处理失败:", chain.ID, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"token-points-system/internal/service"
+	"token-points-system/pkg/logger"
+
+	"github.com/robfig/cron/v3"
+)
+
+type LeaderboardScheduler struct {
+	cron           *cron.Cron
+	leaderboardSvc *service.LeaderboardService
+	cronExpr       string
+}
+
+// NewLeaderboardScheduler 创建排行榜快照调度器，默认每小时第5分钟生成快照（在整点积分计算之后）
+func NewLeaderboardScheduler(leaderboardSvc *service.LeaderboardService, cronExpr string) *LeaderboardScheduler {
+	if cronExpr == "" {
+		cronExpr = "0 5 * * * *"
+	}
+
+	return &LeaderboardScheduler{
+		leaderboardSvc: leaderboardSvc,
+		cronExpr:       cronExpr,
+	}
+}
+
+// Start 启动快照调度器
+func (s *LeaderboardScheduler) Start() error {
+	s.cron = cron.New(cron.WithSeconds())
+	if _, err := s.cron.AddFunc(s.cronExpr, s.takeSnapshots); err != nil {
+		return err
+	}
+
+	s.cron.Start()
+	logger.Info("排行榜快照调度器已启动")
+	return nil
+}
+
+// Stop 停止快照调度器，等待正在生成的快照完成
+func (s *LeaderboardScheduler) Stop() {
+	ctx := s.cron.Stop()
+	<-ctx.Done()
+	logger.Info("排行榜快照调度器已停止")
+}
+
+func (s *LeaderboardScheduler) takeSnapshots() {
+	if err := s.leaderboardSvc.Snapshot(context.Background(), time.Now()); err != nil {
+		logger.Error("排行榜快照失败:", err)
+	}
+}
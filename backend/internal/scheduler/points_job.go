@@ -2,9 +2,15 @@ package scheduler
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
 	"time"
 
 	"token-points-system/internal/config"
+	"token-points-system/internal/jobs"
+	"token-points-system/internal/models"
+	"token-points-system/internal/period"
 	"token-points-system/internal/repository"
 	"token-points-system/internal/service"
 	"token-points-system/pkg/logger"
@@ -12,153 +18,445 @@ import (
 	"github.com/robfig/cron/v3"
 )
 
+const (
+	defaultCatchUpConcurrency = 2
+
+	// ingestionRetryInterval 余额历史尚未处理到周期结束时间时，隔多久重新尝试补算
+	ingestionRetryInterval = time.Minute
+)
+
 type PointsScheduler struct {
-	cron        *cron.Cron
-	pointsSvc   *service.PointsService
-	balanceRepo *repository.BalanceRepository
-	chains      []config.ChainConfig
-	cronExpr    string
+	cron       *cron.Cron
+	pointsSvc  *service.PointsService
+	cursorRepo *repository.CursorRepository
+	blockRepo  *repository.BlockRepository
+	chains     []config.ChainConfig
+	periods    map[string]*period.Period
+	cronExprs  map[string]string
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	semaphore chan struct{}
+
+	mu       sync.Mutex
+	progress map[string]*CatchUpProgress
+}
+
+// CatchUpProgress 链的积分补算进度
+type CatchUpProgress struct {
+	ChainID            string     `json:"chain_id"`
+	Period             string     `json:"period"`
+	Running            bool       `json:"running"`
+	LastCompletedEnd   *time.Time `json:"last_completed_end"`
+	CurrentPeriodStart *time.Time `json:"current_period_start,omitempty"`
+	PendingPeriods     int        `json:"pending_periods"`
+	IngestedUntil      *time.Time `json:"ingested_until,omitempty"`
+	WaitingIngestion   bool       `json:"waiting_ingestion"`
+	ProcessedPeriods   int        `json:"processed_periods"`
+	LastError          string     `json:"last_error,omitempty"`
+	UpdatedAt          time.Time  `json:"updated_at"`
 }
 
 // NewPointsScheduler 创建积分调度器
+// 每条启用的链按自己的周期调度；显式配置的calculation_cron必须与所有链的周期边界一致
 func NewPointsScheduler(
 	pointsSvc *service.PointsService,
-	balanceRepo *repository.BalanceRepository,
+	cursorRepo *repository.CursorRepository,
+	blockRepo *repository.BlockRepository,
 	chains []config.ChainConfig,
-	cronExpr string,
-) *PointsScheduler {
-	if cronExpr == "" {
-		cronExpr = "0 0 * * * *"
+	cfg *config.PointsConfig,
+) (*PointsScheduler, error) {
+	periods := make(map[string]*period.Period)
+	cronExprs := make(map[string]string)
+	for i := range chains {
+		chain := &chains[i]
+		if !chain.Enabled {
+			continue
+		}
+
+		p, err := period.ForChain(cfg, chain)
+		if err != nil {
+			return nil, err
+		}
+
+		cronExpr := p.Cron()
+		if cfg.CalculationCron != "" {
+			if err := p.ValidateCron(cfg.CalculationCron); err != nil {
+				return nil, fmt.Errorf("链 %s: %w", chain.ID, err)
+			}
+			cronExpr = cfg.CalculationCron
+		}
+
+		periods[chain.ID] = p
+		cronExprs[chain.ID] = cronExpr
 	}
 
-	return &PointsScheduler{
-		cron:        cron.New(cron.WithSeconds()),
-		pointsSvc:   pointsSvc,
-		balanceRepo: balanceRepo,
-		chains:      chains,
-		cronExpr:    cronExpr,
+	concurrency := cfg.CatchUpConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultCatchUpConcurrency
 	}
+
+	return &PointsScheduler{
+		pointsSvc:  pointsSvc,
+		cursorRepo: cursorRepo,
+		blockRepo:  blockRepo,
+		chains:     chains,
+		periods:    periods,
+		cronExprs:  cronExprs,
+		semaphore:  make(chan struct{}, concurrency),
+		progress:   make(map[string]*CatchUpProgress),
+	}, nil
 }
 
-// Start 启动积分计算调度器
+// Start 启动积分计算调度器，并立即补算停机期间遗漏的周期
+// 停止后可以再次启动，用于主节点切换
 func (s *PointsScheduler) Start() error {
-	_, err := s.cron.AddFunc(s.cronExpr, s.calculatePoints)
-	if err != nil {
-		return err
+	s.cron = cron.New(cron.WithSeconds())
+	for chainID, cronExpr := range s.cronExprs {
+		chainID := chainID
+		if _, err := s.cron.AddFunc(cronExpr, func() { s.triggerChain(chainID) }); err != nil {
+			return fmt.Errorf("链 %s 的调度表达式无效: %w", chainID, err)
+		}
 	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
 
 	s.cron.Start()
+	s.calculatePoints()
 	logger.Info("积分计算调度器已启动")
 	return nil
 }
 
-// Stop 停止积分计算调度器
+// Stop 停止积分计算调度器，等待进行中的补算退出
 func (s *PointsScheduler) Stop() {
 	ctx := s.cron.Stop()
 	<-ctx.Done()
+	s.cancel()
+	s.wg.Wait()
 	logger.Info("积分计算调度器已停止")
 }
 
-// calculatePoints 执行积分计算
-func (s *PointsScheduler) calculatePoints() {
-	ctx := context.Background()
-	now := time.Now()
-	periodEnd := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
-	periodStart := periodEnd.Add(-time.Hour)
+// Progress 返回各链的补算进度
+func (s *PointsScheduler) Progress() []CatchUpProgress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	logger.WithFields(map[string]interface{}{
-		"period_start": periodStart,
-		"period_end":   periodEnd,
-	}).Info("开始积分计算")
+	result := make([]CatchUpProgress, 0, len(s.chains))
+	for _, chain := range s.chains {
+		if p, ok := s.progress[chain.ID]; ok {
+			result = append(result, *p)
+		}
+	}
+	return result
+}
 
+// calculatePoints 为每条启用的链补算所有已结束但未计算的周期
+func (s *PointsScheduler) calculatePoints() {
 	for _, chain := range s.chains {
-		if !chain.Enabled {
-			continue
+		if _, ok := s.periods[chain.ID]; ok {
+			s.triggerChain(chain.ID)
 		}
+	}
+}
 
-		go s.calculatePointsForChain(ctx, chain.ID, periodStart, periodEnd)
+// triggerChain 在后台补算指定链，上一次补算未完成时跳过
+// 余额历史尚未处理到下一个周期的结束时间时，隔ingestionRetryInterval后重新触发，不必等到下一个调度时间
+func (s *PointsScheduler) triggerChain(chainID string) {
+	if !s.markRunning(chainID) {
+		logger.WithFields(map[string]interface{}{
+			"chain_id": chainID,
+		}).Debug("上一次补算尚未完成，跳过本次触发")
+		return
 	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		if !s.runCatchUp(chainID) {
+			return
+		}
+		select {
+		case <-time.After(ingestionRetryInterval):
+			s.triggerChain(chainID)
+		case <-s.ctx.Done():
+		}
+	}()
 }
 
-// calculatePointsForChain 为指定链计算积分
-func (s *PointsScheduler) calculatePointsForChain(ctx context.Context, chainID string, periodStart, periodEnd time.Time) {
-	pageSize := 100
-	offset := 0
+// runCatchUp 占用并发名额补算指定链，返回是否因余额历史未处理完而需要稍后重试
+func (s *PointsScheduler) runCatchUp(chainID string) bool {
+	defer s.markStopped(chainID)
+
+	select {
+	case s.semaphore <- struct{}{}:
+		defer func() { <-s.semaphore }()
+	case <-s.ctx.Done():
+		return false
+	}
 
-	totalUsers, err := s.balanceRepo.CountByChain(ctx, chainID)
+	waiting, err := s.catchUpChain(s.ctx, chainID)
 	if err != nil {
-		logger.Error("获取链用户数量失败:", chainID, err)
-		return
+		logger.Error("积分补算失败:", chainID, err)
+		s.updateProgress(chainID, func(p *CatchUpProgress) {
+			p.LastError = err.Error()
+		})
+		return false
 	}
+	return waiting
+}
 
-	logger.WithFields(map[string]interface{}{
-		"chain_id":    chainID,
-		"total_users": totalUsers,
-	}).Info("分页处理链用户")
+// catchUpChain 按时间顺序计算游标之后所有已结束的周期，每完成一个周期推进一次游标
+// 周期配置变更后游标可能不在新的边界上，此时第一段计算到下一个边界为止
+// 只计算链监听器已处理到周期结束时间的周期：成为主节点后监听器才开始追赶区块，
+// 用不完整的余额历史计算的积分会被计算哈希固定下来。遇到未处理完的周期时停止并返回waiting
+func (s *PointsScheduler) catchUpChain(ctx context.Context, chainID string) (waiting bool, err error) {
+	p := s.periods[chainID]
+	now := time.Now()
+	currentEnd := p.Floor(now)
 
-	processedCount := 0
+	lastEnd, err := s.cursorRepo.GetLastPeriodEnd(ctx, chainID)
+	if err != nil {
+		return false, fmt.Errorf("获取计算游标失败: %w", err)
+	}
+	ingestedUntil, err := s.blockRepo.GetLastProcessedTime(ctx, chainID)
+	if err != nil {
+		return false, fmt.Errorf("获取已处理区块的时间失败: %w", err)
+	}
+	if lastEnd.IsZero() {
+		// 首次运行只计算上一个周期
+		lastEnd = p.Previous(now)
+	}
 
-	for {
-		balances, err := s.balanceRepo.GetByChainPaginated(ctx, chainID, offset, pageSize)
-		if err != nil {
-			logger.Error("获取链余额数据失败:", chainID, err)
-			break
+	pending := p.Count(lastEnd, currentEnd)
+	s.updateProgress(chainID, func(prog *CatchUpProgress) {
+		prog.Period = p.String()
+		prog.LastCompletedEnd = &lastEnd
+		prog.PendingPeriods = pending
+		prog.ProcessedPeriods = 0
+		prog.IngestedUntil = nil
+		if !ingestedUntil.IsZero() {
+			prog.IngestedUntil = &ingestedUntil
 		}
+		prog.WaitingIngestion = false
+		prog.LastError = ""
+	})
 
-		if len(balances) == 0 {
-			break
+	if pending <= 0 {
+		return false, nil
+	}
+
+	if pending > 1 {
+		logger.WithFields(map[string]interface{}{
+			"chain_id":        chainID,
+			"last_period_end": lastEnd,
+			"pending_periods": pending,
+		}).Warn("检测到遗漏的积分周期，开始补算")
+	}
+
+	done := 0
+	for periodStart := lastEnd; periodStart.Before(currentEnd); {
+		if err := ctx.Err(); err != nil {
+			return false, err
 		}
 
-		for _, balance := range balances {
-			_, err := s.pointsSvc.CalculatePointsForUser(ctx, chainID, balance.UserAddress, periodStart, periodEnd)
-			if err != nil {
-				logger.Error("计算用户积分失败:", balance.UserAddress, err)
-				continue
-			}
-			processedCount++
+		periodEnd := p.End(periodStart)
+		if ingestedUntil.Before(periodEnd) {
+			logger.WithFields(map[string]interface{}{
+				"chain_id":       chainID,
+				"period_start":   periodStart,
+				"period_end":     periodEnd,
+				"ingested_until": ingestedUntil,
+			}).Info("余额历史尚未处理到周期结束时间，等待监听器追赶后再计算")
+			s.updateProgress(chainID, func(prog *CatchUpProgress) {
+				prog.WaitingIngestion = true
+			})
+			return true, nil
 		}
 
-		offset += pageSize
+		start := periodStart
+		s.updateProgress(chainID, func(prog *CatchUpProgress) {
+			prog.CurrentPeriodStart = &start
+		})
+
+		if _, err := s.calculatePointsForChain(ctx, chainID, periodStart, periodEnd); err != nil {
+			return false, fmt.Errorf("周期 %s 计算失败，等待下次重试: %w", periodStart.Format(time.RFC3339), err)
+		}
 
-		if len(balances) < pageSize {
-			break
+		if err := s.cursorRepo.Advance(ctx, chainID, periodEnd); err != nil {
+			return false, fmt.Errorf("推进计算游标失败: %w", err)
 		}
+
+		end := periodEnd
+		s.updateProgress(chainID, func(prog *CatchUpProgress) {
+			prog.LastCompletedEnd = &end
+			prog.CurrentPeriodStart = nil
+			prog.PendingPeriods--
+			prog.ProcessedPeriods++
+		})
+
+		done++
+		logger.WithFields(map[string]interface{}{
+			"chain_id":     chainID,
+			"period_start": periodStart,
+			"period_end":   periodEnd,
+			"progress":     fmt.Sprintf("%d/%d", done, pending),
+		}).Info("积分周期计算完成")
+
+		periodStart = periodEnd
+	}
+
+	return false, nil
+}
+
+// calculatePointsForChain 为指定链批量计算一个周期的积分
+func (s *PointsScheduler) calculatePointsForChain(ctx context.Context, chainID string, periodStart, periodEnd time.Time) (*service.ChainPeriodResult, error) {
+	return s.pointsSvc.CalculateChainPeriod(ctx, chainID, periodStart, periodEnd)
+}
+
+// RecalculateParams 回溯计算任务参数，时间范围按周期对齐
+type RecalculateParams struct {
+	ChainID string    `json:"chain_id"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+}
+
+// NewRecalculateParams 将时间范围对齐到链的周期边界，结束时间不超过当前周期起点和余额历史已处理到的时间
+func (s *PointsScheduler) NewRecalculateParams(ctx context.Context, chainID string, start, end time.Time) (RecalculateParams, error) {
+	p, ok := s.periods[chainID]
+	if !ok {
+		return RecalculateParams{}, fmt.Errorf("链 %s 未启用积分计算", chainID)
+	}
+
+	currentStart := p.Floor(time.Now())
+	if end.After(currentStart) {
+		end = currentStart
+	}
+	ingestedUntil, err := s.blockRepo.GetLastProcessedTime(ctx, chainID)
+	if err != nil {
+		return RecalculateParams{}, fmt.Errorf("获取已处理区块的时间失败: %w", err)
+	}
+	if end.After(ingestedUntil) {
+		end = ingestedUntil
 	}
 
-	logger.WithFields(map[string]interface{}{
-		"chain_id":        chainID,
-		"processed_count": processedCount,
-	}).Info("链积分计算完成")
+	params := RecalculateParams{
+		ChainID: chainID,
+		Start:   p.Floor(start),
+		End:     p.Floor(end),
+	}
+	if !params.End.After(params.Start) {
+		return params, fmt.Errorf("时间范围内没有已结束的完整周期")
+	}
+	return params, nil
+}
+
+// RecalculateReport 回溯计算任务结果，Overlaps为因已有起止时间不同的计算记录重叠而未发放的时间段
+type RecalculateReport struct {
+	Periods     int                          `json:"periods"`
+	Credited    int                          `json:"credited"`
+	Skipped     int                          `json:"skipped"`
+	Overlapping int                          `json:"overlapping"`
+	Overlaps    []service.CalculationOverlap `json:"overlaps"`
 }
 
-// TriggerManualCalculation 手动触发积分计算
-func (s *PointsScheduler) TriggerManualCalculation(ctx context.Context, chainID string, periodStart, periodEnd time.Time) error {
-	pageSize := 100
-	offset := 0
+// RecalculateJob 回溯计算任务处理器
+// 按周期顺序逐个计算，每完成一个周期保存一次检查点和结果；已计算过的周期由计算哈希跳过，
+// 修改周期配置后与旧周期重叠的用户不发放并在结果中报告，因此重复执行或从检查点恢复都不会重复发放积分
+func (s *PointsScheduler) RecalculateJob(ctx context.Context, job *models.Job, progress *jobs.Progress) error {
+	var params RecalculateParams
+	if err := job.DecodeParams(&params); err != nil {
+		return fmt.Errorf("解析任务参数失败: %w", err)
+	}
 
-	for {
-		balances, err := s.balanceRepo.GetByChainPaginated(ctx, chainID, offset, pageSize)
+	report := RecalculateReport{Overlaps: []service.CalculationOverlap{}}
+	if job.Result != "" {
+		if err := json.Unmarshal([]byte(job.Result), &report); err != nil {
+			return fmt.Errorf("解析任务结果失败: %w", err)
+		}
+	}
+
+	p, ok := s.periods[params.ChainID]
+	if !ok {
+		return fmt.Errorf("链 %s 未启用积分计算", params.ChainID)
+	}
+
+	progress.SetTotal(int64(p.Count(params.Start, params.End)))
+
+	periodStart := params.Start
+	if job.Checkpoint != "" {
+		checkpoint, err := time.Parse(time.RFC3339, job.Checkpoint)
 		if err != nil {
-			return err
+			return fmt.Errorf("解析任务检查点失败: %w", err)
 		}
+		periodStart = checkpoint
+	}
 
-		if len(balances) == 0 {
-			break
+	for periodStart.Before(params.End) {
+		periodEnd := p.End(periodStart)
+		if periodEnd.After(params.End) {
+			periodEnd = params.End
 		}
 
-		for _, balance := range balances {
-			_, err := s.pointsSvc.CalculatePointsForUser(ctx, chainID, balance.UserAddress, periodStart, periodEnd)
-			if err != nil {
-				logger.Error("计算用户积分失败:", balance.UserAddress, err)
-			}
+		result, err := s.calculatePointsForChain(ctx, params.ChainID, periodStart, periodEnd)
+		if err != nil {
+			return err
 		}
 
-		offset += pageSize
+		report.Periods++
+		report.Credited += result.Credited
+		report.Skipped += result.Skipped
+		report.Overlapping += result.Overlapping
+		for _, o := range result.Overlaps {
+			report.Overlaps = service.MergeOverlap(report.Overlaps, o)
+		}
+		if err := progress.SetResult(report); err != nil {
+			return err
+		}
 
-		if len(balances) < pageSize {
-			break
+		progress.Add(1, 0)
+		if err := progress.Save(ctx, periodEnd.Format(time.RFC3339)); err != nil {
+			return err
 		}
+		periodStart = periodEnd
 	}
 
 	return nil
 }
+
+func (s *PointsScheduler) markRunning(chainID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.progress[chainID]
+	if !ok {
+		p = &CatchUpProgress{ChainID: chainID}
+		s.progress[chainID] = p
+	}
+	if p.Running {
+		return false
+	}
+	p.Running = true
+	p.UpdatedAt = time.Now()
+	return true
+}
+
+func (s *PointsScheduler) markStopped(chainID string) {
+	s.updateProgress(chainID, func(p *CatchUpProgress) {
+		p.Running = false
+		p.CurrentPeriodStart = nil
+	})
+}
+
+func (s *PointsScheduler) updateProgress(chainID string, fn func(p *CatchUpProgress)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.progress[chainID]
+	if !ok {
+		p = &CatchUpProgress{ChainID: chainID}
+		s.progress[chainID] = p
+	}
+	fn(p)
+	p.UpdatedAt = time.Now()
+}
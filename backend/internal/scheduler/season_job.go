@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"token-points-system/internal/service"
+	"token-points-system/pkg/logger"
+
+	"github.com/robfig/cron/v3"
+)
+
+// seasonCloseCron 每小时检查一次已结束的赛季，周期计算覆盖结束时间后冻结排名
+const seasonCloseCron = "0 10 * * * *"
+
+type SeasonScheduler struct {
+	cron      *cron.Cron
+	seasonSvc *service.SeasonService
+}
+
+// NewSeasonScheduler 创建赛季关闭调度器
+func NewSeasonScheduler(seasonSvc *service.SeasonService) *SeasonScheduler {
+	return &SeasonScheduler{seasonSvc: seasonSvc}
+}
+
+// Start 启动赛季关闭调度器，并立即检查一次
+func (s *SeasonScheduler) Start() error {
+	s.cron = cron.New(cron.WithSeconds())
+	if _, err := s.cron.AddFunc(seasonCloseCron, s.closeEndedSeasons); err != nil {
+		return err
+	}
+
+	s.cron.Start()
+	go s.closeEndedSeasons()
+	logger.Info("赛季调度器已启动")
+	return nil
+}
+
+// Stop 停止赛季关闭调度器
+func (s *SeasonScheduler) Stop() {
+	ctx := s.cron.Stop()
+	<-ctx.Done()
+	logger.Info("赛季调度器已停止")
+}
+
+func (s *SeasonScheduler) closeEndedSeasons() {
+	if err := s.seasonSvc.CloseEndedSeasons(context.Background(), time.Now()); err != nil {
+		logger.Warn("赛季暂未关闭:", err)
+	}
+}
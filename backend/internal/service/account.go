@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"token-points-system/internal/blockchain"
+	"token-points-system/internal/config"
+	"token-points-system/internal/models"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+	"token-points-system/pkg/logger"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+const (
+	defaultNonceTTL      = 10 * time.Minute
+	defaultDomainName    = "Token Points System"
+	defaultDomainVersion = "1"
+)
+
+// AccountService 多钱包账户关联
+// 关联需要两个钱包分别对同一挑战签名，解除关联需要账户中任一钱包签名；
+// 挑战绑定操作、双方地址和过期时间，使用一次即失效
+type AccountService struct {
+	accountRepo  *repository.AccountRepository
+	aggregateSvc *AggregateService
+	domain       blockchain.SigningDomain
+	nonceTTL     time.Duration
+	maxWallets   int
+}
+
+func NewAccountService(accountRepo *repository.AccountRepository, aggregateSvc *AggregateService, cfg *config.AccountsConfig) *AccountService {
+	s := &AccountService{
+		accountRepo:  accountRepo,
+		aggregateSvc: aggregateSvc,
+		domain:       blockchain.SigningDomain{Name: cfg.DomainName, Version: cfg.DomainVersion},
+		nonceTTL:     time.Duration(cfg.NonceTTL) * time.Second,
+		maxWallets:   cfg.MaxWallets,
+	}
+	if s.domain.Name == "" {
+		s.domain.Name = defaultDomainName
+	}
+	if s.domain.Version == "" {
+		s.domain.Version = defaultDomainVersion
+	}
+	if s.nonceTTL <= 0 {
+		s.nonceTTL = defaultNonceTTL
+	}
+	return s
+}
+
+// AccountChallenge 需要钱包签名的挑战，Message用于EIP-191签名，TypedData用于EIP-712签名
+type AccountChallenge struct {
+	Nonce     string             `json:"nonce"`
+	Action    string             `json:"action"`
+	Account   string             `json:"account"`
+	Wallet    string             `json:"wallet"`
+	ExpiresAt time.Time          `json:"expires_at"`
+	Message   string             `json:"message"`
+	TypedData apitypes.TypedData `json:"typed_data"`
+}
+
+// AccountSignatures 对挑战的签名
+// 关联时AccountSignature和WalletSignature分别由账户钱包和目标钱包签署；解除关联只需要AccountSignature
+type AccountSignatures struct {
+	Nonce            string
+	Scheme           models.SignatureScheme
+	AccountSignature string
+	WalletSignature  string
+}
+
+// CreateChallenge 为关联或解除关联创建签名挑战
+// account为已在账户中（或将要创建账户）的钱包，wallet为要加入或移除的钱包；解除关联时二者可以相同
+func (s *AccountService) CreateChallenge(ctx context.Context, action models.AccountAction, account, wallet string) (*AccountChallenge, error) {
+	if action != models.AccountActionLink && action != models.AccountActionUnlink {
+		return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的操作: %s", action), nil)
+	}
+	if !common.IsHexAddress(account) || !common.IsHexAddress(wallet) {
+		return nil, errors.New(errors.ErrInvalidRequest, "account和wallet必须是有效的地址", nil)
+	}
+
+	accountAddr, walletAddr := common.HexToAddress(account), common.HexToAddress(wallet)
+	if action == models.AccountActionLink && accountAddr == walletAddr {
+		return nil, errors.New(errors.ErrInvalidRequest, "不能关联钱包自身", nil)
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "生成挑战失败", err)
+	}
+
+	nonce := &models.AccountNonce{
+		Nonce:          hex.EncodeToString(buf),
+		Action:         action,
+		AccountAddress: strings.ToLower(accountAddr.Hex()),
+		WalletAddress:  strings.ToLower(walletAddr.Hex()),
+		ExpiresAt:      time.Now().Add(s.nonceTTL).Truncate(time.Second),
+	}
+	if err := s.accountRepo.CreateNonce(ctx, nonce); err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "保存挑战失败", err)
+	}
+
+	msg := linkMessage(nonce)
+	return &AccountChallenge{
+		Nonce:     nonce.Nonce,
+		Action:    string(action),
+		Account:   accountAddr.Hex(),
+		Wallet:    walletAddr.Hex(),
+		ExpiresAt: nonce.ExpiresAt,
+		Message:   msg.Text(s.domain),
+		TypedData: msg.TypedData(s.domain),
+	}, nil
+}
+
+// Link 校验双方签名后把两个钱包关联到同一账户
+func (s *AccountService) Link(ctx context.Context, sigs AccountSignatures) (*AggregatedPoints, error) {
+	nonce, err := s.loadChallenge(ctx, sigs.Nonce, models.AccountActionLink)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := linkMessage(nonce)
+	if err := s.verify(msg, sigs.Scheme, sigs.AccountSignature, msg.Account); err != nil {
+		return nil, err
+	}
+	if err := s.verify(msg, sigs.Scheme, sigs.WalletSignature, msg.Wallet); err != nil {
+		return nil, err
+	}
+
+	accountID, err := s.accountRepo.Link(ctx, nonce, sigs.Scheme, s.maxWallets)
+	if err != nil {
+		return nil, accountError("关联钱包失败", err)
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"account_id": accountID,
+		"account":    nonce.AccountAddress,
+		"wallet":     nonce.WalletAddress,
+		"scheme":     sigs.Scheme,
+	}).Info("钱包已关联")
+
+	return s.aggregateSvc.GetAggregatedPoints(ctx, nonce.AccountAddress)
+}
+
+// Unlink 校验账户钱包签名后把目标钱包从账户移除
+func (s *AccountService) Unlink(ctx context.Context, sigs AccountSignatures) (*AggregatedPoints, error) {
+	nonce, err := s.loadChallenge(ctx, sigs.Nonce, models.AccountActionUnlink)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := linkMessage(nonce)
+	if err := s.verify(msg, sigs.Scheme, sigs.AccountSignature, msg.Account); err != nil {
+		return nil, err
+	}
+
+	accountID, err := s.accountRepo.Unlink(ctx, nonce, sigs.Scheme)
+	if err != nil {
+		return nil, accountError("解除关联失败", err)
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"account_id": accountID,
+		"account":    nonce.AccountAddress,
+		"wallet":     nonce.WalletAddress,
+		"scheme":     sigs.Scheme,
+	}).Info("钱包已解除关联")
+
+	return s.aggregateSvc.GetAggregatedPoints(ctx, nonce.WalletAddress)
+}
+
+// GetAuditLog 获取地址所属账户的关联变更记录，地址未关联任何账户时返回空
+func (s *AccountService) GetAuditLog(ctx context.Context, address string, limit int) ([]models.AccountAuditLog, error) {
+	wallet, err := s.accountRepo.GetWallet(ctx, strings.ToLower(address))
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取账户关联失败", err)
+	}
+	if wallet == nil {
+		return []models.AccountAuditLog{}, nil
+	}
+
+	logs, err := s.accountRepo.GetAuditLogs(ctx, wallet.AccountID, limit)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取账户变更记录失败", err)
+	}
+	return logs, nil
+}
+
+// loadChallenge 读取挑战并做预校验，最终以仓储层的原子消费为准
+func (s *AccountService) loadChallenge(ctx context.Context, nonce string, action models.AccountAction) (*models.AccountNonce, error) {
+	if nonce == "" {
+		return nil, errors.New(errors.ErrInvalidRequest, "nonce不能为空", nil)
+	}
+
+	n, err := s.accountRepo.GetNonce(ctx, nonce)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取挑战失败", err)
+	}
+	if n == nil || n.Action != action || n.UsedAt != nil || !time.Now().Before(n.ExpiresAt) {
+		return nil, accountError("挑战无效", repository.ErrNonceInvalid)
+	}
+	return n, nil
+}
+
+// verify 按签名方式校验签名者
+func (s *AccountService) verify(msg *blockchain.WalletLinkMessage, scheme models.SignatureScheme, signature string, signer common.Address) error {
+	if signature == "" {
+		return errors.New(errors.ErrInvalidRequest, fmt.Sprintf("缺少 %s 的签名", signer.Hex()), nil)
+	}
+
+	var err error
+	switch scheme {
+	case models.SignatureEIP191:
+		err = blockchain.VerifyPersonalSign(msg.Text(s.domain), signature, signer)
+	case models.SignatureEIP712:
+		err = blockchain.VerifyTypedData(msg.TypedData(s.domain), signature, signer)
+	default:
+		return errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的签名方式: %s", scheme), nil)
+	}
+	if err != nil {
+		return errors.New(errors.ErrUnauthorized, fmt.Sprintf("%s 的签名校验失败", signer.Hex()), err)
+	}
+	return nil
+}
+
+func linkMessage(n *models.AccountNonce) *blockchain.WalletLinkMessage {
+	return &blockchain.WalletLinkMessage{
+		Action:    string(n.Action),
+		Account:   common.HexToAddress(n.AccountAddress),
+		Wallet:    common.HexToAddress(n.WalletAddress),
+		Nonce:     n.Nonce,
+		ExpiresAt: n.ExpiresAt,
+	}
+}
+
+// accountError 把仓储层的关联错误映射为业务错误码
+func accountError(message string, err error) error {
+	switch {
+	case stderrors.Is(err, repository.ErrNonceInvalid):
+		return errors.New(errors.ErrUnauthorized, "挑战不存在、已过期或已使用", err)
+	case stderrors.Is(err, repository.ErrWalletLinked),
+		stderrors.Is(err, repository.ErrAlreadyLinked),
+		stderrors.Is(err, repository.ErrTooManyWallets):
+		return errors.New(errors.ErrConflict, message, err)
+	case stderrors.Is(err, repository.ErrWalletNotLinked):
+		return errors.New(errors.ErrNotFound, message, err)
+	default:
+		return errors.New(errors.ErrPointsCalc, message, err)
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"token-points-system/internal/config"
+	"token-points-system/internal/models"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+	"token-points-system/pkg/logger"
+)
+
+var adjustmentReasons = map[string]bool{
+	models.AdjustmentReasonCompensation: true,
+	models.AdjustmentReasonPromotion:    true,
+	models.AdjustmentReasonExploit:      true,
+	models.AdjustmentReasonCorrection:   true,
+	models.AdjustmentReasonOther:        true,
+}
+
+type AdjustmentService struct {
+	adjustmentRepo    *repository.AdjustmentRepository
+	calcRepo          *repository.CalculationRepository
+	approvalThreshold *big.Rat
+}
+
+func NewAdjustmentService(adjustmentRepo *repository.AdjustmentRepository, calcRepo *repository.CalculationRepository, cfg *config.PointsConfig) *AdjustmentService {
+	threshold := new(big.Rat)
+	threshold.SetFloat64(cfg.AdjustmentApprovalThreshold)
+
+	return &AdjustmentService{
+		adjustmentRepo:    adjustmentRepo,
+		calcRepo:          calcRepo,
+		approvalThreshold: threshold,
+	}
+}
+
+// AdjustmentRequest 人工调整请求
+// Amount为正表示补发，为负表示扣回；CalculationID指定被更正的周期计算，会出现在该周期的积分明细中
+type AdjustmentRequest struct {
+	ChainID       string
+	UserAddress   string
+	Amount        string
+	ReasonCode    string
+	Note          string
+	Actor         string
+	ExpiresAt     *time.Time
+	CalculationID *uint64
+}
+
+// CreateAdjustment 创建人工调整
+// 绝对值超过审批阈值的调整进入待审批状态，否则立即生效
+func (s *AdjustmentService) CreateAdjustment(ctx context.Context, req AdjustmentRequest) (*models.PointsAdjustment, error) {
+	if req.ChainID == "" || req.UserAddress == "" {
+		return nil, errors.New(errors.ErrInvalidRequest, "chain和address不能为空", nil)
+	}
+	if req.Actor == "" {
+		return nil, errors.New(errors.ErrInvalidRequest, "缺少操作人身份", nil)
+	}
+	if !adjustmentReasons[req.ReasonCode] {
+		return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的原因代码: %s", req.ReasonCode), nil)
+	}
+
+	amount := parseDecimal(req.Amount)
+	if amount.Sign() == 0 {
+		return nil, errors.New(errors.ErrInvalidRequest, "调整数量不能为0", nil)
+	}
+	if req.ExpiresAt != nil && amount.Sign() < 0 {
+		return nil, errors.New(errors.ErrInvalidRequest, "扣回积分不支持设置过期时间", nil)
+	}
+
+	if req.CalculationID != nil {
+		calc, err := s.calcRepo.GetByID(ctx, *req.CalculationID)
+		if err != nil {
+			return nil, errors.New(errors.ErrPointsCalc, "获取计算记录失败", err)
+		}
+		if calc == nil || calc.ChainID != req.ChainID || calc.UserAddress != req.UserAddress {
+			return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("计算记录不存在或不属于该用户: %d", *req.CalculationID), nil)
+		}
+	}
+
+	adj := &models.PointsAdjustment{
+		ChainID:       req.ChainID,
+		UserAddress:   req.UserAddress,
+		Amount:        formatDecimal(amount),
+		ReasonCode:    req.ReasonCode,
+		Note:          req.Note,
+		RequestedBy:   req.Actor,
+		ExpiresAt:     req.ExpiresAt,
+		Status:        models.AdjustmentApplied,
+		CalculationID: req.CalculationID,
+	}
+
+	if s.requiresApproval(amount) {
+		adj.Status = models.AdjustmentPending
+		adj.RequiresApproval = true
+	}
+
+	if err := s.adjustmentRepo.Create(ctx, adj); err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "创建积分调整失败", err)
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"adjustment_id": adj.ID,
+		"chain_id":      adj.ChainID,
+		"user_address":  adj.UserAddress,
+		"amount":        adj.Amount,
+		"reason_code":   adj.ReasonCode,
+		"requested_by":  adj.RequestedBy,
+		"status":        adj.Status,
+	}).Info("积分调整已创建")
+
+	return adj, nil
+}
+
+// Approve 审批通过调整，审批人不能是申请人
+func (s *AdjustmentService) Approve(ctx context.Context, id uint64, approver string) (*models.PointsAdjustment, error) {
+	return s.decide(ctx, id, approver, models.AdjustmentApplied)
+}
+
+// Reject 驳回调整
+func (s *AdjustmentService) Reject(ctx context.Context, id uint64, approver string) (*models.PointsAdjustment, error) {
+	return s.decide(ctx, id, approver, models.AdjustmentRejected)
+}
+
+// List 按状态获取调整记录
+func (s *AdjustmentService) List(ctx context.Context, status models.AdjustmentStatus, limit int) ([]models.PointsAdjustment, error) {
+	return s.adjustmentRepo.List(ctx, status, limit)
+}
+
+func (s *AdjustmentService) decide(ctx context.Context, id uint64, approver string, status models.AdjustmentStatus) (*models.PointsAdjustment, error) {
+	if approver == "" {
+		return nil, errors.New(errors.ErrInvalidRequest, "缺少审批人身份", nil)
+	}
+
+	errSameActor := stderrors.New("approver must differ from requester")
+	adj, err := s.adjustmentRepo.Decide(ctx, id, status, approver, func(a *models.PointsAdjustment) error {
+		if a.RequestedBy == approver {
+			return errSameActor
+		}
+		return nil
+	})
+	switch {
+	case stderrors.Is(err, errSameActor):
+		return nil, errors.New(errors.ErrConflict, "审批人不能与申请人相同", nil)
+	case stderrors.Is(err, repository.ErrAdjustmentState):
+		return nil, errors.New(errors.ErrConflict, "调整已处理", err)
+	case err != nil:
+		return nil, errors.New(errors.ErrPointsCalc, "审批积分调整失败", err)
+	case adj == nil:
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("调整记录不存在: %d", id), nil)
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"adjustment_id": adj.ID,
+		"status":        adj.Status,
+		"decided_by":    approver,
+	}).Info("积分调整已审批")
+
+	return adj, nil
+}
+
+// requiresApproval 阈值为0时不需要审批
+func (s *AdjustmentService) requiresApproval(amount *big.Rat) bool {
+	if s.approvalThreshold.Sign() <= 0 {
+		return false
+	}
+	return new(big.Rat).Abs(amount).Cmp(s.approvalThreshold) > 0
+}
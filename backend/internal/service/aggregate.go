@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"token-points-system/internal/config"
+	"token-points-system/internal/models"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+)
+
+// AggregateService 按地址或账户汇总所有启用链上的积分和余额
+// 综合积分 = Σ 链上总积分 × 链权重，权重由chains[].points_weight配置；
+// 地址关联了账户时，汇总账户下全部钱包
+type AggregateService struct {
+	pointsRepo  *repository.PointsRepository
+	balanceRepo *repository.BalanceRepository
+	accountRepo *repository.AccountRepository
+	weights     []repository.ChainWeight
+	chainIDs    []string
+	factors     map[string]*big.Rat
+}
+
+func NewAggregateService(
+	pointsRepo *repository.PointsRepository,
+	balanceRepo *repository.BalanceRepository,
+	accountRepo *repository.AccountRepository,
+	chains []config.ChainConfig,
+) (*AggregateService, error) {
+	s := &AggregateService{
+		pointsRepo:  pointsRepo,
+		balanceRepo: balanceRepo,
+		accountRepo: accountRepo,
+		factors:     make(map[string]*big.Rat),
+	}
+
+	for i := range chains {
+		chain := &chains[i]
+		if !chain.Enabled {
+			continue
+		}
+
+		weight := chain.GetPointsWeight()
+		if weight < 0 {
+			return nil, fmt.Errorf("链 %s 的points_weight不能为负数", chain.ID)
+		}
+
+		factor := decimalFromFloat(weight)
+		s.factors[chain.ID] = factor
+		s.chainIDs = append(s.chainIDs, chain.ID)
+		s.weights = append(s.weights, repository.ChainWeight{
+			ChainID: chain.ID,
+			Weight:  factor.FloatString(12),
+		})
+	}
+	return s, nil
+}
+
+// ChainWeights 返回启用链及其积分权重，按配置顺序
+func (s *AggregateService) ChainWeights() []repository.ChainWeight {
+	return s.weights
+}
+
+// ChainPoints 用户在一条链上的积分、余额及积分在综合积分中的加权值
+type ChainPoints struct {
+	ChainID        string `json:"chain_id"`
+	Points         string `json:"points"`
+	Weight         string `json:"weight"`
+	WeightedPoints string `json:"weighted_points"`
+	Balance        string `json:"balance"`
+}
+
+// AggregatedPoints 用户的跨链积分，AccountID不为空时为账户下全部钱包的合计
+type AggregatedPoints struct {
+	UserAddress    string        `json:"user_address"`
+	AccountID      *uint64       `json:"account_id,omitempty"`
+	Wallets        []string      `json:"wallets"`
+	Chains         []ChainPoints `json:"chains"`
+	CombinedPoints string        `json:"combined_points"`
+}
+
+// LeaderboardEntry 综合积分排行榜条目
+type LeaderboardEntry struct {
+	Rank int `json:"rank"`
+	AggregatedPoints
+}
+
+// GetAggregatedPoints 获取用户（或其所属账户）在所有启用链上的积分和综合积分，没有积分的链按0计
+func (s *AggregateService) GetAggregatedPoints(ctx context.Context, userAddress string) (*AggregatedPoints, error) {
+	address := strings.ToLower(userAddress)
+	result := &AggregatedPoints{UserAddress: userAddress, Wallets: []string{address}}
+
+	wallet, err := s.accountRepo.GetWallet(ctx, address)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取账户关联失败", err)
+	}
+	if wallet != nil {
+		wallets, err := s.accountRepo.GetWallets(ctx, wallet.AccountID)
+		if err != nil {
+			return nil, errors.New(errors.ErrPointsCalc, "获取账户钱包失败", err)
+		}
+		result.AccountID = &wallet.AccountID
+		result.Wallets = walletAddresses(wallets)
+	}
+
+	holdings, err := s.loadHoldings(ctx, result.Wallets)
+	if err != nil {
+		return nil, err
+	}
+	s.aggregate(result, holdings)
+	return result, nil
+}
+
+// Leaderboard 按综合积分分页返回排行榜和参与排名的总数，关联到同一账户的钱包合并排名
+func (s *AggregateService) Leaderboard(ctx context.Context, offset, limit int) ([]LeaderboardEntry, int64, error) {
+	entries := make([]LeaderboardEntry, 0, limit)
+	if len(s.chainIDs) == 0 {
+		return entries, 0, nil
+	}
+
+	scores, err := s.pointsRepo.RankByWeightedTotal(ctx, s.weights, offset, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrPointsCalc, "获取综合积分排行失败", err)
+	}
+
+	total, err := s.pointsRepo.CountRanked(ctx, s.chainIDs)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrPointsCalc, "统计排行数量失败", err)
+	}
+
+	accountIDs := make([]uint64, 0, len(scores))
+	for _, sc := range scores {
+		if sc.AccountID != nil {
+			accountIDs = append(accountIDs, *sc.AccountID)
+		}
+	}
+	wallets, err := s.accountRepo.GetWalletsByAccounts(ctx, accountIDs)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrPointsCalc, "获取账户钱包失败", err)
+	}
+	byAccount := make(map[uint64][]string, len(accountIDs))
+	for _, w := range wallets {
+		byAccount[w.AccountID] = append(byAccount[w.AccountID], w.Address)
+	}
+
+	addresses := make([]string, 0, len(scores)+len(wallets))
+	for i, sc := range scores {
+		entry := LeaderboardEntry{
+			Rank: offset + i + 1,
+			AggregatedPoints: AggregatedPoints{
+				UserAddress: sc.UserAddress,
+				AccountID:   sc.AccountID,
+				Wallets:     []string{strings.ToLower(sc.UserAddress)},
+			},
+		}
+		if sc.AccountID != nil {
+			entry.Wallets = byAccount[*sc.AccountID]
+		}
+		addresses = append(addresses, entry.Wallets...)
+		entries = append(entries, entry)
+	}
+
+	holdings, err := s.loadHoldings(ctx, addresses)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range entries {
+		s.aggregate(&entries[i].AggregatedPoints, holdings)
+	}
+	return entries, total, nil
+}
+
+// walletHoldings 钱包在一条链上的积分与余额
+type walletHoldings struct {
+	points  *big.Rat
+	balance *big.Rat
+}
+
+// loadHoldings 批量读取钱包在各启用链上的积分和余额，按小写地址和链索引
+// 地址按不区分大小写的排序规则存储，这里同样忽略大小写
+func (s *AggregateService) loadHoldings(ctx context.Context, addresses []string) (map[string]map[string]*walletHoldings, error) {
+	holdings := make(map[string]map[string]*walletHoldings, len(addresses))
+	if len(s.chainIDs) == 0 || len(addresses) == 0 {
+		return holdings, nil
+	}
+
+	get := func(address, chainID string) *walletHoldings {
+		key := strings.ToLower(address)
+		if holdings[key] == nil {
+			holdings[key] = make(map[string]*walletHoldings)
+		}
+		h, ok := holdings[key][chainID]
+		if !ok {
+			h = &walletHoldings{points: new(big.Rat), balance: new(big.Rat)}
+			holdings[key][chainID] = h
+		}
+		return h
+	}
+
+	points, err := s.pointsRepo.GetByAddresses(ctx, s.chainIDs, addresses)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取跨链积分失败", err)
+	}
+	for _, p := range points {
+		h := get(p.UserAddress, p.ChainID)
+		h.points.Add(h.points, parseDecimal(p.TotalPoints))
+	}
+
+	balances, err := s.balanceRepo.GetByAddresses(ctx, s.chainIDs, addresses)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取跨链余额失败", err)
+	}
+	for _, b := range balances {
+		h := get(b.UserAddress, b.ChainID)
+		h.balance.Add(h.balance, parseDecimal(b.Balance))
+	}
+	return holdings, nil
+}
+
+// aggregate 按配置的链顺序汇总result.Wallets的积分和余额，计算加权积分与综合积分
+func (s *AggregateService) aggregate(result *AggregatedPoints, holdings map[string]map[string]*walletHoldings) {
+	combined := new(big.Rat)
+	result.Chains = make([]ChainPoints, 0, len(s.chainIDs))
+	for _, chainID := range s.chainIDs {
+		points, balance := new(big.Rat), new(big.Rat)
+		for _, address := range result.Wallets {
+			if h, ok := holdings[address][chainID]; ok {
+				points.Add(points, h.points)
+				balance.Add(balance, h.balance)
+			}
+		}
+
+		weighted := new(big.Rat).Mul(points, s.factors[chainID])
+		combined.Add(combined, weighted)
+
+		result.Chains = append(result.Chains, ChainPoints{
+			ChainID:        chainID,
+			Points:         formatDecimal(points),
+			Weight:         s.factors[chainID].FloatString(6),
+			WeightedPoints: formatDecimal(weighted),
+			Balance:        balance.FloatString(0),
+		})
+	}
+	result.CombinedPoints = formatDecimal(combined)
+}
+
+func walletAddresses(wallets []models.AccountWallet) []string {
+	addresses := make([]string, 0, len(wallets))
+	for _, w := range wallets {
+		addresses = append(addresses, w.Address)
+	}
+	return addresses
+}
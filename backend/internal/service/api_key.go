@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	stderrors "errors"
+	"strings"
+	"time"
+
+	"token-points-system/internal/config"
+	"token-points-system/internal/models"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+	"token-points-system/pkg/logger"
+)
+
+const (
+	// apiKeyScheme API Key的固定开头，格式为 tps_<前缀>_<密钥>
+	apiKeyScheme = "tps"
+
+	apiKeyPrefixBytes          = 6
+	apiKeySecretBytes          = 32
+	defaultAPIKeyRotationGrace = 24 * time.Hour
+	maxUsagePathLength         = 255
+)
+
+// errAPIKeyInvalid 不区分Key不存在和密钥错误，避免泄露前缀是否有效
+var errAPIKeyInvalid = errors.New(errors.ErrUnauthorized, "无效的API Key", nil)
+
+// APIKeyService 管理接口API Key的创建、轮换、吊销、校验和使用记录
+// 数据库只保存Key的SHA-256摘要，完整的Key只在创建和轮换时返回一次
+type APIKeyService struct {
+	keyRepo       *repository.APIKeyRepository
+	rotationGrace time.Duration
+}
+
+func NewAPIKeyService(keyRepo *repository.APIKeyRepository, cfg *config.AuthConfig) *APIKeyService {
+	s := &APIKeyService{
+		keyRepo:       keyRepo,
+		rotationGrace: time.Duration(cfg.RotationGrace) * time.Second,
+	}
+	if s.rotationGrace <= 0 {
+		s.rotationGrace = defaultAPIKeyRotationGrace
+	}
+	return s
+}
+
+// APIKeyRequest 创建API Key的请求，ExpiresAt为空表示不过期
+type APIKeyRequest struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+	Actor     string
+}
+
+// IssuedAPIKey 新创建的API Key，Key为完整的密钥，之后无法再次获取
+type IssuedAPIKey struct {
+	APIKey *models.APIKey
+	Key    string
+}
+
+// Create 创建API Key
+func (s *APIKeyService) Create(ctx context.Context, req APIKeyRequest) (*IssuedAPIKey, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New(errors.ErrInvalidRequest, "name不能为空", nil)
+	}
+	if len(name) > 100 {
+		return nil, errors.New(errors.ErrInvalidRequest, "name不能超过100个字符", nil)
+	}
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New(errors.ErrInvalidRequest, "expiresAt必须晚于当前时间", nil)
+	}
+
+	key, issued, err := newAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	key.Name = name
+	key.Scopes = scopes
+	key.ExpiresAt = req.ExpiresAt
+	key.CreatedBy = req.Actor
+
+	if err := s.keyRepo.Create(ctx, key); err != nil {
+		return nil, errors.New(errors.ErrAPIKey, "创建API Key失败", err)
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"key_id":     key.ID,
+		"name":       key.Name,
+		"prefix":     key.Prefix,
+		"scopes":     key.Scopes,
+		"created_by": key.CreatedBy,
+	}).Info("API Key已创建")
+
+	return &IssuedAPIKey{APIKey: key, Key: issued}, nil
+}
+
+// Rotate 为Key生成名称、权限和过期时间都相同的新Key，旧Key在轮换宽限期结束时过期
+// 宽限期内新旧Key都可用，调用方可以逐步替换；已吊销或已过期的Key不能轮换
+func (s *APIKeyService) Rotate(ctx context.Context, id uint64, actor string) (*IssuedAPIKey, error) {
+	newKey, issued, err := newAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	newKey.CreatedBy = actor
+
+	now := time.Now()
+	old, err := s.keyRepo.Rotate(ctx, id, now.Add(s.rotationGrace), func(old *models.APIKey) error {
+		if !old.Active(now) {
+			return errors.New(errors.ErrConflict, "已吊销或已过期的API Key不能轮换", nil)
+		}
+		newKey.Name = old.Name
+		newKey.Scopes = old.Scopes
+		newKey.ExpiresAt = old.ExpiresAt
+		return nil
+	}, newKey)
+	if err != nil {
+		var appErr *errors.AppError
+		if stderrors.As(err, &appErr) {
+			return nil, err
+		}
+		return nil, errors.New(errors.ErrAPIKey, "轮换API Key失败", err)
+	}
+	if old == nil {
+		return nil, errors.New(errors.ErrNotFound, "API Key不存在", nil)
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"key_id":       newKey.ID,
+		"rotated_from": old.ID,
+		"old_expires":  old.ExpiresAt,
+		"created_by":   actor,
+	}).Info("API Key已轮换")
+
+	return &IssuedAPIKey{APIKey: newKey, Key: issued}, nil
+}
+
+// Revoke 立即吊销Key
+func (s *APIKeyService) Revoke(ctx context.Context, id uint64, actor string) (*models.APIKey, error) {
+	revoked, err := s.keyRepo.Revoke(ctx, id, time.Now())
+	if err != nil {
+		return nil, errors.New(errors.ErrAPIKey, "吊销API Key失败", err)
+	}
+
+	key, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		logger.WithFields(map[string]interface{}{
+			"key_id":     key.ID,
+			"name":       key.Name,
+			"revoked_by": actor,
+		}).Info("API Key已吊销")
+	}
+	return key, nil
+}
+
+// Get 获取Key
+func (s *APIKeyService) Get(ctx context.Context, id uint64) (*models.APIKey, error) {
+	key, err := s.keyRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.New(errors.ErrAPIKey, "查询API Key失败", err)
+	}
+	if key == nil {
+		return nil, errors.New(errors.ErrNotFound, "API Key不存在", nil)
+	}
+	return key, nil
+}
+
+// List 分页列出Key，包括已吊销和已过期的
+func (s *APIKeyService) List(ctx context.Context, offset, limit int) ([]models.APIKey, int64, error) {
+	keys, total, err := s.keyRepo.List(ctx, offset, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrAPIKey, "查询API Key失败", err)
+	}
+	return keys, total, nil
+}
+
+// ListUsage 分页列出Key的使用记录
+func (s *APIKeyService) ListUsage(ctx context.Context, id uint64, offset, limit int) ([]models.APIKeyUsage, int64, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, 0, err
+	}
+	usage, total, err := s.keyRepo.ListUsage(ctx, id, offset, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrAPIKey, "查询API Key使用记录失败", err)
+	}
+	return usage, total, nil
+}
+
+// Authenticate 校验完整的Key，返回可用的Key
+func (s *APIKeyService) Authenticate(ctx context.Context, raw string) (*models.APIKey, error) {
+	prefix, ok := parseAPIKey(raw)
+	if !ok {
+		return nil, errAPIKeyInvalid
+	}
+
+	key, err := s.keyRepo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, errors.New(errors.ErrAPIKey, "查询API Key失败", err)
+	}
+	if key == nil || subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashAPIKey(raw))) != 1 {
+		return nil, errAPIKeyInvalid
+	}
+
+	if !key.Active(time.Now()) {
+		logger.WithFields(map[string]interface{}{
+			"key_id": key.ID,
+			"name":   key.Name,
+		}).Warn("使用已吊销或已过期的API Key")
+		if key.RevokedAt != nil {
+			return nil, errors.New(errors.ErrUnauthorized, "API Key已吊销", nil)
+		}
+		return nil, errors.New(errors.ErrUnauthorized, "API Key已过期", nil)
+	}
+	return key, nil
+}
+
+// RecordUsage 记录Key的一次使用，失败只记录日志
+func (s *APIKeyService) RecordUsage(ctx context.Context, usage *models.APIKeyUsage) {
+	if len(usage.Path) > maxUsagePathLength {
+		usage.Path = usage.Path[:maxUsagePathLength]
+	}
+	if err := s.keyRepo.RecordUsage(ctx, usage); err != nil {
+		logger.Error("记录API Key使用失败:", usage.KeyID, err)
+	}
+}
+
+// newAPIKey 生成随机的Key，返回待保存的记录（只含前缀和摘要）和完整的Key
+func newAPIKey() (*models.APIKey, string, error) {
+	prefix := make([]byte, apiKeyPrefixBytes)
+	secret := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, "", errors.New(errors.ErrAPIKey, "生成API Key失败", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", errors.New(errors.ErrAPIKey, "生成API Key失败", err)
+	}
+
+	key := apiKeyScheme + "_" + hex.EncodeToString(prefix) + "_" + hex.EncodeToString(secret)
+	return &models.APIKey{
+		Prefix:  hex.EncodeToString(prefix),
+		KeyHash: hashAPIKey(key),
+	}, key, nil
+}
+
+// parseAPIKey 检查Key的格式并返回其中的前缀
+func parseAPIKey(raw string) (string, bool) {
+	parts := strings.Split(raw, "_")
+	if len(parts) != 3 || parts[0] != apiKeyScheme ||
+		len(parts[1]) != apiKeyPrefixBytes*2 || len(parts[2]) != apiKeySecretBytes*2 {
+		return "", false
+	}
+	return parts[1], true
+}
+
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeScopes 校验权限并按APIKeyScopes的顺序去重，返回逗号分隔的结果
+func normalizeScopes(scopes []string) (string, error) {
+	if len(scopes) == 0 {
+		return "", errors.New(errors.ErrInvalidRequest, "scopes不能为空", nil)
+	}
+
+	requested := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		valid := false
+		for _, known := range models.APIKeyScopes {
+			if scope == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return "", errors.New(errors.ErrInvalidRequest, "无效的权限: "+scope, nil)
+		}
+		requested[scope] = true
+	}
+
+	var normalized []string
+	for _, known := range models.APIKeyScopes {
+		if requested[known] {
+			normalized = append(normalized, known)
+		}
+	}
+	return strings.Join(normalized, ","), nil
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"token-points-system/internal/blockchain"
+	"token-points-system/internal/config"
+	"token-points-system/internal/models"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+const (
+	defaultAttestationDomainName = "Token Points Attestation"
+	defaultAttestationTTL        = time.Hour
+	defaultAttestationMaxTTL     = 7 * 24 * time.Hour
+)
+
+// AttestationService 签发EIP-712积分证明，供合作方在链上校验用户积分而不依赖本系统的API
+// 签名私钥从本地文件加载；每个账户的nonce递增，签发的证明全部记录在points_attestations中
+type AttestationService struct {
+	attestationRepo *repository.AttestationRepository
+	pointsRepo      *repository.PointsRepository
+	seasonSvc       *SeasonService
+	signer          *blockchain.Signer
+	domain          blockchain.AttestationDomain
+	evmChainIDs     map[string]uint64
+	ttl             time.Duration
+	maxTTL          time.Duration
+}
+
+// NewAttestationService 创建积分证明服务，未配置私钥文件时服务不可用（Enabled返回false）
+func NewAttestationService(attestationRepo *repository.AttestationRepository, pointsRepo *repository.PointsRepository, seasonSvc *SeasonService, chains []config.ChainConfig, cfg *config.AttestationConfig) (*AttestationService, error) {
+	domain := blockchain.AttestationDomain{
+		Name:    cfg.DomainName,
+		Version: cfg.DomainVersion,
+		ChainID: cfg.VerifyingChainID,
+	}
+	s := &AttestationService{
+		attestationRepo: attestationRepo,
+		pointsRepo:      pointsRepo,
+		seasonSvc:       seasonSvc,
+		domain:          domain,
+		evmChainIDs:     make(map[string]uint64),
+		ttl:             time.Duration(cfg.TTL) * time.Second,
+		maxTTL:          time.Duration(cfg.MaxTTL) * time.Second,
+	}
+	if s.domain.Name == "" {
+		s.domain.Name = defaultAttestationDomainName
+	}
+	if s.domain.Version == "" {
+		s.domain.Version = defaultDomainVersion
+	}
+	if s.ttl <= 0 {
+		s.ttl = defaultAttestationTTL
+	}
+	if s.maxTTL <= 0 {
+		s.maxTTL = defaultAttestationMaxTTL
+	}
+	if s.ttl > s.maxTTL {
+		return nil, fmt.Errorf("attestation ttl must not exceed max_ttl")
+	}
+
+	for _, chain := range chains {
+		if chain.Enabled {
+			s.evmChainIDs[chain.ID] = chain.ChainID
+		}
+	}
+
+	if cfg.KeyFile != "" {
+		if cfg.VerifyingChainID == 0 {
+			return nil, fmt.Errorf("attestation verifying_chain_id is required when key_file is set")
+		}
+		if !common.IsHexAddress(cfg.VerifyingContract) {
+			return nil, fmt.Errorf("attestation verifying_contract must be a valid address when key_file is set")
+		}
+		s.domain.VerifyingContract = common.HexToAddress(cfg.VerifyingContract)
+
+		signer, err := blockchain.LoadSigner(cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		s.signer = signer
+	}
+	return s, nil
+}
+
+// Enabled 是否配置了签名私钥
+func (s *AttestationService) Enabled() bool {
+	return s.signer != nil
+}
+
+// AttestationSigner 签名者地址和EIP-712签名域，合作方据此配置合约
+type AttestationSigner struct {
+	Signer            string `json:"signer"`
+	DomainName        string `json:"domain_name"`
+	DomainVersion     string `json:"domain_version"`
+	DomainChainID     uint64 `json:"domain_chain_id"`
+	VerifyingContract string `json:"verifying_contract"`
+	PrimaryType       string `json:"primary_type"`
+}
+
+// AttestationRequest 签发证明请求，Season为空表示累计总积分，否则为赛季ID或current；TTL为0时使用默认有效期
+type AttestationRequest struct {
+	ChainID     string
+	Address     string
+	Season      string
+	TTL         time.Duration
+	RequestedBy string
+}
+
+// SignedAttestation 签发的证明记录及签名的结构化数据
+type SignedAttestation struct {
+	models.PointsAttestation
+	TypedData apitypes.TypedData `json:"typed_data"`
+}
+
+// AttestationVerifyRequest 待校验的证明，数值均为十进制字符串
+type AttestationVerifyRequest struct {
+	Account   string
+	ChainID   string
+	Points    string
+	Season    string
+	Nonce     string
+	Expiry    string
+	Signature string
+}
+
+// AttestationVerification 校验结果
+// Valid表示签名由当前签名者签署且未过期；Logged表示与签发记录中同一账户同一nonce的证明一致
+type AttestationVerification struct {
+	Valid         bool       `json:"valid"`
+	Signer        string     `json:"signer,omitempty"`
+	TrustedSigner string     `json:"trusted_signer"`
+	Expired       bool       `json:"expired"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	Digest        string     `json:"digest,omitempty"`
+	Logged        bool       `json:"logged"`
+	AttestationID *uint64    `json:"attestation_id,omitempty"`
+	IssuedAt      *time.Time `json:"issued_at,omitempty"`
+	Reason        string     `json:"reason,omitempty"`
+	CheckedAt     time.Time  `json:"checked_at"`
+}
+
+// Signer 返回签名者地址和签名域
+func (s *AttestationService) Signer() *AttestationSigner {
+	return &AttestationSigner{
+		Signer:            s.signer.Address().Hex(),
+		DomainName:        s.domain.Name,
+		DomainVersion:     s.domain.Version,
+		DomainChainID:     s.domain.ChainID,
+		VerifyingContract: s.domain.VerifyingContract.Hex(),
+		PrimaryType:       blockchain.AttestationTypedDataType,
+	}
+}
+
+// Issue 读取用户当前的累计积分或赛季积分，分配nonce并签发证明
+func (s *AttestationService) Issue(ctx context.Context, req AttestationRequest) (*SignedAttestation, error) {
+	evmChainID, ok := s.evmChainIDs[req.ChainID]
+	if !ok {
+		return nil, errors.New(errors.ErrInvalidChain, fmt.Sprintf("链未启用: %s", req.ChainID), nil)
+	}
+	if !common.IsHexAddress(req.Address) {
+		return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的地址: %s", req.Address), nil)
+	}
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = s.ttl
+	}
+	if ttl < 0 || ttl > s.maxTTL {
+		return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("有效期必须在1到%d秒之间", int64(s.maxTTL/time.Second)), nil)
+	}
+
+	address := common.HexToAddress(req.Address)
+	points, seasonID, err := s.currentPoints(ctx, req.ChainID, address.Hex(), req.Season)
+	if err != nil {
+		return nil, err
+	}
+
+	scaled := new(big.Rat).Mul(parseDecimal(points), new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(pointsScale), nil)))
+	units := new(big.Int).Quo(scaled.Num(), scaled.Denom())
+
+	attestation := &models.PointsAttestation{
+		Signer:      s.signer.Address().Hex(),
+		UserAddress: address.Hex(),
+		ChainID:     req.ChainID,
+		EVMChainID:  evmChainID,
+		SeasonID:    seasonID,
+		Points:      formatDecimal(parseDecimal(points)),
+		PointsUnits: units.String(),
+		ExpiresAt:   time.Now().Add(ttl).Truncate(time.Second),
+		RequestedBy: req.RequestedBy,
+	}
+
+	var typedData apitypes.TypedData
+	err = s.attestationRepo.Create(ctx, attestation, func(a *models.PointsAttestation) error {
+		msg := &blockchain.PointsAttestation{
+			Account: address,
+			ChainID: evmChainID,
+			Points:  units,
+			Season:  seasonID,
+			Nonce:   a.Nonce,
+			Expiry:  a.ExpiresAt.Unix(),
+		}
+		typedData = msg.TypedData(s.domain)
+
+		digest, signature, err := s.signer.SignTypedData(typedData)
+		if err != nil {
+			return err
+		}
+		a.Digest = digest
+		a.Signature = signature
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New(errors.ErrAttestation, "签发积分证明失败", err)
+	}
+
+	return &SignedAttestation{PointsAttestation: *attestation, TypedData: typedData}, nil
+}
+
+// Verify 从签名恢复签名者，检查是否为当前签名者、是否过期以及是否与签发记录一致
+func (s *AttestationService) Verify(ctx context.Context, req AttestationVerifyRequest) (*AttestationVerification, error) {
+	msg, err := parseAttestationMessage(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := &AttestationVerification{
+		TrustedSigner: s.signer.Address().Hex(),
+		ExpiresAt:     time.Unix(msg.Expiry, 0).UTC(),
+		Expired:       msg.Expiry <= now.Unix(),
+		CheckedAt:     now,
+	}
+
+	typedData := msg.TypedData(s.domain)
+	signer, err := blockchain.RecoverTypedData(typedData, req.Signature)
+	if err != nil {
+		result.Reason = err.Error()
+		return result, nil
+	}
+	result.Signer = signer.Hex()
+	if digest, _, err := apitypes.TypedDataAndHash(typedData); err == nil {
+		result.Digest = hexutil.Encode(digest)
+	}
+
+	logged, err := s.attestationRepo.GetByNonce(ctx, msg.Account.Hex(), msg.Nonce)
+	if err != nil {
+		return nil, errors.New(errors.ErrAttestation, "查询积分证明记录失败", err)
+	}
+	if logged != nil && strings.EqualFold(logged.Digest, result.Digest) && strings.EqualFold(logged.Signer, result.Signer) {
+		result.Logged = true
+		result.AttestationID = &logged.ID
+		result.IssuedAt = &logged.CreatedAt
+	}
+
+	switch {
+	case signer != s.signer.Address():
+		result.Reason = "signature was not produced by the trusted signer"
+	case result.Expired:
+		result.Reason = "attestation has expired"
+	default:
+		result.Valid = true
+	}
+	return result, nil
+}
+
+// GetAttestation 获取证明记录
+func (s *AttestationService) GetAttestation(ctx context.Context, id uint64) (*models.PointsAttestation, error) {
+	attestation, err := s.attestationRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.New(errors.ErrAttestation, "获取积分证明记录失败", err)
+	}
+	if attestation == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("积分证明不存在: %d", id), nil)
+	}
+	return attestation, nil
+}
+
+// ListAttestations 按ID倒序分页获取证明记录
+func (s *AttestationService) ListAttestations(ctx context.Context, userAddress, chainID string, offset, limit int) ([]models.PointsAttestation, int64, error) {
+	if userAddress != "" {
+		if !common.IsHexAddress(userAddress) {
+			return nil, 0, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的地址: %s", userAddress), nil)
+		}
+		userAddress = common.HexToAddress(userAddress).Hex()
+	}
+
+	attestations, total, err := s.attestationRepo.List(ctx, userAddress, chainID, offset, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrAttestation, "获取积分证明记录失败", err)
+	}
+	if attestations == nil {
+		attestations = []models.PointsAttestation{}
+	}
+	return attestations, total, nil
+}
+
+// currentPoints 返回用户的累计积分，或赛季积分及赛季ID
+func (s *AttestationService) currentPoints(ctx context.Context, chainID, userAddress, season string) (string, uint64, error) {
+	if season == "" {
+		points, err := s.pointsRepo.GetByUser(ctx, chainID, userAddress)
+		if err != nil {
+			return "", 0, errors.New(errors.ErrAttestation, "获取用户积分失败", err)
+		}
+		if points == nil {
+			return "0", 0, nil
+		}
+		return points.TotalPoints, 0, nil
+	}
+
+	standing, err := s.seasonSvc.UserStanding(ctx, season, chainID, userAddress)
+	if err != nil {
+		return "", 0, err
+	}
+	if standing.Standing == nil {
+		return "0", standing.Season.ID, nil
+	}
+	return standing.Standing.Total, standing.Season.ID, nil
+}
+
+// parseAttestationMessage 解析待校验证明的各字段
+func parseAttestationMessage(req AttestationVerifyRequest) (*blockchain.PointsAttestation, error) {
+	if !common.IsHexAddress(req.Account) {
+		return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的地址: %s", req.Account), nil)
+	}
+	if req.Signature == "" {
+		return nil, errors.New(errors.ErrInvalidRequest, "缺少签名", nil)
+	}
+
+	chainID, err := strconv.ParseUint(req.ChainID, 10, 64)
+	if err != nil {
+		return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的chainId: %s", req.ChainID), nil)
+	}
+	points, ok := new(big.Int).SetString(req.Points, 10)
+	if !ok || points.Sign() < 0 {
+		return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的points: %s", req.Points), nil)
+	}
+	season, err := strconv.ParseUint(req.Season, 10, 64)
+	if err != nil {
+		return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的season: %s", req.Season), nil)
+	}
+	nonce, err := strconv.ParseUint(req.Nonce, 10, 64)
+	if err != nil {
+		return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的nonce: %s", req.Nonce), nil)
+	}
+	expiry, err := strconv.ParseInt(req.Expiry, 10, 64)
+	if err != nil || expiry < 0 {
+		return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的expiry: %s", req.Expiry), nil)
+	}
+
+	return &blockchain.PointsAttestation{
+		Account: common.HexToAddress(req.Account),
+		ChainID: chainID,
+		Points:  points,
+		Season:  season,
+		Nonce:   nonce,
+		Expiry:  expiry,
+	}, nil
+}
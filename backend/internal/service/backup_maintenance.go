@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"token-points-system/internal/models"
+	"token-points-system/pkg/errors"
+	"token-points-system/pkg/logger"
+)
+
+// BackupVerifyReport 备份校验结果
+// 余额比较忽略余额为0的地址；MismatchedHolders为两边余额不一致或只出现在一边的地址数
+type BackupVerifyReport struct {
+	BackupID          uint64                    `json:"backup_id"`
+	ChainID           string                    `json:"chain_id"`
+	BlockHeight       int64                     `json:"block_height"`
+	Status            models.BackupVerifyStatus `json:"status"`
+	Note              string                    `json:"note,omitempty"`
+	ChecksumValid     bool                      `json:"checksum_valid"`
+	SnapshotHolders   int                       `json:"snapshot_holders"`
+	ReplayHolders     int                       `json:"replay_holders"`
+	SnapshotTotal     string                    `json:"snapshot_total"`
+	ReplayTotal       string                    `json:"replay_total"`
+	MismatchedHolders int                       `json:"mismatched_holders"`
+	VerifiedAt        time.Time                 `json:"verified_at"`
+}
+
+// VerifyBackup 重新计算备份的校验和，并与按余额历史重放到备份区块的余额逐个地址比较
+// 校验和不匹配或余额不一致时标记为corrupted；余额历史尚未处理到备份区块时标记为unverifiable
+func (s *RecoveryService) VerifyBackup(ctx context.Context, id uint64) (*BackupVerifyReport, error) {
+	backup, err := s.backupRepo.GetByID(ctx, id, true)
+	if err != nil {
+		return nil, errors.New(errors.ErrBackup, "获取备份失败", err)
+	}
+	if backup == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("备份不存在: %d", id), nil)
+	}
+
+	report := &BackupVerifyReport{
+		BackupID:      backup.ID,
+		ChainID:       backup.ChainID,
+		BlockHeight:   backup.BlockHeight,
+		SnapshotTotal: "0",
+		ReplayTotal:   "0",
+		VerifiedAt:    time.Now(),
+	}
+
+	if err := s.verifyBackup(ctx, backup, report); err != nil {
+		return nil, err
+	}
+
+	if err := s.backupRepo.UpdateVerification(ctx, backup.ID, report.Status, report.Note, report.VerifiedAt); err != nil {
+		return nil, errors.New(errors.ErrBackup, "保存校验结果失败", err)
+	}
+
+	entry := logger.WithFields(map[string]interface{}{
+		"backup_id":    backup.ID,
+		"chain_id":     backup.ChainID,
+		"block_height": backup.BlockHeight,
+		"status":       report.Status,
+		"note":         report.Note,
+	})
+	if report.Status == models.BackupVerified {
+		entry.Info("备份校验完成")
+	} else {
+		entry.Warn("备份校验未通过")
+	}
+
+	return report, nil
+}
+
+func (s *RecoveryService) verifyBackup(ctx context.Context, backup *models.CalculationBackup, report *BackupVerifyReport) error {
+	checksum, err := backupChecksum(&backup.BackupData)
+	if err != nil {
+		return errors.New(errors.ErrBackup, "计算备份校验和失败", err)
+	}
+	if checksum != backup.Checksum {
+		report.Status = models.BackupCorrupted
+		report.Note = "校验和不匹配"
+		return nil
+	}
+	report.ChecksumValid = true
+
+	processed, err := s.blockRepo.GetLastProcessed(ctx, backup.ChainID)
+	if err != nil {
+		return errors.New(errors.ErrBackup, "获取已处理区块失败", err)
+	}
+	if processed < backup.BlockHeight {
+		report.Status = models.BackupUnverifiable
+		report.Note = fmt.Sprintf("余额历史只处理到区块%d", processed)
+		return nil
+	}
+
+	snapshot, snapshotTotal, err := holderBalances(backup.BackupData.Balances)
+	if err != nil {
+		report.Status = models.BackupCorrupted
+		report.Note = err.Error()
+		return nil
+	}
+
+	replayed, err := s.backupRepo.ReplayBalances(ctx, backup.ChainID, backup.BlockHeight)
+	if err != nil {
+		return errors.New(errors.ErrBackup, "重放余额历史失败", err)
+	}
+	replay, replayTotal, err := holderBalances(replayed)
+	if err != nil {
+		return errors.New(errors.ErrBackup, "重放余额历史失败", err)
+	}
+
+	report.SnapshotHolders = len(snapshot)
+	report.ReplayHolders = len(replay)
+	report.SnapshotTotal = snapshotTotal.String()
+	report.ReplayTotal = replayTotal.String()
+
+	if len(snapshot) > 0 && len(replay) == 0 {
+		report.Status = models.BackupUnverifiable
+		report.Note = fmt.Sprintf("没有区块%d及之前的余额历史", backup.BlockHeight)
+		return nil
+	}
+
+	for address, balance := range snapshot {
+		if other, ok := replay[address]; !ok || other.Cmp(balance) != 0 {
+			report.MismatchedHolders++
+		}
+	}
+	for address := range replay {
+		if _, ok := snapshot[address]; !ok {
+			report.MismatchedHolders++
+		}
+	}
+
+	if report.MismatchedHolders > 0 || snapshotTotal.Cmp(replayTotal) != 0 {
+		report.Status = models.BackupCorrupted
+		report.Note = fmt.Sprintf("%d个地址的余额与余额历史不一致", report.MismatchedHolders)
+		return nil
+	}
+
+	report.Status = models.BackupVerified
+	return nil
+}
+
+// holderBalances 按小写地址汇总非零余额
+func holderBalances(balances []models.BackupBalance) (map[string]*big.Int, *big.Int, error) {
+	holders := make(map[string]*big.Int, len(balances))
+	total := new(big.Int)
+	for _, b := range balances {
+		balance, ok := new(big.Int).SetString(b.Balance, 10)
+		if !ok {
+			return nil, nil, fmt.Errorf("地址%s的余额无效: %s", b.UserAddress, b.Balance)
+		}
+		if balance.Sign() == 0 {
+			continue
+		}
+		holders[strings.ToLower(b.UserAddress)] = balance
+		total.Add(total, balance)
+	}
+	return holders, total, nil
+}
+
+// ApplyRetention 按保留策略删除备份
+// 每条链保留最新的一份，以及最近retention_days天每天、最近keep_weeks周每周、最近keep_months个月每月的最后一份；
+// 挑选每个时间段的代表时跳过已损坏的备份。三项都不大于0时不删除任何备份
+func (s *RecoveryService) ApplyRetention(ctx context.Context, now time.Time) (int64, error) {
+	policy := s.retention
+	if policy.RetentionDays <= 0 && policy.KeepWeeks <= 0 && policy.KeepMonths <= 0 {
+		return 0, nil
+	}
+
+	backups, err := s.backupRepo.ListAll(ctx)
+	if err != nil {
+		return 0, errors.New(errors.ErrBackup, "获取备份列表失败", err)
+	}
+
+	prune := backupsToPrune(backups, now, policy.RetentionDays, policy.KeepWeeks, policy.KeepMonths)
+	if len(prune) == 0 {
+		return 0, nil
+	}
+
+	deleted, err := s.backupRepo.DeleteByIDs(ctx, prune)
+	if err != nil {
+		return deleted, errors.New(errors.ErrBackup, "删除过期备份失败", err)
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"deleted": deleted,
+		"kept":    len(backups) - int(deleted),
+	}).Info("备份保留策略已执行")
+
+	return deleted, nil
+}
+
+// backupsToPrune 返回不在保留范围内的备份ID，backups需按链分组、同一链内按创建时间倒序排列
+func backupsToPrune(backups []models.CalculationBackup, now time.Time, days, weeks, months int) []uint64 {
+	loc := now.Location()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	weekStart := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+
+	tiers := []retentionTier{
+		{today.AddDate(0, 0, -(days - 1)), func(t time.Time) string { return t.Format("2006-01-02") }, days},
+		{weekStart.AddDate(0, 0, -7*(weeks-1)), func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}, weeks},
+		{monthStart.AddDate(0, -(months - 1), 0), func(t time.Time) string { return t.Format("2006-01") }, months},
+	}
+
+	var prune []uint64
+	chain := ""
+	var seen []map[string]bool
+	for i := range backups {
+		b := &backups[i]
+		if i == 0 || b.ChainID != chain {
+			// 每条链最新的一份始终保留
+			chain = b.ChainID
+			seen = make([]map[string]bool, len(tiers))
+			for j := range seen {
+				seen[j] = make(map[string]bool)
+			}
+			if b.VerifyStatus != models.BackupCorrupted {
+				markTiers(tiers, seen, b)
+			}
+			continue
+		}
+
+		keep := false
+		if b.VerifyStatus != models.BackupCorrupted {
+			keep = markTiers(tiers, seen, b)
+		}
+		if !keep {
+			prune = append(prune, b.ID)
+		}
+	}
+	return prune
+}
+
+// retentionTier 一个保留层级：since之后的备份按key分段，每段保留一份
+type retentionTier struct {
+	since time.Time
+	key   func(t time.Time) string
+	count int
+}
+
+// markTiers 把备份记为它所在的每个尚无代表的时间段的代表，成为任一时间段的代表时返回true
+func markTiers(tiers []retentionTier, seen []map[string]bool, b *models.CalculationBackup) bool {
+	keep := false
+	for j, t := range tiers {
+		if t.count <= 0 || b.CreatedAt.Before(t.since) {
+			continue
+		}
+		k := t.key(b.CreatedAt.In(t.since.Location()))
+		if !seen[j][k] {
+			seen[j][k] = true
+			keep = true
+		}
+	}
+	return keep
+}
@@ -43,14 +43,12 @@ func (s *BalanceService) ProcessTransfer(ctx context.Context, chainID string, ev
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	exists, err := s.historyRepo.ExistsByTxHash(ctx, event.TxHash)
-	if err != nil {
-		return errors.New(errors.ErrBalanceUpdate, "检查交易是否存在失败", err)
-	}
-	if exists {
+	// 监听器已回退（如恢复备份后），事件会被重新拉取
+	if event.Stale() {
 		logger.WithFields(map[string]interface{}{
-			"tx_hash": event.TxHash,
-		}).Debug("交易已处理")
+			"tx_hash":   event.TxHash,
+			"block_num": event.BlockNum,
+		}).Debug("事件已过期，跳过")
 		return nil
 	}
 
@@ -66,10 +64,32 @@ func (s *BalanceService) ProcessTransfer(ctx context.Context, chainID string, ev
 		}
 	}
 
-	return s.blockRepo.MarkProcessed(ctx, chainID, event.BlockNum)
+	return s.blockRepo.MarkProcessed(ctx, chainID, event.BlockNum, timestamp)
+}
+
+// Exclusive 暂停转账处理并执行fn，用于恢复备份等需要与事件处理互斥的操作
+func (s *BalanceService) Exclusive(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn()
 }
 
+// processUserTransfer 记录一个Transfer事件对单个用户余额的变动
+// 按（链，交易哈希，日志序号，用户）去重：同一交易中的多个事件分别记录，上次只写入了一方的事件重新处理时补写另一方
 func (s *BalanceService) processUserTransfer(ctx context.Context, chainID string, userAddr string, event *blockchain.TransferEvent, timestamp time.Time, client BalanceClient) error {
+	exists, err := s.historyRepo.ExistsByEvent(ctx, chainID, event.TxHash, event.LogIndex, userAddr)
+	if err != nil {
+		return errors.New(errors.ErrBalanceUpdate, "检查事件是否已处理失败", err)
+	}
+	if exists {
+		logger.WithFields(map[string]interface{}{
+			"tx_hash":      event.TxHash,
+			"log_index":    event.LogIndex,
+			"user_address": userAddr,
+		}).Debug("事件已处理")
+		return nil
+	}
+
 	currentBalance, err := s.balanceRepo.GetByUser(ctx, chainID, userAddr)
 	if err != nil {
 		return err
@@ -128,6 +148,7 @@ func (s *BalanceService) processUserTransfer(ctx context.Context, chainID string
 		ChangeAmount:  changeAmount.String(),
 		ChangeType:    event.DetermineChangeType(userAddr),
 		TxHash:        event.TxHash,
+		LogIndex:      event.LogIndex,
 		BlockNumber:   event.BlockNum,
 		Timestamp:     timestamp,
 	}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+)
+
+// PointInTimeBalance 用户在指定区块或时间的余额
+// Block为查询的区块（按时间查询时为空），LastChangeBlock为生效的最后一次变动所在区块
+type PointInTimeBalance struct {
+	ChainID         string     `json:"chain"`
+	Address         string     `json:"address"`
+	Balance         string     `json:"balance"`
+	Block           *int64     `json:"block,omitempty"`
+	At              *time.Time `json:"at,omitempty"`
+	LastChangeBlock *int64     `json:"lastChangeBlock"`
+	LastChangeTx    string     `json:"lastChangeTx,omitempty"`
+	ProcessedBlock  int64      `json:"processedBlock"`
+}
+
+// HolderQuery 持有人快照查询条件，Block为0时使用最新已处理区块
+type HolderQuery struct {
+	ChainID    string
+	Block      int64
+	MinBalance string
+	Offset     int
+	Limit      int
+}
+
+// HolderSnapshot 指定区块处理完成时的持有人列表
+type HolderSnapshot struct {
+	ChainID      string                     `json:"chain"`
+	Block        int64                      `json:"block"`
+	MinBalance   string                     `json:"minBalance"`
+	Holders      int64                      `json:"total"`
+	TotalBalance string                     `json:"totalBalance"`
+	Items        []repository.HolderBalance `json:"items"`
+}
+
+// BalanceAtBlock 按余额历史查询用户在block处理完成时的余额，block不能超过已处理的区块
+func (s *BalanceService) BalanceAtBlock(ctx context.Context, chainID, userAddress string, block int64) (*PointInTimeBalance, error) {
+	processed, err := s.checkProcessed(ctx, chainID, block)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.balanceRepo.GetHistoryAtBlock(ctx, chainID, userAddress, block)
+	if err != nil {
+		return nil, errors.New(errors.ErrBalanceUpdate, "查询历史余额失败", err)
+	}
+
+	result := &PointInTimeBalance{
+		ChainID:        chainID,
+		Address:        userAddress,
+		Balance:        "0",
+		Block:          &block,
+		ProcessedBlock: processed,
+	}
+	if history != nil {
+		result.Balance = history.BalanceAfter
+		result.LastChangeBlock = &history.BlockNumber
+		result.LastChangeTx = history.TxHash
+	}
+	return result, nil
+}
+
+// BalanceAtTime 按余额历史查询用户在at时的余额，同一秒内的多条变动以区块号和日志顺序最后的为准
+// 余额历史只覆盖已处理的区块，at晚于已处理区块的时间时结果可能不完整
+func (s *BalanceService) BalanceAtTime(ctx context.Context, chainID, userAddress string, at time.Time) (*PointInTimeBalance, error) {
+	if at.After(time.Now()) {
+		return nil, errors.New(errors.ErrInvalidRequest, "时间不能晚于当前时间", nil)
+	}
+
+	processed, err := s.blockRepo.GetLastProcessed(ctx, chainID)
+	if err != nil {
+		return nil, errors.New(errors.ErrBalanceUpdate, "获取已处理区块失败", err)
+	}
+
+	history, err := s.balanceRepo.GetHistoryAtTime(ctx, chainID, userAddress, at)
+	if err != nil {
+		return nil, errors.New(errors.ErrBalanceUpdate, "查询历史余额失败", err)
+	}
+
+	result := &PointInTimeBalance{
+		ChainID:        chainID,
+		Address:        userAddress,
+		Balance:        "0",
+		At:             &at,
+		ProcessedBlock: processed,
+	}
+	if history != nil {
+		result.Balance = history.BalanceAfter
+		result.LastChangeBlock = &history.BlockNumber
+		result.LastChangeTx = history.TxHash
+	}
+	return result, nil
+}
+
+// HoldersAtBlock 按余额倒序分页查询指定区块处理完成时余额大于0且不低于最小余额的持有人
+func (s *BalanceService) HoldersAtBlock(ctx context.Context, query HolderQuery) (*HolderSnapshot, error) {
+	if err := s.ResolveHolderQuery(ctx, &query); err != nil {
+		return nil, err
+	}
+
+	holders, total, err := s.balanceRepo.CountHoldersAtBlock(ctx, query.ChainID, query.Block, query.MinBalance)
+	if err != nil {
+		return nil, errors.New(errors.ErrBalanceUpdate, "统计持有人失败", err)
+	}
+
+	items, err := s.balanceRepo.GetHoldersAtBlock(ctx, query.ChainID, query.Block, query.MinBalance, query.Offset, query.Limit)
+	if err != nil {
+		return nil, errors.New(errors.ErrBalanceUpdate, "查询持有人失败", err)
+	}
+	if items == nil {
+		items = []repository.HolderBalance{}
+	}
+
+	return &HolderSnapshot{
+		ChainID:      query.ChainID,
+		Block:        query.Block,
+		MinBalance:   query.MinBalance,
+		Holders:      holders,
+		TotalBalance: total,
+		Items:        items,
+	}, nil
+}
+
+// EachHolderAtBlock 按余额倒序逐个输出指定区块时的全部持有人，用于导出
+// 校验失败时在调用fn之前返回错误
+func (s *BalanceService) EachHolderAtBlock(ctx context.Context, query HolderQuery, fn func(*repository.HolderBalance) error) error {
+	if err := s.ResolveHolderQuery(ctx, &query); err != nil {
+		return err
+	}
+
+	if err := s.balanceRepo.EachHolderAtBlock(ctx, query.ChainID, query.Block, query.MinBalance, fn); err != nil {
+		return errors.New(errors.ErrBalanceUpdate, "导出持有人失败", err)
+	}
+	return nil
+}
+
+// HolderCSVHeader 持有人导出CSV的表头
+var HolderCSVHeader = []string{"address", "balance"}
+
+// ResolveHolderQuery 校验查询条件，并把未指定的区块替换为最新已处理区块
+func (s *BalanceService) ResolveHolderQuery(ctx context.Context, query *HolderQuery) error {
+	if query.MinBalance == "" {
+		query.MinBalance = "0"
+	}
+	minBalance, ok := new(big.Int).SetString(query.MinBalance, 10)
+	if !ok || minBalance.Sign() < 0 {
+		return errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的最小余额: %s", query.MinBalance), nil)
+	}
+	query.MinBalance = minBalance.String()
+
+	if query.Block < 0 {
+		return errors.New(errors.ErrInvalidRequest, "区块号不能为负数", nil)
+	}
+	if query.Block == 0 {
+		processed, err := s.blockRepo.GetLastProcessed(ctx, query.ChainID)
+		if err != nil {
+			return errors.New(errors.ErrBalanceUpdate, "获取已处理区块失败", err)
+		}
+		query.Block = processed
+		return nil
+	}
+
+	_, err := s.checkProcessed(ctx, query.ChainID, query.Block)
+	return err
+}
+
+// checkProcessed 要求block已经处理，返回最新已处理区块
+// 尚未处理的区块余额历史不完整，快照会随后续处理而变化
+func (s *BalanceService) checkProcessed(ctx context.Context, chainID string, block int64) (int64, error) {
+	if block < 0 {
+		return 0, errors.New(errors.ErrInvalidRequest, "区块号不能为负数", nil)
+	}
+
+	processed, err := s.blockRepo.GetLastProcessed(ctx, chainID)
+	if err != nil {
+		return 0, errors.New(errors.ErrBalanceUpdate, "获取已处理区块失败", err)
+	}
+	if block > processed {
+		return processed, errors.New(errors.ErrConflict, fmt.Sprintf("区块%d尚未处理，已处理到区块%d", block, processed), nil)
+	}
+	return processed, nil
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"math/big"
+	"strconv"
+)
+
+// pointsScale 积分字段精度，与decimal(65,18)保持一致
+const pointsScale = 18
+
+// parseDecimal 解析十进制字符串，无法解析时按0处理
+func parseDecimal(value string) *big.Rat {
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+// decimalEqual 比较两个十进制字符串是否相等
+func decimalEqual(a, b string) bool {
+	return parseDecimal(a).Cmp(parseDecimal(b)) == 0
+}
+
+// formatDecimal 按积分精度格式化
+func formatDecimal(r *big.Rat) string {
+	return r.FloatString(pointsScale)
+}
+
+// decimalFromFloat 按浮点数的十进制表示转换，避免0.05等配置值引入二进制误差
+func decimalFromFloat(f float64) *big.Rat {
+	return parseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+}
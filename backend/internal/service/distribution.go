@@ -0,0 +1,563 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"token-points-system/internal/config"
+	"token-points-system/internal/models"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+)
+
+// DistributionRules 分配规则，数值均为十进制字符串，为空表示不限制
+// MinPoints为参与分配的最低积分；MinAmount和MaxAmount为每个地址分配数量的下限和上限（代币最小单位）
+type DistributionRules struct {
+	MinPoints string `json:"min_points,omitempty"`
+	MinAmount string `json:"min_amount,omitempty"`
+	MaxAmount string `json:"max_amount,omitempty"`
+}
+
+// DistributionRequest 创建分配请求，SourceRef为赛季ID或活动ID，链积分来源不需要
+// 赛季来源需要ChainID，活动来源不需要
+type DistributionRequest struct {
+	Name      string
+	Source    models.DistributionSource
+	SourceRef string
+	ChainID   string
+	Budget    string
+	Rules     DistributionRules
+}
+
+// DistributionView 分配及解析后的规则
+type DistributionView struct {
+	models.Distribution
+	Rules DistributionRules `json:"rules"`
+}
+
+// DistributionAllocationsPage 分配结果分页
+type DistributionAllocationsPage struct {
+	Distribution DistributionView                `json:"distribution"`
+	Items        []models.DistributionAllocation `json:"items"`
+}
+
+// DistributionProof 地址的分配数量和Merkle证明，可直接用于OpenZeppelin MerkleProof.verify
+type DistributionProof struct {
+	DistributionID uint64   `json:"distribution_id"`
+	MerkleRoot     string   `json:"merkle_root"`
+	UserAddress    string   `json:"user_address"`
+	Points         string   `json:"points"`
+	Amount         string   `json:"amount"`
+	Leaf           string   `json:"leaf"`
+	Proof          []string `json:"proof"`
+}
+
+// DistributionService 按积分快照把代币预算分配给各地址，生成与OpenZeppelin MerkleProof兼容的Merkle树
+//
+// 叶子与@openzeppelin/merkle-tree的StandardMerkleTree（叶子类型["address", "uint256"]）一致：
+// keccak256(bytes.concat(keccak256(abi.encode(account, amount))))，父节点为排序后两个子节点拼接的哈希。
+// 生成的根与该库对同一组(地址, 数量)生成的根相同
+type DistributionService struct {
+	distributionRepo *repository.DistributionRepository
+	seasonRepo       *repository.SeasonRepository
+	leaderboardRepo  *repository.LeaderboardRepository
+	chains           map[string]bool
+	campaigns        map[string]config.CampaignConfig
+}
+
+func NewDistributionService(distributionRepo *repository.DistributionRepository, seasonRepo *repository.SeasonRepository, leaderboardRepo *repository.LeaderboardRepository, cfg *config.Config) *DistributionService {
+	s := &DistributionService{
+		distributionRepo: distributionRepo,
+		seasonRepo:       seasonRepo,
+		leaderboardRepo:  leaderboardRepo,
+		chains:           make(map[string]bool),
+		campaigns:        make(map[string]config.CampaignConfig),
+	}
+	for _, chain := range cfg.Chains {
+		if chain.Enabled {
+			s.chains[chain.ID] = true
+		}
+	}
+	for _, campaign := range cfg.Leaderboard.Campaigns {
+		s.campaigns[campaign.ID] = campaign
+	}
+	return s
+}
+
+// recipient 参与分配的地址，Weight为按积分精度放大后的整数积分
+type recipient struct {
+	address common.Address
+	points  *big.Rat
+	weight  *big.Int
+	amount  *big.Int
+}
+
+// CreateDistribution 读取积分快照，按规则分配预算并保存分配结果和Merkle证明
+func (s *DistributionService) CreateDistribution(ctx context.Context, req DistributionRequest, actor string) (*DistributionView, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, errors.New(errors.ErrInvalidRequest, "分配名称不能为空", nil)
+	}
+	budget, err := parseAmount("budget", req.Budget)
+	if err != nil {
+		return nil, err
+	}
+	if budget == nil || budget.Sign() == 0 {
+		return nil, errors.New(errors.ErrInvalidRequest, "预算必须大于0", nil)
+	}
+	minAmount, err := parseAmount("min_amount", req.Rules.MinAmount)
+	if err != nil {
+		return nil, err
+	}
+	maxAmount, err := parseAmount("max_amount", req.Rules.MaxAmount)
+	if err != nil {
+		return nil, err
+	}
+	if maxAmount != nil && maxAmount.Sign() == 0 {
+		return nil, errors.New(errors.ErrInvalidRequest, "max_amount必须大于0", nil)
+	}
+	if minAmount != nil && maxAmount != nil && minAmount.Cmp(maxAmount) > 0 {
+		return nil, errors.New(errors.ErrInvalidRequest, "min_amount不能大于max_amount", nil)
+	}
+	var minPoints *big.Rat
+	if req.Rules.MinPoints != "" {
+		var ok bool
+		minPoints, ok = new(big.Rat).SetString(req.Rules.MinPoints)
+		if !ok || minPoints.Sign() < 0 {
+			return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的min_points: %s", req.Rules.MinPoints), nil)
+		}
+	}
+
+	entries, err := s.loadPoints(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients, totalPoints, err := collectRecipients(entries, minPoints)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, errors.New(errors.ErrInvalidRequest, "积分快照中没有满足条件的地址", nil)
+	}
+
+	if err := allocate(recipients, budget, minAmount, maxAmount); err != nil {
+		return nil, err
+	}
+
+	// 只有分配数量大于0的地址进入Merkle树，按地址排序编号
+	allocated := recipients[:0]
+	for _, rc := range recipients {
+		if rc.amount.Sign() > 0 {
+			allocated = append(allocated, rc)
+		}
+	}
+	root, leaves, proofs := buildMerkleTree(allocated)
+
+	allocations := make([]models.DistributionAllocation, len(allocated))
+	for i, rc := range allocated {
+		proof, err := json.Marshal(proofs[i])
+		if err != nil {
+			return nil, errors.New(errors.ErrDistribution, "序列化Merkle证明失败", err)
+		}
+		allocations[i] = models.DistributionAllocation{
+			Seq:         int64(i + 1),
+			UserAddress: rc.address.Hex(),
+			Points:      formatDecimal(rc.points),
+			Amount:      rc.amount.String(),
+			Leaf:        leaves[i],
+			Proof:       string(proof),
+		}
+	}
+
+	rules := DistributionRules{MinPoints: req.Rules.MinPoints}
+	if minAmount != nil {
+		rules.MinAmount = minAmount.String()
+	}
+	if maxAmount != nil {
+		rules.MaxAmount = maxAmount.String()
+	}
+	rawRules, err := json.Marshal(rules)
+	if err != nil {
+		return nil, errors.New(errors.ErrDistribution, "序列化分配规则失败", err)
+	}
+
+	distribution := &models.Distribution{
+		Name:        strings.TrimSpace(req.Name),
+		Source:      req.Source,
+		SourceRef:   req.SourceRef,
+		ChainID:     req.ChainID,
+		Budget:      budget.String(),
+		Rules:       string(rawRules),
+		TotalPoints: formatDecimal(totalPoints),
+		Recipients:  int64(len(allocations)),
+		MerkleRoot:  root,
+		CreatedBy:   actor,
+	}
+	if err := s.distributionRepo.Create(ctx, distribution, allocations); err != nil {
+		return nil, errors.New(errors.ErrDistribution, "保存分配失败", err)
+	}
+
+	return &DistributionView{Distribution: *distribution, Rules: rules}, nil
+}
+
+// GetDistribution 获取分配
+func (s *DistributionService) GetDistribution(ctx context.Context, id uint64) (*DistributionView, error) {
+	distribution, err := s.distributionRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.New(errors.ErrDistribution, "获取分配失败", err)
+	}
+	if distribution == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("分配不存在: %d", id), nil)
+	}
+	return distributionView(distribution), nil
+}
+
+// ListDistributions 按ID倒序分页获取分配
+func (s *DistributionService) ListDistributions(ctx context.Context, offset, limit int) ([]DistributionView, int64, error) {
+	distributions, total, err := s.distributionRepo.List(ctx, offset, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrDistribution, "获取分配列表失败", err)
+	}
+
+	views := make([]DistributionView, 0, len(distributions))
+	for i := range distributions {
+		views = append(views, *distributionView(&distributions[i]))
+	}
+	return views, total, nil
+}
+
+// Allocations 按地址顺序分页获取分配结果
+func (s *DistributionService) Allocations(ctx context.Context, id uint64, offset, limit int) (*DistributionAllocationsPage, error) {
+	view, err := s.GetDistribution(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.distributionRepo.GetAllocations(ctx, id, offset, limit)
+	if err != nil {
+		return nil, errors.New(errors.ErrDistribution, "获取分配结果失败", err)
+	}
+	if items == nil {
+		items = []models.DistributionAllocation{}
+	}
+	return &DistributionAllocationsPage{Distribution: *view, Items: items}, nil
+}
+
+// Proof 获取地址的分配数量和Merkle证明
+func (s *DistributionService) Proof(ctx context.Context, id uint64, userAddress string) (*DistributionProof, error) {
+	if !common.IsHexAddress(userAddress) {
+		return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的地址: %s", userAddress), nil)
+	}
+
+	view, err := s.GetDistribution(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	address := common.HexToAddress(userAddress).Hex()
+	allocation, err := s.distributionRepo.GetAllocation(ctx, id, address)
+	if err != nil {
+		return nil, errors.New(errors.ErrDistribution, "获取分配结果失败", err)
+	}
+	if allocation == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("地址 %s 不在分配 %d 中", address, id), nil)
+	}
+
+	var proof []string
+	if err := json.Unmarshal([]byte(allocation.Proof), &proof); err != nil {
+		return nil, errors.New(errors.ErrDistribution, "解析Merkle证明失败", err)
+	}
+	if proof == nil {
+		proof = []string{}
+	}
+
+	return &DistributionProof{
+		DistributionID: id,
+		MerkleRoot:     view.MerkleRoot,
+		UserAddress:    allocation.UserAddress,
+		Points:         allocation.Points,
+		Amount:         allocation.Amount,
+		Leaf:           allocation.Leaf,
+		Proof:          proof,
+	}, nil
+}
+
+// loadPoints 按来源读取积分快照
+// 赛季必须已关闭、活动必须已结束且有结束后生成的快照，保证同一来源重复生成的结果相同
+func (s *DistributionService) loadPoints(ctx context.Context, req *DistributionRequest) ([]repository.PointsEntry, error) {
+	var (
+		entries []repository.PointsEntry
+		err     error
+	)
+
+	switch req.Source {
+	case models.DistributionSourceChain:
+		if !s.chains[req.ChainID] {
+			return nil, errors.New(errors.ErrInvalidChain, fmt.Sprintf("链未启用: %s", req.ChainID), nil)
+		}
+		req.SourceRef = ""
+		entries, err = s.distributionRepo.ChainPoints(ctx, req.ChainID)
+
+	case models.DistributionSourceSeason:
+		if !s.chains[req.ChainID] {
+			return nil, errors.New(errors.ErrInvalidChain, fmt.Sprintf("链未启用: %s", req.ChainID), nil)
+		}
+		seasonID, parseErr := parseSeasonID(req.SourceRef)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		season, getErr := s.seasonRepo.GetByID(ctx, seasonID)
+		if getErr != nil {
+			return nil, errors.New(errors.ErrDistribution, "获取赛季失败", getErr)
+		}
+		if season == nil {
+			return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("赛季不存在: %s", req.SourceRef), nil)
+		}
+		if season.ClosedAt == nil {
+			return nil, errors.New(errors.ErrConflict, fmt.Sprintf("赛季 %d 尚未关闭，排名未冻结", season.ID), nil)
+		}
+		req.SourceRef = strconv.FormatUint(season.ID, 10)
+		entries, err = s.distributionRepo.SeasonPoints(ctx, season.ID, req.ChainID)
+
+	case models.DistributionSourceCampaign:
+		campaign, ok := s.campaigns[req.SourceRef]
+		if !ok {
+			return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("活动不存在: %s", req.SourceRef), nil)
+		}
+		end, parseErr := time.Parse(time.RFC3339, campaign.End)
+		if parseErr != nil {
+			return nil, errors.New(errors.ErrDistribution, fmt.Sprintf("活动 %s 的end无效", campaign.ID), parseErr)
+		}
+		snapshot, getErr := s.leaderboardRepo.GetLatestSnapshot(ctx, CampaignScope(campaign.ID))
+		if getErr != nil {
+			return nil, errors.New(errors.ErrDistribution, "获取活动排行榜快照失败", getErr)
+		}
+		if time.Now().Before(end) || snapshot == nil || snapshot.TakenAt.Before(end) {
+			return nil, errors.New(errors.ErrConflict, fmt.Sprintf("活动 %s 尚未结束或结束后还没有生成排行榜快照", campaign.ID), nil)
+		}
+		req.ChainID = ""
+		entries, err = s.distributionRepo.SnapshotPoints(ctx, snapshot.ID)
+
+	default:
+		return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的积分来源: %s", req.Source), nil)
+	}
+
+	if err != nil {
+		return nil, errors.New(errors.ErrDistribution, "读取积分快照失败", err)
+	}
+	return entries, nil
+}
+
+// collectRecipients 合并同一地址的积分并过滤低于最低积分的地址，结果按地址排序
+func collectRecipients(entries []repository.PointsEntry, minPoints *big.Rat) ([]*recipient, *big.Rat, error) {
+	byAddress := make(map[common.Address]*recipient, len(entries))
+	for _, entry := range entries {
+		if !common.IsHexAddress(entry.UserAddress) {
+			return nil, nil, errors.New(errors.ErrDistribution, fmt.Sprintf("积分快照中有无效地址: %s", entry.UserAddress), nil)
+		}
+		address := common.HexToAddress(entry.UserAddress)
+		rc, ok := byAddress[address]
+		if !ok {
+			rc = &recipient{address: address, points: new(big.Rat)}
+			byAddress[address] = rc
+		}
+		rc.points.Add(rc.points, parseDecimal(entry.Points))
+	}
+
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(pointsScale), nil))
+	total := new(big.Rat)
+	recipients := make([]*recipient, 0, len(byAddress))
+	for _, rc := range byAddress {
+		if rc.points.Sign() <= 0 || (minPoints != nil && rc.points.Cmp(minPoints) < 0) {
+			continue
+		}
+		scaled := new(big.Rat).Mul(rc.points, scale)
+		rc.weight = new(big.Int).Quo(scaled.Num(), scaled.Denom())
+		if rc.weight.Sign() == 0 {
+			continue
+		}
+		rc.amount = new(big.Int)
+		total.Add(total, rc.points)
+		recipients = append(recipients, rc)
+	}
+
+	sort.Slice(recipients, func(i, j int) bool {
+		return bytes.Compare(recipients[i].address.Bytes(), recipients[j].address.Bytes()) < 0
+	})
+	return recipients, total, nil
+}
+
+// allocate 按积分比例把budget分配给recipients，分配数量之和恰好等于budget
+//
+// 每一轮把剩余预算按最大余数法分给仍参与分配的地址：先向下取整，余下的单位按余数从大到小
+// （余数相同时按地址顺序）各补1。超过上限的地址固定为上限并退出后续分配；低于下限的地址
+// 在同一轮中一起剔除，分配为0。有地址被固定或剔除时重新分配剩余预算，直到没有变化
+func allocate(recipients []*recipient, budget, minAmount, maxAmount *big.Int) error {
+	active := make([]*recipient, len(recipients))
+	copy(active, recipients)
+	remaining := new(big.Int).Set(budget)
+
+	for {
+		if len(active) == 0 {
+			if remaining.Sign() == 0 {
+				return nil
+			}
+			return errors.New(errors.ErrInvalidRequest, "没有地址满足分配规则，或上限之和小于预算，无法分配全部预算", nil)
+		}
+
+		largestRemainder(active, remaining)
+
+		next := active[:0:0]
+		changed := false
+		for _, rc := range active {
+			if maxAmount != nil && rc.amount.Cmp(maxAmount) > 0 {
+				rc.amount.Set(maxAmount)
+				remaining.Sub(remaining, maxAmount)
+				changed = true
+				continue
+			}
+			next = append(next, rc)
+		}
+		if changed {
+			active = next
+			continue
+		}
+
+		next = active[:0:0]
+		for _, rc := range active {
+			if minAmount != nil && rc.amount.Cmp(minAmount) < 0 {
+				rc.amount.SetInt64(0)
+				changed = true
+				continue
+			}
+			next = append(next, rc)
+		}
+		if !changed {
+			return nil
+		}
+		active = next
+	}
+}
+
+// largestRemainder 按weight比例把total分给recipients，写入amount
+func largestRemainder(recipients []*recipient, total *big.Int) {
+	weightSum := new(big.Int)
+	for _, rc := range recipients {
+		weightSum.Add(weightSum, rc.weight)
+	}
+
+	remainders := make([]*big.Int, len(recipients))
+	leftover := new(big.Int).Set(total)
+	for i, rc := range recipients {
+		quo, rem := new(big.Int).QuoRem(new(big.Int).Mul(total, rc.weight), weightSum, new(big.Int))
+		rc.amount = quo
+		remainders[i] = rem
+		leftover.Sub(leftover, quo)
+	}
+
+	// 余下的单位少于地址数
+	order := make([]int, len(recipients))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].Cmp(remainders[order[b]]) > 0
+	})
+	for i := int64(0); i < leftover.Int64(); i++ {
+		rc := recipients[order[i]]
+		rc.amount.Add(rc.amount, big.NewInt(1))
+	}
+}
+
+// buildMerkleTree 按StandardMerkleTree的方式构建Merkle树，返回根以及每个recipient的叶子和证明
+// 叶子按哈希排序后从数组末尾倒序放置，节点i的子节点为2i+1和2i+2
+func buildMerkleTree(recipients []*recipient) (string, []string, [][]string) {
+	leaves := make([]string, len(recipients))
+	proofs := make([][]string, len(recipients))
+	if len(recipients) == 0 {
+		return common.Hash{}.Hex(), leaves, proofs
+	}
+
+	hashes := make([][]byte, len(recipients))
+	for i, rc := range recipients {
+		hashes[i] = merkleLeaf(rc.address, rc.amount)
+		leaves[i] = hexutil.Encode(hashes[i])
+	}
+
+	order := make([]int, len(recipients))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return bytes.Compare(hashes[order[a]], hashes[order[b]]) < 0
+	})
+
+	tree := make([][]byte, 2*len(recipients)-1)
+	position := make([]int, len(recipients))
+	for i, idx := range order {
+		position[idx] = len(tree) - 1 - i
+		tree[position[idx]] = hashes[idx]
+	}
+	for i := len(tree) - 1 - len(recipients); i >= 0; i-- {
+		tree[i] = hashPair(tree[2*i+1], tree[2*i+2])
+	}
+
+	for idx, pos := range position {
+		proof := []string{}
+		for pos > 0 {
+			sibling := pos + 1
+			if pos%2 == 0 {
+				sibling = pos - 1
+			}
+			proof = append(proof, hexutil.Encode(tree[sibling]))
+			pos = (pos - 1) / 2
+		}
+		proofs[idx] = proof
+	}
+
+	return hexutil.Encode(tree[0]), leaves, proofs
+}
+
+// merkleLeaf keccak256(bytes.concat(keccak256(abi.encode(address, uint256))))
+func merkleLeaf(address common.Address, amount *big.Int) []byte {
+	encoded := append(common.LeftPadBytes(address.Bytes(), 32), common.LeftPadBytes(amount.Bytes(), 32)...)
+	return crypto.Keccak256(crypto.Keccak256(encoded))
+}
+
+// hashPair 与MerkleProof一致，两个节点排序后拼接再哈希
+func hashPair(a, b []byte) []byte {
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	return crypto.Keccak256(a, b)
+}
+
+// parseAmount 解析非负整数数量，为空时返回nil
+func parseAmount(field, value string) (*big.Int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	amount, ok := new(big.Int).SetString(value, 10)
+	if !ok || amount.Sign() < 0 {
+		return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的%s: %s", field, value), nil)
+	}
+	return amount, nil
+}
+
+func distributionView(distribution *models.Distribution) *DistributionView {
+	view := &DistributionView{Distribution: *distribution}
+	// 规则由本服务写入，解析失败时按无规则展示
+	_ = json.Unmarshal([]byte(distribution.Rules), &view.Rules)
+	return view
+}
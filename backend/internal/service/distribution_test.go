@@ -0,0 +1,251 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func testRecipients(weights ...int64) []*recipient {
+	recipients := make([]*recipient, len(weights))
+	for i, w := range weights {
+		recipients[i] = &recipient{
+			address: common.BigToAddress(big.NewInt(int64(i + 1))),
+			weight:  big.NewInt(w),
+			amount:  new(big.Int),
+		}
+	}
+	return recipients
+}
+
+func bigInt(t *testing.T, value string) *big.Int {
+	t.Helper()
+	n, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		t.Fatalf("invalid integer %q", value)
+	}
+	return n
+}
+
+// verifyProof 与OpenZeppelin MerkleProof.verify一致
+func verifyProof(root string, leaf string, proof []string) bool {
+	computed := hexutil.MustDecode(leaf)
+	for _, node := range proof {
+		computed = hashPair(computed, hexutil.MustDecode(node))
+	}
+	return hexutil.Encode(computed) == root
+}
+
+func TestBuildMerkleTreeStandardVector(t *testing.T) {
+	// @openzeppelin/merkle-tree README：StandardMerkleTree.of(values, ["address", "uint256"])
+	recipients := []*recipient{
+		{address: common.HexToAddress("0x1111111111111111111111111111111111111111"), amount: bigInt(t, "5000000000000000000")},
+		{address: common.HexToAddress("0x2222222222222222222222222222222222222222"), amount: bigInt(t, "2500000000000000000")},
+	}
+
+	root, leaves, proofs := buildMerkleTree(recipients)
+
+	if want := "0xd4dee0beab2d53f2cc83e567171bd2820e49898130a22622b10ead383e90bd77"; root != want {
+		t.Fatalf("root = %s, want %s", root, want)
+	}
+	wantLeaves := []string{
+		"0xeb02c421cfa48976e66dfb29120745909ea3a0f843456c263cf8f1253483e283",
+		"0xb92c48e9d7abe27fd8dfd6b5dfdbfb1c9a463f80c712b66f3a5180a090cccafc",
+	}
+	for i, want := range wantLeaves {
+		if leaves[i] != want {
+			t.Errorf("leaf %d = %s, want %s", i, leaves[i], want)
+		}
+		if len(proofs[i]) != 1 || proofs[i][0] != wantLeaves[1-i] {
+			t.Errorf("proof %d = %v, want [%s]", i, proofs[i], wantLeaves[1-i])
+		}
+	}
+}
+
+func TestBuildMerkleTreeProofs(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+	}{
+		{name: "single leaf", count: 1},
+		{name: "two leaves", count: 2},
+		{name: "odd leaves", count: 3},
+		{name: "full tree", count: 4},
+		{name: "unbalanced", count: 7},
+		{name: "larger", count: 33},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipients := make([]*recipient, tt.count)
+			for i := range recipients {
+				recipients[i] = &recipient{
+					address: common.BigToAddress(big.NewInt(int64(1000 + i))),
+					amount:  big.NewInt(int64((i + 1) * 1_000_000)),
+				}
+			}
+
+			root, leaves, proofs := buildMerkleTree(recipients)
+			for i := range recipients {
+				if leaves[i] != hexutil.Encode(merkleLeaf(recipients[i].address, recipients[i].amount)) {
+					t.Fatalf("leaf %d does not match merkleLeaf", i)
+				}
+				if !verifyProof(root, leaves[i], proofs[i]) {
+					t.Errorf("proof for recipient %d does not verify against root %s", i, root)
+				}
+			}
+
+			// 错误的数量不能通过校验
+			forged := hexutil.Encode(merkleLeaf(recipients[0].address, big.NewInt(1)))
+			if tt.count > 1 && verifyProof(root, forged, proofs[0]) {
+				t.Errorf("forged leaf verified against root")
+			}
+		})
+	}
+}
+
+func TestBuildMerkleTreeEmpty(t *testing.T) {
+	root, leaves, proofs := buildMerkleTree(nil)
+	if root != (common.Hash{}).Hex() || len(leaves) != 0 || len(proofs) != 0 {
+		t.Fatalf("empty tree = %s %v %v", root, leaves, proofs)
+	}
+}
+
+func TestMerkleLeafEncoding(t *testing.T) {
+	// 叶子为keccak256(bytes.concat(keccak256(abi.encode(address, uint256))))，地址和数量各占32字节
+	leaf := merkleLeaf(common.HexToAddress("0x1111111111111111111111111111111111111111"), bigInt(t, "5000000000000000000"))
+	if got, want := hexutil.Encode(leaf), "0xeb02c421cfa48976e66dfb29120745909ea3a0f843456c263cf8f1253483e283"; got != want {
+		t.Fatalf("leaf = %s, want %s", got, want)
+	}
+	if !bytes.Equal(hashPair([]byte{1}, []byte{2}), hashPair([]byte{2}, []byte{1})) {
+		t.Fatalf("hashPair must not depend on argument order")
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights []int64
+		budget  string
+		min     string
+		max     string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "even split, leftover by address order",
+			weights: []int64{1, 1, 1},
+			budget:  "100",
+			want:    []string{"34", "33", "33"},
+		},
+		{
+			name:    "largest remainder first",
+			weights: []int64{1, 2, 4},
+			budget:  "10",
+			want:    []string{"1", "3", "6"},
+		},
+		{
+			name:    "capped recipient frees budget for others",
+			weights: []int64{6, 3, 1},
+			budget:  "100",
+			max:     "50",
+			want:    []string{"50", "38", "12"},
+		},
+		{
+			name:    "recipient below minimum is dropped",
+			weights: []int64{10, 10, 1},
+			budget:  "21",
+			min:     "5",
+			want:    []string{"11", "10", "0"},
+		},
+		{
+			name:    "18 decimal budget",
+			weights: []int64{3, 3, 3},
+			budget:  "1000000000000000000",
+			want:    []string{"333333333333333334", "333333333333333333", "333333333333333333"},
+		},
+		{
+			name:    "caps below budget",
+			weights: []int64{1, 1},
+			budget:  "100",
+			max:     "10",
+			wantErr: true,
+		},
+		{
+			name:    "nobody reaches minimum",
+			weights: []int64{1, 1},
+			budget:  "10",
+			min:     "6",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipients := testRecipients(tt.weights...)
+			budget := bigInt(t, tt.budget)
+			var minAmount, maxAmount *big.Int
+			if tt.min != "" {
+				minAmount = bigInt(t, tt.min)
+			}
+			if tt.max != "" {
+				maxAmount = bigInt(t, tt.max)
+			}
+
+			err := allocate(recipients, budget, minAmount, maxAmount)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("allocate: %v", err)
+			}
+
+			sum := new(big.Int)
+			for i, rc := range recipients {
+				sum.Add(sum, rc.amount)
+				if rc.amount.String() != tt.want[i] {
+					t.Errorf("amount %d = %s, want %s", i, rc.amount, tt.want[i])
+				}
+			}
+			if sum.Cmp(budget) != 0 {
+				t.Errorf("sum = %s, want budget %s", sum, budget)
+			}
+		})
+	}
+}
+
+func TestAllocateSumsToBudget(t *testing.T) {
+	weights := []int64{7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47}
+	for _, budget := range []int64{1, 5, 97, 1000, 123457, 999999937} {
+		t.Run(fmt.Sprint(budget), func(t *testing.T) {
+			recipients := testRecipients(weights...)
+			if err := allocate(recipients, big.NewInt(budget), nil, nil); err != nil {
+				t.Fatalf("allocate: %v", err)
+			}
+
+			var weightSum int64
+			for _, w := range weights {
+				weightSum += w
+			}
+			sum := new(big.Int)
+			for i, rc := range recipients {
+				sum.Add(sum, rc.amount)
+				// 每个地址的数量为精确份额向下或向上取整
+				floor := budget * weights[i] / weightSum
+				if got := rc.amount.Int64(); got != floor && got != floor+1 {
+					t.Errorf("amount %d = %d, want %d or %d", i, got, floor, floor+1)
+				}
+			}
+			if sum.Int64() != budget {
+				t.Errorf("sum = %s, want %d", sum, budget)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"token-points-system/internal/config"
+	"token-points-system/internal/models"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+	"token-points-system/pkg/logger"
+)
+
+const (
+	LedgerSourceExpiry = "expiry"
+	LedgerSourceDecay  = "decay"
+)
+
+// ExpiryService 积分过期与衰减
+// 以UTC日期为单位执行，每个用户每天最多产生一条过期流水和一条衰减流水，重复执行不会重复扣减。
+// 每天的扣减数量只取决于当天及之前生效的积分流水，与执行时间和当时的兑换预留无关；
+// 停机期间遗漏的日期在下次执行时按顺序补跑，补跑时扣减不超过当前总积分，已被之后的兑换消耗的积分不再扣减
+type ExpiryService struct {
+	ledgerRepo *repository.LedgerRepository
+	cursorRepo *repository.CursorRepository
+	cfg        config.ExpiryConfig
+}
+
+func NewExpiryService(
+	ledgerRepo *repository.LedgerRepository,
+	cursorRepo *repository.CursorRepository,
+	cfg *config.PointsConfig,
+) *ExpiryService {
+	return &ExpiryService{
+		ledgerRepo: ledgerRepo,
+		cursorRepo: cursorRepo,
+		cfg:        cfg.Expiry,
+	}
+}
+
+// ExpiryResult 单日过期处理结果
+type ExpiryResult struct {
+	ChainID      string `json:"chain_id"`
+	Day          string `json:"day"`
+	ExpiredUsers int    `json:"expired_users"`
+	DecayedUsers int    `json:"decayed_users"`
+}
+
+// UpcomingExpiration 即将过期的积分
+type UpcomingExpiration struct {
+	ExpiresAt time.Time `json:"expires_at"`
+	Amount    string    `json:"amount"`
+}
+
+// Enabled 是否启用过期处理
+func (s *ExpiryService) Enabled() bool {
+	return s.cfg.Enabled
+}
+
+// ApplyExpirations 对指定链按顺序执行上次处理之后直到asOf所在UTC日期的每一天的过期与衰减
+// 首次执行只处理asOf当天；每处理完一天推进一次游标，中途失败时下次从失败的日期继续
+func (s *ExpiryService) ApplyExpirations(ctx context.Context, chainID string, asOf time.Time) ([]ExpiryResult, error) {
+	today := asOf.UTC().Truncate(24 * time.Hour)
+
+	lastDay, err := s.cursorRepo.GetLastExpiryDay(ctx, chainID)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取过期处理游标失败", err)
+	}
+	day := today
+	if !lastDay.IsZero() {
+		day = time.Date(lastDay.Year(), lastDay.Month(), lastDay.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	}
+
+	if missed := int(today.Sub(day)/(24*time.Hour)) + 1; missed > 1 {
+		logger.WithFields(map[string]interface{}{
+			"chain_id": chainID,
+			"from_day": day.Format("2006-01-02"),
+			"missed":   missed,
+			"last_day": lastDay.Format("2006-01-02"),
+		}).Warn("检测到遗漏的过期处理日期，开始补跑")
+	}
+
+	var results []ExpiryResult
+	for ; !day.After(today); day = day.AddDate(0, 0, 1) {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		result, err := s.applyDay(ctx, chainID, day)
+		if err != nil {
+			return results, err
+		}
+		if err := s.cursorRepo.AdvanceExpiry(ctx, chainID, day); err != nil {
+			return results, errors.New(errors.ErrPointsCalc, "推进过期处理游标失败", err)
+		}
+		results = append(results, *result)
+	}
+	return results, nil
+}
+
+// applyDay 执行一天的过期与衰减，过期先于衰减
+func (s *ExpiryService) applyDay(ctx context.Context, chainID string, day time.Time) (*ExpiryResult, error) {
+	result := &ExpiryResult{ChainID: chainID, Day: day.Format("2006-01-02")}
+
+	expired, err := s.applyFIFOExpiry(ctx, chainID, day)
+	if err != nil {
+		return nil, err
+	}
+	result.ExpiredUsers = expired
+
+	if s.cfg.DecayEnabled && s.cfg.DecayRate > 0 && s.cfg.DecayInactiveDays > 0 {
+		decayed, err := s.applyDecay(ctx, chainID, day)
+		if err != nil {
+			return nil, err
+		}
+		result.DecayedUsers = decayed
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"chain_id":      chainID,
+		"day":           result.Day,
+		"expired_users": result.ExpiredUsers,
+		"decayed_users": result.DecayedUsers,
+	}).Info("积分过期处理完成")
+
+	return result, nil
+}
+
+func (s *ExpiryService) applyFIFOExpiry(ctx context.Context, chainID string, day time.Time) (int, error) {
+	candidates, err := s.ledgerRepo.GetExpiryCandidates(ctx, chainID, s.cfg.AccrualTTLDays, day)
+	if err != nil {
+		return 0, errors.New(errors.ErrPointsCalc, "查询到期积分失败", err)
+	}
+
+	applied := 0
+	for _, c := range candidates {
+		due := new(big.Rat).Sub(parseDecimal(c.ExpiredCredits), parseDecimal(c.Debits))
+		ok, err := s.debit(ctx, chainID, c.UserAddress, due, LedgerSourceExpiry, day)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+func (s *ExpiryService) applyDecay(ctx context.Context, chainID string, day time.Time) (int, error) {
+	since := day.AddDate(0, 0, -s.cfg.DecayInactiveDays)
+	candidates, err := s.ledgerRepo.GetDecayCandidates(ctx, chainID, since, day)
+	if err != nil {
+		return 0, errors.New(errors.ErrPointsCalc, "查询衰减用户失败", err)
+	}
+
+	rate := decimalFromFloat(s.cfg.DecayRate)
+
+	applied := 0
+	for _, c := range candidates {
+		amount := new(big.Rat).Mul(parseDecimal(c.TotalPoints), rate)
+		ok, err := s.debit(ctx, chainID, c.UserAddress, amount, LedgerSourceDecay, day)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// debit 写入过期流水，扣减数量在锁定用户积分行后按当天的余额和当前总积分封顶
+// 兑换预留不保护积分不过期：预留的积分过期后，提交兑换时会因可用积分不足失败
+func (s *ExpiryService) debit(ctx context.Context, chainID, userAddress string, amount *big.Rat, source string, day time.Time) (bool, error) {
+	// 按积分精度截断后再判断，避免写入为0的流水
+	amount = parseDecimal(formatDecimal(amount))
+	if amount.Sign() <= 0 {
+		return false, nil
+	}
+
+	created, err := s.ledgerRepo.AppendDebit(ctx, &models.PointsLedgerEntry{
+		ChainID:     chainID,
+		UserAddress: userAddress,
+		EntryType:   models.LedgerEntryExpiration,
+		Amount:      formatDecimal(new(big.Rat).Neg(amount)),
+		SourceType:  source,
+		SourceRef:   fmt.Sprintf("%s:%s:%s", chainID, userAddress, day.Format("2006-01-02")),
+		EffectiveAt: day,
+	})
+	if err != nil {
+		return false, errors.New(errors.ErrPointsCalc, "写入过期流水失败", err)
+	}
+	return created, nil
+}
+
+// UpcomingExpirations 按先进先出规则预测用户未来days天内到期的积分
+// 已到期但尚未处理的积分以其原到期时间返回
+func (s *ExpiryService) UpcomingExpirations(ctx context.Context, chainID, userAddress string, days int) ([]UpcomingExpiration, error) {
+	credits, err := s.ledgerRepo.GetCredits(ctx, chainID, userAddress)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取入账流水失败", err)
+	}
+	debitsStr, err := s.ledgerRepo.SumDebits(ctx, chainID, userAddress)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "汇总出账流水失败", err)
+	}
+
+	type credit struct {
+		expiresAt *time.Time
+		amount    *big.Rat
+	}
+
+	items := make([]credit, 0, len(credits))
+	for _, c := range credits {
+		items = append(items, credit{expiresAt: s.creditExpiry(&c), amount: parseDecimal(c.Amount)})
+	}
+
+	// 先到期的入账先被消耗，不过期的入账排在最后
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i].expiresAt, items[j].expiresAt
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return a.Before(*b)
+	})
+
+	horizon := time.Now().AddDate(0, 0, days)
+	remainingDebits := parseDecimal(debitsStr)
+	upcoming := make([]UpcomingExpiration, 0)
+
+	for _, item := range items {
+		consumed := item.amount
+		if remainingDebits.Cmp(consumed) < 0 {
+			consumed = remainingDebits
+		}
+		remainingDebits = new(big.Rat).Sub(remainingDebits, consumed)
+		leftover := new(big.Rat).Sub(item.amount, consumed)
+
+		if item.expiresAt == nil || leftover.Sign() <= 0 || item.expiresAt.After(horizon) {
+			continue
+		}
+		upcoming = append(upcoming, UpcomingExpiration{
+			ExpiresAt: *item.expiresAt,
+			Amount:    formatDecimal(leftover),
+		})
+	}
+
+	return upcoming, nil
+}
+
+func (s *ExpiryService) creditExpiry(entry *models.PointsLedgerEntry) *time.Time {
+	if entry.ExpiresAt != nil {
+		return entry.ExpiresAt
+	}
+	if s.cfg.AccrualTTLDays <= 0 {
+		return nil
+	}
+	expiresAt := entry.EffectiveAt.AddDate(0, 0, s.cfg.AccrualTTLDays)
+	return &expiresAt
+}
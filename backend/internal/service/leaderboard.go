@@ -0,0 +1,505 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"token-points-system/internal/config"
+	"token-points-system/internal/models"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/cache"
+	"token-points-system/pkg/errors"
+	"token-points-system/pkg/logger"
+)
+
+const (
+	LeaderboardScopeGlobal = "global"
+
+	defaultLeaderboardRetention = 48 * time.Hour
+	defaultLeaderboardCacheTTL  = time.Minute
+	leaderboardCacheEntries     = 10000
+
+	// rankChangeWindow 排名变化与该时长之前最近的快照比较
+	rankChangeWindow = 24 * time.Hour
+)
+
+// RankMode 积分相同时的名次计算方式
+type RankMode string
+
+const (
+	// RankCompetition 并列占用名次：1, 2, 2, 4
+	RankCompetition RankMode = "competition"
+	// RankDense 并列不占用名次：1, 2, 2, 3
+	RankDense RankMode = "dense"
+)
+
+// ParseRankMode 解析名次计算方式，为空时使用competition
+func ParseRankMode(mode string) (RankMode, error) {
+	switch RankMode(mode) {
+	case "", RankCompetition:
+		return RankCompetition, nil
+	case RankDense:
+		return RankDense, nil
+	default:
+		return "", errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的排名方式: %s", mode), nil)
+	}
+}
+
+// campaignEntryTypes 活动积分计入的流水类型，兑换和过期不影响活动排名
+var campaignEntryTypes = []models.LedgerEntryType{
+	models.LedgerEntryAccrual,
+	models.LedgerEntryGrant,
+	models.LedgerEntryCorrection,
+}
+
+type leaderboardScope struct {
+	name   string
+	source repository.ScoreSource
+	// 活动的起止时间，其他排行榜为零值
+	start time.Time
+	end   time.Time
+}
+
+// LeaderboardService 全局、单链和活动排行榜
+// 排名来自定时生成的快照，读取按快照缓存；关联到同一账户的钱包合并排名
+type LeaderboardService struct {
+	repo        *repository.LeaderboardRepository
+	accountRepo *repository.AccountRepository
+	scopes      map[string]*leaderboardScope
+	order       []string
+	retention   time.Duration
+	cache       *cache.TTLCache
+}
+
+// NewLeaderboardService 创建排行榜服务，weights为启用链的跨链积分权重（全局排行榜使用）
+func NewLeaderboardService(
+	repo *repository.LeaderboardRepository,
+	accountRepo *repository.AccountRepository,
+	weights []repository.ChainWeight,
+	cfg *config.LeaderboardConfig,
+) (*LeaderboardService, error) {
+	retention := time.Duration(cfg.RetentionHours) * time.Hour
+	if retention <= 0 {
+		retention = defaultLeaderboardRetention
+	}
+	if retention <= rankChangeWindow {
+		return nil, fmt.Errorf("leaderboard.retention_hours必须大于%d，才能比较排名变化", int(rankChangeWindow.Hours()))
+	}
+
+	cacheTTL := time.Duration(cfg.CacheTTL) * time.Second
+	if cacheTTL <= 0 {
+		cacheTTL = defaultLeaderboardCacheTTL
+	}
+
+	s := &LeaderboardService{
+		repo:        repo,
+		accountRepo: accountRepo,
+		scopes:      make(map[string]*leaderboardScope),
+		retention:   retention,
+		cache:       cache.New(cacheTTL, leaderboardCacheEntries),
+	}
+
+	s.addScope(&leaderboardScope{name: LeaderboardScopeGlobal, source: repository.ScoreSource{Weights: weights}})
+
+	enabled := make(map[string]bool, len(weights))
+	for _, w := range weights {
+		enabled[w.ChainID] = true
+		s.addScope(&leaderboardScope{
+			name:   ChainScope(w.ChainID),
+			source: repository.ScoreSource{Weights: []repository.ChainWeight{{ChainID: w.ChainID, Weight: "1"}}},
+		})
+	}
+
+	for _, c := range cfg.Campaigns {
+		scope, err := campaignScope(c, weights, enabled)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := s.scopes[scope.name]; exists {
+			return nil, fmt.Errorf("活动 %s 重复配置", c.ID)
+		}
+		s.addScope(scope)
+	}
+	return s, nil
+}
+
+func (s *LeaderboardService) addScope(scope *leaderboardScope) {
+	s.scopes[scope.name] = scope
+	s.order = append(s.order, scope.name)
+}
+
+// ChainScope 单链排行榜的scope
+func ChainScope(chainID string) string {
+	return "chain:" + chainID
+}
+
+// CampaignScope 活动排行榜的scope
+func CampaignScope(campaignID string) string {
+	return "campaign:" + campaignID
+}
+
+func campaignScope(c config.CampaignConfig, weights []repository.ChainWeight, enabled map[string]bool) (*leaderboardScope, error) {
+	if c.ID == "" {
+		return nil, fmt.Errorf("活动排行榜必须配置id")
+	}
+
+	start, err := time.Parse(time.RFC3339, c.Start)
+	if err != nil {
+		return nil, fmt.Errorf("活动 %s 的start无效: %w", c.ID, err)
+	}
+	end, err := time.Parse(time.RFC3339, c.End)
+	if err != nil {
+		return nil, fmt.Errorf("活动 %s 的end无效: %w", c.ID, err)
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("活动 %s 的end必须晚于start", c.ID)
+	}
+
+	chains := c.Chains
+	if len(chains) == 0 {
+		for _, w := range weights {
+			chains = append(chains, w.ChainID)
+		}
+	}
+
+	campaignWeights := make([]repository.ChainWeight, 0, len(chains))
+	for _, chainID := range chains {
+		if !enabled[chainID] {
+			return nil, fmt.Errorf("活动 %s 的链 %s 未启用", c.ID, chainID)
+		}
+		campaignWeights = append(campaignWeights, repository.ChainWeight{ChainID: chainID, Weight: "1"})
+	}
+
+	return &leaderboardScope{
+		name: CampaignScope(c.ID),
+		source: repository.ScoreSource{
+			Weights: campaignWeights,
+			Ledger:  &repository.LedgerWindow{Start: start, End: end, EntryTypes: campaignEntryTypes},
+		},
+		start: start,
+		end:   end,
+	}, nil
+}
+
+// LeaderboardInfo 排行榜及其最新快照
+type LeaderboardInfo struct {
+	Scope      string     `json:"scope"`
+	Start      *time.Time `json:"start,omitempty"`
+	End        *time.Time `json:"end,omitempty"`
+	SnapshotAt *time.Time `json:"snapshot_at"`
+	Total      int64      `json:"total"`
+}
+
+// RankedEntry 排行榜条目，PreviousRank为24小时前快照中的名次，RankChange为正表示名次上升
+type RankedEntry struct {
+	Rank         int64   `json:"rank"`
+	UserAddress  string  `json:"user_address"`
+	AccountID    *uint64 `json:"account_id,omitempty"`
+	Score        string  `json:"score"`
+	PreviousRank *int64  `json:"previous_rank"`
+	RankChange   *int64  `json:"rank_change"`
+}
+
+// LeaderboardPage 排行榜的一页，ComparedTo为比较排名变化的快照时间
+type LeaderboardPage struct {
+	Scope      string        `json:"scope"`
+	Mode       RankMode      `json:"mode"`
+	SnapshotAt time.Time     `json:"snapshot_at"`
+	ComparedTo *time.Time    `json:"compared_to"`
+	Total      int64         `json:"total"`
+	Items      []RankedEntry `json:"items"`
+}
+
+// RankLookup 单个地址的名次及前后相邻的条目
+type RankLookup struct {
+	Scope      string        `json:"scope"`
+	Mode       RankMode      `json:"mode"`
+	SnapshotAt time.Time     `json:"snapshot_at"`
+	ComparedTo *time.Time    `json:"compared_to"`
+	Total      int64         `json:"total"`
+	Entry      RankedEntry   `json:"entry"`
+	Above      []RankedEntry `json:"above"`
+	Below      []RankedEntry `json:"below"`
+}
+
+// Scopes 返回全部排行榜及其最新快照
+func (s *LeaderboardService) Scopes(ctx context.Context) ([]LeaderboardInfo, error) {
+	infos := make([]LeaderboardInfo, 0, len(s.order))
+	for _, name := range s.order {
+		scope := s.scopes[name]
+		info := LeaderboardInfo{Scope: name}
+		if !scope.start.IsZero() {
+			info.Start, info.End = &scope.start, &scope.end
+		}
+
+		latest, _, err := s.snapshots(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if latest != nil {
+			info.SnapshotAt, info.Total = &latest.TakenAt, latest.Entries
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Snapshot 为所有排行榜生成快照并清理过期快照
+// 未开始的活动不生成快照，已结束的活动在结束后只再生成一次最终排名；单个排行榜失败不影响其他排行榜
+func (s *LeaderboardService) Snapshot(ctx context.Context, now time.Time) error {
+	takenAt := now.Truncate(time.Second)
+	failed := 0
+	for _, name := range s.order {
+		scope := s.scopes[name]
+		if !scope.start.IsZero() {
+			if takenAt.Before(scope.start) {
+				continue
+			}
+			if !takenAt.Before(scope.end) {
+				latest, err := s.repo.GetLatestSnapshot(ctx, name)
+				if err != nil {
+					logger.Error("获取排行榜快照失败:", name, err)
+					failed++
+					continue
+				}
+				if latest != nil && !latest.TakenAt.Before(scope.end) {
+					continue
+				}
+			}
+		}
+
+		started := time.Now()
+		snapshot, err := s.repo.CreateSnapshot(ctx, name, scope.source, takenAt)
+		if err != nil {
+			logger.Error("生成排行榜快照失败:", name, err)
+			failed++
+			continue
+		}
+
+		logger.WithFields(map[string]interface{}{
+			"scope":    name,
+			"entries":  snapshot.Entries,
+			"taken_at": takenAt,
+			"duration": time.Since(started).String(),
+		}).Info("排行榜快照已生成")
+	}
+
+	pruned, err := s.repo.PruneSnapshots(ctx, takenAt.Add(-s.retention))
+	if err != nil {
+		logger.Error("清理排行榜快照失败:", err)
+		failed++
+	} else if pruned > 0 {
+		logger.Info("已清理过期排行榜快照:", pruned)
+	}
+
+	s.cache.Clear()
+	if failed > 0 {
+		return errors.New(errors.ErrPointsCalc, fmt.Sprintf("%d个排行榜快照任务失败", failed), nil)
+	}
+	return nil
+}
+
+// Leaderboard 分页获取排行榜，offset从0开始
+func (s *LeaderboardService) Leaderboard(ctx context.Context, scope string, mode RankMode, offset, limit int) (*LeaderboardPage, error) {
+	latest, previous, err := s.requireSnapshots(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.entries(ctx, latest.ID, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.rank(ctx, entries, latest, previous, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LeaderboardPage{
+		Scope:      scope,
+		Mode:       mode,
+		SnapshotAt: latest.TakenAt,
+		ComparedTo: snapshotTime(previous),
+		Total:      latest.Entries,
+		Items:      items,
+	}, nil
+}
+
+// Rank 获取地址（或其所属账户）的名次，以及前后各neighbours个相邻条目
+func (s *LeaderboardService) Rank(ctx context.Context, scope string, mode RankMode, address string, neighbours int) (*RankLookup, error) {
+	latest, previous, err := s.requireSnapshots(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.rankKey(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := s.cache.GetOrLoad(fmt.Sprintf("entry:%d:%s", latest.ID, key), func() (interface{}, error) {
+		return s.repo.GetEntry(ctx, latest.ID, key)
+	})
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取排名失败", err)
+	}
+	entry := value.(*models.LeaderboardEntry)
+	if entry == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("%s 不在排行榜 %s 中", address, scope), nil)
+	}
+
+	offset := int(entry.Seq) - 1 - neighbours
+	if offset < 0 {
+		offset = 0
+	}
+	window, err := s.entries(ctx, latest.ID, offset, int(entry.Seq)-offset+neighbours)
+	if err != nil {
+		return nil, err
+	}
+	ranked, err := s.rank(ctx, window, latest, previous, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RankLookup{
+		Scope:      scope,
+		Mode:       mode,
+		SnapshotAt: latest.TakenAt,
+		ComparedTo: snapshotTime(previous),
+		Total:      latest.Entries,
+		Above:      []RankedEntry{},
+		Below:      []RankedEntry{},
+	}
+	for i, e := range window {
+		switch {
+		case e.Seq < entry.Seq:
+			result.Above = append(result.Above, ranked[i])
+		case e.Seq == entry.Seq:
+			result.Entry = ranked[i]
+		default:
+			result.Below = append(result.Below, ranked[i])
+		}
+	}
+	return result, nil
+}
+
+// snapshots 获取scope的最新快照和用于比较排名变化的快照
+func (s *LeaderboardService) snapshots(ctx context.Context, scope string) (*models.LeaderboardSnapshot, *models.LeaderboardSnapshot, error) {
+	value, err := s.cache.GetOrLoad("snapshots:"+scope, func() (interface{}, error) {
+		latest, err := s.repo.GetLatestSnapshot(ctx, scope)
+		if err != nil || latest == nil {
+			return [2]*models.LeaderboardSnapshot{}, err
+		}
+		previous, err := s.repo.GetSnapshotAtOrBefore(ctx, scope, latest.TakenAt.Add(-rankChangeWindow))
+		return [2]*models.LeaderboardSnapshot{latest, previous}, err
+	})
+	if err != nil {
+		return nil, nil, errors.New(errors.ErrPointsCalc, "获取排行榜快照失败", err)
+	}
+	pair := value.([2]*models.LeaderboardSnapshot)
+	return pair[0], pair[1], nil
+}
+
+func (s *LeaderboardService) requireSnapshots(ctx context.Context, scope string) (*models.LeaderboardSnapshot, *models.LeaderboardSnapshot, error) {
+	if _, ok := s.scopes[scope]; !ok {
+		return nil, nil, errors.New(errors.ErrNotFound, fmt.Sprintf("排行榜不存在: %s", scope), nil)
+	}
+
+	latest, previous, err := s.snapshots(ctx, scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	if latest == nil {
+		return nil, nil, errors.New(errors.ErrNotFound, fmt.Sprintf("排行榜 %s 尚未生成快照", scope), nil)
+	}
+	return latest, previous, nil
+}
+
+func (s *LeaderboardService) entries(ctx context.Context, snapshotID uint64, offset, limit int) ([]models.LeaderboardEntry, error) {
+	value, err := s.cache.GetOrLoad(fmt.Sprintf("entries:%d:%d:%d", snapshotID, offset, limit), func() (interface{}, error) {
+		return s.repo.GetEntries(ctx, snapshotID, offset, limit)
+	})
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取排行榜失败", err)
+	}
+	return value.([]models.LeaderboardEntry), nil
+}
+
+// rank 按名次方式转换条目，并附上在比较快照中的名次
+func (s *LeaderboardService) rank(ctx context.Context, entries []models.LeaderboardEntry, latest, previous *models.LeaderboardSnapshot, mode RankMode) ([]RankedEntry, error) {
+	ranked := make([]RankedEntry, 0, len(entries))
+	if len(entries) == 0 {
+		return ranked, nil
+	}
+
+	var before map[string]models.LeaderboardEntry
+	if previous != nil {
+		key := fmt.Sprintf("previous:%d:%d:%d:%d", previous.ID, latest.ID, entries[0].Seq, len(entries))
+		value, err := s.cache.GetOrLoad(key, func() (interface{}, error) {
+			keys := make([]string, 0, len(entries))
+			for _, e := range entries {
+				keys = append(keys, e.RankKey)
+			}
+			found, err := s.repo.GetEntriesByKeys(ctx, previous.ID, keys)
+			if err != nil {
+				return nil, err
+			}
+			byKey := make(map[string]models.LeaderboardEntry, len(found))
+			for _, e := range found {
+				byKey[e.RankKey] = e
+			}
+			return byKey, nil
+		})
+		if err != nil {
+			return nil, errors.New(errors.ErrPointsCalc, "获取历史排名失败", err)
+		}
+		before = value.(map[string]models.LeaderboardEntry)
+	}
+
+	for _, e := range entries {
+		item := RankedEntry{
+			Rank:        rankOf(e, mode),
+			UserAddress: e.UserAddress,
+			AccountID:   e.AccountID,
+			Score:       e.Score,
+		}
+		if prev, ok := before[e.RankKey]; ok {
+			prevRank := rankOf(prev, mode)
+			change := prevRank - item.Rank
+			item.PreviousRank, item.RankChange = &prevRank, &change
+		}
+		ranked = append(ranked, item)
+	}
+	return ranked, nil
+}
+
+// rankKey 地址关联了账户时按账户排名，否则按小写地址
+func (s *LeaderboardService) rankKey(ctx context.Context, address string) (string, error) {
+	address = strings.ToLower(address)
+	value, err := s.cache.GetOrLoad("wallet:"+address, func() (interface{}, error) {
+		return s.accountRepo.GetWallet(ctx, address)
+	})
+	if err != nil {
+		return "", errors.New(errors.ErrPointsCalc, "获取账户关联失败", err)
+	}
+	if wallet := value.(*models.AccountWallet); wallet != nil {
+		return fmt.Sprintf("account:%d", wallet.AccountID), nil
+	}
+	return address, nil
+}
+
+func rankOf(e models.LeaderboardEntry, mode RankMode) int64 {
+	if mode == RankDense {
+		return e.RankDense
+	}
+	return e.RankCompetition
+}
+
+func snapshotTime(snapshot *models.LeaderboardSnapshot) *time.Time {
+	if snapshot == nil {
+		return nil
+	}
+	return &snapshot.TakenAt
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"math/big"
 	"time"
 
@@ -13,32 +14,67 @@ import (
 )
 
 type PointsService struct {
-	pointsRepo      *repository.PointsRepository
-	historyRepo     *repository.HistoryRepository
-	calcRepo        *repository.CalculationRepository
-	calculationRate float64
+	pointsRepo     *repository.PointsRepository
+	historyRepo    *repository.HistoryRepository
+	calcRepo       *repository.CalculationRepository
+	ledgerRepo     *repository.LedgerRepository
+	redemptionRepo *repository.RedemptionRepository
+	adjustmentRepo *repository.AdjustmentRepository
+	rate           *big.Rat
+	ruleVersion    string
+}
+
+const (
+	// RuleVersionFirstChange 从周期内第一条余额变动开始计时，周期内没有变动的持有者不计分
+	RuleVersionFirstChange = "v1"
+	// RuleVersionOpeningBalance 从周期起点以期初余额开始计时
+	RuleVersionOpeningBalance = "v2"
+)
+
+// defaultRuleVersion 未配置points.rule_version时使用的规则版本
+const defaultRuleVersion = RuleVersionFirstChange
+
+// validRuleVersion 是否为支持的规则版本，升级前的计算记录rule_version为空，按v1解释
+func validRuleVersion(ruleVersion string) bool {
+	return ruleVersion == RuleVersionFirstChange || ruleVersion == RuleVersionOpeningBalance
 }
 
 func NewPointsService(
 	pointsRepo *repository.PointsRepository,
 	historyRepo *repository.HistoryRepository,
 	calcRepo *repository.CalculationRepository,
+	ledgerRepo *repository.LedgerRepository,
+	redemptionRepo *repository.RedemptionRepository,
+	adjustmentRepo *repository.AdjustmentRepository,
 	cfg *config.PointsConfig,
-) *PointsService {
-	return &PointsService{
-		pointsRepo:      pointsRepo,
-		historyRepo:     historyRepo,
-		calcRepo:        calcRepo,
-		calculationRate: cfg.CalculationRate,
+) (*PointsService, error) {
+	ruleVersion := cfg.RuleVersion
+	if ruleVersion == "" {
+		ruleVersion = defaultRuleVersion
+	}
+	if !validRuleVersion(ruleVersion) {
+		return nil, fmt.Errorf("unknown points rule_version %q, expected %s or %s", ruleVersion, RuleVersionFirstChange, RuleVersionOpeningBalance)
 	}
+
+	return &PointsService{
+		pointsRepo:     pointsRepo,
+		historyRepo:    historyRepo,
+		calcRepo:       calcRepo,
+		ledgerRepo:     ledgerRepo,
+		redemptionRepo: redemptionRepo,
+		adjustmentRepo: adjustmentRepo,
+		rate:           decimalFromFloat(cfg.CalculationRate),
+		ruleVersion:    ruleVersion,
+	}, nil
 }
 
 // CalculatePointsForUser 计算并发放用户在指定时间段的积分
-// 基于余额历史进行分钟级精确计算
-// 使用计算哈希确保幂等性，防止重复计算
+// 基于余额历史按秒精确计算，是否计入期初余额取决于规则版本
+// 使用计算哈希确保幂等性，防止重复计算；已有起止时间不同的计算记录与该周期重叠时返回冲突错误
 func (s *PointsService) CalculatePointsForUser(ctx context.Context, chainID, userAddress string, periodStart, periodEnd time.Time) (string, error) {
 	hash := s.calcRepo.GenerateHash(chainID, userAddress, periodStart, periodEnd)
 
+	// 仅用于跳过已计算的周期；并发写入由RecordAccrual依赖唯一索引原子去重
 	exists, err := s.calcRepo.ExistsByHash(ctx, hash)
 	if err != nil {
 		return "0", errors.New(errors.ErrPointsCalc, "检查计算是否存在失败", err)
@@ -50,97 +86,178 @@ func (s *PointsService) CalculatePointsForUser(ctx context.Context, chainID, use
 		return "0", nil
 	}
 
+	overlapping, err := s.findOverlapping(ctx, chainID, []string{userAddress}, periodStart, periodEnd)
+	if err != nil {
+		return "0", errors.New(errors.ErrPointsCalc, "检查重叠的计算记录失败", err)
+	}
+	if existing, ok := overlapping[userAddress]; ok {
+		return "0", errors.New(errors.ErrConflict, fmt.Sprintf("已有起止时间不同的计算记录与该周期重叠: %s - %s",
+			existing.PeriodStart.Format(time.RFC3339), existing.PeriodEnd.Format(time.RFC3339)), nil)
+	}
+
+	opening, err := s.historyRepo.GetOpeningBalance(ctx, chainID, userAddress, periodStart)
+	if err != nil {
+		return "0", errors.New(errors.ErrPointsCalc, "获取期初余额失败", err)
+	}
+
 	histories, err := s.historyRepo.GetUserHistoryInRange(ctx, chainID, userAddress, periodStart, periodEnd)
 	if err != nil {
 		return "0", errors.New(errors.ErrPointsCalc, "获取历史记录失败", err)
 	}
 
-	totalPoints := s.calculatePointsFromHistory(histories, periodStart, periodEnd)
+	totalPoints := formatDecimal(accruePoints(opening, histories, periodStart, periodEnd, s.rate, s.ruleVersion))
 
-	calc := &models.PointCalculation{
-		ChainID:         chainID,
-		UserAddress:     userAddress,
-		PeriodStart:     periodStart,
-		PeriodEnd:       periodEnd,
-		PointsEarned:    totalPoints.String(),
-		CalculationHash: hash,
-	}
+	calc := s.newCalculation(chainID, userAddress, periodStart, periodEnd, totalPoints)
 
-	if err := s.calcRepo.Create(ctx, calc); err != nil {
-		return "0", errors.New(errors.ErrPointsCalc, "保存计算记录失败", err)
+	created, err := s.ledgerRepo.RecordAccrual(ctx, calc)
+	if err != nil {
+		return "0", errors.New(errors.ErrPointsCalc, "保存计算记录及积分流水失败", err)
 	}
-
-	if err := s.pointsRepo.AddPoints(ctx, chainID, userAddress, totalPoints.String()); err != nil {
-		return "0", errors.New(errors.ErrPointsCalc, "更新总积分失败", err)
+	if !created {
+		logger.WithFields(map[string]interface{}{
+			"hash": hash,
+		}).Debug("计算已被并发写入")
+		return "0", nil
 	}
 
 	logger.WithFields(map[string]interface{}{
 		"chain_id":      chainID,
 		"user_address":  userAddress,
-		"points_earned": totalPoints.String(),
+		"points_earned": totalPoints,
 		"period_start":  periodStart,
 		"period_end":    periodEnd,
 	}).Info("积分已计算")
 
-	return totalPoints.String(), nil
+	return totalPoints, nil
 }
 
-// calculatePointsFromHistory 基于余额历史计算积分
-// 公式：积分 = 余额 × 费率 × 持有时长（小时）
-// 时长精确到分钟级别
-func (s *PointsService) calculatePointsFromHistory(histories []models.BalanceHistory, periodStart, periodEnd time.Time) *big.Float {
-	if len(histories) == 0 {
-		return big.NewFloat(0)
+// newCalculation 创建带当前费率和规则版本的计算记录
+func (s *PointsService) newCalculation(chainID, userAddress string, periodStart, periodEnd time.Time, points string) *models.PointCalculation {
+	rate := formatDecimal(s.rate)
+	return &models.PointCalculation{
+		ChainID:         chainID,
+		UserAddress:     userAddress,
+		PeriodStart:     periodStart,
+		PeriodEnd:       periodEnd,
+		PointsEarned:    points,
+		CalculationHash: s.calcRepo.GenerateHash(chainID, userAddress, periodStart, periodEnd),
+		Rate:            &rate,
+		RuleVersion:     s.ruleVersion,
 	}
+}
 
-	totalPoints := big.NewFloat(0)
-	rate := big.NewFloat(s.calculationRate)
+// accruePoints 按规则版本计算周期内的积分，公式：积分 = 余额 × 费率 × 持有时长（小时），时长按秒计算
+func accruePoints(opening string, events []models.BalanceHistory, periodStart, periodEnd time.Time, rate *big.Rat, ruleVersion string) *big.Rat {
+	total := new(big.Rat)
+	walkSegments(opening, events, periodStart, periodEnd, func(seg accrualSegment) {
+		if seg.balance.Sign() > 0 && segmentCounted(seg, ruleVersion) {
+			total.Add(total, segmentPoints(seg, rate))
+		}
+	})
+	return total
+}
 
-	var currentBalance *big.Int
-	var currentStart time.Time
+// segmentCounted 规则版本是否对该时间段计分：v1不计周期起点到第一条变动之间的期初段
+func segmentCounted(seg accrualSegment, ruleVersion string) bool {
+	return seg.cause != nil || ruleVersion == RuleVersionOpeningBalance
+}
 
-	for i, h := range histories {
-		if i == 0 {
-			currentBalance, _ = new(big.Int).SetString(h.BalanceAfter, 10)
-			currentStart = h.Timestamp
-			continue
-		}
+// accrualSegment 余额保持不变的一段时间，cause为使余额变为该值的变动，期初段为nil
+type accrualSegment struct {
+	start   time.Time
+	end     time.Time
+	seconds int64
+	balance *big.Rat
+	cause   *models.BalanceHistory
+}
 
-		duration := h.Timestamp.Sub(currentStart).Minutes()
-		balanceFloat := new(big.Float).SetInt(currentBalance)
-		durationFloat := big.NewFloat(duration / 60.0)
+// walkSegments 按时间顺序遍历周期内余额不变的各个时间段，跳过长度不足一秒的时间段
+func walkSegments(opening string, events []models.BalanceHistory, periodStart, periodEnd time.Time, fn func(seg accrualSegment)) {
+	seg := accrualSegment{start: periodStart, balance: parseDecimal(opening)}
 
-		points := new(big.Float).Mul(balanceFloat, rate)
-		points.Mul(points, durationFloat)
-		totalPoints.Add(totalPoints, points)
+	emit := func(until time.Time) {
+		seg.end = until
+		seg.seconds = int64(until.Sub(seg.start) / time.Second)
+		if seg.seconds > 0 {
+			fn(seg)
+		}
+	}
 
-		currentBalance, _ = new(big.Int).SetString(h.BalanceAfter, 10)
-		currentStart = h.Timestamp
+	for i := range events {
+		h := &events[i]
+		emit(h.Timestamp)
+		seg = accrualSegment{start: h.Timestamp, balance: parseDecimal(h.BalanceAfter), cause: h}
 	}
+	emit(periodEnd)
+}
 
-	if currentStart.Before(periodEnd) {
-		duration := periodEnd.Sub(currentStart).Minutes()
-		balanceFloat := new(big.Float).SetInt(currentBalance)
-		durationFloat := big.NewFloat(duration / 60.0)
+// segmentPoints 计算一个时间段的积分
+func segmentPoints(seg accrualSegment, rate *big.Rat) *big.Rat {
+	points := new(big.Rat).Mul(seg.balance, rate)
+	return points.Mul(points, big.NewRat(seg.seconds, 3600))
+}
 
-		points := new(big.Float).Mul(balanceFloat, rate)
-		points.Mul(points, durationFloat)
-		totalPoints.Add(totalPoints, points)
-	}
+// GetUserPointsAt 根据积分流水获取用户在指定时间点的总积分
+func (s *PointsService) GetUserPointsAt(ctx context.Context, chainID, userAddress string, at time.Time) (string, error) {
+	return s.ledgerRepo.GetTotalAt(ctx, chainID, userAddress, at)
+}
 
-	return totalPoints
+// GetLedger 获取用户的积分流水
+func (s *PointsService) GetLedger(ctx context.Context, chainID, userAddress string, limit int) ([]models.PointsLedgerEntry, error) {
+	return s.ledgerRepo.GetByUser(ctx, chainID, userAddress, limit)
 }
 
-// GetUserPoints 获取用户在指定链上的总积分
-func (s *PointsService) GetUserPoints(ctx context.Context, chainID, userAddress string) (string, error) {
+// PointsSummary 用户积分概览
+// Total为当前总积分，Available扣除了未过期的兑换预留，Lifetime为累计获得的积分（不扣除兑换与过期）
+type PointsSummary struct {
+	Total            string     `json:"total"`
+	Available        string     `json:"available"`
+	Reserved         string     `json:"reserved"`
+	Redeemed         string     `json:"redeemed"`
+	Lifetime         string     `json:"lifetime"`
+	LastCalculatedAt *time.Time `json:"last_calculated_at"`
+}
+
+// GetUserPoints 获取用户在指定链上的积分概览
+func (s *PointsService) GetUserPoints(ctx context.Context, chainID, userAddress string) (*PointsSummary, error) {
 	points, err := s.pointsRepo.GetByUser(ctx, chainID, userAddress)
 	if err != nil {
-		return "0", err
+		return nil, err
 	}
-	if points == nil {
-		return "0", nil
+
+	total := new(big.Rat)
+	summary := &PointsSummary{}
+	if points != nil {
+		total = parseDecimal(points.TotalPoints)
+		summary.LastCalculatedAt = points.LastCalculatedAt
+	}
+
+	reservedStr, err := s.redemptionRepo.SumReserved(ctx, chainID, userAddress)
+	if err != nil {
+		return nil, err
 	}
-	return points.TotalPoints, nil
+	reserved := parseDecimal(reservedStr)
+
+	sums, err := s.ledgerRepo.SumByType(ctx, chainID, userAddress)
+	if err != nil {
+		return nil, err
+	}
+	redeemed := new(big.Rat).Neg(parseDecimal(sums[models.LedgerEntryRedemption]))
+	expired := new(big.Rat).Neg(parseDecimal(sums[models.LedgerEntryExpiration]))
+
+	available := new(big.Rat).Sub(total, reserved)
+	if available.Sign() < 0 {
+		available = new(big.Rat)
+	}
+	lifetime := new(big.Rat).Add(total, redeemed)
+	lifetime.Add(lifetime, expired)
+
+	summary.Total = formatDecimal(total)
+	summary.Available = formatDecimal(available)
+	summary.Reserved = formatDecimal(reserved)
+	summary.Redeemed = formatDecimal(redeemed)
+	summary.Lifetime = formatDecimal(lifetime)
+	return summary, nil
 }
 
 func (s *PointsService) ListPoints(ctx context.Context, chainID string, offset, limit int) ([]models.UserPoints, error) {
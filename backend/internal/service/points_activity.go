@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"token-points-system/pkg/errors"
+)
+
+const (
+	ActivityCalculation = "calculation"
+	ActivityAdjustment  = "adjustment"
+)
+
+// PointsActivity 用户积分记录，合并周期计算与人工调整
+type PointsActivity struct {
+	Kind        string     `json:"kind"`
+	ID          uint64     `json:"id"`
+	Points      string     `json:"points"`
+	OccurredAt  time.Time  `json:"occurred_at"`
+	PeriodStart *time.Time `json:"period_start,omitempty"`
+	PeriodEnd   *time.Time `json:"period_end,omitempty"`
+	ReasonCode  string     `json:"reason_code,omitempty"`
+	Note        string     `json:"note,omitempty"`
+	Status      string     `json:"status,omitempty"`
+	RequestedBy string     `json:"requested_by,omitempty"`
+	DecidedBy   string     `json:"decided_by,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// GetPointsActivity 获取用户的积分记录，按发生时间倒序
+func (s *PointsService) GetPointsActivity(ctx context.Context, chainID, userAddress string, limit int) ([]PointsActivity, error) {
+	calcs, err := s.calcRepo.GetByUser(ctx, chainID, userAddress, limit)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取积分计算记录失败", err)
+	}
+
+	adjustments, err := s.adjustmentRepo.GetByUser(ctx, chainID, userAddress, limit)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取积分调整记录失败", err)
+	}
+
+	activities := make([]PointsActivity, 0, len(calcs)+len(adjustments))
+	for i := range calcs {
+		c := &calcs[i]
+		activities = append(activities, PointsActivity{
+			Kind:        ActivityCalculation,
+			ID:          c.ID,
+			Points:      c.PointsEarned,
+			OccurredAt:  c.PeriodEnd,
+			PeriodStart: &c.PeriodStart,
+			PeriodEnd:   &c.PeriodEnd,
+		})
+	}
+	for i := range adjustments {
+		a := &adjustments[i]
+		occurredAt := a.CreatedAt
+		if a.DecidedAt != nil {
+			occurredAt = *a.DecidedAt
+		}
+		activities = append(activities, PointsActivity{
+			Kind:        ActivityAdjustment,
+			ID:          a.ID,
+			Points:      a.Amount,
+			OccurredAt:  occurredAt,
+			ReasonCode:  a.ReasonCode,
+			Note:        a.Note,
+			Status:      string(a.Status),
+			RequestedBy: a.RequestedBy,
+			DecidedBy:   a.DecidedBy,
+			ExpiresAt:   a.ExpiresAt,
+		})
+	}
+
+	sort.SliceStable(activities, func(i, j int) bool {
+		return activities[i].OccurredAt.After(activities[j].OccurredAt)
+	})
+
+	if limit > 0 && len(activities) > limit {
+		activities = activities[:limit]
+	}
+	return activities, nil
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"token-points-system/internal/models"
+	"token-points-system/pkg/errors"
+)
+
+// AccrualFormula 周期积分的计算规则
+const AccrualFormula = "balance × rate × seconds / 3600"
+
+const (
+	RateSourceRecorded = "recorded"
+	RateSourceConfig   = "config"
+)
+
+// BreakdownSegment 周期内余额不变的一段时间及其产生的积分
+// HistoryID等字段指向使余额变为Balance的变动记录；期初段指向周期开始前的最后一条变动，没有时为空，v1规则下期初段不计分
+type BreakdownSegment struct {
+	Start       time.Time         `json:"start"`
+	End         time.Time         `json:"end"`
+	Seconds     int64             `json:"seconds"`
+	Balance     string            `json:"balance"`
+	Rate        string            `json:"rate"`
+	Formula     string            `json:"formula"`
+	Points      string            `json:"points"`
+	Opening     bool              `json:"opening"`
+	HistoryID   *uint64           `json:"history_id,omitempty"`
+	TxHash      string            `json:"tx_hash,omitempty"`
+	BlockNumber int64             `json:"block_number,omitempty"`
+	ChangeType  models.ChangeType `json:"change_type,omitempty"`
+}
+
+// PointsBreakdown 一个周期的积分明细
+// RecomputedPoints用记录的费率、规则版本和当前的余额历史重新计算，与RecordedPoints不一致说明计算后余额历史发生了变化；
+// Corrections为此后针对该周期的人工调整，CorrectedPoints为记录积分加上已生效的调整
+type PointsBreakdown struct {
+	ChainID          string                    `json:"chain_id"`
+	UserAddress      string                    `json:"user_address"`
+	PeriodStart      time.Time                 `json:"period_start"`
+	PeriodEnd        time.Time                 `json:"period_end"`
+	CalculationID    uint64                    `json:"calculation_id"`
+	CalculationHash  string                    `json:"calculation_hash"`
+	CalculatedAt     time.Time                 `json:"calculated_at"`
+	RuleVersion      string                    `json:"rule_version"`
+	Rate             string                    `json:"rate"`
+	RateSource       string                    `json:"rate_source"`
+	OpeningBalance   string                    `json:"opening_balance"`
+	Segments         []BreakdownSegment        `json:"segments"`
+	RecordedPoints   string                    `json:"recorded_points"`
+	RecomputedPoints string                    `json:"recomputed_points"`
+	Consistent       bool                      `json:"consistent"`
+	Corrections      []models.PointsAdjustment `json:"corrections"`
+	CorrectedPoints  string                    `json:"corrected_points"`
+}
+
+// GetPointsBreakdown 解释用户包含at时刻的周期计算：逐段列出余额、费率和积分，并附带之后的更正
+// 该周期尚未计算时返回ErrNotFound
+func (s *PointsService) GetPointsBreakdown(ctx context.Context, chainID, userAddress string, at time.Time) (*PointsBreakdown, error) {
+	calc, err := s.calcRepo.GetCovering(ctx, chainID, userAddress, at)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取计算记录失败", err)
+	}
+	if calc == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("该时间所在周期没有积分计算记录: %s", at.Format(time.RFC3339)), nil)
+	}
+
+	rate, rateSource := s.rate, RateSourceConfig
+	if calc.Rate != nil {
+		rate, rateSource = parseDecimal(*calc.Rate), RateSourceRecorded
+	}
+
+	opening, err := s.historyRepo.GetOpeningBalance(ctx, chainID, userAddress, calc.PeriodStart)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取期初余额失败", err)
+	}
+	openingCause, err := s.historyRepo.GetLastBefore(ctx, chainID, userAddress, calc.PeriodStart)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取期初余额来源失败", err)
+	}
+	events, err := s.historyRepo.GetUserHistoryInRange(ctx, chainID, userAddress, calc.PeriodStart, calc.PeriodEnd)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取历史记录失败", err)
+	}
+
+	corrections, err := s.adjustmentRepo.GetByCalculation(ctx, calc.ID)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取更正记录失败", err)
+	}
+
+	rateStr := formatDecimal(rate)
+	segments := make([]BreakdownSegment, 0, len(events)+1)
+	walkSegments(opening, events, calc.PeriodStart, calc.PeriodEnd, func(seg accrualSegment) {
+		points := new(big.Rat)
+		if seg.balance.Sign() > 0 && segmentCounted(seg, calc.RuleVersion) {
+			points = segmentPoints(seg, rate)
+		}
+
+		item := BreakdownSegment{
+			Start:   seg.start,
+			End:     seg.end,
+			Seconds: seg.seconds,
+			Balance: seg.balance.FloatString(0),
+			Rate:    rateStr,
+			Formula: AccrualFormula,
+			Points:  formatDecimal(points),
+			Opening: seg.cause == nil,
+		}
+		cause := seg.cause
+		if cause == nil {
+			cause = openingCause
+		}
+		if cause != nil {
+			id := cause.ID
+			item.HistoryID = &id
+			item.TxHash = cause.TxHash
+			item.BlockNumber = cause.BlockNumber
+			item.ChangeType = cause.ChangeType
+		}
+		segments = append(segments, item)
+	})
+
+	recomputed := formatDecimal(accruePoints(opening, events, calc.PeriodStart, calc.PeriodEnd, rate, calc.RuleVersion))
+
+	corrected := parseDecimal(calc.PointsEarned)
+	for _, c := range corrections {
+		if c.Status == models.AdjustmentApplied {
+			corrected.Add(corrected, parseDecimal(c.Amount))
+		}
+	}
+
+	return &PointsBreakdown{
+		ChainID:          chainID,
+		UserAddress:      userAddress,
+		PeriodStart:      calc.PeriodStart,
+		PeriodEnd:        calc.PeriodEnd,
+		CalculationID:    calc.ID,
+		CalculationHash:  calc.CalculationHash,
+		CalculatedAt:     calc.CreatedAt,
+		RuleVersion:      calc.RuleVersion,
+		Rate:             rateStr,
+		RateSource:       rateSource,
+		OpeningBalance:   parseDecimal(opening).FloatString(0),
+		Segments:         segments,
+		RecordedPoints:   calc.PointsEarned,
+		RecomputedPoints: recomputed,
+		Consistent:       decimalEqual(recomputed, calc.PointsEarned),
+		Corrections:      corrections,
+		CorrectedPoints:  formatDecimal(corrected),
+	}, nil
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"token-points-system/internal/models"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+	"token-points-system/pkg/logger"
+)
+
+// bulkBatchSize 批量计算时每个写入事务包含的用户数
+const bulkBatchSize = 2000
+
+// ChainPeriodResult 整链周期计算结果
+// TotalPoints为本周期计算出的积分总和，包含此前已发放而被跳过的部分；
+// Overlapping为已有不同起止时间的计算记录与本周期重叠而未发放的用户数，Overlaps按已有记录的起止时间汇总
+type ChainPeriodResult struct {
+	ChainID     string               `json:"chain_id"`
+	PeriodStart time.Time            `json:"period_start"`
+	PeriodEnd   time.Time            `json:"period_end"`
+	Holders     int                  `json:"holders"`
+	Credited    int                  `json:"credited"`
+	Skipped     int                  `json:"skipped"`
+	Overlapping int                  `json:"overlapping"`
+	Overlaps    []CalculationOverlap `json:"overlaps,omitempty"`
+	TotalPoints string               `json:"total_points"`
+	Duration    time.Duration        `json:"duration"`
+}
+
+// CalculationOverlap 与新周期重叠的已有计算记录的起止时间及涉及的用户数
+type CalculationOverlap struct {
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	Users       int       `json:"users"`
+}
+
+// MergeOverlap 按起止时间合并重叠记录的用户数
+func MergeOverlap(overlaps []CalculationOverlap, o CalculationOverlap) []CalculationOverlap {
+	for i := range overlaps {
+		if overlaps[i].PeriodStart.Equal(o.PeriodStart) && overlaps[i].PeriodEnd.Equal(o.PeriodEnd) {
+			overlaps[i].Users += o.Users
+			return overlaps
+		}
+	}
+	return append(overlaps, o)
+}
+
+// overlapsPeriod 已有计算记录是否与[periodStart, periodEnd)重叠且起止时间不同
+// 修改周期配置后新旧周期的计算哈希不同，只按哈希去重会对重叠的时间重复发放积分
+func overlapsPeriod(calc *models.PointCalculation, periodStart, periodEnd time.Time) bool {
+	if calc.PeriodStart.Equal(periodStart) && calc.PeriodEnd.Equal(periodEnd) {
+		return false
+	}
+	return calc.PeriodStart.Before(periodEnd) && calc.PeriodEnd.After(periodStart)
+}
+
+// findOverlapping 返回users中已有计算记录与[periodStart, periodEnd)重叠且起止时间不同的用户及该记录
+func (s *PointsService) findOverlapping(ctx context.Context, chainID string, users []string, periodStart, periodEnd time.Time) (map[string]*models.PointCalculation, error) {
+	last, err := s.calcRepo.GetLastStartedBefore(ctx, chainID, users, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+	overlapping := make(map[string]*models.PointCalculation)
+	for i := range last {
+		if overlapsPeriod(&last[i], periodStart, periodEnd) {
+			overlapping[last[i].UserAddress] = &last[i]
+		}
+	}
+	return overlapping, nil
+}
+
+// CalculateChainPeriod 计算整条链在一个周期内所有用户的积分
+// 在一致性快照中流式读取余额和周期内的变动，在内存中计算积分，按批次在事务中写入计算记录、流水和投影。
+// 周期内余额为0的用户不产生计算记录；已存在计算哈希的用户会被跳过，重复执行同一周期不会重复发放；
+// 已有不同起止时间的计算记录与本周期重叠的用户（如修改周期配置后回溯计算）不发放，在结果中报告
+func (s *PointsService) CalculateChainPeriod(ctx context.Context, chainID string, periodStart, periodEnd time.Time) (*ChainPeriodResult, error) {
+	started := time.Now()
+	result := &ChainPeriodResult{
+		ChainID:     chainID,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+	}
+	total := new(big.Rat)
+	batch := make([]*models.PointCalculation, 0, bulkBatchSize)
+
+	flush := func() error {
+		users := make([]string, 0, len(batch))
+		for _, c := range batch {
+			users = append(users, c.UserAddress)
+		}
+		overlapping, err := s.findOverlapping(ctx, chainID, users, periodStart, periodEnd)
+		if err != nil {
+			return err
+		}
+		if len(overlapping) > 0 {
+			kept := batch[:0]
+			for _, c := range batch {
+				if existing, ok := overlapping[c.UserAddress]; ok {
+					result.Overlaps = MergeOverlap(result.Overlaps, CalculationOverlap{PeriodStart: existing.PeriodStart, PeriodEnd: existing.PeriodEnd, Users: 1})
+					continue
+				}
+				kept = append(kept, c)
+			}
+			result.Overlapping += len(overlapping)
+			batch = kept
+		}
+
+		created, err := s.ledgerRepo.RecordAccrualBatch(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for _, c := range batch {
+			total.Add(total, parseDecimal(c.PointsEarned))
+		}
+		result.Credited += created
+		result.Skipped += len(batch) - created
+		batch = batch[:0]
+		return nil
+	}
+
+	err := s.historyRepo.StreamPeriodBalances(ctx, chainID, periodStart, periodEnd, func(b *repository.UserPeriodBalance) error {
+		result.Holders++
+
+		points := accruePoints(b.Opening, b.Events, periodStart, periodEnd, s.rate, s.ruleVersion)
+		earned := formatDecimal(points)
+		if parseDecimal(earned).Sign() == 0 {
+			return nil
+		}
+
+		batch = append(batch, s.newCalculation(chainID, b.UserAddress, periodStart, periodEnd, earned))
+		if len(batch) >= bulkBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "整链积分计算失败", err)
+	}
+
+	result.TotalPoints = formatDecimal(total)
+	result.Duration = time.Since(started)
+
+	if result.Overlapping > 0 {
+		logger.WithFields(map[string]interface{}{
+			"chain_id":     chainID,
+			"period_start": periodStart,
+			"period_end":   periodEnd,
+			"overlapping":  result.Overlapping,
+			"overlaps":     result.Overlaps,
+		}).Warn("已有不同起止时间的计算记录与本周期重叠，跳过这些用户")
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"chain_id":     chainID,
+		"period_start": periodStart,
+		"period_end":   periodEnd,
+		"holders":      result.Holders,
+		"credited":     result.Credited,
+		"skipped":      result.Skipped,
+		"total_points": result.TotalPoints,
+		"duration":     result.Duration.String(),
+	}).Info("整链积分计算完成")
+
+	return result, nil
+}
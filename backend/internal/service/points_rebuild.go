@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+
+	"token-points-system/pkg/errors"
+	"token-points-system/pkg/logger"
+)
+
+// PointsMismatch 投影总积分与流水汇总不一致的用户
+type PointsMismatch struct {
+	UserAddress string `json:"user_address"`
+	Projected   string `json:"projected"`
+	Ledger      string `json:"ledger"`
+}
+
+// RebuildReport 积分投影重建结果
+type RebuildReport struct {
+	ChainID           string           `json:"chain_id"`
+	BackfilledEntries int64            `json:"backfilled_entries"`
+	Users             int              `json:"users"`
+	Mismatches        []PointsMismatch `json:"mismatches"`
+	Applied           bool             `json:"applied"`
+	Verified          bool             `json:"verified"`
+}
+
+// RebuildTotals 从积分流水重新生成user_points并校验
+// apply为false时只比对不写入；为true时覆盖投影并再次校验
+func (s *PointsService) RebuildTotals(ctx context.Context, chainID string, apply bool) (*RebuildReport, error) {
+	report := &RebuildReport{ChainID: chainID}
+
+	if apply {
+		backfilled, err := s.ledgerRepo.BackfillAccruals(ctx, chainID)
+		if err != nil {
+			return nil, errors.New(errors.ErrPointsCalc, "补写历史入账流水失败", err)
+		}
+		report.BackfilledEntries = backfilled
+	}
+
+	mismatches, users, err := s.compareProjection(ctx, chainID)
+	if err != nil {
+		return nil, err
+	}
+	report.Users = users
+	report.Mismatches = mismatches
+
+	if apply && len(mismatches) > 0 {
+		if err := s.ledgerRepo.RebuildProjection(ctx, chainID); err != nil {
+			return nil, errors.New(errors.ErrPointsCalc, "重建积分投影失败", err)
+		}
+		report.Applied = true
+
+		remaining, _, err := s.compareProjection(ctx, chainID)
+		if err != nil {
+			return nil, err
+		}
+		report.Verified = len(remaining) == 0
+	} else {
+		report.Verified = len(mismatches) == 0
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"chain_id":           chainID,
+		"users":              report.Users,
+		"mismatches":         len(report.Mismatches),
+		"backfilled_entries": report.BackfilledEntries,
+		"applied":            report.Applied,
+		"verified":           report.Verified,
+	}).Info("积分投影校验完成")
+
+	return report, nil
+}
+
+// compareProjection 比对user_points与流水汇总，返回不一致的用户及涉及的用户数
+func (s *PointsService) compareProjection(ctx context.Context, chainID string) ([]PointsMismatch, int, error) {
+	sums, err := s.ledgerRepo.SumByChain(ctx, chainID)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrPointsCalc, "汇总积分流水失败", err)
+	}
+
+	projections, err := s.pointsRepo.GetAllByChain(ctx, chainID)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrPointsCalc, "获取积分投影失败", err)
+	}
+
+	mismatches := make([]PointsMismatch, 0)
+	seen := make(map[string]bool, len(projections))
+
+	for _, p := range projections {
+		seen[p.UserAddress] = true
+		ledgerTotal, ok := sums[p.UserAddress]
+		if !ok {
+			ledgerTotal = "0"
+		}
+		if !decimalEqual(p.TotalPoints, ledgerTotal) {
+			mismatches = append(mismatches, PointsMismatch{
+				UserAddress: p.UserAddress,
+				Projected:   p.TotalPoints,
+				Ledger:      ledgerTotal,
+			})
+		}
+	}
+
+	for userAddress, ledgerTotal := range sums {
+		if seen[userAddress] {
+			continue
+		}
+		seen[userAddress] = true
+		if !decimalEqual(ledgerTotal, "0") {
+			mismatches = append(mismatches, PointsMismatch{
+				UserAddress: userAddress,
+				Projected:   "0",
+				Ledger:      ledgerTotal,
+			})
+		}
+	}
+
+	return mismatches, len(seen), nil
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+	"token-points-system/pkg/logger"
+)
+
+const (
+	defaultSimulationTop = 10
+	maxSimulationTop     = 100
+)
+
+// simulationBuckets 积分分布的区间下界，按数量级划分：0、(0,1)、[1,10)、[10,100)……
+var simulationBuckets = []int64{1, 10, 100, 1000, 10000, 100000, 1000000, 10000000}
+
+// RuleSet 一组积分计算规则
+// Rate为每个代币每小时的积分；余额低于MinBalance的时间段不产生积分；BalanceCap大于0时余额超出部分不计积分；
+// RuleVersion为v1或v2，决定是否计入期初余额，为空时使用当前规则版本
+type RuleSet struct {
+	Rate        string `json:"rate"`
+	MinBalance  string `json:"min_balance,omitempty"`
+	BalanceCap  string `json:"balance_cap,omitempty"`
+	RuleVersion string `json:"rule_version,omitempty"`
+}
+
+// SimulationRequest 在历史窗口[Start, End)上比较当前规则与候选规则，Top为涨幅和跌幅榜的条数
+type SimulationRequest struct {
+	ChainID   string
+	Start     time.Time
+	End       time.Time
+	Candidate RuleSet
+	Top       int
+}
+
+// UserDiff 单个用户在两套规则下的积分差异
+type UserDiff struct {
+	UserAddress string `json:"user_address"`
+	Baseline    string `json:"baseline"`
+	Candidate   string `json:"candidate"`
+	Diff        string `json:"diff"`
+	// DiffPercent 相对当前规则的变化百分比，当前规则下没有积分时为空
+	DiffPercent *string `json:"diff_percent"`
+
+	diff *big.Rat
+}
+
+// DistributionBucket 积分分布的一个区间，Max为空表示没有上界
+type DistributionBucket struct {
+	Min       string `json:"min"`
+	Max       string `json:"max,omitempty"`
+	Baseline  int    `json:"baseline"`
+	Candidate int    `json:"candidate"`
+}
+
+// SimulationReport 规则模拟结果
+// 两套规则都基于同一份余额历史重新计算，不读取也不写入已发放的积分
+type SimulationReport struct {
+	ChainID        string               `json:"chain_id"`
+	Start          time.Time            `json:"start"`
+	End            time.Time            `json:"end"`
+	Baseline       RuleSet              `json:"baseline"`
+	Candidate      RuleSet              `json:"candidate"`
+	Holders        int                  `json:"holders"`
+	BaselineTotal  string               `json:"baseline_total"`
+	CandidateTotal string               `json:"candidate_total"`
+	TotalDiff      string               `json:"total_diff"`
+	Gainers        int                  `json:"gainers"`
+	Losers         int                  `json:"losers"`
+	Unchanged      int                  `json:"unchanged"`
+	Distribution   []DistributionBucket `json:"distribution"`
+	TopGainers     []UserDiff           `json:"top_gainers"`
+	TopLosers      []UserDiff           `json:"top_losers"`
+	Duration       time.Duration        `json:"duration"`
+}
+
+// compiledRules 解析后的规则
+type compiledRules struct {
+	rate        *big.Rat
+	minBalance  *big.Rat
+	balanceCap  *big.Rat
+	ruleVersion string
+}
+
+func compileRules(rules RuleSet) (*compiledRules, error) {
+	c := &compiledRules{ruleVersion: rules.RuleVersion}
+	if !validRuleVersion(c.ruleVersion) {
+		return nil, fmt.Errorf("无效的rule_version: %s", c.ruleVersion)
+	}
+	parse := func(name, value string, required bool) (*big.Rat, error) {
+		if value == "" {
+			if required {
+				return nil, fmt.Errorf("%s不能为空", name)
+			}
+			return nil, nil
+		}
+		r, ok := new(big.Rat).SetString(value)
+		if !ok || r.Sign() < 0 {
+			return nil, fmt.Errorf("无效的%s: %s", name, value)
+		}
+		return r, nil
+	}
+
+	var err error
+	if c.rate, err = parse("rate", rules.Rate, true); err != nil {
+		return nil, err
+	}
+	if c.minBalance, err = parse("min_balance", rules.MinBalance, false); err != nil {
+		return nil, err
+	}
+	if c.balanceCap, err = parse("balance_cap", rules.BalanceCap, false); err != nil {
+		return nil, err
+	}
+	if c.balanceCap != nil && c.balanceCap.Sign() == 0 {
+		c.balanceCap = nil
+	}
+	return c, nil
+}
+
+// points 按规则计算一段时间的积分
+func (c *compiledRules) points(seg accrualSegment) *big.Rat {
+	if seg.balance.Sign() <= 0 || !segmentCounted(seg, c.ruleVersion) {
+		return new(big.Rat)
+	}
+	if c.minBalance != nil && seg.balance.Cmp(c.minBalance) < 0 {
+		return new(big.Rat)
+	}
+	if c.balanceCap != nil && seg.balance.Cmp(c.balanceCap) > 0 {
+		seg.balance = c.balanceCap
+	}
+	return segmentPoints(seg, c.rate)
+}
+
+// SimulateRules 用当前规则和候选规则在历史窗口上重新计算所有用户的积分并比较，不写入任何数据
+// each不为空时按地址顺序逐个回调每个用户的差异（用于导出），两套规则下都没有积分的用户不回调
+func (s *PointsService) SimulateRules(ctx context.Context, req SimulationRequest, each func(d *UserDiff) error) (*SimulationReport, error) {
+	if req.ChainID == "" {
+		return nil, errors.New(errors.ErrInvalidRequest, "chain不能为空", nil)
+	}
+	if !req.End.After(req.Start) {
+		return nil, errors.New(errors.ErrInvalidRequest, "结束时间必须晚于开始时间", nil)
+	}
+	if req.End.After(time.Now()) {
+		return nil, errors.New(errors.ErrInvalidRequest, "只能模拟已经过去的时间窗口", nil)
+	}
+
+	if req.Candidate.RuleVersion == "" {
+		req.Candidate.RuleVersion = s.ruleVersion
+	}
+	candidate, err := compileRules(req.Candidate)
+	if err != nil {
+		return nil, errors.New(errors.ErrInvalidRequest, "候选规则无效", err)
+	}
+	baselineRules := RuleSet{Rate: formatDecimal(s.rate), RuleVersion: s.ruleVersion}
+	baseline := &compiledRules{rate: s.rate, ruleVersion: s.ruleVersion}
+
+	top := req.Top
+	if top <= 0 {
+		top = defaultSimulationTop
+	}
+	if top > maxSimulationTop {
+		top = maxSimulationTop
+	}
+
+	started := time.Now()
+	report := &SimulationReport{
+		ChainID:      req.ChainID,
+		Start:        req.Start,
+		End:          req.End,
+		Baseline:     baselineRules,
+		Candidate:    req.Candidate,
+		Distribution: newDistribution(),
+		TopGainers:   []UserDiff{},
+		TopLosers:    []UserDiff{},
+	}
+	baseTotal, candTotal := new(big.Rat), new(big.Rat)
+
+	err = s.historyRepo.StreamPeriodBalances(ctx, req.ChainID, req.Start, req.End, func(b *repository.UserPeriodBalance) error {
+		base, cand := new(big.Rat), new(big.Rat)
+		walkSegments(b.Opening, b.Events, req.Start, req.End, func(seg accrualSegment) {
+			base.Add(base, baseline.points(seg))
+			cand.Add(cand, candidate.points(seg))
+		})
+		if base.Sign() == 0 && cand.Sign() == 0 {
+			return nil
+		}
+
+		report.Holders++
+		baseTotal.Add(baseTotal, base)
+		candTotal.Add(candTotal, cand)
+		report.Distribution[bucketIndex(base)].Baseline++
+		report.Distribution[bucketIndex(cand)].Candidate++
+
+		d := newUserDiff(b.UserAddress, base, cand)
+		switch d.diff.Sign() {
+		case 1:
+			report.Gainers++
+			report.TopGainers = insertTop(report.TopGainers, d, top, func(a, b *big.Rat) bool { return a.Cmp(b) > 0 })
+		case -1:
+			report.Losers++
+			report.TopLosers = insertTop(report.TopLosers, d, top, func(a, b *big.Rat) bool { return a.Cmp(b) < 0 })
+		default:
+			report.Unchanged++
+		}
+
+		if each != nil {
+			return each(d)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "规则模拟失败", err)
+	}
+
+	report.BaselineTotal = formatDecimal(baseTotal)
+	report.CandidateTotal = formatDecimal(candTotal)
+	report.TotalDiff = formatDecimal(new(big.Rat).Sub(candTotal, baseTotal))
+	report.Duration = time.Since(started)
+
+	logger.WithFields(map[string]interface{}{
+		"chain_id":        req.ChainID,
+		"start":           req.Start,
+		"end":             req.End,
+		"holders":         report.Holders,
+		"baseline_total":  report.BaselineTotal,
+		"candidate_total": report.CandidateTotal,
+		"duration":        report.Duration.String(),
+	}).Info("积分规则模拟完成")
+
+	return report, nil
+}
+
+// SimulationCSVHeader 用户差异CSV的表头，与UserDiff.CSVRecord对应
+var SimulationCSVHeader = []string{"user_address", "baseline", "candidate", "diff", "diff_percent"}
+
+// CSVRecord 返回用户差异的CSV行
+func (d *UserDiff) CSVRecord() []string {
+	pct := ""
+	if d.DiffPercent != nil {
+		pct = *d.DiffPercent
+	}
+	return []string{d.UserAddress, d.Baseline, d.Candidate, d.Diff, pct}
+}
+
+func newUserDiff(userAddress string, base, cand *big.Rat) *UserDiff {
+	diff := new(big.Rat).Sub(cand, base)
+	d := &UserDiff{
+		UserAddress: userAddress,
+		Baseline:    formatDecimal(base),
+		Candidate:   formatDecimal(cand),
+		Diff:        formatDecimal(diff),
+		diff:        diff,
+	}
+	if base.Sign() != 0 {
+		pct := new(big.Rat).Quo(diff, base)
+		pct.Mul(pct, big.NewRat(100, 1))
+		s := pct.FloatString(4)
+		d.DiffPercent = &s
+	}
+	return d
+}
+
+// insertTop 把d按better顺序插入长度不超过n的榜单
+func insertTop(list []UserDiff, d *UserDiff, n int, better func(a, b *big.Rat) bool) []UserDiff {
+	if len(list) == n && !better(d.diff, list[n-1].diff) {
+		return list
+	}
+	i := sort.Search(len(list), func(i int) bool { return better(d.diff, list[i].diff) })
+	list = append(list, UserDiff{})
+	copy(list[i+1:], list[i:])
+	list[i] = *d
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+func newDistribution() []DistributionBucket {
+	buckets := make([]DistributionBucket, 0, len(simulationBuckets)+2)
+	buckets = append(buckets, DistributionBucket{Min: "0", Max: "0"})
+	lower := "0"
+	for _, edge := range simulationBuckets {
+		upper := fmt.Sprintf("%d", edge)
+		buckets = append(buckets, DistributionBucket{Min: lower, Max: upper})
+		lower = upper
+	}
+	return append(buckets, DistributionBucket{Min: lower})
+}
+
+// bucketIndex 返回积分所在的分布区间：0单独一档，其余为左闭右开区间
+func bucketIndex(points *big.Rat) int {
+	if points.Sign() == 0 {
+		return 0
+	}
+	for i, edge := range simulationBuckets {
+		if points.Cmp(new(big.Rat).SetInt64(edge)) < 0 {
+			return i + 1
+		}
+	}
+	return len(simulationBuckets) + 1
+}
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"token-points-system/internal/config"
+	"token-points-system/internal/models"
+	"token-points-system/internal/period"
+)
+
+var testPeriodStart = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func balanceAt(offset time.Duration, balanceAfter string) models.BalanceHistory {
+	return models.BalanceHistory{Timestamp: testPeriodStart.Add(offset), BalanceAfter: balanceAfter}
+}
+
+func TestAccruePointsOpeningBalance(t *testing.T) {
+	tests := []struct {
+		name    string
+		opening string
+		events  []models.BalanceHistory
+		end     time.Duration
+		rate    *big.Rat
+		want    string
+	}{
+		{
+			name:    "no events",
+			opening: "100",
+			end:     time.Hour,
+			want:    "100",
+		},
+		{
+			name:    "balance change mid-period",
+			opening: "100",
+			events:  []models.BalanceHistory{balanceAt(30*time.Minute, "300")},
+			end:     time.Hour,
+			want:    "200",
+		},
+		{
+			name:    "change exactly at period start",
+			opening: "100",
+			events:  []models.BalanceHistory{balanceAt(0, "300")},
+			end:     time.Hour,
+			want:    "300",
+		},
+		{
+			name:    "first transfer in during period",
+			opening: "0",
+			events:  []models.BalanceHistory{balanceAt(45*time.Minute, "400")},
+			end:     time.Hour,
+			want:    "100",
+		},
+		{
+			name:    "balance drops to zero",
+			opening: "100",
+			events:  []models.BalanceHistory{balanceAt(15*time.Minute, "0")},
+			end:     time.Hour,
+			want:    "25",
+		},
+		{
+			// 同一区块内的多次变动时间戳相同，只按最后的余额计算
+			name:    "changes within the same block",
+			opening: "100",
+			events: []models.BalanceHistory{
+				balanceAt(30*time.Minute, "200"),
+				balanceAt(30*time.Minute, "50"),
+			},
+			end:  time.Hour,
+			want: "75",
+		},
+		{
+			name:    "partial period",
+			opening: "120",
+			end:     20 * time.Minute,
+			want:    "40",
+		},
+		{
+			name:    "fractional rate",
+			opening: "1",
+			end:     time.Hour,
+			rate:    decimalFromFloat(0.05),
+			want:    "0.05",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate := tt.rate
+			if rate == nil {
+				rate = big.NewRat(1, 1)
+			}
+			got := accruePoints(tt.opening, tt.events, testPeriodStart, testPeriodStart.Add(tt.end), rate, RuleVersionOpeningBalance)
+			if want := parseDecimal(tt.want); got.Cmp(want) != 0 {
+				t.Errorf("points = %s, want %s", formatDecimal(got), formatDecimal(want))
+			}
+		})
+	}
+}
+
+func TestAccruePointsAcrossPeriodEdges(t *testing.T) {
+	// v2规则下两个相邻周期分别计算的积分之和应等于整段时间一次计算的积分
+	boundary := testPeriodStart.Add(time.Hour)
+	end := testPeriodStart.Add(2 * time.Hour)
+	rate := decimalFromFloat(0.05)
+
+	tests := []struct {
+		name    string
+		opening string
+		events  []models.BalanceHistory
+		want    string
+	}{
+		{
+			name:    "no events",
+			opening: "1000",
+			want:    "100",
+		},
+		{
+			name:    "change on the boundary",
+			opening: "1000",
+			events:  []models.BalanceHistory{balanceAt(time.Hour, "250")},
+			want:    "62.5",
+		},
+		{
+			name:    "changes on both sides of the boundary",
+			opening: "1000",
+			events: []models.BalanceHistory{
+				balanceAt(30*time.Minute, "1500"),
+				balanceAt(time.Hour, "700"),
+				balanceAt(80*time.Minute, "0"),
+			},
+		},
+		{
+			name:    "change one second before the boundary",
+			opening: "0",
+			events:  []models.BalanceHistory{balanceAt(time.Hour-time.Second, "3600")},
+			want:    "180.05",
+		},
+		{
+			name:    "18 decimal balances",
+			opening: "123456789012345678901",
+			events: []models.BalanceHistory{
+				balanceAt(59*time.Minute+59*time.Second, "1"),
+				balanceAt(time.Hour+time.Second, "999999999999999999999"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 与计算时一致：周期内的变动为[start, end)，下一周期的期初余额为边界前最后一次变动后的余额
+			var first, second []models.BalanceHistory
+			opening := tt.opening
+			for _, h := range tt.events {
+				if h.Timestamp.Before(boundary) {
+					first = append(first, h)
+					opening = h.BalanceAfter
+				} else {
+					second = append(second, h)
+				}
+			}
+
+			whole := accruePoints(tt.opening, tt.events, testPeriodStart, end, rate, RuleVersionOpeningBalance)
+			split := new(big.Rat).Add(
+				accruePoints(tt.opening, first, testPeriodStart, boundary, rate, RuleVersionOpeningBalance),
+				accruePoints(opening, second, boundary, end, rate, RuleVersionOpeningBalance),
+			)
+			if whole.Cmp(split) != 0 {
+				t.Errorf("whole range = %s, sum of periods = %s", formatDecimal(whole), formatDecimal(split))
+			}
+			if tt.want != "" && whole.Cmp(parseDecimal(tt.want)) != 0 {
+				t.Errorf("points = %s, want %s", formatDecimal(whole), tt.want)
+			}
+		})
+	}
+}
+
+func TestAccruePointsFirstChange(t *testing.T) {
+	// v1规则从周期内第一条变动开始计时，与最初的实现一致
+	tests := []struct {
+		name    string
+		opening string
+		events  []models.BalanceHistory
+		want    string
+	}{
+		{
+			name:    "no events",
+			opening: "100",
+			want:    "0",
+		},
+		{
+			name:    "opening balance before first change is not counted",
+			opening: "100",
+			events:  []models.BalanceHistory{balanceAt(30*time.Minute, "300")},
+			want:    "150",
+		},
+		{
+			name:    "change exactly at period start",
+			opening: "100",
+			events:  []models.BalanceHistory{balanceAt(0, "300")},
+			want:    "300",
+		},
+		{
+			name:    "README example",
+			opening: "0",
+			events: []models.BalanceHistory{
+				balanceAt(10*time.Minute, "100"),
+				balanceAt(30*time.Minute, "200"),
+			},
+			want: "133.333333333333333333",
+		},
+		{
+			name:    "balance drops to zero",
+			opening: "100",
+			events:  []models.BalanceHistory{balanceAt(15*time.Minute, "0")},
+			want:    "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := accruePoints(tt.opening, tt.events, testPeriodStart, testPeriodStart.Add(time.Hour), big.NewRat(1, 1), RuleVersionFirstChange)
+			if formatDecimal(got) != formatDecimal(parseDecimal(tt.want)) {
+				t.Errorf("points = %s, want %s", formatDecimal(got), tt.want)
+			}
+		})
+	}
+
+	// 升级前的计算记录rule_version为空，按v1解释
+	legacy := accruePoints("100", nil, testPeriodStart, testPeriodStart.Add(time.Hour), big.NewRat(1, 1), "")
+	if legacy.Sign() != 0 {
+		t.Errorf("legacy rule points = %s, want 0", formatDecimal(legacy))
+	}
+}
+
+// lastStartedBefore 与CalculationRepository.GetLastStartedBefore相同：用户在before之前开始的最后一条计算记录
+func lastStartedBefore(calcs []models.PointCalculation, before time.Time) *models.PointCalculation {
+	var last *models.PointCalculation
+	for i := range calcs {
+		if calcs[i].PeriodStart.Before(before) && (last == nil || calcs[i].PeriodStart.After(last.PeriodStart)) {
+			last = &calcs[i]
+		}
+	}
+	return last
+}
+
+func TestOverlapsPeriodAfterPeriodChange(t *testing.T) {
+	// 按小时周期已发放00:00-06:00，之后修改周期配置并回溯计算00:00-12:00
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hourly, err := period.New(config.PeriodConfig{Unit: period.UnitHour, Timezone: "UTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var credited []models.PointCalculation
+	for start := day; start.Before(day.Add(6 * time.Hour)); start = hourly.Next(start) {
+		credited = append(credited, models.PointCalculation{PeriodStart: start, PeriodEnd: hourly.End(start)})
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.PeriodConfig
+		// want 回溯窗口内每个新周期是否因与已发放的旧周期重叠而跳过
+		want []bool
+	}{
+		{
+			name: "unchanged hourly period",
+			cfg:  config.PeriodConfig{Unit: period.UnitHour, Timezone: "UTC"},
+			want: []bool{false, false, false, false, false, false, false, false, false, false, false, false},
+		},
+		{
+			name: "4 hours",
+			cfg:  config.PeriodConfig{Unit: period.UnitHour, Every: 4, Timezone: "UTC"},
+			want: []bool{true, true, false},
+		},
+		{
+			name: "30 minutes",
+			cfg:  config.PeriodConfig{Unit: period.UnitMinute, Every: 30, Timezone: "UTC"},
+			want: []bool{
+				true, true, true, true, true, true, true, true, true, true, true, true,
+				false, false, false, false, false, false, false, false, false, false, false, false,
+			},
+		},
+		{
+			name: "custom 90 minutes",
+			cfg:  config.PeriodConfig{Unit: period.UnitCustom, Duration: 5400, Anchor: "2024-01-01T00:00:00Z", Timezone: "UTC"},
+			want: []bool{true, true, true, true, false, false, false, false},
+		},
+		{
+			name: "day",
+			cfg:  config.PeriodConfig{Unit: period.UnitDay, Timezone: "UTC"},
+			want: []bool{true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := period.New(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []bool
+			for start := day; start.Before(day.Add(12 * time.Hour)); start = p.Next(start) {
+				end := p.End(start)
+				overlaps := false
+				if last := lastStartedBefore(credited, end); last != nil {
+					overlaps = overlapsPeriod(last, start, end)
+				}
+				got = append(got, overlaps)
+
+				// 未跳过的新周期与已发放的时间没有交集，不会重复发放
+				if !overlaps {
+					for _, c := range credited {
+						sameBounds := c.PeriodStart.Equal(start) && c.PeriodEnd.Equal(end)
+						if !sameBounds && c.PeriodStart.Before(end) && c.PeriodEnd.After(start) {
+							t.Errorf("period %s-%s credited again over %s-%s", start.Format("15:04"), end.Format("15:04"),
+								c.PeriodStart.Format("15:04"), c.PeriodEnd.Format("15:04"))
+						}
+					}
+				}
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("periods = %d, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("period %d overlaps = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMergeOverlap(t *testing.T) {
+	a := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var overlaps []CalculationOverlap
+	overlaps = MergeOverlap(overlaps, CalculationOverlap{PeriodStart: a, PeriodEnd: a.Add(time.Hour), Users: 1})
+	overlaps = MergeOverlap(overlaps, CalculationOverlap{PeriodStart: a.Add(time.Hour), PeriodEnd: a.Add(2 * time.Hour), Users: 1})
+	overlaps = MergeOverlap(overlaps, CalculationOverlap{PeriodStart: a, PeriodEnd: a.Add(time.Hour), Users: 2})
+	if len(overlaps) != 2 || overlaps[0].Users != 3 || overlaps[1].Users != 1 {
+		t.Fatalf("overlaps = %+v", overlaps)
+	}
+}
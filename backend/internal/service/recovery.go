@@ -2,114 +2,250 @@ package service
 
 import (
 	"context"
-	"time"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
 
+	"token-points-system/internal/config"
+	"token-points-system/internal/jobs"
 	"token-points-system/internal/models"
 	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
 	"token-points-system/pkg/logger"
 )
 
+// ChainRewinder 可以回退处理位置的链监听器
+type ChainRewinder interface {
+	Rewind(block int64)
+}
+
+type attachedListener struct {
+	chainID  string
+	rewinder ChainRewinder
+}
+
 type RecoveryService struct {
 	balanceRepo *repository.BalanceRepository
-	pointsRepo  *repository.PointsRepository
-	historyRepo *repository.HistoryRepository
-	calcRepo    *repository.CalculationRepository
+	backupRepo  *repository.BackupRepository
+	blockRepo   *repository.BlockRepository
+	balanceSvc  *BalanceService
+	retention   config.BackupConfig
+
+	mu        sync.Mutex
+	listeners map[*attachedListener]struct{}
 }
 
 func NewRecoveryService(
 	balanceRepo *repository.BalanceRepository,
-	pointsRepo *repository.PointsRepository,
-	historyRepo *repository.HistoryRepository,
-	calcRepo *repository.CalculationRepository,
+	backupRepo *repository.BackupRepository,
+	blockRepo *repository.BlockRepository,
+	balanceSvc *BalanceService,
+	cfg *config.BackupConfig,
 ) *RecoveryService {
 	return &RecoveryService{
 		balanceRepo: balanceRepo,
-		pointsRepo:  pointsRepo,
-		historyRepo: historyRepo,
-		calcRepo:    calcRepo,
+		backupRepo:  backupRepo,
+		blockRepo:   blockRepo,
+		balanceSvc:  balanceSvc,
+		retention:   *cfg,
+		listeners:   make(map[*attachedListener]struct{}),
 	}
 }
 
-func (s *RecoveryService) BackupBalances(ctx context.Context, chainID string) error {
-	balances, err := s.balanceRepo.GetAllByChain(ctx, chainID)
+// AttachListener 登记本节点运行的链监听器，恢复备份后将其回退到备份区块；返回注销函数
+func (s *RecoveryService) AttachListener(chainID string, rewinder ChainRewinder) func() {
+	l := &attachedListener{chainID: chainID, rewinder: rewinder}
+
+	s.mu.Lock()
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}
+}
+
+// CreateBackup 备份一条链当前的余额、积分和计算状态
+func (s *RecoveryService) CreateBackup(ctx context.Context, chainID string, trigger models.BackupTrigger, actor string) (*models.CalculationBackup, error) {
+	data, err := s.backupRepo.Capture(ctx, chainID)
 	if err != nil {
-		return err
+		return nil, errors.New(errors.ErrBackup, "读取备份数据失败", err)
 	}
-	
-	backupData := make(map[string]interface{})
-	for _, b := range balances {
-		backupData[b.UserAddress] = b.Balance
+
+	checksum, err := backupChecksum(data)
+	if err != nil {
+		return nil, errors.New(errors.ErrBackup, "计算备份校验和失败", err)
 	}
-	
+
 	backup := &models.CalculationBackup{
-		ChainID:    chainID,
-		BackupType: models.BackupTypeBalanceSnapshot,
-		BackupData: backupData,
+		ChainID:      chainID,
+		BlockHeight:  data.BlockHeight,
+		Checksum:     checksum,
+		Trigger:      trigger,
+		BalanceCount: len(data.Balances),
+		PointsCount:  len(data.Points),
+		BackupData:   *data,
+		CreatedBy:    actor,
+	}
+	if err := s.backupRepo.Create(ctx, backup); err != nil {
+		return nil, errors.New(errors.ErrBackup, "保存备份失败", err)
 	}
-	
-	return s.createBackup(ctx, backup)
+
+	logger.WithFields(map[string]interface{}{
+		"backup_id":     backup.ID,
+		"chain_id":      chainID,
+		"block_height":  backup.BlockHeight,
+		"balance_count": backup.BalanceCount,
+		"points_count":  backup.PointsCount,
+		"trigger":       trigger,
+	}).Info("备份已创建")
+
+	return backup, nil
+}
+
+// ListBackups 分页获取备份，不含备份内容；status不为空时只返回该校验状态的备份
+func (s *RecoveryService) ListBackups(ctx context.Context, chainID string, status models.BackupVerifyStatus, offset, limit int) ([]models.CalculationBackup, int64, error) {
+	return s.backupRepo.List(ctx, chainID, status, offset, limit)
 }
 
-func (s *RecoveryService) BackupPoints(ctx context.Context, chainID string) error {
-	points, err := s.pointsRepo.GetAllByChain(ctx, chainID)
+// GetBackup 获取备份，不含备份内容
+func (s *RecoveryService) GetBackup(ctx context.Context, id uint64) (*models.CalculationBackup, error) {
+	backup, err := s.backupRepo.GetByID(ctx, id, false)
 	if err != nil {
-		return err
+		return nil, errors.New(errors.ErrBackup, "获取备份失败", err)
 	}
-	
-	backupData := make(map[string]interface{})
-	for _, p := range points {
-		backupData[p.UserAddress] = map[string]interface{}{
-			"total_points":        p.TotalPoints,
-			"last_calculated_at":  p.LastCalculatedAt,
-		}
+	if backup == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("备份不存在: %d", id), nil)
 	}
-	
-	backup := &models.CalculationBackup{
-		ChainID:    chainID,
-		BackupType: models.BackupTypePointsSnapshot,
-		BackupData: backupData,
-	}
-	
-	return s.createBackup(ctx, backup)
+	return backup, nil
 }
 
-func (s *RecoveryService) RecalculateFromTime(ctx context.Context, chainID string, startTime time.Time, pointsSvc *PointsService) error {
-	logger.WithFields(map[string]interface{}{
-		"chain_id":    chainID,
-		"start_time":  startTime,
-	}).Info("Starting recalculation from time")
-	
-	balances, err := s.balanceRepo.GetAllByChain(ctx, chainID)
+// LatestBackup 获取指定链最新的备份，不存在时返回nil
+func (s *RecoveryService) LatestBackup(ctx context.Context, chainID string) (*models.CalculationBackup, error) {
+	return s.backupRepo.GetLatest(ctx, chainID)
+}
+
+// RestoreBackup 校验备份后在同一事务中恢复余额、积分和计算状态，并回退链监听器
+// 恢复期间暂停本节点的转账处理并立即回退本节点的监听器；同一事务中写入的回退请求由运行监听器的主节点
+// 在下一次拉取前读取，即使恢复期间主节点发生切换也会生效。备份区块之后的链上事件由监听器重新处理
+func (s *RecoveryService) RestoreBackup(ctx context.Context, id uint64, actor string) (*models.CalculationBackup, error) {
+	if actor == "" {
+		return nil, errors.New(errors.ErrInvalidRequest, "缺少操作人身份", nil)
+	}
+
+	backup, err := s.backupRepo.GetByID(ctx, id, true)
 	if err != nil {
-		return err
+		return nil, errors.New(errors.ErrBackup, "获取备份失败", err)
+	}
+	if backup == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("备份不存在: %d", id), nil)
+	}
+
+	checksum, err := backupChecksum(&backup.BackupData)
+	if err != nil {
+		return nil, errors.New(errors.ErrBackup, "计算备份校验和失败", err)
 	}
-	
-	now := time.Now()
-	currentHour := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
-	
-	startHour := time.Date(startTime.Year(), startTime.Month(), startTime.Day(), startTime.Hour(), 0, 0, 0, startTime.Location())
-	
-	for _, balance := range balances {
-		currentPeriod := startHour
-		for currentPeriod.Before(currentHour) {
-			nextPeriod := currentPeriod.Add(time.Hour)
-			
-			_, err := pointsSvc.CalculatePointsForUser(ctx, chainID, balance.UserAddress, currentPeriod, nextPeriod)
-			if err != nil {
-				logger.Error("Failed to recalculate points:", err)
-			}
-			
-			currentPeriod = nextPeriod
+	if checksum != backup.Checksum {
+		return nil, errors.New(errors.ErrConflict, fmt.Sprintf("备份校验失败: %d", id), nil)
+	}
+	if backup.VerifyStatus == models.BackupCorrupted {
+		return nil, errors.New(errors.ErrConflict, fmt.Sprintf("备份已标记为损坏: %d, %s", id, backup.VerifyNote), nil)
+	}
+
+	err = s.balanceSvc.Exclusive(func() error {
+		if err := s.backupRepo.Restore(ctx, backup, actor); err != nil {
+			return err
 		}
+		s.RewindListeners(backup.ChainID, backup.BlockHeight)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New(errors.ErrBackup, "恢复备份失败", err)
 	}
-	
+
 	logger.WithFields(map[string]interface{}{
-		"chain_id": chainID,
-	}).Info("Recalculation completed")
-	
-	return nil
+		"backup_id":    backup.ID,
+		"chain_id":     backup.ChainID,
+		"block_height": backup.BlockHeight,
+		"restored_by":  actor,
+	}).Warn("备份已恢复")
+
+	return backup, nil
+}
+
+// RewindListeners 把本节点运行的指定链的监听器回退到block，使已发出但尚未处理的事件立即过期
+// 其他节点的监听器通过数据库中的回退请求回退，见BlockRepository.GetRewind
+func (s *RecoveryService) RewindListeners(chainID string, block int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for l := range s.listeners {
+		if l.chainID == chainID {
+			l.rewinder.Rewind(block)
+		}
+	}
+}
+
+// backupChecksum 备份内容规范JSON的SHA-256
+func backupChecksum(data *models.BackupData) (string, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// BackupParams 备份任务参数
+type BackupParams struct {
+	ChainID string `json:"chain_id"`
 }
 
-func (s *RecoveryService) createBackup(ctx context.Context, backup *models.CalculationBackup) error {
-	return nil
+// BackupJob 备份任务处理器，检查点为已创建的备份ID
+func (s *RecoveryService) BackupJob(ctx context.Context, job *models.Job, progress *jobs.Progress) error {
+	var params BackupParams
+	if err := job.DecodeParams(&params); err != nil {
+		return fmt.Errorf("解析任务参数失败: %w", err)
+	}
+
+	progress.SetTotal(1)
+	if job.Checkpoint != "" {
+		return nil
+	}
+
+	backup, err := s.CreateBackup(ctx, params.ChainID, models.BackupTriggerManual, job.CreatedBy)
+	if err != nil {
+		return err
+	}
+	progress.Add(1, 0)
+	return progress.Save(ctx, strconv.FormatUint(backup.ID, 10))
+}
+
+// RestoreParams 恢复任务参数
+type RestoreParams struct {
+	BackupID uint64 `json:"backup_id"`
+}
+
+// RestoreJob 恢复任务处理器，由主节点执行以便回退正在运行的链监听器
+func (s *RecoveryService) RestoreJob(ctx context.Context, job *models.Job, progress *jobs.Progress) error {
+	var params RestoreParams
+	if err := job.DecodeParams(&params); err != nil {
+		return fmt.Errorf("解析任务参数失败: %w", err)
+	}
+
+	progress.SetTotal(1)
+	if job.Checkpoint != "" {
+		return nil
+	}
+
+	if _, err := s.RestoreBackup(ctx, params.BackupID, job.CreatedBy); err != nil {
+		return err
+	}
+	progress.Add(1, 0)
+	return progress.Save(ctx, "restored")
 }
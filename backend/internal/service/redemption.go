@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"token-points-system/internal/config"
+	"token-points-system/internal/models"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+	"token-points-system/pkg/logger"
+)
+
+const defaultReservationTTL = 15 * time.Minute
+
+type RedemptionService struct {
+	redemptionRepo *repository.RedemptionRepository
+	reservationTTL time.Duration
+}
+
+func NewRedemptionService(redemptionRepo *repository.RedemptionRepository, cfg *config.PointsConfig) *RedemptionService {
+	ttl := time.Duration(cfg.RedemptionReservationTTL) * time.Second
+	if ttl <= 0 {
+		ttl = defaultReservationTTL
+	}
+
+	return &RedemptionService{
+		redemptionRepo: redemptionRepo,
+		reservationTTL: ttl,
+	}
+}
+
+// RedeemRequest 兑换请求
+// Reserve为true时只冻结积分，需要后续Commit或Cancel
+type RedeemRequest struct {
+	ChainID        string
+	UserAddress    string
+	Amount         string
+	IdempotencyKey string
+	Reference      string
+	Reserve        bool
+}
+
+// Redeem 扣减或预留用户积分
+// 相同幂等键的重复请求返回首次创建的记录；参数不一致时返回冲突错误
+func (s *RedemptionService) Redeem(ctx context.Context, req RedeemRequest) (*models.PointsRedemption, error) {
+	if req.ChainID == "" || req.UserAddress == "" || req.IdempotencyKey == "" {
+		return nil, errors.New(errors.ErrInvalidRequest, "chain、address和幂等键不能为空", nil)
+	}
+
+	amount, ok := parsePositiveDecimal(req.Amount)
+	if !ok {
+		return nil, errors.New(errors.ErrInvalidRequest, "兑换数量必须为最多18位小数的正数", nil)
+	}
+
+	existing, err := s.redemptionRepo.GetByIdempotencyKey(ctx, req.IdempotencyKey)
+	if err != nil {
+		return nil, errors.New(errors.ErrRedemption, "查询幂等键失败", err)
+	}
+	if existing != nil {
+		return s.matchIdempotent(existing, req, amount)
+	}
+
+	redemption := &models.PointsRedemption{
+		ChainID:        req.ChainID,
+		UserAddress:    req.UserAddress,
+		Amount:         amount,
+		IdempotencyKey: req.IdempotencyKey,
+		Reference:      req.Reference,
+	}
+
+	now := time.Now()
+	if req.Reserve {
+		expiresAt := now.Add(s.reservationTTL)
+		redemption.Status = models.RedemptionReserved
+		redemption.ExpiresAt = &expiresAt
+	} else {
+		redemption.Status = models.RedemptionCommitted
+		redemption.CommittedAt = &now
+	}
+
+	if err := s.redemptionRepo.Create(ctx, redemption); err != nil {
+		if stderrors.Is(err, repository.ErrInsufficientPoints) {
+			return nil, errors.New(errors.ErrInsufficient, "可用积分不足", err)
+		}
+
+		// 并发请求使用了相同幂等键，以先写入的记录为准
+		existing, lookupErr := s.redemptionRepo.GetByIdempotencyKey(ctx, req.IdempotencyKey)
+		if lookupErr == nil && existing != nil {
+			return s.matchIdempotent(existing, req, amount)
+		}
+		return nil, errors.New(errors.ErrRedemption, "创建兑换记录失败", err)
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"chain_id":        redemption.ChainID,
+		"user_address":    redemption.UserAddress,
+		"amount":          redemption.Amount,
+		"status":          redemption.Status,
+		"idempotency_key": redemption.IdempotencyKey,
+	}).Info("积分兑换已创建")
+
+	return redemption, nil
+}
+
+// Commit 提交预留的兑换，提交时重新校验可用积分
+func (s *RedemptionService) Commit(ctx context.Context, id uint64) (*models.PointsRedemption, error) {
+	redemption, err := s.redemptionRepo.Commit(ctx, id)
+	if err != nil {
+		if stderrors.Is(err, repository.ErrRedemptionState) {
+			return nil, errors.New(errors.ErrConflict, "兑换已取消或预留已过期", err)
+		}
+		if stderrors.Is(err, repository.ErrInsufficientPoints) {
+			return nil, errors.New(errors.ErrInsufficient, "可用积分不足，预留期间积分已减少", err)
+		}
+		return nil, errors.New(errors.ErrRedemption, "提交兑换失败", err)
+	}
+	if redemption == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("兑换记录不存在: %d", id), nil)
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"redemption_id": id,
+		"amount":        redemption.Amount,
+	}).Info("积分兑换已提交")
+
+	return redemption, nil
+}
+
+// Cancel 取消预留的兑换，释放冻结的积分
+func (s *RedemptionService) Cancel(ctx context.Context, id uint64) (*models.PointsRedemption, error) {
+	redemption, err := s.redemptionRepo.Cancel(ctx, id)
+	if err != nil {
+		if stderrors.Is(err, repository.ErrRedemptionState) {
+			return nil, errors.New(errors.ErrConflict, "兑换已提交，无法取消", err)
+		}
+		return nil, errors.New(errors.ErrRedemption, "取消兑换失败", err)
+	}
+	if redemption == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("兑换记录不存在: %d", id), nil)
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"redemption_id": id,
+	}).Info("积分兑换已取消")
+
+	return redemption, nil
+}
+
+// History 获取用户的兑换记录
+func (s *RedemptionService) History(ctx context.Context, chainID, userAddress string, limit int) ([]models.PointsRedemption, error) {
+	return s.redemptionRepo.GetByUser(ctx, chainID, userAddress, limit)
+}
+
+// matchIdempotent 重放的请求必须与首次请求完全一致，包括模式和reference
+// 预留模式创建的记录始终带有ExpiresAt，直接扣减的记录没有
+func (s *RedemptionService) matchIdempotent(existing *models.PointsRedemption, req RedeemRequest, amount string) (*models.PointsRedemption, error) {
+	reserved := existing.ExpiresAt != nil
+	if existing.ChainID != req.ChainID || existing.UserAddress != req.UserAddress ||
+		!decimalEqual(existing.Amount, amount) || existing.Reference != req.Reference || reserved != req.Reserve {
+		return nil, errors.New(errors.ErrConflict, "幂等键已被其他兑换请求使用", nil)
+	}
+	return existing, nil
+}
+
+// redemptionAmountPattern 兑换数量只接受普通十进制写法，不接受分数和科学计数法
+var redemptionAmountPattern = regexp.MustCompile(`^\d+(\.\d{1,18})?$`)
+
+// parsePositiveDecimal 解析正数并按积分精度格式化，按格式化后的值判断是否为正
+func parsePositiveDecimal(value string) (string, bool) {
+	if !redemptionAmountPattern.MatchString(value) {
+		return "", false
+	}
+	amount := formatDecimal(parseDecimal(value))
+	if parseDecimal(amount).Sign() <= 0 {
+		return "", false
+	}
+	return amount, true
+}
@@ -0,0 +1,38 @@
+package service
+
+import "testing"
+
+func TestParsePositiveDecimal(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{value: "1", want: "1.000000000000000000", wantOK: true},
+		{value: "12.5", want: "12.500000000000000000", wantOK: true},
+		{value: "0.000000000000000001", want: "0.000000000000000001", wantOK: true},
+		{value: "007.10", want: "7.100000000000000000", wantOK: true},
+		{value: "0"},
+		{value: "0.000000000000000000"},
+		{value: "-1"},
+		{value: "+1"},
+		{value: "1/3"},
+		{value: "1e-30"},
+		{value: "1e3"},
+		{value: "0.0000000000000000001"},
+		{value: ".5"},
+		{value: "5."},
+		{value: " 5"},
+		{value: "0x10"},
+		{value: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parsePositiveDecimal(tt.value)
+			if ok != tt.wantOK || got != tt.want {
+				t.Fatalf("parsePositiveDecimal(%q) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
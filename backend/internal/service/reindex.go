@@ -0,0 +1,581 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"token-points-system/internal/blockchain"
+	"token-points-system/internal/config"
+	"token-points-system/internal/jobs"
+	"token-points-system/internal/models"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+	"token-points-system/pkg/logger"
+)
+
+const (
+	// reindexDefaultBatchSize 链配置未设置batch_size时每次拉取的区块数，与链监听器一致
+	reindexDefaultBatchSize = 100
+	reindexMaxBatchSize     = 5000
+	// reindexDiffSamples 差异报告中最多列出的样例数
+	reindexDiffSamples = 20
+)
+
+// ReindexDiffReport 数据集与正式数据的差异报告
+// 交易只比较两边都已处理的区块（ComparedBlock及之前）；余额比较的是两边各自的当前余额
+type ReindexDiffReport struct {
+	DatasetID          uint64                     `json:"dataset_id"`
+	ChainID            string                     `json:"chain_id"`
+	Status             models.ReindexStatus       `json:"status"`
+	SyncedBlock        int64                      `json:"synced_block"`
+	LiveBlock          int64                      `json:"live_block"`
+	ComparedBlock      int64                      `json:"compared_block"`
+	DatasetHistoryRows int64                      `json:"dataset_history_rows"`
+	LiveHistoryRows    int64                      `json:"live_history_rows"`
+	MissingTxs         int64                      `json:"missing_txs"`
+	ExtraTxs           int64                      `json:"extra_txs"`
+	TxSamples          []repository.ReindexTxDiff `json:"tx_samples"`
+	Balances           *repository.StateDiff      `json:"balances"`
+	Note               string                     `json:"note,omitempty"`
+	GeneratedAt        time.Time                  `json:"generated_at"`
+}
+
+// ReindexService 从链上重新同步一条链的余额和余额历史到版本化的影子数据集，
+// 正式数据照常提供服务；确认差异后切换，切换时保存被替换的正式数据以便回滚
+type ReindexService struct {
+	cfg         *config.Config
+	reindexRepo *repository.ReindexRepository
+	blockRepo   *repository.BlockRepository
+	balanceSvc  *BalanceService
+	recoverySvc *RecoveryService
+
+	mu      sync.Mutex
+	running map[uint64]bool
+}
+
+func NewReindexService(
+	cfg *config.Config,
+	reindexRepo *repository.ReindexRepository,
+	blockRepo *repository.BlockRepository,
+	balanceSvc *BalanceService,
+	recoverySvc *RecoveryService,
+) *ReindexService {
+	return &ReindexService{
+		cfg:         cfg,
+		reindexRepo: reindexRepo,
+		blockRepo:   blockRepo,
+		balanceSvc:  balanceSvc,
+		recoverySvc: recoverySvc,
+		running:     make(map[uint64]bool),
+	}
+}
+
+// CreateDataset 创建从链配置的StartBlock（含）开始同步的数据集
+func (s *ReindexService) CreateDataset(ctx context.Context, chainID, actor string) (*models.ReindexDataset, error) {
+	chainCfg, err := s.cfg.GetChainConfig(chainID)
+	if err != nil {
+		return nil, errors.New(errors.ErrInvalidChain, err.Error(), nil)
+	}
+
+	dataset := &models.ReindexDataset{
+		ChainID:     chainID,
+		Status:      models.ReindexSyncing,
+		Source:      models.ReindexSourceChain,
+		StartBlock:  chainCfg.StartBlock,
+		SyncedBlock: chainCfg.StartBlock - 1,
+		CreatedBy:   actor,
+	}
+	if err := s.reindexRepo.Create(ctx, dataset); err != nil {
+		return nil, errors.New(errors.ErrReindex, "创建数据集失败", err)
+	}
+	return dataset, nil
+}
+
+// GetDataset 获取数据集
+func (s *ReindexService) GetDataset(ctx context.Context, id uint64) (*models.ReindexDataset, error) {
+	dataset, err := s.reindexRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.New(errors.ErrReindex, "获取数据集失败", err)
+	}
+	if dataset == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("数据集不存在: %d", id), nil)
+	}
+	return dataset, nil
+}
+
+// ListDatasets 分页获取数据集
+func (s *ReindexService) ListDatasets(ctx context.Context, chainID string, offset, limit int) ([]models.ReindexDataset, int64, error) {
+	datasets, total, err := s.reindexRepo.List(ctx, chainID, offset, limit)
+	if err != nil {
+		return nil, 0, errors.New(errors.ErrReindex, "获取数据集列表失败", err)
+	}
+	return datasets, total, nil
+}
+
+// hasShadowData 数据集在影子表中是否有数据
+func hasShadowData(dataset *models.ReindexDataset) bool {
+	switch dataset.Status {
+	case models.ReindexSyncing, models.ReindexReady, models.ReindexFailed:
+		return true
+	}
+	return false
+}
+
+// CheckSync 检查数据集能否继续同步
+func (s *ReindexService) CheckSync(ctx context.Context, id uint64) (*models.ReindexDataset, error) {
+	dataset, err := s.GetDataset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !hasShadowData(dataset) {
+		return nil, errors.New(errors.ErrConflict, fmt.Sprintf("数据集状态为%s，不能同步", dataset.Status), nil)
+	}
+	return dataset, nil
+}
+
+// CheckCutover 检查数据集能否切换为正式数据，只有同步到过确认区块的数据集可以切换
+func (s *ReindexService) CheckCutover(ctx context.Context, id uint64) (*models.ReindexDataset, error) {
+	dataset, err := s.GetDataset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dataset.Status != models.ReindexReady {
+		return nil, errors.New(errors.ErrConflict, fmt.Sprintf("数据集状态为%s，只能切换ready的数据集", dataset.Status), nil)
+	}
+	return dataset, nil
+}
+
+// RollbackTarget 返回回滚时要切换的数据集，即当前正式数据集切换时保存的快照
+func (s *ReindexService) RollbackTarget(ctx context.Context, chainID string) (*models.ReindexDataset, error) {
+	live, err := s.reindexRepo.GetLive(ctx, chainID)
+	if err != nil {
+		return nil, errors.New(errors.ErrReindex, "获取正式数据集失败", err)
+	}
+	if live == nil || live.ReplacesID == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("链%s没有可以回滚的切换", chainID), nil)
+	}
+	return s.CheckCutover(ctx, *live.ReplacesID)
+}
+
+// Discard 删除数据集的影子数据，正在同步或切换的数据集不能删除
+func (s *ReindexService) Discard(ctx context.Context, id uint64) (*models.ReindexDataset, error) {
+	dataset, err := s.GetDataset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !hasShadowData(dataset) {
+		return nil, errors.New(errors.ErrConflict, fmt.Sprintf("数据集状态为%s，不能删除", dataset.Status), nil)
+	}
+
+	release, err := s.acquire(id)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := s.reindexRepo.Discard(ctx, id); err != nil {
+		return nil, errors.New(errors.ErrReindex, "删除数据集失败", err)
+	}
+	dataset.Status = models.ReindexDiscarded
+	return dataset, nil
+}
+
+// Diff 比较数据集与正式数据
+func (s *ReindexService) Diff(ctx context.Context, id uint64) (*ReindexDiffReport, error) {
+	dataset, err := s.GetDataset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !hasShadowData(dataset) {
+		return nil, errors.New(errors.ErrConflict, fmt.Sprintf("数据集状态为%s，没有影子数据", dataset.Status), nil)
+	}
+	return s.diff(ctx, dataset)
+}
+
+func (s *ReindexService) diff(ctx context.Context, dataset *models.ReindexDataset) (*ReindexDiffReport, error) {
+	liveBlock, err := s.blockRepo.GetLastProcessed(ctx, dataset.ChainID)
+	if err != nil {
+		return nil, errors.New(errors.ErrReindex, "获取已处理区块失败", err)
+	}
+
+	report := &ReindexDiffReport{
+		DatasetID:     dataset.ID,
+		ChainID:       dataset.ChainID,
+		Status:        dataset.Status,
+		SyncedBlock:   dataset.SyncedBlock,
+		LiveBlock:     liveBlock,
+		ComparedBlock: dataset.SyncedBlock,
+		GeneratedAt:   time.Now(),
+	}
+	if liveBlock < report.ComparedBlock {
+		report.ComparedBlock = liveBlock
+	}
+
+	report.DatasetHistoryRows, report.LiveHistoryRows, err = s.reindexRepo.CountHistory(ctx, dataset, report.ComparedBlock)
+	if err != nil {
+		return nil, errors.New(errors.ErrReindex, "统计余额历史失败", err)
+	}
+	report.MissingTxs, report.ExtraTxs, report.TxSamples, err = s.reindexRepo.DiffTransactions(ctx, dataset, report.ComparedBlock, reindexDiffSamples)
+	if err != nil {
+		return nil, errors.New(errors.ErrReindex, "比较交易失败", err)
+	}
+	report.Balances, err = s.reindexRepo.DiffBalances(ctx, dataset, reindexDiffSamples)
+	if err != nil {
+		return nil, errors.New(errors.ErrReindex, "比较余额失败", err)
+	}
+
+	if dataset.SyncedBlock != liveBlock {
+		report.Note = fmt.Sprintf("数据集同步到区块%d，正式数据处理到区块%d，余额差异包含两者之间的变动", dataset.SyncedBlock, liveBlock)
+	}
+	return report, nil
+}
+
+// acquire 标记数据集正在同步或切换，返回释放函数
+func (s *ReindexService) acquire(id uint64) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running[id] {
+		return nil, errors.New(errors.ErrConflict, fmt.Sprintf("数据集%d正在同步或切换", id), nil)
+	}
+	s.running[id] = true
+	return func() {
+		s.mu.Lock()
+		delete(s.running, id)
+		s.mu.Unlock()
+	}, nil
+}
+
+// syncToHead 从SyncedBlock之后开始按批拉取Transfer事件写入数据集，直到确认区块
+// progress不为nil时每批保存进度，检查点为已同步的区块
+func (s *ReindexService) syncToHead(ctx context.Context, dataset *models.ReindexDataset, chainCfg *config.ChainConfig, client *blockchain.Client, progress *jobs.Progress) error {
+	batchSize := int64(chainCfg.BatchSize)
+	if batchSize <= 0 {
+		batchSize = reindexDefaultBatchSize
+	}
+	if batchSize > reindexMaxBatchSize {
+		batchSize = reindexMaxBatchSize
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		confirmed, err := client.GetConfirmBlockNumber(ctx)
+		if err != nil {
+			return err
+		}
+		if dataset.SyncedBlock >= confirmed {
+			return nil
+		}
+
+		start := dataset.SyncedBlock + 1
+		end := start + batchSize - 1
+		if end > confirmed {
+			end = confirmed
+		}
+
+		if err := s.syncRange(ctx, dataset, client, start, end); err != nil {
+			return err
+		}
+		dataset.SyncedBlock = end
+
+		if progress != nil {
+			progress.SetTotal(confirmed - dataset.StartBlock + 1)
+			progress.Add(end-start+1, 0)
+			if err := progress.Save(ctx, strconv.FormatInt(end, 10)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// syncRange 拉取[start, end]区块的Transfer事件，按与链监听相同的规则计算余额，在一个事务中写入数据集
+// 与链监听一致，同一交易中的多个Transfer事件按日志序号分别记录
+func (s *ReindexService) syncRange(ctx context.Context, dataset *models.ReindexDataset, client *blockchain.Client, start, end int64) error {
+	logs, err := client.GetTransferLogs(ctx, start, end)
+	if err != nil {
+		return err
+	}
+
+	type parsedLog struct {
+		event *blockchain.TransferEvent
+		index uint
+	}
+	events := make([]parsedLog, 0, len(logs))
+	addressSet := make(map[string]bool)
+	for _, log := range logs {
+		event, err := blockchain.ParseTransferLog(log)
+		if err != nil {
+			logger.Error("解析日志失败:", err)
+			continue
+		}
+		events = append(events, parsedLog{event: event, index: log.Index})
+		for _, address := range transferParties(event) {
+			addressSet[address] = true
+		}
+	}
+
+	addresses := make([]string, 0, len(addressSet))
+	for address := range addressSet {
+		addresses = append(addresses, address)
+	}
+	stored, err := s.reindexRepo.GetBalances(ctx, dataset.ID, addresses)
+	if err != nil {
+		return err
+	}
+
+	balances := make(map[string]*big.Int, len(addresses))
+	for _, address := range addresses {
+		balance := new(big.Int)
+		if value, ok := stored[address]; ok {
+			balance.SetString(value, 10)
+		}
+		balances[address] = balance
+	}
+
+	timestamps := make(map[int64]time.Time)
+	history := make([]models.ReindexHistory, 0, len(events)*2)
+	for _, e := range events {
+		timestamp, ok := timestamps[e.event.BlockNum]
+		if !ok {
+			timestamp, err = client.GetBlockTimestamp(ctx, e.event.BlockNum)
+			if err != nil {
+				return err
+			}
+			timestamps[e.event.BlockNum] = timestamp
+		}
+
+		for _, address := range transferParties(e.event) {
+			before := balances[address]
+			change := e.event.GetChangeAmount(address)
+			after := new(big.Int).Add(before, change)
+
+			// 与链监听一致：余额不足时以链上余额为准
+			if after.Sign() < 0 {
+				onchain, err := client.GetTokenBalance(ctx, address)
+				if err != nil {
+					return fmt.Errorf("地址%s余额为负且无法从链上同步: %w", address, err)
+				}
+				before = onchain
+				after = new(big.Int).Add(before, change)
+				if after.Sign() < 0 {
+					return fmt.Errorf("地址%s在交易%s中余额不足", address, e.event.TxHash)
+				}
+			}
+
+			history = append(history, models.ReindexHistory{
+				DatasetID:     dataset.ID,
+				UserAddress:   address,
+				BalanceBefore: before.String(),
+				BalanceAfter:  after.String(),
+				ChangeAmount:  change.String(),
+				ChangeType:    e.event.DetermineChangeType(address),
+				TxHash:        e.event.TxHash,
+				LogIndex:      e.index,
+				BlockNumber:   e.event.BlockNum,
+				Timestamp:     timestamp,
+			})
+			balances[address] = after
+		}
+	}
+
+	changed := make(map[string]string, len(balances))
+	for address, balance := range balances {
+		changed[address] = balance.String()
+	}
+	return s.reindexRepo.ApplyBatch(ctx, dataset.ID, history, changed, end)
+}
+
+// transferParties 返回Transfer事件中余额发生变化的地址，与BalanceService.ProcessTransfer一致
+func transferParties(event *blockchain.TransferEvent) []string {
+	var parties []string
+	if strings.ToLower(event.From.Hex()) != zeroAddress {
+		parties = append(parties, event.From.Hex())
+	}
+	if event.From != event.To && strings.ToLower(event.To.Hex()) != zeroAddress {
+		parties = append(parties, event.To.Hex())
+	}
+	return parties
+}
+
+// ReindexParams 同步和切换任务参数
+type ReindexParams struct {
+	DatasetID uint64 `json:"dataset_id"`
+}
+
+// openDataset 解析任务参数，占用数据集并连接数据集所在的链
+func (s *ReindexService) openDataset(ctx context.Context, job *models.Job) (*models.ReindexDataset, *config.ChainConfig, *blockchain.Client, func(), error) {
+	var params ReindexParams
+	if err := job.DecodeParams(&params); err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("解析任务参数失败: %w", err)
+	}
+
+	dataset, err := s.GetDataset(ctx, params.DatasetID)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	chainCfg, err := s.cfg.GetChainConfig(dataset.ChainID)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	release, err := s.acquire(dataset.ID)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	client, err := blockchain.NewClient(chainCfg)
+	if err != nil {
+		release()
+		return nil, nil, nil, nil, err
+	}
+
+	return dataset, chainCfg, client, func() {
+		client.Close()
+		release()
+	}, nil
+}
+
+// SyncJob 同步任务处理器：把数据集同步到确认区块后标记为ready，差异报告保存为任务结果；
+// 失败时数据集标记为failed，可再次提交同步任务从已同步的区块继续
+func (s *ReindexService) SyncJob(ctx context.Context, job *models.Job, progress *jobs.Progress) error {
+	dataset, chainCfg, client, closeFn, err := s.openDataset(ctx, job)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	if !hasShadowData(dataset) {
+		return fmt.Errorf("数据集状态为%s，不能同步", dataset.Status)
+	}
+	if err := s.reindexRepo.UpdateStatus(ctx, dataset.ID, models.ReindexSyncing, ""); err != nil {
+		return err
+	}
+	dataset.Status = models.ReindexSyncing
+
+	if err := s.syncToHead(ctx, dataset, chainCfg, client, progress); err != nil {
+		// 任务上下文可能已取消，使用独立的上下文记录失败
+		if uerr := s.reindexRepo.UpdateStatus(context.Background(), dataset.ID, models.ReindexFailed, err.Error()); uerr != nil {
+			logger.Error("更新数据集状态失败:", dataset.ID, uerr)
+		}
+		return err
+	}
+
+	if err := s.reindexRepo.UpdateStatus(ctx, dataset.ID, models.ReindexReady, ""); err != nil {
+		return err
+	}
+	dataset.Status = models.ReindexReady
+
+	report, err := s.diff(ctx, dataset)
+	if err != nil {
+		return err
+	}
+	if err := progress.SetResult(report); err != nil {
+		return err
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"dataset_id":   dataset.ID,
+		"chain_id":     dataset.ChainID,
+		"synced_block": dataset.SyncedBlock,
+		"missing_txs":  report.MissingTxs,
+		"extra_txs":    report.ExtraTxs,
+	}).Info("数据集同步完成")
+
+	return progress.Save(ctx, strconv.FormatInt(dataset.SyncedBlock, 10))
+}
+
+// CutoverResult 切换任务结果
+type CutoverResult struct {
+	DatasetID   uint64 `json:"dataset_id"`
+	ChainID     string `json:"chain_id"`
+	SnapshotID  uint64 `json:"snapshot_id"`
+	SyncedBlock int64  `json:"synced_block"`
+	LiveBlock   int64  `json:"live_block"`
+	// PointsStaleFrom 新旧余额历史出现差异的最早时间，StaleCalculations为此后结束、按被替换的余额历史计算的积分计算记录数
+	PointsStaleFrom   *time.Time `json:"points_stale_from,omitempty"`
+	StaleCalculations int64      `json:"stale_calculations"`
+}
+
+// CutoverJob 切换任务处理器，由主节点执行以便暂停转账处理和回退链监听器
+// 先把数据集追赶到确认区块，再在暂停转账处理期间追赶最后的区块并在一个事务中替换正式余额和余额历史；
+// 被替换的正式数据保存为快照数据集，回滚即切换到该快照
+func (s *ReindexService) CutoverJob(ctx context.Context, job *models.Job, progress *jobs.Progress) error {
+	dataset, chainCfg, client, closeFn, err := s.openDataset(ctx, job)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	progress.SetTotal(1)
+	if job.Checkpoint != "" {
+		return nil
+	}
+	if dataset.Status != models.ReindexReady {
+		return fmt.Errorf("数据集状态为%s，只能切换ready的数据集", dataset.Status)
+	}
+
+	if err := s.syncToHead(ctx, dataset, chainCfg, client, nil); err != nil {
+		return err
+	}
+
+	result := &CutoverResult{DatasetID: dataset.ID, ChainID: dataset.ChainID}
+	err = s.balanceSvc.Exclusive(func() error {
+		if err := s.syncToHead(ctx, dataset, chainCfg, client, nil); err != nil {
+			return err
+		}
+
+		liveBlock, err := s.blockRepo.GetLastProcessed(ctx, dataset.ChainID)
+		if err != nil {
+			return err
+		}
+		if dataset.SyncedBlock < liveBlock {
+			return fmt.Errorf("数据集只同步到区块%d，正式数据已处理到区块%d", dataset.SyncedBlock, liveBlock)
+		}
+
+		snapshot, err := s.reindexRepo.Cutover(ctx, dataset, liveBlock, chainCfg.StartBlock, job.CreatedBy)
+		if err != nil {
+			return errors.New(errors.ErrReindex, "切换数据集失败", err)
+		}
+		s.recoverySvc.RewindListeners(dataset.ChainID, dataset.SyncedBlock)
+
+		result.SnapshotID = snapshot.ID
+		result.SyncedBlock = dataset.SyncedBlock
+		result.LiveBlock = liveBlock
+		result.PointsStaleFrom = dataset.PointsStaleFrom
+		result.StaleCalculations = dataset.StaleCalculations
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"dataset_id":   dataset.ID,
+		"chain_id":     dataset.ChainID,
+		"snapshot_id":  result.SnapshotID,
+		"synced_block": result.SyncedBlock,
+		"live_block":   result.LiveBlock,
+		"cutover_by":   job.CreatedBy,
+	}).Warn("数据集已切换为正式数据")
+
+	if result.PointsStaleFrom != nil {
+		logger.WithFields(map[string]interface{}{
+			"chain_id":           dataset.ChainID,
+			"points_stale_from":  result.PointsStaleFrom,
+			"stale_calculations": result.StaleCalculations,
+		}).Warn("切换前已发放的积分按被替换的余额历史计算，需要核对受影响的周期")
+	}
+
+	if err := progress.SetResult(result); err != nil {
+		return err
+	}
+	progress.Add(1, 0)
+	return progress.Save(ctx, "cutover")
+}
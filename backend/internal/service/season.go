@@ -0,0 +1,369 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"token-points-system/internal/config"
+	"token-points-system/internal/models"
+	"token-points-system/internal/period"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+	"token-points-system/pkg/logger"
+)
+
+// SeasonService 积分赛季
+// 赛季积分由周期计算记录按周期开始时间归入赛季，user_points的累计积分不受赛季影响；
+// 赛季结束且所有链的周期计算都覆盖结束时间后冻结最终排名，并按结转比例计入下一赛季
+type SeasonService struct {
+	seasonRepo *repository.SeasonRepository
+	cursorRepo *repository.CursorRepository
+	chainIDs   []string
+	periods    map[string]*period.Period
+}
+
+func NewSeasonService(seasonRepo *repository.SeasonRepository, cursorRepo *repository.CursorRepository, chains []config.ChainConfig, points *config.PointsConfig) (*SeasonService, error) {
+	s := &SeasonService{
+		seasonRepo: seasonRepo,
+		cursorRepo: cursorRepo,
+		periods:    make(map[string]*period.Period),
+	}
+
+	for i := range chains {
+		chain := &chains[i]
+		if !chain.Enabled {
+			continue
+		}
+		p, err := period.ForChain(points, chain)
+		if err != nil {
+			return nil, err
+		}
+		s.chainIDs = append(s.chainIDs, chain.ID)
+		s.periods[chain.ID] = p
+	}
+	return s, nil
+}
+
+// SeasonRequest 创建赛季请求，CarryOverRate为0-1之间的小数，为空表示不结转
+type SeasonRequest struct {
+	Name          string
+	StartAt       time.Time
+	EndAt         time.Time
+	CarryOverRate string
+	Actor         string
+}
+
+// SeasonView 赛季及其当前状态
+type SeasonView struct {
+	models.Season
+	Status models.SeasonStatus `json:"status"`
+}
+
+// SeasonStandingsPage 赛季在一条链上的排名，Frozen表示赛季已关闭、排名不再变化
+type SeasonStandingsPage struct {
+	Season  SeasonView              `json:"season"`
+	ChainID string                  `json:"chain_id"`
+	Frozen  bool                    `json:"frozen"`
+	Total   int64                   `json:"total"`
+	Items   []models.SeasonStanding `json:"items"`
+}
+
+// SeasonUserStanding 用户在赛季中的积分和名次，Standing为空表示赛季内没有积分
+type SeasonUserStanding struct {
+	Season   SeasonView             `json:"season"`
+	ChainID  string                 `json:"chain_id"`
+	Address  string                 `json:"address"`
+	Frozen   bool                   `json:"frozen"`
+	Standing *models.SeasonStanding `json:"standing"`
+}
+
+// CreateSeason 创建赛季
+// 赛季不能重叠，起止时间必须是所有启用链的周期边界，保证每个周期完整地属于一个赛季
+func (s *SeasonService) CreateSeason(ctx context.Context, req SeasonRequest) (*SeasonView, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, errors.New(errors.ErrInvalidRequest, "赛季名称不能为空", nil)
+	}
+	if req.Actor == "" {
+		return nil, errors.New(errors.ErrInvalidRequest, "缺少操作人身份", nil)
+	}
+	if !req.EndAt.After(req.StartAt) {
+		return nil, errors.New(errors.ErrInvalidRequest, "赛季结束时间必须晚于开始时间", nil)
+	}
+
+	rate := new(big.Rat)
+	if req.CarryOverRate != "" {
+		if _, ok := rate.SetString(req.CarryOverRate); !ok {
+			return nil, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的结转比例: %s", req.CarryOverRate), nil)
+		}
+	}
+	if rate.Sign() < 0 || rate.Cmp(big.NewRat(1, 1)) > 0 {
+		return nil, errors.New(errors.ErrInvalidRequest, "结转比例必须在0到1之间", nil)
+	}
+
+	for _, chainID := range s.chainIDs {
+		p := s.periods[chainID]
+		for _, t := range []time.Time{req.StartAt, req.EndAt} {
+			if !p.Floor(t).Equal(t) {
+				return nil, errors.New(errors.ErrInvalidRequest,
+					fmt.Sprintf("%s 不是链 %s 的周期边界（%s）", t.Format(time.RFC3339), chainID, p), nil)
+			}
+		}
+	}
+
+	overlapping, err := s.seasonRepo.CountOverlapping(ctx, req.StartAt, req.EndAt)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "检查赛季重叠失败", err)
+	}
+	if overlapping > 0 {
+		return nil, errors.New(errors.ErrConflict, "赛季时间与已有赛季重叠", nil)
+	}
+
+	season := &models.Season{
+		Name:          strings.TrimSpace(req.Name),
+		StartAt:       req.StartAt,
+		EndAt:         req.EndAt,
+		CarryOverRate: rate.FloatString(6),
+		CreatedBy:     req.Actor,
+	}
+	if err := s.seasonRepo.Create(ctx, season); err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "创建赛季失败", err)
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"season_id":  season.ID,
+		"name":       season.Name,
+		"start_at":   season.StartAt,
+		"end_at":     season.EndAt,
+		"carry_over": season.CarryOverRate,
+		"created_by": season.CreatedBy,
+	}).Info("赛季已创建")
+
+	return seasonView(season, time.Now()), nil
+}
+
+// ListSeasons 按开始时间倒序获取全部赛季
+func (s *SeasonService) ListSeasons(ctx context.Context) ([]SeasonView, error) {
+	seasons, err := s.seasonRepo.List(ctx)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取赛季列表失败", err)
+	}
+
+	now := time.Now()
+	views := make([]SeasonView, 0, len(seasons))
+	for i := range seasons {
+		views = append(views, *seasonView(&seasons[i], now))
+	}
+	return views, nil
+}
+
+// GetSeason 获取赛季，ref为赛季ID或current（当前进行中的赛季）
+func (s *SeasonService) GetSeason(ctx context.Context, ref string) (*SeasonView, error) {
+	season, err := s.resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return seasonView(season, time.Now()), nil
+}
+
+// Standings 分页获取赛季在一条链上的排名，已关闭赛季返回冻结排名，否则实时计算
+func (s *SeasonService) Standings(ctx context.Context, ref, chainID string, offset, limit int) (*SeasonStandingsPage, error) {
+	season, err := s.resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkChain(chainID); err != nil {
+		return nil, err
+	}
+
+	page := &SeasonStandingsPage{
+		Season:  *seasonView(season, time.Now()),
+		ChainID: chainID,
+		Frozen:  season.ClosedAt != nil,
+	}
+
+	if page.Frozen {
+		page.Items, err = s.seasonRepo.GetStandings(ctx, season.ID, chainID, offset, limit)
+		if err == nil {
+			page.Total, err = s.seasonRepo.CountStandings(ctx, season.ID, chainID)
+		}
+	} else {
+		var previous *models.Season
+		previous, err = s.carryOverSource(ctx, season)
+		if err == nil {
+			page.Items, err = s.seasonRepo.GetLiveStandings(ctx, season, previous, chainID, offset, limit)
+		}
+		if err == nil {
+			page.Total, err = s.seasonRepo.CountLiveStandings(ctx, season, previous, chainID)
+		}
+	}
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取赛季排名失败", err)
+	}
+	if page.Items == nil {
+		page.Items = []models.SeasonStanding{}
+	}
+	return page, nil
+}
+
+// UserStanding 获取用户在赛季中的积分和名次
+func (s *SeasonService) UserStanding(ctx context.Context, ref, chainID, userAddress string) (*SeasonUserStanding, error) {
+	season, err := s.resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkChain(chainID); err != nil {
+		return nil, err
+	}
+
+	result := &SeasonUserStanding{
+		Season:  *seasonView(season, time.Now()),
+		ChainID: chainID,
+		Address: userAddress,
+		Frozen:  season.ClosedAt != nil,
+	}
+
+	if result.Frozen {
+		result.Standing, err = s.seasonRepo.GetStanding(ctx, season.ID, chainID, userAddress)
+	} else {
+		var previous *models.Season
+		previous, err = s.carryOverSource(ctx, season)
+		if err == nil {
+			result.Standing, err = s.seasonRepo.GetLiveStanding(ctx, season, previous, chainID, userAddress)
+		}
+	}
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取赛季积分失败", err)
+	}
+	return result, nil
+}
+
+// CloseSeason 冻结已结束赛季的最终排名
+// 要求所有启用链的周期计算都已覆盖赛季结束时间，且上一赛季已关闭（其最终积分是本赛季的结转来源）
+func (s *SeasonService) CloseSeason(ctx context.Context, id uint64) (*SeasonView, error) {
+	season, err := s.seasonRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取赛季失败", err)
+	}
+	if season == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("赛季不存在: %d", id), nil)
+	}
+
+	now := time.Now()
+	switch season.Status(now) {
+	case models.SeasonClosed:
+		return nil, errors.New(errors.ErrConflict, "赛季已关闭", nil)
+	case models.SeasonScheduled, models.SeasonActive:
+		return nil, errors.New(errors.ErrConflict, "赛季尚未结束", nil)
+	}
+
+	for _, chainID := range s.chainIDs {
+		lastEnd, err := s.cursorRepo.GetLastPeriodEnd(ctx, chainID)
+		if err != nil {
+			return nil, errors.New(errors.ErrPointsCalc, "获取计算游标失败", err)
+		}
+		if lastEnd.Before(season.EndAt) {
+			return nil, errors.New(errors.ErrConflict,
+				fmt.Sprintf("链 %s 的周期计算尚未覆盖赛季结束时间（已计算到 %s）", chainID, lastEnd.Format(time.RFC3339)), nil)
+		}
+	}
+
+	previous, err := s.carryOverSource(ctx, season)
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取上一赛季失败", err)
+	}
+	if previous != nil && previous.ClosedAt == nil {
+		return nil, errors.New(errors.ErrConflict, fmt.Sprintf("上一赛季 %d 尚未关闭", previous.ID), nil)
+	}
+
+	entries, err := s.seasonRepo.Close(ctx, season, previous, s.chainIDs, now)
+	if err != nil {
+		if stderrors.Is(err, repository.ErrSeasonClosed) {
+			return nil, errors.New(errors.ErrConflict, "赛季已关闭", err)
+		}
+		return nil, errors.New(errors.ErrPointsCalc, "关闭赛季失败", err)
+	}
+
+	season.ClosedAt, season.Entries = &now, entries
+	logger.WithFields(map[string]interface{}{
+		"season_id": season.ID,
+		"entries":   entries,
+	}).Info("赛季已关闭，最终排名已冻结")
+
+	return seasonView(season, now), nil
+}
+
+// CloseEndedSeasons 按结束时间依次关闭已结束的赛季，条件未满足的赛季留到下次再试
+func (s *SeasonService) CloseEndedSeasons(ctx context.Context, now time.Time) error {
+	seasons, err := s.seasonRepo.GetEndedOpen(ctx, now)
+	if err != nil {
+		return errors.New(errors.ErrPointsCalc, "获取待关闭赛季失败", err)
+	}
+
+	for _, season := range seasons {
+		if _, err := s.CloseSeason(ctx, season.ID); err != nil {
+			// 赛季按顺序关闭，前一个未关闭时后面的也无法关闭
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve 按ID或current获取赛季
+func (s *SeasonService) resolve(ctx context.Context, ref string) (*models.Season, error) {
+	var (
+		season *models.Season
+		err    error
+	)
+	if ref == "current" {
+		season, err = s.seasonRepo.GetAt(ctx, time.Now())
+	} else {
+		id, parseErr := parseSeasonID(ref)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		season, err = s.seasonRepo.GetByID(ctx, id)
+	}
+	if err != nil {
+		return nil, errors.New(errors.ErrPointsCalc, "获取赛季失败", err)
+	}
+	if season == nil {
+		return nil, errors.New(errors.ErrNotFound, fmt.Sprintf("赛季不存在: %s", ref), nil)
+	}
+	return season, nil
+}
+
+// carryOverSource 返回向season结转积分的上一赛季
+func (s *SeasonService) carryOverSource(ctx context.Context, season *models.Season) (*models.Season, error) {
+	previous, err := s.seasonRepo.GetPrevious(ctx, season.StartAt)
+	if err != nil || previous == nil {
+		return nil, err
+	}
+	if parseDecimal(previous.CarryOverRate).Sign() == 0 && previous.ClosedAt != nil {
+		return nil, nil
+	}
+	return previous, nil
+}
+
+func (s *SeasonService) checkChain(chainID string) error {
+	if _, ok := s.periods[chainID]; !ok {
+		return errors.New(errors.ErrInvalidChain, fmt.Sprintf("链未启用: %s", chainID), nil)
+	}
+	return nil
+}
+
+func parseSeasonID(ref string) (uint64, error) {
+	id, err := strconv.ParseUint(ref, 10, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New(errors.ErrInvalidRequest, fmt.Sprintf("无效的赛季ID: %s", ref), nil)
+	}
+	return id, nil
+}
+
+func seasonView(season *models.Season, now time.Time) *SeasonView {
+	return &SeasonView{Season: *season, Status: season.Status(now)}
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"token-points-system/internal/jobs"
+	"token-points-system/internal/models"
+	"token-points-system/internal/repository"
+	"token-points-system/pkg/errors"
+	"token-points-system/pkg/logger"
+)
+
+const (
+	// rebuildPageSize 每次读取的余额历史条数
+	rebuildPageSize = 1000
+	// maxReportedInconsistencies 报告中最多列出的不一致记录数，超出部分只计数
+	maxReportedInconsistencies = 100
+	// rebuildDiffSamples 每张表最多列出的差异样例数
+	rebuildDiffSamples = 20
+)
+
+// 余额历史不一致的类型
+const (
+	// InconsistencyGap 变动前余额与前一条记录的变动后余额不连续，如负余额时从链上同步过余额
+	InconsistencyGap = "gap"
+	// InconsistencyArithmetic 变动后余额不等于变动前余额加变动金额
+	InconsistencyArithmetic = "arithmetic"
+	// InconsistencyInvalid 金额无法解析
+	InconsistencyInvalid = "invalid"
+)
+
+// BalanceInconsistency 一条与重放结果不一致的余额历史
+type BalanceInconsistency struct {
+	Kind          string `json:"kind"`
+	HistoryID     uint64 `json:"history_id"`
+	UserAddress   string `json:"user_address"`
+	TxHash        string `json:"tx_hash"`
+	BlockNumber   int64  `json:"block_number"`
+	Expected      string `json:"expected"`
+	BalanceBefore string `json:"balance_before"`
+	BalanceAfter  string `json:"balance_after"`
+	ChangeAmount  string `json:"change_amount"`
+}
+
+// StateRebuildReport 余额和积分重建结果
+type StateRebuildReport struct {
+	ChainID         string                 `json:"chain_id"`
+	Watermark       uint64                 `json:"watermark"`
+	HistoryRows     int64                  `json:"history_rows"`
+	CaughtUpRows    int64                  `json:"caught_up_rows"`
+	Users           int                    `json:"users"`
+	AllowGaps       bool                   `json:"allow_gaps"`
+	Inconsistencies int                    `json:"inconsistencies"`
+	Gaps            int                    `json:"gaps"`
+	Details         []BalanceInconsistency `json:"details"`
+	MissingAccruals int64                  `json:"missing_accruals"`
+	Balances        *repository.StateDiff  `json:"balances"`
+	Points          *repository.StateDiff  `json:"points"`
+	Verified        bool                   `json:"verified"`
+	Applied         bool                   `json:"applied"`
+	Note            string                 `json:"note,omitempty"`
+	StartedAt       time.Time              `json:"started_at"`
+	FinishedAt      time.Time              `json:"finished_at"`
+}
+
+// StateRebuildService 按余额历史和积分流水重建user_balances和user_points
+type StateRebuildService struct {
+	rebuildRepo *repository.RebuildRepository
+	balanceSvc  *BalanceService
+}
+
+func NewStateRebuildService(rebuildRepo *repository.RebuildRepository, balanceSvc *BalanceService) *StateRebuildService {
+	return &StateRebuildService{
+		rebuildRepo: rebuildRepo,
+		balanceSvc:  balanceSvc,
+	}
+}
+
+// balanceReplay 按顺序重放余额历史时每个用户的当前余额
+type balanceReplay struct {
+	report   *StateRebuildReport
+	balances map[string]*big.Int
+	touched  map[string]bool
+}
+
+// apply 校验一条余额历史并推进该用户的余额
+// 变动前余额不连续时以记录的值为准继续重放，变动后余额算错时以重新计算的值为准
+func (b *balanceReplay) apply(h *models.BalanceHistory) {
+	address := h.UserAddress
+	b.touched[address] = true
+
+	before, okBefore := new(big.Int).SetString(h.BalanceBefore, 10)
+	after, okAfter := new(big.Int).SetString(h.BalanceAfter, 10)
+	change, okChange := new(big.Int).SetString(h.ChangeAmount, 10)
+
+	running, ok := b.balances[address]
+	if !ok {
+		running = new(big.Int)
+	}
+
+	if !okBefore || !okAfter || !okChange {
+		b.record(InconsistencyInvalid, h, running.String())
+		if okAfter {
+			b.balances[address] = after
+		}
+		return
+	}
+
+	if before.Cmp(running) != 0 {
+		b.record(InconsistencyGap, h, running.String())
+		running = before
+	}
+
+	expected := new(big.Int).Add(running, change)
+	if expected.Cmp(after) != 0 {
+		b.record(InconsistencyArithmetic, h, expected.String())
+	}
+	b.balances[address] = expected
+}
+
+func (b *balanceReplay) record(kind string, h *models.BalanceHistory, expected string) {
+	b.report.Inconsistencies++
+	if kind == InconsistencyGap {
+		b.report.Gaps++
+	}
+	if len(b.report.Details) >= maxReportedInconsistencies {
+		return
+	}
+	b.report.Details = append(b.report.Details, BalanceInconsistency{
+		Kind:          kind,
+		HistoryID:     h.ID,
+		UserAddress:   h.UserAddress,
+		TxHash:        h.TxHash,
+		BlockNumber:   h.BlockNumber,
+		Expected:      expected,
+		BalanceBefore: h.BalanceBefore,
+		BalanceAfter:  h.BalanceAfter,
+		ChangeAmount:  h.ChangeAmount,
+	})
+}
+
+// blockingInconsistencies 阻止替换正式表的不一致数量，allowGaps为true时不计余额不连续
+func (r *StateRebuildReport) blockingInconsistencies() int {
+	if r.AllowGaps {
+		return r.Inconsistencies - r.Gaps
+	}
+	return r.Inconsistencies
+}
+
+// Rebuild 按(区块, 日志)顺序重放链的余额历史到影子表，逐条校验变动前后余额，并按积分流水汇总影子积分
+// apply为true且校验通过时，暂停转账处理，追赶重放期间新写入的余额历史后在一个事务中替换正式表；
+// allowGaps为true时余额不连续（如从链上同步过余额）不视为校验失败
+func (s *StateRebuildService) Rebuild(ctx context.Context, chainID string, apply, allowGaps bool) (*StateRebuildReport, error) {
+	report := &StateRebuildReport{
+		ChainID:   chainID,
+		AllowGaps: allowGaps,
+		Details:   []BalanceInconsistency{},
+		StartedAt: time.Now(),
+	}
+
+	watermark, err := s.rebuildRepo.MaxHistoryID(ctx, chainID)
+	if err != nil {
+		return nil, errors.New(errors.ErrRebuild, "获取余额历史水位失败", err)
+	}
+	report.Watermark = watermark
+
+	if err := s.rebuildRepo.ResetShadow(ctx, chainID); err != nil {
+		return nil, errors.New(errors.ErrRebuild, "清空影子表失败", err)
+	}
+
+	replay := &balanceReplay{
+		report:   report,
+		balances: make(map[string]*big.Int),
+		touched:  make(map[string]bool),
+	}
+
+	var after repository.HistoryPosition
+	for {
+		rows, err := s.rebuildRepo.HistoryPage(ctx, chainID, after, watermark, rebuildPageSize)
+		if err != nil {
+			return nil, errors.New(errors.ErrRebuild, "读取余额历史失败", err)
+		}
+		for i := range rows {
+			replay.apply(&rows[i])
+		}
+		report.HistoryRows += int64(len(rows))
+		if len(rows) < rebuildPageSize {
+			break
+		}
+		last := rows[len(rows)-1]
+		after = repository.HistoryPosition{BlockNumber: last.BlockNumber, LogIndex: last.LogIndex, ID: last.ID}
+	}
+
+	if err := s.saveShadowBalances(ctx, chainID, replay); err != nil {
+		return nil, err
+	}
+	if err := s.rebuildRepo.BuildShadowPoints(ctx, chainID); err != nil {
+		return nil, errors.New(errors.ErrRebuild, "汇总影子积分失败", err)
+	}
+
+	missing, err := s.rebuildRepo.CountMissingAccruals(ctx, chainID)
+	if err != nil {
+		return nil, errors.New(errors.ErrRebuild, "检查入账流水失败", err)
+	}
+	report.MissingAccruals = missing
+
+	if err := s.diff(ctx, report); err != nil {
+		return nil, err
+	}
+
+	report.Verified = report.blockingInconsistencies() == 0 && report.MissingAccruals == 0
+	switch {
+	case report.MissingAccruals > 0:
+		report.Note = "存在没有入账流水的计算记录，请先执行rebuild-points -apply补写流水"
+	case !report.Verified:
+		report.Note = "余额历史存在不一致，未替换正式表"
+	}
+
+	if apply && report.Verified {
+		if err := s.swap(ctx, chainID, replay, watermark); err != nil {
+			return nil, err
+		}
+	}
+
+	report.Users = len(replay.balances)
+	report.FinishedAt = time.Now()
+
+	entry := logger.WithFields(map[string]interface{}{
+		"chain_id":         chainID,
+		"history_rows":     report.HistoryRows,
+		"caught_up_rows":   report.CaughtUpRows,
+		"users":            report.Users,
+		"inconsistencies":  report.Inconsistencies,
+		"gaps":             report.Gaps,
+		"missing_accruals": report.MissingAccruals,
+		"verified":         report.Verified,
+		"applied":          report.Applied,
+	})
+	if report.Verified {
+		entry.Info("余额和积分重建完成")
+	} else {
+		entry.Warn("余额和积分重建校验未通过")
+	}
+
+	return report, nil
+}
+
+// swap 暂停转账处理，重放水位之后新写入的余额历史并替换正式表
+// 追赶阶段出现阻止替换的不一致时放弃替换，影子表保留供排查
+func (s *StateRebuildService) swap(ctx context.Context, chainID string, replay *balanceReplay, watermark uint64) error {
+	return s.balanceSvc.Exclusive(func() error {
+		blocking := replay.report.blockingInconsistencies()
+		replay.touched = make(map[string]bool)
+
+		afterID := watermark
+		for {
+			rows, err := s.rebuildRepo.HistoryAfter(ctx, chainID, afterID, rebuildPageSize)
+			if err != nil {
+				return errors.New(errors.ErrRebuild, "读取余额历史失败", err)
+			}
+			for i := range rows {
+				replay.apply(&rows[i])
+			}
+			replay.report.CaughtUpRows += int64(len(rows))
+			if len(rows) < rebuildPageSize {
+				break
+			}
+			afterID = rows[len(rows)-1].ID
+		}
+
+		if replay.report.blockingInconsistencies() > blocking {
+			replay.report.Verified = false
+			replay.report.Note = "追赶重放时发现余额历史不一致，未替换正式表"
+			return nil
+		}
+
+		if err := s.saveShadowBalances(ctx, chainID, replay); err != nil {
+			return err
+		}
+		if err := s.rebuildRepo.Swap(ctx, chainID); err != nil {
+			return errors.New(errors.ErrRebuild, "替换余额和积分失败", err)
+		}
+		replay.report.Applied = true
+		return nil
+	})
+}
+
+// saveShadowBalances 把本轮重放涉及的用户余额写入影子表
+func (s *StateRebuildService) saveShadowBalances(ctx context.Context, chainID string, replay *balanceReplay) error {
+	balances := make(map[string]string, len(replay.touched))
+	for address := range replay.touched {
+		balances[address] = replay.balances[address].String()
+	}
+	if err := s.rebuildRepo.SaveShadowBalances(ctx, chainID, balances); err != nil {
+		return errors.New(errors.ErrRebuild, "写入影子余额失败", err)
+	}
+	return nil
+}
+
+func (s *StateRebuildService) diff(ctx context.Context, report *StateRebuildReport) error {
+	balances, err := s.rebuildRepo.DiffBalances(ctx, report.ChainID, rebuildDiffSamples)
+	if err != nil {
+		return errors.New(errors.ErrRebuild, "比较余额失败", err)
+	}
+	points, err := s.rebuildRepo.DiffPoints(ctx, report.ChainID, rebuildDiffSamples)
+	if err != nil {
+		return errors.New(errors.ErrRebuild, "比较积分失败", err)
+	}
+	report.Balances = balances
+	report.Points = points
+	return nil
+}
+
+// StateRebuildParams 重建任务参数
+type StateRebuildParams struct {
+	ChainID   string `json:"chain_id"`
+	Apply     bool   `json:"apply"`
+	AllowGaps bool   `json:"allow_gaps"`
+}
+
+// RebuildJob 重建任务处理器，由主节点执行以便暂停转账处理；报告保存为任务结果，校验未通过时任务失败
+func (s *StateRebuildService) RebuildJob(ctx context.Context, job *models.Job, progress *jobs.Progress) error {
+	var params StateRebuildParams
+	if err := job.DecodeParams(&params); err != nil {
+		return fmt.Errorf("解析任务参数失败: %w", err)
+	}
+
+	progress.SetTotal(1)
+	if job.Checkpoint != "" {
+		return nil
+	}
+
+	report, err := s.Rebuild(ctx, params.ChainID, params.Apply, params.AllowGaps)
+	if err != nil {
+		return err
+	}
+	if err := progress.SetResult(report); err != nil {
+		return err
+	}
+	if !report.Verified {
+		progress.Add(0, 1)
+		return fmt.Errorf("重建校验未通过: %s", report.Note)
+	}
+
+	progress.Add(1, 0)
+	return progress.Save(ctx, "rebuilt")
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// TTLCache 进程内的定时过期缓存，条目数达到上限时先清理过期条目，仍然已满则整体清空
+type TTLCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	items      map[string]item
+}
+
+type item struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+func New(ttl time.Duration, maxEntries int) *TTLCache {
+	return &TTLCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		items:      make(map[string]item),
+	}
+}
+
+// Get 获取未过期的缓存值
+func (c *TTLCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	it, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(it.expiresAt) {
+		delete(c.items, key)
+		return nil, false
+	}
+	return it.value, true
+}
+
+// Set 写入缓存值
+func (c *TTLCache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.maxEntries > 0 && len(c.items) >= c.maxEntries {
+		for k, it := range c.items {
+			if now.After(it.expiresAt) {
+				delete(c.items, k)
+			}
+		}
+		if len(c.items) >= c.maxEntries {
+			c.items = make(map[string]item)
+		}
+	}
+	c.items[key] = item{value: value, expiresAt: now.Add(c.ttl)}
+}
+
+// GetOrLoad 缓存未命中时调用load并缓存其结果，load出错时不缓存
+func (c *TTLCache) GetOrLoad(key string, load func() (interface{}, error)) (interface{}, error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+	c.Set(key, value)
+	return value, nil
+}
+
+// Clear 清空缓存
+func (c *TTLCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]item)
+}
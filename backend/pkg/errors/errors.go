@@ -32,4 +32,18 @@ var (
 	ErrBalanceUpdate   = "BALANCE_UPDATE_ERROR"
 	ErrPointsCalc      = "POINTS_CALCULATION_ERROR"
 	ErrInvalidChain    = "INVALID_CHAIN_ERROR"
+	ErrInvalidRequest  = "INVALID_REQUEST_ERROR"
+	ErrNotFound        = "NOT_FOUND_ERROR"
+	ErrConflict        = "CONFLICT_ERROR"
+	ErrRedemption      = "REDEMPTION_ERROR"
+	ErrInsufficient    = "INSUFFICIENT_POINTS_ERROR"
+	ErrJob             = "JOB_ERROR"
+	ErrUnauthorized    = "UNAUTHORIZED_ERROR"
+	ErrBackup          = "BACKUP_ERROR"
+	ErrRebuild         = "REBUILD_ERROR"
+	ErrReindex         = "REINDEX_ERROR"
+	ErrDistribution    = "DISTRIBUTION_ERROR"
+	ErrAttestation     = "ATTESTATION_ERROR"
+	ErrAPIKey          = "API_KEY_ERROR"
+	ErrForbidden       = "FORBIDDEN_ERROR"
 )
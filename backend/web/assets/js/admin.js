@@ -1,9 +1,51 @@
-const API_BASE_URL = '/api';
+const API_BASE_URL = '/api/v1';
+const API_KEY_STORAGE = 'tpsApiKey';
+
+// Admin endpoints require an API key; it is kept in sessionStorage for this tab only
+axios.interceptors.request.use(config => {
+    const apiKey = sessionStorage.getItem(API_KEY_STORAGE);
+    if (apiKey) {
+        config.headers['Authorization'] = `Bearer ${apiKey}`;
+    }
+    return config;
+});
+
+axios.interceptors.response.use(response => response, error => {
+    const status = error.response && error.response.status;
+    if (status === 401) {
+        sessionStorage.removeItem(API_KEY_STORAGE);
+        showNotification('API key missing, invalid or expired', 'warning');
+    } else if (status === 403) {
+        showNotification('API key lacks the required scope', 'warning');
+    }
+    return Promise.reject(error);
+});
+
+function promptApiKey() {
+    const apiKey = prompt('Admin API key (create one with: go run ./cmd create-api-key -name <name>)');
+    if (!apiKey) {
+        return false;
+    }
+    sessionStorage.setItem(API_KEY_STORAGE, apiKey.trim());
+    return true;
+}
 
 document.addEventListener('DOMContentLoaded', function() {
+    document.getElementById('apiKeyButton').addEventListener('click', e => {
+        e.preventDefault();
+        if (promptApiKey()) {
+            loadBackups();
+            loadJobs();
+        }
+    });
+    if (!sessionStorage.getItem(API_KEY_STORAGE)) {
+        promptApiKey();
+    }
     document.getElementById('recalcForm').addEventListener('submit', handleRecalculate);
     loadBackups();
+    loadJobs();
     loadUptime();
+    setInterval(loadJobs, 5000);
 });
 
 async function handleRecalculate(e) {
@@ -32,14 +74,15 @@ async function handleRecalculate(e) {
     btn.innerHTML = '<span class="spinner-border spinner-border-sm me-2"></span>Processing...';
     
     try {
-        const response = await axios.post(`${API_BASE_URL}/recalculate`, {
+        const response = await axios.post(`${API_BASE_URL}/admin/recalculate`, {
             chain: chain,
             startTime: startTime,
             endTime: endTime
         });
         
-        showNotification('Recalculation started successfully!', 'success');
-        addLog('info', `Points recalculation started for ${chain}`);
+        showNotification(`Recalculation job #${response.data.jobId} submitted`, 'success');
+        addLog('info', `Points recalculation job #${response.data.jobId} submitted for ${chain}`);
+        loadJobs();
         
     } catch (error) {
         console.error('Recalculation failed:', error);
@@ -53,10 +96,10 @@ async function handleRecalculate(e) {
 
 async function createBackup(chain) {
     try {
-        const response = await axios.post(`${API_BASE_URL}/backup`, { chain: chain });
-        showNotification(`Backup created for ${chain}`, 'success');
-        addLog('info', `Backup created for ${chain}`);
-        loadBackups();
+        const response = await axios.post(`${API_BASE_URL}/admin/backups`, { chain: chain });
+        showNotification(`Backup job #${response.data.jobId} submitted for ${chain}`, 'success');
+        addLog('info', `Backup job #${response.data.jobId} submitted for ${chain}`);
+        loadJobs();
     } catch (error) {
         console.error('Backup failed:', error);
         showNotification('Failed to create backup', 'danger');
@@ -66,7 +109,7 @@ async function createBackup(chain) {
 
 async function loadBackups() {
     try {
-        const response = await axios.get(`${API_BASE_URL}/backups`);
+        const response = await axios.get(`${API_BASE_URL}/admin/backups`);
         const backups = response.data;
         
         const container = document.getElementById('backupList');
@@ -97,7 +140,7 @@ async function restoreBackup(backupId) {
     }
     
     try {
-        await axios.post(`${API_BASE_URL}/backup/restore`, { backupId: backupId });
+        await axios.post(`${API_BASE_URL}/admin/backups/${backupId}/restore`);
         showNotification('Backup restored successfully', 'success');
         addLog('info', `Backup restored: ${backupId}`);
     } catch (error) {
@@ -107,6 +150,72 @@ async function restoreBackup(backupId) {
     }
 }
 
+const JOB_STATE_BADGES = {
+    pending: 'bg-secondary',
+    running: 'bg-primary',
+    succeeded: 'bg-success',
+    failed: 'bg-danger',
+    cancelled: 'bg-warning text-dark'
+};
+
+async function loadJobs() {
+    try {
+        const response = await axios.get(`${API_BASE_URL}/jobs`, { params: { limit: 20 } });
+        const jobs = response.data;
+        
+        const container = document.getElementById('jobList');
+        
+        if (jobs && jobs.length > 0) {
+            container.innerHTML = jobs.map(job => {
+                const percent = job.total > 0 ? Math.floor(job.processed * 100 / job.total) : 0;
+                const active = job.state === 'pending' || job.state === 'running';
+                return `
+                    <tr>
+                        <td>#${job.id}</td>
+                        <td>${job.type}</td>
+                        <td><small class="text-muted">${job.params}</small></td>
+                        <td>
+                            <span class="badge ${JOB_STATE_BADGES[job.state] || 'bg-secondary'}">${job.state}</span>
+                            ${job.error ? `<div class="small text-danger">${job.error}</div>` : ''}
+                        </td>
+                        <td style="min-width: 160px;">
+                            <div class="progress" style="height: 16px;">
+                                <div class="progress-bar" style="width: ${percent}%">${job.processed}/${job.total}</div>
+                            </div>
+                            ${job.failed > 0 ? `<small class="text-danger">${job.failed} failed</small>` : ''}
+                        </td>
+                        <td><small>${formatTime(job.createdAt)}</small></td>
+                        <td>
+                            ${active && !job.cancelRequested ? `<button class="btn btn-sm btn-outline-danger" onclick="cancelJob(${job.id})">Cancel</button>` : ''}
+                        </td>
+                    </tr>
+                `;
+            }).join('');
+        } else {
+            container.innerHTML = '<tr><td colspan="7" class="text-muted small">No jobs</td></tr>';
+        }
+    } catch (error) {
+        console.error('Failed to load jobs:', error);
+    }
+}
+
+async function cancelJob(jobId) {
+    if (!confirm(`Cancel job #${jobId}?`)) {
+        return;
+    }
+    
+    try {
+        await axios.post(`${API_BASE_URL}/jobs/${jobId}/cancel`);
+        showNotification(`Job #${jobId} cancellation requested`, 'success');
+        addLog('info', `Job #${jobId} cancellation requested`);
+        loadJobs();
+    } catch (error) {
+        console.error('Cancel failed:', error);
+        showNotification('Failed to cancel job', 'danger');
+        addLog('error', `Cancel job #${jobId} failed: ${error.message}`);
+    }
+}
+
 function filterLogs(level) {
     const buttons = document.querySelectorAll('.btn-group .btn');
     buttons.forEach(btn => btn.classList.remove('active'));
@@ -1,4 +1,4 @@
-const API_BASE_URL = '/api';
+const API_BASE_URL = '/api/v1';
 
 let pointsChart = null;
 
@@ -71,7 +71,7 @@ function initPointsChart() {
 
 async function loadChartData() {
     try {
-        const response = await axios.get(`${API_BASE_URL}/points/history`);
+        const response = await axios.get(`${API_BASE_URL}/points/daily`);
         const data = response.data;
         
         pointsChart.data.labels = data.labels || [];
@@ -1,4 +1,4 @@
-const API_BASE_URL = '/api';
+const API_BASE_URL = '/api/v1';
 
 document.addEventListener('DOMContentLoaded', function() {
     document.getElementById('queryForm').addEventListener('submit', handleQuery);
@@ -18,14 +18,16 @@ async function handleQuery(e) {
     showLoading(true);
     
     try {
-        const [balanceRes, pointsRes, historyRes] = await Promise.all([
-            axios.get(`${API_BASE_URL}/balance/${chain}/${address}`),
+        const [balanceRes, pointsRes, historyRes, aggregateRes] = await Promise.all([
+            axios.get(`${API_BASE_URL}/balances/${chain}/${address}`),
             axios.get(`${API_BASE_URL}/points/${chain}/${address}`),
-            axios.get(`${API_BASE_URL}/history/${chain}/${address}`)
+            axios.get(`${API_BASE_URL}/balances/${chain}/${address}/history`),
+            axios.get(`${API_BASE_URL}/points/aggregate/${address}`)
         ]);
         
         displayBalance(balanceRes.data);
         displayPoints(pointsRes.data);
+        displayAggregate(aggregateRes.data);
         displayHistory(historyRes.data);
         
         document.getElementById('resultSection').style.display = 'block';
@@ -49,6 +51,14 @@ function displayPoints(data) {
     document.getElementById('lastCalculated').textContent = formatTime(data.lastCalculatedAt);
 }
 
+function displayAggregate(data) {
+    const el = document.getElementById('combinedPoints');
+    el.textContent = formatNumber(data.combined_points || 0);
+    el.title = (data.chains || [])
+        .map(c => `${c.chain_id}: ${formatNumber(c.points)} × ${c.weight}`)
+        .join('\n');
+}
+
 function displayHistory(data) {
     const tbody = document.getElementById('historyTable');
     tbody.innerHTML = '';
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.19;
+
+import "@openzeppelin/contracts/utils/cryptography/ECDSA.sol";
+import "@openzeppelin/contracts/utils/cryptography/MessageHashUtils.sol";
+
+/// @title 积分证明
+/// @notice 后端签发的EIP-712积分证明的结构定义、哈希与签名者恢复
+/// @dev 签名域为EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)，
+///      name、version取值与后端配置的attestation.domain_name、attestation.domain_version一致，
+///      chainId和verifyingContract为校验合约所在的链和合约地址（attestation.verifying_chain_id、
+///      attestation.verifying_contract），可通过 GET /api/attestations/signer 查询。
+///      消息中的chainId是积分所在链的EVM链ID，与签名域的chainId无关
+library PointsAttestationLib {
+    struct PointsAttestation {
+        address account;
+        // 积分所在链的EVM链ID
+        uint256 chainId;
+        // 积分 × 1e18
+        uint256 points;
+        // 赛季ID，0表示累计总积分
+        uint256 season;
+        // 按账户从1开始递增
+        uint256 nonce;
+        // 过期时间（Unix秒），block.timestamp >= expiry时失效
+        uint256 expiry;
+    }
+
+    bytes32 internal constant DOMAIN_TYPEHASH =
+        keccak256("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)");
+
+    bytes32 internal constant ATTESTATION_TYPEHASH =
+        keccak256(
+            "PointsAttestation(address account,uint256 chainId,uint256 points,uint256 season,uint256 nonce,uint256 expiry)"
+        );
+
+    function domainSeparator(
+        string memory name,
+        string memory version,
+        uint256 chainId,
+        address verifyingContract
+    ) internal pure returns (bytes32) {
+        return
+            keccak256(
+                abi.encode(DOMAIN_TYPEHASH, keccak256(bytes(name)), keccak256(bytes(version)), chainId, verifyingContract)
+            );
+    }
+
+    function hash(PointsAttestation memory attestation) internal pure returns (bytes32) {
+        return
+            keccak256(
+                abi.encode(
+                    ATTESTATION_TYPEHASH,
+                    attestation.account,
+                    attestation.chainId,
+                    attestation.points,
+                    attestation.season,
+                    attestation.nonce,
+                    attestation.expiry
+                )
+            );
+    }
+
+    /// @return 与后端返回的digest相同的EIP-712摘要
+    function digest(bytes32 separator, PointsAttestation memory attestation) internal pure returns (bytes32) {
+        return MessageHashUtils.toTypedDataHash(separator, hash(attestation));
+    }
+
+    function recover(
+        bytes32 separator,
+        PointsAttestation memory attestation,
+        bytes memory signature
+    ) internal pure returns (address) {
+        return ECDSA.recover(digest(separator, attestation), signature);
+    }
+}
+
+/// @title 积分证明校验
+/// @notice 合作方合约可以继承此合约，校验证明并按账户记录已使用的nonce
+/// @dev 每个账户只接受比上次使用的更大的nonce，较早签发的证明在使用更新的证明后失效。
+///      签名域绑定部署时的block.chainid和本合约地址，链分叉导致链ID变化后按新的链ID重新计算
+abstract contract PointsAttestationVerifier {
+    using PointsAttestationLib for PointsAttestationLib.PointsAttestation;
+
+    event AttestationConsumed(address indexed account, uint256 chainId, uint256 season, uint256 points, uint256 nonce);
+
+    address public immutable attestationSigner;
+    uint256 public immutable attestationChainId;
+
+    bytes32 private immutable _cachedDomainSeparator;
+    uint256 private immutable _cachedChainId;
+    bytes32 private immutable _hashedName;
+    bytes32 private immutable _hashedVersion;
+
+    mapping(address => uint256) public lastAttestationNonce;
+
+    constructor(string memory name, string memory version, address signer, uint256 chainId) {
+        require(signer != address(0), "Signer cannot be zero address");
+        attestationSigner = signer;
+        attestationChainId = chainId;
+        _hashedName = keccak256(bytes(name));
+        _hashedVersion = keccak256(bytes(version));
+        _cachedChainId = block.chainid;
+        _cachedDomainSeparator = _buildDomainSeparator();
+    }
+
+    /// @return 当前链上本合约的EIP-712签名域分隔符
+    function attestationDomainSeparator() public view returns (bytes32) {
+        if (block.chainid == _cachedChainId) {
+            return _cachedDomainSeparator;
+        }
+        return _buildDomainSeparator();
+    }
+
+    function _buildDomainSeparator() private view returns (bytes32) {
+        return
+            keccak256(
+                abi.encode(
+                    PointsAttestationLib.DOMAIN_TYPEHASH,
+                    _hashedName,
+                    _hashedVersion,
+                    block.chainid,
+                    address(this)
+                )
+            );
+    }
+
+    function _consumeAttestation(
+        PointsAttestationLib.PointsAttestation memory attestation,
+        bytes memory signature
+    ) internal {
+        require(attestation.chainId == attestationChainId, "Attestation for another chain");
+        require(block.timestamp < attestation.expiry, "Attestation expired");
+        require(attestation.nonce > lastAttestationNonce[attestation.account], "Attestation nonce already used");
+        require(
+            attestation.recover(attestationDomainSeparator(), signature) == attestationSigner,
+            "Invalid attestation signature"
+        );
+
+        lastAttestationNonce[attestation.account] = attestation.nonce;
+        emit AttestationConsumed(
+            attestation.account,
+            attestation.chainId,
+            attestation.season,
+            attestation.points,
+            attestation.nonce
+        );
+    }
+}
@@ -0,0 +1,412 @@
+-- Upgrade a database created from the original schema.sql to schema version 8
+-- Run once, in order, before starting the new version:
+--   mysql -u root -p token_points_system < database/migrations/001_schema_v8.sql
+-- Then backfill the points ledger from point_calculations (see README "升级已有部署")
+
+USE token_points_system;
+
+-- user_points: index used by leaderboards and distributions
+ALTER TABLE user_points
+    ADD INDEX idx_chain_points (chain_id, total_points);
+
+-- Shadow tables written by the state rebuild job
+CREATE TABLE user_balances_shadow LIKE user_balances;
+CREATE TABLE user_points_shadow LIKE user_points;
+
+-- balance_history: log index of the Transfer event, existing rows keep 0.
+-- The original listener recorded at most one Transfer event per transaction,
+-- so existing rows are already unique on (chain_id, tx_hash, 0, user_address).
+ALTER TABLE balance_history
+    ADD COLUMN log_index INT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'Log index of the Transfer event within the block' AFTER tx_hash,
+    ADD INDEX idx_chain_time (chain_id, timestamp),
+    DROP INDEX idx_block_number,
+    ADD INDEX idx_block_number (chain_id, block_number, log_index),
+    ADD UNIQUE KEY uk_chain_event (chain_id, tx_hash, log_index, user_address);
+
+-- processed_blocks: block timestamp, unknown for existing rows
+ALTER TABLE processed_blocks
+    ADD COLUMN block_time TIMESTAMP NULL COMMENT 'Timestamp of the processed block, NULL when unknown after restore or cutover' AFTER block_number;
+
+-- point_calculations: rate and rule version, existing rows are legacy (rate NULL, rule_version '')
+ALTER TABLE point_calculations
+    ADD COLUMN rate DECIMAL(65,18) NULL COMMENT 'Rate applied (points per token-hour), NULL for legacy rows' AFTER calculation_hash,
+    ADD COLUMN rule_version VARCHAR(32) NOT NULL DEFAULT '' COMMENT 'Points rule version used' AFTER rate,
+    ADD INDEX idx_chain_period_start (chain_id, period_start);
+
+-- calculation_backups: the old JSON snapshot format cannot be restored, keep it aside
+RENAME TABLE calculation_backups TO calculation_backups_legacy;
+
+-- Listener rewind requests (written by restore and cutover, polled by the leader's listeners)
+CREATE TABLE listener_rewinds (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    chain_id VARCHAR(50) NOT NULL,
+    block_number BIGINT NOT NULL COMMENT 'Block the listener rewinds to',
+    seq BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'Incremented on every rewind request',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_chain (chain_id)
+) ENGINE=InnoDB COMMENT='Listener rewind requests';
+
+-- Calculation cursor table (last fully calculated period per chain)
+CREATE TABLE calculation_cursors (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    chain_id VARCHAR(50) NOT NULL,
+    last_period_end TIMESTAMP NOT NULL COMMENT 'End of the last period calculated for all users',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_chain (chain_id)
+) ENGINE=InnoDB COMMENT='Calculation cursor table for catch-up';
+
+-- Expiry cursor table (last UTC day expiry and decay were applied)
+CREATE TABLE expiry_cursors (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    chain_id VARCHAR(50) NOT NULL,
+    last_day DATE NOT NULL COMMENT 'Last UTC day expiry and decay were applied',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_chain (chain_id)
+) ENGINE=InnoDB COMMENT='Expiry cursor table for catch-up';
+
+-- Points ledger table (append-only, user_points is its projection)
+CREATE TABLE points_ledger (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    chain_id VARCHAR(50) NOT NULL,
+    user_address VARCHAR(42) NOT NULL,
+    entry_type ENUM('accrual', 'grant', 'correction', 'redemption', 'expiration') NOT NULL COMMENT 'Entry type',
+    amount DECIMAL(65,18) NOT NULL COMMENT 'Signed amount (credit positive, debit negative)',
+    source_type VARCHAR(32) NOT NULL COMMENT 'Source kind (calculation, adjustment, redemption...)',
+    source_ref VARCHAR(128) NOT NULL COMMENT 'Source reference, e.g. calculation hash',
+    memo VARCHAR(255) NULL,
+    effective_at TIMESTAMP NOT NULL COMMENT 'Time the entry takes effect',
+    expires_at TIMESTAMP NULL COMMENT 'Explicit expiry of a credit entry',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_source (entry_type, source_type, source_ref),
+    INDEX idx_chain_user_effective (chain_id, user_address, effective_at)
+) ENGINE=InnoDB COMMENT='Append-only points ledger';
+
+-- Points redemption table (reserve / commit / cancel)
+CREATE TABLE points_redemptions (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    chain_id VARCHAR(50) NOT NULL,
+    user_address VARCHAR(42) NOT NULL,
+    amount DECIMAL(65,18) NOT NULL COMMENT 'Points to redeem',
+    status ENUM('reserved', 'committed', 'cancelled') NOT NULL,
+    idempotency_key VARCHAR(128) NOT NULL COMMENT 'Client supplied idempotency key',
+    reference VARCHAR(128) NULL COMMENT 'External reward / order reference',
+    expires_at TIMESTAMP NULL COMMENT 'Reservation expiry',
+    committed_at TIMESTAMP NULL,
+    cancelled_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_idempotency_key (idempotency_key),
+    INDEX idx_chain_user_status (chain_id, user_address, status)
+) ENGINE=InnoDB COMMENT='Points redemption table';
+
+-- Manual points adjustment table (with approval workflow)
+CREATE TABLE points_adjustments (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    chain_id VARCHAR(50) NOT NULL,
+    user_address VARCHAR(42) NOT NULL,
+    amount DECIMAL(65,18) NOT NULL COMMENT 'Signed amount (credit positive, debit negative)',
+    reason_code VARCHAR(32) NOT NULL COMMENT 'compensation, promotion, exploit_clawback, error_correction, other',
+    note VARCHAR(255) NULL,
+    status ENUM('pending', 'applied', 'rejected') NOT NULL,
+    requires_approval TINYINT(1) NOT NULL DEFAULT 0,
+    requested_by VARCHAR(100) NOT NULL COMMENT 'Actor who requested the adjustment',
+    decided_by VARCHAR(100) NULL COMMENT 'Actor who approved or rejected',
+    decided_at TIMESTAMP NULL,
+    expires_at TIMESTAMP NULL COMMENT 'Optional expiry of granted points',
+    calculation_id BIGINT NULL COMMENT 'Point calculation this adjustment corrects',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_chain_user (chain_id, user_address),
+    INDEX idx_status (status),
+    INDEX idx_calculation (calculation_id)
+) ENGINE=InnoDB COMMENT='Manual points adjustment table';
+
+-- Leader lease table (only the lease holder runs scheduler and listeners)
+CREATE TABLE leader_leases (
+    name VARCHAR(64) PRIMARY KEY,
+    holder VARCHAR(128) NOT NULL COMMENT 'Node ID of the current leader',
+    expires_at DATETIME(3) NOT NULL COMMENT 'Lease expiry, renewed periodically by the holder',
+    updated_at DATETIME(3) NOT NULL
+) ENGINE=InnoDB COMMENT='Leader election lease table';
+
+-- Background jobs table (recalculations, backups)
+CREATE TABLE jobs (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    type VARCHAR(32) NOT NULL COMMENT 'recalculate, backup, restore, rebuild, reindex, cutover',
+    params JSON NOT NULL COMMENT 'Job parameters',
+    state ENUM('pending', 'running', 'succeeded', 'failed', 'cancelled') NOT NULL,
+    total BIGINT NOT NULL DEFAULT 0,
+    processed BIGINT NOT NULL DEFAULT 0,
+    failed BIGINT NOT NULL DEFAULT 0,
+    checkpoint VARCHAR(255) NULL COMMENT 'Handler-defined resume point',
+    cancel_requested TINYINT(1) NOT NULL DEFAULT 0,
+    error TEXT NULL,
+    result JSON NULL COMMENT 'Handler-defined result report',
+    created_by VARCHAR(100) NULL,
+    owner VARCHAR(128) NULL COMMENT 'Node that is running the job',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP NULL,
+    finished_at TIMESTAMP NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_type_state (type, state),
+    INDEX idx_state (state)
+) ENGINE=InnoDB COMMENT='Persistent background jobs table';
+
+-- Accounts table (groups linked wallets)
+CREATE TABLE accounts (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB COMMENT='User accounts with multiple linked wallets';
+
+-- Account wallets table
+CREATE TABLE account_wallets (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    account_id BIGINT NOT NULL,
+    address VARCHAR(42) NOT NULL COMMENT 'Lowercase wallet address',
+    linked_at TIMESTAMP NOT NULL,
+    UNIQUE KEY uk_address (address),
+    INDEX idx_account (account_id)
+) ENGINE=InnoDB COMMENT='Wallets linked to accounts, one account per wallet';
+
+-- Account signing challenges (single use)
+CREATE TABLE account_nonces (
+    nonce VARCHAR(64) PRIMARY KEY,
+    action ENUM('link', 'unlink') NOT NULL,
+    account_address VARCHAR(42) NOT NULL,
+    wallet_address VARCHAR(42) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_expires_at (expires_at)
+) ENGINE=InnoDB COMMENT='Single-use challenges for wallet linking';
+
+-- Account audit log (append-only)
+CREATE TABLE account_audit_logs (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    account_id BIGINT NOT NULL,
+    action ENUM('link', 'unlink') NOT NULL,
+    wallet_address VARCHAR(42) NOT NULL,
+    account_address VARCHAR(42) NOT NULL COMMENT 'Wallet that authorized the change',
+    scheme ENUM('eip191', 'eip712') NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_account (account_id),
+    INDEX idx_wallet (wallet_address)
+) ENGINE=InnoDB COMMENT='Wallet link/unlink audit log';
+
+-- Leaderboard snapshots table
+CREATE TABLE leaderboard_snapshots (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    scope VARCHAR(64) NOT NULL COMMENT 'global, chain:{chain_id} or campaign:{campaign_id}',
+    taken_at TIMESTAMP NOT NULL,
+    entries BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_scope_taken (scope, taken_at)
+) ENGINE=InnoDB COMMENT='Periodic leaderboard snapshots';
+
+-- Leaderboard snapshot entries
+CREATE TABLE leaderboard_entries (
+    snapshot_id BIGINT NOT NULL,
+    seq BIGINT NOT NULL COMMENT 'Position within the snapshot, starting at 1',
+    rank_key VARCHAR(64) NOT NULL COMMENT 'account:{account_id} or lowercase address',
+    user_address VARCHAR(42) NOT NULL,
+    account_id BIGINT NULL,
+    score DECIMAL(65,18) NOT NULL,
+    rank_competition BIGINT NOT NULL COMMENT 'Competition rank (1,2,2,4)',
+    rank_dense BIGINT NOT NULL COMMENT 'Dense rank (1,2,2,3)',
+    PRIMARY KEY (snapshot_id, seq),
+    UNIQUE KEY uk_snapshot_key (snapshot_id, rank_key)
+) ENGINE=InnoDB COMMENT='Ranked entries of leaderboard snapshots';
+
+-- Seasons table
+CREATE TABLE seasons (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(100) NOT NULL,
+    start_at TIMESTAMP NOT NULL COMMENT 'Periods starting in [start_at, end_at) belong to the season',
+    end_at TIMESTAMP NOT NULL,
+    carry_over_rate DECIMAL(10,6) NOT NULL DEFAULT 0 COMMENT 'Share of final points carried into the next season',
+    closed_at TIMESTAMP NULL COMMENT 'When final standings were frozen',
+    entries BIGINT NOT NULL DEFAULT 0,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_start_at (start_at)
+) ENGINE=InnoDB COMMENT='Points seasons table';
+
+-- Season standings table (frozen when a season closes)
+CREATE TABLE season_standings (
+    season_id BIGINT NOT NULL,
+    chain_id VARCHAR(50) NOT NULL,
+    seq BIGINT NOT NULL COMMENT 'Position within the chain, starting at 1',
+    user_address VARCHAR(42) NOT NULL,
+    earned DECIMAL(65,18) NOT NULL COMMENT 'Points from period calculations in the season',
+    carried_in DECIMAL(65,18) NOT NULL COMMENT 'Points carried over from the previous season',
+    total DECIMAL(65,18) NOT NULL,
+    rank_competition BIGINT NOT NULL,
+    rank_dense BIGINT NOT NULL,
+    PRIMARY KEY (season_id, chain_id, seq),
+    UNIQUE KEY uk_season_chain_user (season_id, chain_id, user_address)
+) ENGINE=InnoDB COMMENT='Final season standings';
+
+-- Calculation backup table (for recovery)
+CREATE TABLE calculation_backups (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    chain_id VARCHAR(50) NOT NULL,
+    block_height BIGINT NOT NULL COMMENT 'Balances reflect the chain state after this block',
+    checksum CHAR(64) NOT NULL COMMENT 'SHA-256 of backup_data',
+    trigger_type ENUM('manual', 'scheduled') NOT NULL,
+    balance_count INT NOT NULL,
+    points_count INT NOT NULL,
+    backup_data JSON NOT NULL COMMENT 'Balances, points and calculation state',
+    created_by VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    restored_at TIMESTAMP NULL,
+    restored_by VARCHAR(100),
+    verify_status ENUM('unverified', 'verified', 'corrupted', 'unverifiable') NOT NULL DEFAULT 'unverified',
+    verify_note VARCHAR(255),
+    verified_at TIMESTAMP NULL,
+    INDEX idx_chain_time (chain_id, created_at)
+) ENGINE=InnoDB COMMENT='Chain state backups for recovery';
+
+-- Re-index datasets (versioned shadow copies of a chain's balances and history)
+CREATE TABLE reindex_datasets (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    chain_id VARCHAR(50) NOT NULL,
+    status ENUM('syncing', 'ready', 'failed', 'live', 'retired', 'discarded') NOT NULL,
+    source ENUM('chain', 'snapshot') NOT NULL COMMENT 'chain: synced from StartBlock, snapshot: live data saved at cutover',
+    start_block BIGINT NOT NULL,
+    synced_block BIGINT NOT NULL DEFAULT 0 COMMENT 'Last block synced into the dataset',
+    replaces_id BIGINT NULL COMMENT 'Snapshot dataset holding the live data replaced at cutover',
+    error TEXT NULL,
+    created_by VARCHAR(100) NULL,
+    cutover_by VARCHAR(100) NULL,
+    cutover_at TIMESTAMP NULL,
+    points_stale_from TIMESTAMP NULL COMMENT 'Earliest timestamp where the replaced and new balance history differ',
+    stale_calculations BIGINT NOT NULL DEFAULT 0 COMMENT 'Point calculations ending after points_stale_from at cutover',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_chain_status (chain_id, status)
+) ENGINE=InnoDB COMMENT='Re-index datasets';
+
+-- Re-index dataset balances
+CREATE TABLE reindex_balances (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    dataset_id BIGINT NOT NULL,
+    user_address VARCHAR(42) NOT NULL,
+    balance DECIMAL(65,0) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_dataset_user (dataset_id, user_address)
+) ENGINE=InnoDB COMMENT='Re-index dataset balances';
+
+-- Re-index dataset balance history
+CREATE TABLE reindex_balance_history (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    dataset_id BIGINT NOT NULL,
+    user_address VARCHAR(42) NOT NULL,
+    balance_before DECIMAL(65,0) NOT NULL,
+    balance_after DECIMAL(65,0) NOT NULL,
+    change_amount DECIMAL(65,0) NOT NULL,
+    change_type ENUM('transfer', 'mint', 'burn') NOT NULL,
+    tx_hash VARCHAR(66) NOT NULL,
+    log_index INT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'Log index within the block',
+    block_number BIGINT NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_dataset_block (dataset_id, block_number),
+    INDEX idx_dataset_tx (dataset_id, tx_hash)
+) ENGINE=InnoDB COMMENT='Re-index dataset balance history';
+
+-- Merkle distributions generated from points snapshots
+CREATE TABLE distributions (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(100) NOT NULL,
+    source ENUM('chain', 'season', 'campaign') NOT NULL,
+    source_ref VARCHAR(64) NULL COMMENT 'Season ID or campaign ID',
+    chain_id VARCHAR(50) NULL,
+    budget DECIMAL(65,0) NOT NULL COMMENT 'Token budget in base units',
+    rules JSON NOT NULL,
+    total_points DECIMAL(65,18) NOT NULL,
+    recipients BIGINT NOT NULL,
+    merkle_root CHAR(66) NOT NULL,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_created (created_at)
+) ENGINE=InnoDB COMMENT='Merkle distributions';
+
+-- Per-address allocations with Merkle proofs
+CREATE TABLE distribution_allocations (
+    distribution_id BIGINT NOT NULL,
+    seq BIGINT NOT NULL,
+    user_address VARCHAR(42) NOT NULL,
+    points DECIMAL(65,18) NOT NULL,
+    amount DECIMAL(65,0) NOT NULL,
+    leaf CHAR(66) NOT NULL,
+    proof JSON NOT NULL,
+    PRIMARY KEY (distribution_id, seq),
+    UNIQUE KEY uk_distribution_user (distribution_id, user_address)
+) ENGINE=InnoDB COMMENT='Merkle distribution allocations';
+
+-- Signed EIP-712 points attestations
+CREATE TABLE points_attestations (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    signer VARCHAR(42) NOT NULL,
+    user_address VARCHAR(42) NOT NULL,
+    chain_id VARCHAR(50) NOT NULL,
+    evm_chain_id BIGINT UNSIGNED NOT NULL,
+    season_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '0 for cumulative total points',
+    points DECIMAL(65,18) NOT NULL,
+    points_units DECIMAL(65,0) NOT NULL COMMENT 'Signed points scaled by 1e18',
+    nonce BIGINT UNSIGNED NOT NULL COMMENT 'Increasing per account',
+    expires_at TIMESTAMP NOT NULL,
+    digest CHAR(66) NOT NULL COMMENT 'EIP-712 digest',
+    signature VARCHAR(132) NOT NULL,
+    requested_by VARCHAR(100) NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_user_nonce (user_address, nonce),
+    INDEX idx_chain_created (chain_id, created_at)
+) ENGINE=InnoDB COMMENT='Points attestation log';
+
+-- Last attestation nonce issued per account
+CREATE TABLE attestation_nonces (
+    user_address VARCHAR(42) PRIMARY KEY,
+    last_nonce BIGINT UNSIGNED NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB COMMENT='Attestation nonce counters';
+
+-- API keys for admin endpoints, only the SHA-256 digest of the key is stored
+CREATE TABLE api_keys (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL COMMENT 'Public part of the key, used for lookup',
+    key_hash CHAR(64) NOT NULL COMMENT 'SHA-256 of the full key',
+    scopes VARCHAR(255) NOT NULL COMMENT 'Comma separated: read, admin:recalc, admin:backup, admin:adjust, admin:keys, points:redeem, attestations:issue, accounts:link',
+    expires_at TIMESTAMP NULL,
+    revoked_at TIMESTAMP NULL,
+    last_used_at TIMESTAMP NULL,
+    rotated_from_id BIGINT NULL COMMENT 'Key replaced by this one',
+    created_by VARCHAR(100) NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_prefix (prefix)
+) ENGINE=InnoDB COMMENT='Admin API keys';
+
+-- Requests made with each API key
+CREATE TABLE api_key_usage (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    key_id BIGINT NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    route VARCHAR(255) NOT NULL COMMENT 'Registered route pattern',
+    path VARCHAR(255) NOT NULL,
+    scope VARCHAR(50) NOT NULL,
+    status INT NOT NULL COMMENT '403 when the key lacked the scope',
+    remote_addr VARCHAR(64) NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_key_created (key_id, created_at)
+) ENGINE=InnoDB COMMENT='API key usage log';
+
+-- Schema version checked when importing archives
+INSERT INTO system_config (config_key, config_value, description) VALUES
+('schema_version', '8', 'Database schema version, checked when importing archives')
+ON DUPLICATE KEY UPDATE config_value = VALUES(config_value);